@@ -77,6 +77,15 @@ type Span interface {
 	Context() SpanContext
 }
 
+// SpanWithLinks represents a Span to which span links can be added after it was
+// started, in addition to the ones set at start with StartSpanConfig.SpanLinks.
+type SpanWithLinks interface {
+	Span
+
+	// AddSpanLink adds a link to another span. It has no effect once the span is finished.
+	AddSpanLink(link SpanLink)
+}
+
 // SpanContext represents a span state that can propagate to descendant spans
 // and across process boundaries. It contains all the information needed to
 // spawn a direct descendant of the span that it belongs to. It can be used
@@ -0,0 +1,51 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+// Package sampling describes the sampling decisions of the tracer for spans which
+// were not started by it, such as the spans of the mock tracer, see
+// tracer.NewRulesSampler.
+package sampling // import "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal/sampling"
+
+import "time"
+
+// Input describes the span to sample.
+type Input struct {
+	TraceID  uint64
+	SpanID   uint64
+	Name     string
+	Service  string
+	Resource string
+	// Tags holds the tags of the span, in the same form as with SetTag.
+	Tags map[string]interface{}
+	// Start is the start time of the span.
+	Start time.Time
+	// Duration is the duration of the span, if it is finished.
+	Duration time.Duration
+}
+
+// Result is the outcome of a sampling decision.
+type Result struct {
+	// Priority is the sampling priority of the trace, see RulesSampler.SampleTrace.
+	Priority int
+	// DecisionMaker is the mechanism which made the trace sampling decision,
+	// propagated in the _dd.p.dm tag, e.g. "-3" for sampling rules.
+	DecisionMaker string
+	// SpanKept reports whether a single span sampling rule kept the span, see
+	// RulesSampler.SampleSpan.
+	SpanKept bool
+	// Tags holds the tags set on the span by the samplers, such as _dd.rule_psr.
+	Tags map[string]interface{}
+}
+
+// RulesSampler applies the trace and single span sampling rules of the tracer. It
+// runs the same sampling logic as the tracer, and is safe for concurrent use.
+type RulesSampler interface {
+	// SampleTrace returns the sampling decision of the tracer for a trace whose
+	// local root span is described by in.
+	SampleTrace(in Input) Result
+	// SampleSpan returns whether the single span sampling rules keep the span
+	// described by in. They only apply to spans of dropped traces.
+	SampleSpan(in Input) Result
+}
@@ -0,0 +1,375 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+// Package assert provides assertions on the spans recorded by the mock tracer. Expected
+// traces are described as trees of spans, with their operation names, required and
+// forbidden tags, error status and children:
+//
+//	mt := mocktracer.Start()
+//	defer mt.Stop()
+//	// ... exercise the instrumented code
+//	assert.Trace(t, mt.FinishedSpans(),
+//		assert.Span("http.request").
+//			Tag(ext.HTTPMethod, "GET").
+//			NoError().
+//			Children(
+//				assert.Span("db.query").HasTag(ext.DBStatement),
+//			),
+//	)
+//
+// The package also provides golden-file snapshots of recorded traces, see Snapshot.
+package assert
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+// TestingT is the subset of testing.TB used to report failed assertions.
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+type tHelper interface {
+	Helper()
+}
+
+// SpanMatcher describes the expected properties of a span and of its children. Use
+// Span to create one.
+type SpanMatcher struct {
+	name     string
+	tags     map[string]interface{}
+	present  []string
+	absent   []string
+	isError  *bool
+	children []*SpanMatcher
+	anyChild bool
+}
+
+// Span returns a matcher for spans with the given operation name. An empty name
+// matches any operation name. By default, the matched span must have no children,
+// see Children and AnyChildren.
+func Span(name string) *SpanMatcher {
+	return &SpanMatcher{name: name}
+}
+
+// Tag requires the span to have the tag key set to value. Values are compared as is,
+// then using their string representation, so that e.g. 200 matches "200".
+func (m *SpanMatcher) Tag(key string, value interface{}) *SpanMatcher {
+	if m.tags == nil {
+		m.tags = make(map[string]interface{})
+	}
+	m.tags[key] = value
+	return m
+}
+
+// Tags requires the span to have all of the given tags, as with Tag.
+func (m *SpanMatcher) Tags(tags map[string]interface{}) *SpanMatcher {
+	for k, v := range tags {
+		m.Tag(k, v)
+	}
+	return m
+}
+
+// Service requires the span to have the given service name.
+func (m *SpanMatcher) Service(service string) *SpanMatcher {
+	return m.Tag(ext.ServiceName, service)
+}
+
+// Resource requires the span to have the given resource name.
+func (m *SpanMatcher) Resource(resource string) *SpanMatcher {
+	return m.Tag(ext.ResourceName, resource)
+}
+
+// HasTag requires the span to have the given tags, whatever their values.
+func (m *SpanMatcher) HasTag(keys ...string) *SpanMatcher {
+	m.present = append(m.present, keys...)
+	return m
+}
+
+// NoTag forbids the span to have any of the given tags.
+func (m *SpanMatcher) NoTag(keys ...string) *SpanMatcher {
+	m.absent = append(m.absent, keys...)
+	return m
+}
+
+// Error requires the span to be an error.
+func (m *SpanMatcher) Error() *SpanMatcher {
+	v := true
+	m.isError = &v
+	return m
+}
+
+// NoError requires the span not to be an error.
+func (m *SpanMatcher) NoError() *SpanMatcher {
+	v := false
+	m.isError = &v
+	return m
+}
+
+// Children requires the span to have exactly the given children, in any order.
+func (m *SpanMatcher) Children(children ...*SpanMatcher) *SpanMatcher {
+	m.children = append(m.children, children...)
+	return m
+}
+
+// AnyChildren allows the span to have children which are not matched by the expected
+// children, if any. The expected children must still be found.
+func (m *SpanMatcher) AnyChildren() *SpanMatcher {
+	m.anyChild = true
+	return m
+}
+
+// String returns a short description of the matcher.
+func (m *SpanMatcher) String() string {
+	name := m.name
+	if name == "" {
+		name = "*"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "span %q", name)
+	for _, k := range sortedKeys(m.tags) {
+		fmt.Fprintf(&b, " %s=%v", k, m.tags[k])
+	}
+	return b.String()
+}
+
+// Trace asserts that spans, typically the finished spans of the mock tracer, form
+// exactly the trees described by roots, in any order. The roots are the spans
+// whose parent is not part of spans. It returns whether the assertion succeeded.
+func Trace(t TestingT, spans []mocktracer.Span, roots ...*SpanMatcher) bool {
+	if h, ok := t.(tHelper); ok {
+		h.Helper()
+	}
+	forest := buildForest(spans)
+	var reasons []string
+	if matchNodes(roots, forest, false, &reasons) {
+		return true
+	}
+	t.Errorf("trace does not match the expectations:\n\t%s\nrecorded spans:\n%s",
+		strings.Join(reasons, "\n\t"), renderForest(forest))
+	return false
+}
+
+// Match reports whether s matches m, regardless of its children.
+func (m *SpanMatcher) Match(s mocktracer.Span) bool {
+	return len(m.mismatches(s)) == 0
+}
+
+// node is a span along with its children.
+type node struct {
+	span     mocktracer.Span
+	children []*node
+}
+
+// buildForest arranges spans into trees, ordered by start time.
+func buildForest(spans []mocktracer.Span) []*node {
+	nodes := make(map[uint64]*node, len(spans))
+	for _, s := range spans {
+		nodes[s.SpanID()] = &node{span: s}
+	}
+	var roots []*node
+	for _, s := range spans {
+		n := nodes[s.SpanID()]
+		if p, ok := nodes[s.ParentID()]; ok && s.ParentID() != s.SpanID() {
+			p.children = append(p.children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+	for _, n := range nodes {
+		sortNodes(n.children)
+	}
+	sortNodes(roots)
+	return roots
+}
+
+func sortNodes(nodes []*node) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i].span, nodes[j].span
+		if !a.StartTime().Equal(b.StartTime()) {
+			return a.StartTime().Before(b.StartTime())
+		}
+		return a.OperationName() < b.OperationName()
+	})
+}
+
+// matchNodes reports whether the expected matchers can each be paired with a distinct
+// node, recursively. Unless allowExtra is true, all the nodes must be paired too. When
+// they can't, the reasons are appended to reasons.
+func matchNodes(expected []*SpanMatcher, nodes []*node, allowExtra bool, reasons *[]string) bool {
+	if !allowExtra && len(expected) != len(nodes) {
+		*reasons = append(*reasons, fmt.Sprintf("expected %d span(s), got %d: %s", len(expected), len(nodes), describeNodes(nodes)))
+		return false
+	}
+	if len(expected) > len(nodes) {
+		*reasons = append(*reasons, fmt.Sprintf("expected at least %d span(s), got %d: %s", len(expected), len(nodes), describeNodes(nodes)))
+		return false
+	}
+	used := make([]bool, len(nodes))
+	var assign func(i int) bool
+	assign = func(i int) bool {
+		if i == len(expected) {
+			return true
+		}
+		for j, n := range nodes {
+			if used[j] || !expected[i].matchTree(n, nil) {
+				continue
+			}
+			used[j] = true
+			if assign(i + 1) {
+				return true
+			}
+			used[j] = false
+		}
+		return false
+	}
+	if assign(0) {
+		return true
+	}
+	// explain why the first expectation which can't be paired fails.
+	for _, m := range expected {
+		found := false
+		for _, n := range nodes {
+			if m.matchTree(n, nil) {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		*reasons = append(*reasons, fmt.Sprintf("no match for %s:", m))
+		for _, n := range nodes {
+			var why []string
+			m.matchTree(n, &why)
+			*reasons = append(*reasons, fmt.Sprintf("  %s: %s", describeSpan(n.span), strings.Join(why, "; ")))
+		}
+		return false
+	}
+	*reasons = append(*reasons, "the expected spans can't be paired with distinct recorded spans")
+	return false
+}
+
+// matchTree reports whether n and its children match m. When they don't and reasons
+// is not nil, the reasons are appended to it.
+func (m *SpanMatcher) matchTree(n *node, reasons *[]string) bool {
+	if why := m.mismatches(n.span); len(why) > 0 {
+		if reasons != nil {
+			*reasons = append(*reasons, why...)
+		}
+		return false
+	}
+	var why []string
+	if !matchNodes(m.children, n.children, m.anyChild, &why) {
+		if reasons != nil {
+			*reasons = append(*reasons, fmt.Sprintf("children of %q: %s", n.span.OperationName(), strings.Join(why, "; ")))
+		}
+		return false
+	}
+	return true
+}
+
+// mismatches returns the reasons why s doesn't match m, regardless of its children.
+func (m *SpanMatcher) mismatches(s mocktracer.Span) []string {
+	var why []string
+	if m.name != "" && s.OperationName() != m.name {
+		why = append(why, fmt.Sprintf("operation name is %q, expected %q", s.OperationName(), m.name))
+	}
+	tags := s.Tags()
+	for _, k := range sortedKeys(m.tags) {
+		v, ok := tags[k]
+		if !ok {
+			why = append(why, fmt.Sprintf("missing tag %s", k))
+		} else if !equalValues(v, m.tags[k]) {
+			why = append(why, fmt.Sprintf("tag %s is %v, expected %v", k, v, m.tags[k]))
+		}
+	}
+	for _, k := range m.present {
+		if _, ok := tags[k]; !ok {
+			why = append(why, fmt.Sprintf("missing tag %s", k))
+		}
+	}
+	for _, k := range m.absent {
+		if v, ok := tags[k]; ok {
+			why = append(why, fmt.Sprintf("unexpected tag %s=%v", k, v))
+		}
+	}
+	if m.isError != nil && isError(tags) != *m.isError {
+		if *m.isError {
+			why = append(why, "span is not an error")
+		} else {
+			why = append(why, fmt.Sprintf("span is an error: %v", tags[ext.Error]))
+		}
+	}
+	return why
+}
+
+// isError reports whether the tags of a span mark it as an error.
+func isError(tags map[string]interface{}) bool {
+	switch v := tags[ext.Error].(type) {
+	case nil:
+		return tags[ext.ErrorMsg] != nil
+	case bool:
+		return v
+	default:
+		return true
+	}
+}
+
+// equalValues compares a tag value with an expected value, falling back to the
+// comparison of their string representations.
+func equalValues(got, want interface{}) bool {
+	if reflect.DeepEqual(got, want) {
+		return true
+	}
+	return fmt.Sprint(got) == fmt.Sprint(want)
+}
+
+func describeSpan(s mocktracer.Span) string {
+	return fmt.Sprintf("span %q (id %d)", s.OperationName(), s.SpanID())
+}
+
+func describeNodes(nodes []*node) string {
+	if len(nodes) == 0 {
+		return "none"
+	}
+	names := make([]string, len(nodes))
+	for i, n := range nodes {
+		names[i] = fmt.Sprintf("%q", n.span.OperationName())
+	}
+	return strings.Join(names, ", ")
+}
+
+// renderForest returns an indented representation of the trees, for failure messages.
+func renderForest(roots []*node) string {
+	var b strings.Builder
+	var render func(n *node, depth int)
+	render = func(n *node, depth int) {
+		fmt.Fprintf(&b, "\t%s- %s %v\n", strings.Repeat("  ", depth), n.span.OperationName(), n.span.Tags())
+		for _, c := range n.children {
+			render(c, depth+1)
+		}
+	}
+	for _, r := range roots {
+		render(r, 0)
+	}
+	return b.String()
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,133 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package assert
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	testify "github.com/stretchr/testify/assert"
+)
+
+// recorder records the failures reported by the assertions.
+type recorder struct {
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// recordTrace records an HTTP request span with a database query and a failed cache call.
+func recordTrace() {
+	root := tracer.StartSpan("http.request", tracer.ServiceName("web"), tracer.ResourceName("GET /users"),
+		tracer.Tag(ext.HTTPMethod, "GET"), tracer.Tag(ext.HTTPCode, 200))
+	db := tracer.StartSpan("db.query", tracer.ChildOf(root.Context()), tracer.Tag(ext.DBStatement, "SELECT * FROM users"))
+	db.Finish()
+	cache := tracer.StartSpan("redis.command", tracer.ChildOf(root.Context()))
+	cache.Finish(tracer.WithError(errors.New("connection refused")))
+	root.Finish()
+}
+
+func TestTrace(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	recordTrace()
+	spans := mt.FinishedSpans()
+
+	t.Run("match", func(t *testing.T) {
+		r := &recorder{}
+		ok := Trace(r, spans,
+			Span("http.request").
+				Service("web").
+				Resource("GET /users").
+				Tag(ext.HTTPCode, "200").
+				HasTag(ext.HTTPMethod).
+				NoTag(ext.Error).
+				NoError().
+				Children(
+					// in any order
+					Span("redis.command").Error(),
+					Span("db.query").Tag(ext.DBStatement, "SELECT * FROM users").NoError(),
+				),
+		)
+		testify.True(t, ok)
+		testify.Empty(t, r.errors)
+	})
+
+	t.Run("any-name", func(t *testing.T) {
+		r := &recorder{}
+		testify.True(t, Trace(r, spans, Span("").Children(Span(""), Span(""))), r.errors)
+	})
+
+	t.Run("any-children", func(t *testing.T) {
+		r := &recorder{}
+		testify.True(t, Trace(r, spans, Span("http.request").AnyChildren()), r.errors)
+		testify.True(t, Trace(r, spans, Span("http.request").AnyChildren().Children(Span("db.query"))), r.errors)
+	})
+
+	for name, tt := range map[string]struct {
+		root   *SpanMatcher
+		reason string
+	}{
+		"name":            {Span("grpc.server"), `operation name is "http.request", expected "grpc.server"`},
+		"tag":             {Span("http.request").Tag(ext.HTTPMethod, "POST"), "tag http.method is GET, expected POST"},
+		"missing-tag":     {Span("http.request").HasTag(ext.HTTPRoute), "missing tag http.route"},
+		"forbidden-tag":   {Span("http.request").NoTag(ext.HTTPMethod), "unexpected tag http.method=GET"},
+		"not-error":       {Span("http.request").Error(), "span is not an error"},
+		"no-children":     {Span("http.request"), "expected 0 span(s), got 2"},
+		"missing-child":   {Span("http.request").Children(Span("db.query")), "expected 1 span(s), got 2"},
+		"child-error":     {Span("http.request").Children(Span("db.query"), Span("redis.command").NoError()), "span is an error: connection refused"},
+		"too-many-childs": {Span("http.request").AnyChildren().Children(Span("db.query"), Span("db.query"), Span("db.query")), "expected at least 3 span(s), got 2"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := &recorder{}
+			testify.False(t, Trace(r, spans, tt.root))
+			testify.Len(t, r.errors, 1)
+			testify.Contains(t, r.errors[0], tt.reason)
+			// the recorded spans are shown
+			testify.Contains(t, r.errors[0], "- http.request")
+		})
+	}
+
+	t.Run("roots", func(t *testing.T) {
+		r := &recorder{}
+		testify.False(t, Trace(r, spans))
+		testify.Contains(t, r.errors[0], "expected 0 span(s), got 1")
+	})
+
+	t.Run("distinct", func(t *testing.T) {
+		// each expectation must match a distinct span
+		r := &recorder{}
+		testify.False(t, Trace(r, spans, Span("http.request").Children(Span("db.query"), Span("db.query"))))
+		testify.Contains(t, r.errors[0], "can't be paired with distinct recorded spans")
+	})
+}
+
+func TestTraceMultipleRoots(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	tracer.StartSpan("b").Finish()
+	tracer.StartSpan("a").Finish()
+
+	r := &recorder{}
+	testify.True(t, Trace(r, mt.FinishedSpans(), Span("a"), Span("b")), r.errors)
+}
+
+func TestMatch(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	s := tracer.StartSpan("http.request", tracer.Tag(ext.HTTPCode, 500))
+	s.Finish()
+
+	testify.True(t, Span("http.request").Tag(ext.HTTPCode, 500).Match(mt.FinishedSpans()[0]))
+	testify.False(t, Span("http.request").Tag(ext.HTTPCode, 200).Match(mt.FinishedSpans()[0]))
+}
@@ -0,0 +1,271 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package assert
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+)
+
+// updateFlag is the name of the flag which updates the golden files instead of
+// comparing them. It is shared with the test binary if it already defines it.
+const updateFlag = "update"
+
+func init() {
+	if flag.Lookup(updateFlag) == nil {
+		flag.Bool(updateFlag, false, "update the golden files of trace snapshots")
+	}
+}
+
+// updating reports whether the golden files should be updated.
+func updating() bool {
+	f := flag.Lookup(updateFlag)
+	if f == nil {
+		return false
+	}
+	v, _ := strconv.ParseBool(f.Value.String())
+	return v
+}
+
+// snapshotConfig holds the configuration of Snapshot.
+type snapshotConfig struct {
+	path       string
+	ignoreTags map[string]bool
+}
+
+// SnapshotOption configures Snapshot.
+type SnapshotOption func(*snapshotConfig)
+
+// WithGoldenFile sets the path of the golden file. It defaults to
+// testdata/<test name>.golden.json.
+func WithGoldenFile(path string) SnapshotOption {
+	return func(c *snapshotConfig) {
+		c.path = path
+	}
+}
+
+// IgnoreTags excludes the given tags from the snapshot, e.g. tags holding host names
+// or durations which vary between runs.
+func IgnoreTags(keys ...string) SnapshotOption {
+	return func(c *snapshotConfig) {
+		for _, k := range keys {
+			c.ignoreTags[k] = true
+		}
+	}
+}
+
+// Snapshot compares the trees formed by spans with the golden file of the test. The
+// span and trace IDs are replaced by their order of appearance and the timestamps are
+// left out, so that the snapshot is stable across runs. Run the tests with the -update
+// flag to create or update the golden files.
+func Snapshot(t testing.TB, spans []mocktracer.Span, opts ...SnapshotOption) bool {
+	t.Helper()
+	cfg := snapshotConfig{
+		path:       filepath.Join("testdata", strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())+".golden.json"),
+		ignoreTags: make(map[string]bool),
+	}
+	for _, fn := range opts {
+		fn(&cfg)
+	}
+	got, err := renderSnapshot(spans, &cfg)
+	if err != nil {
+		t.Errorf("rendering snapshot: %v", err)
+		return false
+	}
+	if updating() {
+		if err := os.MkdirAll(filepath.Dir(cfg.path), 0o755); err != nil {
+			t.Errorf("updating snapshot: %v", err)
+			return false
+		}
+		if err := os.WriteFile(cfg.path, got, 0o644); err != nil {
+			t.Errorf("updating snapshot: %v", err)
+			return false
+		}
+		return true
+	}
+	want, err := os.ReadFile(cfg.path)
+	if err != nil {
+		t.Errorf("reading snapshot (run with -%s to create it): %v", updateFlag, err)
+		return false
+	}
+	if bytes.Equal(got, want) {
+		return true
+	}
+	t.Errorf("trace does not match snapshot %s (run with -%s to update it):\n%s",
+		cfg.path, updateFlag, diffLines(string(want), string(got)))
+	return false
+}
+
+// snapshotSpan is the representation of a span in snapshots.
+type snapshotSpan struct {
+	TraceID  string                 `json:"trace_id"`
+	SpanID   string                 `json:"span_id"`
+	Name     string                 `json:"name"`
+	Tags     map[string]interface{} `json:"tags,omitempty"`
+	Children []*snapshotSpan        `json:"children,omitempty"`
+}
+
+// renderSnapshot returns the normalized JSON representation of the trees formed by spans.
+func renderSnapshot(spans []mocktracer.Span, cfg *snapshotConfig) ([]byte, error) {
+	forest := buildForest(spans)
+	ids := newIDNormalizer()
+	// assign the IDs in the order of the trees, so that they don't depend on the
+	// order in which the spans finished.
+	var assign func(n *node)
+	assign = func(n *node) {
+		ids.trace(n.span.TraceID())
+		ids.span(n.span.SpanID())
+		for _, c := range n.children {
+			assign(c)
+		}
+	}
+	for _, r := range forest {
+		assign(r)
+	}
+	var convert func(n *node) *snapshotSpan
+	convert = func(n *node) *snapshotSpan {
+		s := &snapshotSpan{
+			TraceID: ids.trace(n.span.TraceID()),
+			SpanID:  ids.span(n.span.SpanID()),
+			Name:    n.span.OperationName(),
+		}
+		for k, v := range n.span.Tags() {
+			if v == nil || cfg.ignoreTags[k] {
+				continue
+			}
+			if s.Tags == nil {
+				s.Tags = make(map[string]interface{})
+			}
+			s.Tags[k] = ids.value(v)
+		}
+		for _, c := range n.children {
+			s.Children = append(s.Children, convert(c))
+		}
+		return s
+	}
+	out := make([]*snapshotSpan, 0, len(forest))
+	for _, r := range forest {
+		out = append(out, convert(r))
+	}
+	// don't escape the normalized IDs, e.g. <span:1>, to keep the golden files readable.
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// idNormalizer replaces span and trace IDs by their order of appearance.
+type idNormalizer struct {
+	traces map[uint64]string
+	spans  map[uint64]string
+}
+
+func newIDNormalizer() *idNormalizer {
+	return &idNormalizer{
+		traces: make(map[uint64]string),
+		spans:  make(map[uint64]string),
+	}
+}
+
+func (n *idNormalizer) trace(id uint64) string {
+	if v, ok := n.traces[id]; ok {
+		return v
+	}
+	v := fmt.Sprintf("<trace:%d>", len(n.traces)+1)
+	n.traces[id] = v
+	return v
+}
+
+func (n *idNormalizer) span(id uint64) string {
+	if v, ok := n.spans[id]; ok {
+		return v
+	}
+	v := fmt.Sprintf("<span:%d>", len(n.spans)+1)
+	n.spans[id] = v
+	return v
+}
+
+// value returns the tag value v in a form suited to JSON, replacing known span and
+// trace IDs by their normalized form.
+func (n *idNormalizer) value(v interface{}) interface{} {
+	var id uint64
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	case uint64:
+		id = v
+	case string:
+		u, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return v
+		}
+		id = u
+	default:
+		if _, err := json.Marshal(v); err != nil {
+			return fmt.Sprint(v)
+		}
+		return v
+	}
+	if s, ok := n.spans[id]; ok {
+		return s
+	}
+	if s, ok := n.traces[id]; ok {
+		return s
+	}
+	return v
+}
+
+// diffLines returns the lines which differ between want and got, prefixed with
+// "-" and "+" respectively.
+func diffLines(want, got string) string {
+	wl, gl := strings.Split(want, "\n"), strings.Split(got, "\n")
+	// longest common subsequence of the lines.
+	lcs := make([][]int, len(wl)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(gl)+1)
+	}
+	for i := len(wl) - 1; i >= 0; i-- {
+		for j := len(gl) - 1; j >= 0; j-- {
+			if wl[i] == gl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var b strings.Builder
+	i, j := 0, 0
+	for i < len(wl) || j < len(gl) {
+		switch {
+		case i < len(wl) && j < len(gl) && wl[i] == gl[j]:
+			i, j = i+1, j+1
+		case i < len(wl) && (j == len(gl) || lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&b, "- %s\n", wl[i])
+			i++
+		default:
+			fmt.Fprintf(&b, "+ %s\n", gl[j])
+			j++
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,87 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package assert
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	testify "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	recordTrace()
+
+	Snapshot(t, mt.FinishedSpans())
+}
+
+func TestSnapshotNormalization(t *testing.T) {
+	render := func() string {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		root := tracer.StartSpan("root")
+		child := tracer.StartSpan("child", tracer.ChildOf(root.Context()))
+		// tags referring to other spans are normalized too
+		child.SetTag("linked.span_id", strconv.FormatUint(root.Context().SpanID(), 10))
+		child.SetTag("hostname", "random-host")
+		child.Finish()
+		root.Finish()
+		b, err := renderSnapshot(mt.FinishedSpans(), &snapshotConfig{ignoreTags: map[string]bool{"hostname": true}})
+		require.NoError(t, err)
+		return string(b)
+	}
+	first := render()
+	testify.Equal(t, first, render())
+	testify.Contains(t, first, `"span_id": "<span:1>"`)
+	testify.Contains(t, first, `"linked.span_id": "<span:1>"`)
+	testify.NotContains(t, first, "random-host")
+}
+
+func TestSnapshotMismatch(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	tracer.StartSpan("changed").Finish()
+
+	path := filepath.Join(t.TempDir(), "snapshot.golden.json")
+	require.NoError(t, os.WriteFile(path, []byte("[]\n"), 0o644))
+	r := &snapshotRecorder{TB: t}
+	testify.False(t, Snapshot(r, mt.FinishedSpans(), WithGoldenFile(path)))
+	require.Len(t, r.errors, 1)
+	testify.Contains(t, r.errors[0], "-update")
+	testify.Contains(t, r.errors[0], `+     "name": "changed"`)
+
+	r = &snapshotRecorder{TB: t}
+	testify.False(t, Snapshot(r, mt.FinishedSpans(), WithGoldenFile(filepath.Join(t.TempDir(), "missing.json"))))
+	require.Len(t, r.errors, 1)
+	testify.True(t, strings.HasPrefix(r.errors[0], "reading snapshot"))
+}
+
+func TestDiffLines(t *testing.T) {
+	testify.Equal(t, "- b\n+ c\n+ d\n", diffLines("a\nb\ne", "a\nc\nd\ne"))
+	testify.Equal(t, "", diffLines("a\nb", "a\nb"))
+}
+
+// snapshotRecorder records the failures reported by Snapshot.
+type snapshotRecorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *snapshotRecorder) Helper() {}
+
+func (r *snapshotRecorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, strings.TrimSpace(fmt.Sprintf(format, args...)))
+}
@@ -0,0 +1,216 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package mocktracer
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+)
+
+// IsolatedTracer is a mock tracer which only records the spans of the test which
+// started it, see StartIsolated.
+type IsolatedTracer interface {
+	Tracer
+
+	// Context returns a copy of ctx bound to the tracer. The spans started from the
+	// returned context, e.g. using tracer.StartSpanFromContext, and all their
+	// descendants are recorded by the tracer.
+	Context(ctx context.Context) context.Context
+}
+
+// StartIsolated starts a mock tracer recording only the spans of the test t, so that
+// tests running in parallel don't see each other's spans. It is stopped when the test
+// and its subtests complete.
+//
+// While isolated tracers are active, the global tracer routes each new span to:
+//   - the tracer recording its parent, when the parent is a local span;
+//   - the tracer bound to the context the span is started from, see
+//     IsolatedTracer.Context;
+//   - the tracer recording the trace of its parent, when the parent was extracted
+//     from a carrier, e.g. the headers of an HTTP request sent by the test, and the
+//     trace still has open spans;
+//   - the only active isolated tracer, when there is exactly one.
+//
+// Other spans are discarded: when several isolated tracers are active, root spans
+// must be started from the context returned by IsolatedTracer.Context to be recorded.
+// StartIsolated must not be used along with Start.
+func StartIsolated(t testing.TB, opts ...Option) IsolatedTracer {
+	it := &isolatedTracer{mocktracer: newMockTracer(opts...)}
+	it.onFinish = func(s Span) { globalRouter.finished(it, s.TraceID()) }
+	globalRouter.add(it)
+	t.Cleanup(it.Stop)
+	return it
+}
+
+var _ IsolatedTracer = (*isolatedTracer)(nil)
+
+// isolatedTracer is a mock tracer registered with the router.
+type isolatedTracer struct {
+	*mocktracer
+	stopOnce sync.Once
+}
+
+// isolatedTracerKey is the context key holding the isolated tracer bound to a context.
+type isolatedTracerKey struct{}
+
+// Context implements IsolatedTracer.
+func (it *isolatedTracer) Context(ctx context.Context) context.Context {
+	return context.WithValue(ctx, isolatedTracerKey{}, it)
+}
+
+// Reset implements Tracer.
+func (it *isolatedTracer) Reset() {
+	it.mocktracer.Reset()
+	globalRouter.mu.Lock()
+	defer globalRouter.mu.Unlock()
+	globalRouter.forgetLocked(it)
+}
+
+// Stop unregisters the tracer. The global tracer is reset to a no-op when the last
+// isolated tracer stops.
+func (it *isolatedTracer) Stop() {
+	it.stopOnce.Do(func() {
+		globalRouter.remove(it)
+	})
+}
+
+// globalRouter is the global tracer while isolated tracers are active.
+var globalRouter = &router{traces: make(map[uint64]*isolatedTracer)}
+
+var _ ddtrace.Tracer = (*router)(nil)
+
+// router dispatches the spans to the active isolated tracers.
+type router struct {
+	mu      sync.RWMutex // guards below fields
+	tracers []*isolatedTracer
+	traces  map[uint64]*isolatedTracer // trace ID -> tracer recording the open trace
+
+	// codec implements the propagation of span contexts, which is stateless.
+	codec mocktracer
+}
+
+func (r *router) add(it *isolatedTracer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.tracers) == 0 {
+		internal.SetGlobalTracer(r)
+		internal.Testing = true
+	}
+	r.tracers = append(r.tracers, it)
+}
+
+func (r *router) remove(it *isolatedTracer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, t := range r.tracers {
+		if t == it {
+			r.tracers = append(r.tracers[:i], r.tracers[i+1:]...)
+			break
+		}
+	}
+	r.forgetLocked(it)
+	if len(r.tracers) == 0 {
+		internal.SetGlobalTracer(&internal.NoopTracer{})
+		internal.Testing = false
+	}
+}
+
+// forgetLocked forgets the traces recorded by it. r.mu must be held.
+func (r *router) forgetLocked(it *isolatedTracer) {
+	for id, t := range r.traces {
+		if t == it {
+			delete(r.traces, id)
+		}
+	}
+}
+
+// StartSpan implements ddtrace.Tracer.
+func (r *router) StartSpan(operationName string, opts ...ddtrace.StartSpanOption) ddtrace.Span {
+	var cfg ddtrace.StartSpanConfig
+	for _, fn := range opts {
+		fn(&cfg)
+	}
+	it := r.route(&cfg)
+	if it == nil {
+		// not recorded by any test.
+		return newSpan(&mocktracer{openSpans: make(map[uint64]Span)}, operationName, &cfg)
+	}
+	span := it.startSpan(operationName, &cfg)
+	r.mu.Lock()
+	r.traces[span.TraceID()] = it
+	r.mu.Unlock()
+	return span
+}
+
+// route returns the isolated tracer which should record the span started with cfg,
+// or nil if there is none.
+func (r *router) route(cfg *ddtrace.StartSpanConfig) *isolatedTracer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	active := func(it *isolatedTracer) bool {
+		for _, t := range r.tracers {
+			if t == it {
+				return true
+			}
+		}
+		return false
+	}
+	parent, _ := cfg.Parent.(*spanContext)
+	if parent != nil && parent.span != nil {
+		for _, t := range r.tracers {
+			if t.mocktracer == parent.span.tracer {
+				return t
+			}
+		}
+	}
+	if cfg.Context != nil {
+		if it, ok := cfg.Context.Value(isolatedTracerKey{}).(*isolatedTracer); ok && active(it) {
+			return it
+		}
+	}
+	if parent != nil {
+		if it, ok := r.traces[parent.traceID]; ok {
+			return it
+		}
+	}
+	if len(r.tracers) == 1 {
+		return r.tracers[0]
+	}
+	return nil
+}
+
+// finished forgets the trace traceID of it once it has no more open spans, so that
+// the router only keeps track of the open traces.
+func (r *router) finished(it *isolatedTracer, traceID uint64) {
+	for _, s := range it.OpenSpans() {
+		if s.TraceID() == traceID {
+			return
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.traces[traceID] == it {
+		delete(r.traces, traceID)
+	}
+}
+
+// Extract implements ddtrace.Tracer.
+func (r *router) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
+	return r.codec.Extract(carrier)
+}
+
+// Inject implements ddtrace.Tracer.
+func (r *router) Inject(context ddtrace.SpanContext, carrier interface{}) error {
+	return r.codec.Inject(context, carrier)
+}
+
+// Stop implements ddtrace.Tracer. The router is stopped along with the last isolated
+// tracer.
+func (*router) Stop() {}
@@ -0,0 +1,114 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package mocktracer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartIsolated(t *testing.T) {
+	t.Run("single", func(t *testing.T) {
+		mt := StartIsolated(t)
+		// with a single isolated tracer, spans started without context are recorded.
+		tracer.StartSpan("unbound").Finish()
+		assert.Len(t, mt.FinishedSpans(), 1)
+		mt.Reset()
+
+		root, ctx := tracer.StartSpanFromContext(mt.Context(context.Background()), "root")
+		child, _ := tracer.StartSpanFromContext(ctx, "child")
+		child.Finish()
+		root.Finish()
+		assert.Len(t, mt.FinishedSpans(), 2)
+		assert.Empty(t, mt.OpenSpans())
+	})
+
+	t.Run("finished-traces", func(t *testing.T) {
+		mt := StartIsolated(t)
+		root, _ := tracer.StartSpanFromContext(mt.Context(context.Background()), "root")
+		globalRouter.mu.RLock()
+		assert.Len(t, globalRouter.traces, 1)
+		globalRouter.mu.RUnlock()
+
+		// the trace is forgotten once all its spans are finished.
+		root.Finish()
+		globalRouter.mu.RLock()
+		assert.Empty(t, globalRouter.traces)
+		globalRouter.mu.RUnlock()
+
+		// with several isolated tracers, a span extracted from a finished trace
+		// isn't recorded.
+		StartIsolated(t)
+		carrier := tracer.TextMapCarrier{}
+		require.NoError(t, tracer.Inject(root.Context(), carrier))
+		sctx, err := tracer.Extract(carrier)
+		require.NoError(t, err)
+		tracer.StartSpan("server", tracer.ChildOf(sctx)).Finish()
+		assert.Len(t, mt.FinishedSpans(), 1)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		mt := StartIsolated(t)
+		assert.Equal(t, globalRouter, internal.GetGlobalTracer())
+		mt.Stop()
+		mt.Stop()
+		_, ok := internal.GetGlobalTracer().(*internal.NoopTracer)
+		assert.True(t, ok)
+		assert.False(t, internal.Testing)
+	})
+}
+
+func TestStartIsolatedParallel(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("test-%d", i)
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mt := StartIsolated(t)
+			ctx := mt.Context(context.Background())
+
+			root, ctx := tracer.StartSpanFromContext(ctx, name)
+			for j := 0; j < 5; j++ {
+				child, _ := tracer.StartSpanFromContext(ctx, "child")
+				child.Finish()
+			}
+			// a remote child of the trace, e.g. the server side of an HTTP request.
+			carrier := tracer.TextMapCarrier{}
+			require.NoError(t, tracer.Inject(root.Context(), carrier))
+			sctx, err := tracer.Extract(carrier)
+			require.NoError(t, err)
+			tracer.StartSpan("server", tracer.ChildOf(sctx)).Finish()
+			root.Finish()
+
+			spans := mt.FinishedSpans()
+			require.Len(t, spans, 7)
+			for _, s := range spans {
+				assert.Equal(t, root.Context().TraceID(), s.TraceID())
+			}
+			assert.Equal(t, name, spans[6].OperationName())
+		})
+	}
+}
+
+func TestIsolatedUnrouted(t *testing.T) {
+	mt1 := StartIsolated(t)
+	mt2 := StartIsolated(t)
+	// with several isolated tracers, spans without context can't be attributed.
+	tracer.StartSpan("unrouted").Finish()
+	assert.Empty(t, mt1.FinishedSpans())
+	assert.Empty(t, mt2.FinishedSpans())
+
+	s, _ := tracer.StartSpanFromContext(mt2.Context(context.Background()), "routed")
+	s.Finish()
+	assert.Empty(t, mt1.FinishedSpans())
+	assert.Len(t, mt2.FinishedSpans(), 1)
+}
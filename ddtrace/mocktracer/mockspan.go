@@ -13,9 +13,10 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal"
 )
 
-var _ ddtrace.Span = (*mockspan)(nil)
+var _ ddtrace.SpanWithLinks = (*mockspan)(nil)
 var _ Span = (*mockspan)(nil)
 
 // Span is an interface that allows querying a span returned by the mock tracer.
@@ -51,6 +52,38 @@ type Span interface {
 	fmt.Stringer
 }
 
+var _ SpanWithDetails = (*mockspan)(nil)
+
+// SpanWithDetails is implemented by the spans of the mock tracer, in addition to Span.
+// It allows querying the properties of a span which are not tags. Spans returned by
+// the mock tracer can be type-asserted to it:
+//
+//	s := mt.FinishedSpans()[0].(mocktracer.SpanWithDetails)
+type SpanWithDetails interface {
+	Span
+
+	// Links returns the span links of the span.
+	Links() []ddtrace.SpanLink
+
+	// SamplingPriority returns the sampling priority of the span and true, or false
+	// if no sampling decision was made.
+	SamplingPriority() (int, bool)
+
+	// DecisionMaker returns the mechanism which made the sampling decision, as
+	// propagated in the _dd.p.dm tag, e.g. "-4" for a manual decision.
+	DecisionMaker() string
+
+	// Baggage returns a copy of the baggage items of the span.
+	Baggage() map[string]string
+
+	// MetaStruct returns a copy of the structured metadata of the span, such as
+	// the stack traces reported by AppSec.
+	MetaStruct() map[string]interface{}
+
+	// TraceID128 returns the 128-bit trace ID of the span, hex encoded.
+	TraceID128() string
+}
+
 func newSpan(t *mocktracer, operationName string, cfg *ddtrace.StartSpanConfig) *mockspan {
 	if cfg.Tags == nil {
 		cfg.Tags = make(map[string]interface{})
@@ -72,6 +105,11 @@ func newSpan(t *mocktracer, operationName string, cfg *ddtrace.StartSpanConfig)
 		id = nextID()
 	}
 	s.context = &spanContext{spanID: id, traceID: id, span: s}
+	s.links = append(s.links, cfg.SpanLinks...)
+	if t.traceID128 {
+		// as the tracer does: <32-bit unix seconds> <32 bits of zero> <64 bits of ID>
+		s.context.traceIDUpper = uint64(uint32(s.startTime.Unix())) << 32
+	}
 	if ctx, ok := cfg.Parent.(*spanContext); ok {
 		if ctx.span != nil && s.tags[ext.ServiceName] == nil {
 			// if we have a local parent and no service, inherit the parent's
@@ -81,9 +119,12 @@ func newSpan(t *mocktracer, operationName string, cfg *ddtrace.StartSpanConfig)
 			s.SetTag(ext.SamplingPriority, ctx.samplingPriority())
 		}
 		s.parentID = ctx.spanID
+		s.parentSpan = ctx.span
 		s.context.priority = ctx.samplingPriority()
 		s.context.hasPriority = ctx.hasSamplingPriority()
+		s.context.decisionMaker = ctx.samplingDecisionMaker()
 		s.context.traceID = ctx.traceID
+		s.context.traceIDUpper = ctx.traceIDUpper
 		s.context.baggage = make(map[string]string, len(ctx.baggage))
 		ctx.ForeachBaggageItem(func(k, v string) bool {
 			s.context.baggage[k] = v
@@ -108,8 +149,15 @@ type mockspan struct {
 	context   *spanContext
 	tracer    *mocktracer
 	links     []ddtrace.SpanLink
+
+	parentSpan *mockspan // local parent, if any
+
+	metaStruct map[string]interface{} // guarded by the span's lock
 }
 
+// decisionMakerManual is the decision maker of the sampling decisions made by the user.
+const decisionMakerManual = "-4"
+
 // SetTag sets a given tag on the span.
 func (s *mockspan) SetTag(key string, value interface{}) {
 	s.Lock()
@@ -117,20 +165,46 @@ func (s *mockspan) SetTag(key string, value interface{}) {
 	if s.finished {
 		return
 	}
+	if v, ok := value.(internal.MetaStructValue); ok {
+		if s.metaStruct == nil {
+			s.metaStruct = make(map[string]interface{}, 1)
+		}
+		s.metaStruct[key] = v.Value
+		// the tag is kept too, for the tests reading it through Tag.
+	}
 	if s.tags == nil {
 		s.tags = make(map[string]interface{}, 1)
 	}
-	if key == ext.SamplingPriority {
+	switch key {
+	case ext.SamplingPriority:
 		switch p := value.(type) {
 		case int:
-			s.context.setSamplingPriority(p)
+			s.setManualPriority(p)
 		case float64:
-			s.context.setSamplingPriority(int(p))
+			s.setManualPriority(int(p))
+		}
+	case ext.ManualKeep:
+		if value != false {
+			s.setManualPriority(ext.PriorityUserKeep)
+		}
+	case ext.ManualDrop:
+		if value != false {
+			s.setManualPriority(ext.PriorityUserReject)
 		}
 	}
 	s.tags[key] = value
 }
 
+// setManualPriority sets a sampling priority chosen by the user, which only counts
+// as a decision of the user when the trace is kept, as with the tracer.
+func (s *mockspan) setManualPriority(p int) {
+	dm := decisionMakerManual
+	if p <= 0 {
+		dm = ""
+	}
+	s.context.setSamplingDecision(p, dm)
+}
+
 func (s *mockspan) FinishTime() time.Time {
 	s.RLock()
 	defer s.RUnlock()
@@ -162,6 +236,49 @@ func (s *mockspan) SpanID() uint64 { return s.context.spanID }
 
 func (s *mockspan) ParentID() uint64 { return s.parentID }
 
+func (s *mockspan) TraceID128() string { return s.context.TraceID128() }
+
+func (s *mockspan) Links() []ddtrace.SpanLink {
+	s.RLock()
+	defer s.RUnlock()
+	return append([]ddtrace.SpanLink(nil), s.links...)
+}
+
+// AddSpanLink implements ddtrace.SpanWithLinks.
+func (s *mockspan) AddSpanLink(link ddtrace.SpanLink) {
+	s.Lock()
+	defer s.Unlock()
+	if s.finished {
+		return
+	}
+	s.links = append(s.links, link)
+}
+
+func (s *mockspan) SamplingPriority() (int, bool) {
+	return s.context.samplingPriority(), s.context.hasSamplingPriority()
+}
+
+func (s *mockspan) DecisionMaker() string { return s.context.samplingDecisionMaker() }
+
+func (s *mockspan) Baggage() map[string]string {
+	baggage := make(map[string]string)
+	s.context.ForeachBaggageItem(func(k, v string) bool {
+		baggage[k] = v
+		return true
+	})
+	return baggage
+}
+
+func (s *mockspan) MetaStruct() map[string]interface{} {
+	s.RLock()
+	defer s.RUnlock()
+	cp := make(map[string]interface{}, len(s.metaStruct))
+	for k, v := range s.metaStruct {
+		cp[k] = v
+	}
+	return cp
+}
+
 func (s *mockspan) OperationName() string {
 	s.RLock()
 	defer s.RUnlock()
@@ -207,13 +324,22 @@ func (s *mockspan) Finish(opts ...ddtrace.FinishOption) {
 		s.SetTag(ext.ErrorStack, "<debug stack disabled>")
 	}
 	s.Lock()
-	defer s.Unlock()
 	if s.finished {
+		s.Unlock()
 		return
 	}
 	s.finished = true
 	s.finishTime = t
 	s.tracer.addFinishedSpan(s)
+	s.Unlock()
+	if s.tracer.sampler != nil && s.isLocalRoot() {
+		s.tracer.sample(s)
+	}
+}
+
+// isLocalRoot reports whether s is the first span of its trace in this process.
+func (s *mockspan) isLocalRoot() bool {
+	return s.parentID == 0 || s.parentSpan == nil
 }
 
 // String implements fmt.Stringer.
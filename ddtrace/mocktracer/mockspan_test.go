@@ -7,12 +7,14 @@ package mocktracer
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -266,3 +268,63 @@ func TestSetUser(t *testing.T) {
 	})
 
 }
+
+func TestSpanAccessors(t *testing.T) {
+	mt := newMockTracer()
+	link := ddtrace.SpanLink{TraceID: 1, SpanID: 2, Attributes: map[string]string{"a": "b"}}
+	root := mt.StartSpan("http.request", tracer.WithSpanLinks([]ddtrace.SpanLink{link})).(*mockspan)
+	root.SetBaggageItem("user", "42")
+	root.SetTag("_dd.stack", internal.MetaStructValue{Value: map[string]interface{}{"frames": 3}})
+	child := mt.StartSpan("db.query", tracer.ChildOf(root.Context())).(*mockspan)
+	child.Finish()
+	root.Finish()
+
+	assert := assert.New(t)
+	assert.Equal([]ddtrace.SpanLink{link}, root.Links())
+	assert.Empty(child.Links())
+	assert.Equal(map[string]string{"user": "42"}, child.Baggage())
+	assert.Equal(map[string]interface{}{"_dd.stack": map[string]interface{}{"frames": 3}}, root.MetaStruct())
+	assert.Equal(internal.MetaStructValue{Value: map[string]interface{}{"frames": 3}}, root.Tag("_dd.stack"))
+	assert.Len(root.TraceID128(), 32)
+	assert.Equal(root.TraceID128(), child.TraceID128())
+	assert.Equal(fmt.Sprintf("%016x", root.TraceID()), root.TraceID128()[16:])
+
+	_, ok := root.SamplingPriority()
+	assert.False(ok)
+	assert.Empty(root.DecisionMaker())
+}
+
+func TestSpanTraceID128Disabled(t *testing.T) {
+	t.Setenv("DD_TRACE_128_BIT_TRACEID_GENERATION_ENABLED", "false")
+	mt := newMockTracer()
+	// the setting is read when the tracer starts.
+	t.Setenv("DD_TRACE_128_BIT_TRACEID_GENERATION_ENABLED", "true")
+	s := mt.StartSpan("http.request").(*mockspan)
+	assert.Equal(t, fmt.Sprintf("%032x", s.TraceID()), s.TraceID128())
+}
+
+func TestSpanManualSampling(t *testing.T) {
+	for _, tt := range []struct {
+		key      string
+		value    interface{}
+		priority int
+		dm       string
+	}{
+		{ext.ManualKeep, true, ext.PriorityUserKeep, "-4"},
+		{ext.ManualDrop, true, ext.PriorityUserReject, ""},
+		{ext.SamplingPriority, ext.PriorityUserKeep, ext.PriorityUserKeep, "-4"},
+		{ext.SamplingPriority, ext.PriorityAutoReject, ext.PriorityAutoReject, ""},
+	} {
+		t.Run(tt.key, func(t *testing.T) {
+			mt := newMockTracer()
+			root := mt.StartSpan("http.request").(*mockspan)
+			root.SetTag(tt.key, tt.value)
+			child := mt.StartSpan("db.query", tracer.ChildOf(root.Context())).(*mockspan)
+
+			p, ok := child.SamplingPriority()
+			assert.True(t, ok)
+			assert.Equal(t, tt.priority, p)
+			assert.Equal(t, tt.dm, child.DecisionMaker())
+		})
+	}
+}
@@ -6,6 +6,8 @@
 package mocktracer
 
 import (
+	"encoding/binary"
+	"encoding/hex"
 	"sync"
 	"sync/atomic"
 
@@ -19,16 +21,32 @@ type spanContext struct {
 	baggage      map[string]string
 	priority     int
 	hasPriority  bool
-
-	spanID  uint64
-	traceID uint64
-	span    *mockspan // context owner
+	// decisionMaker is the mechanism which made the sampling decision, as in
+	// the _dd.p.dm propagating tag.
+	decisionMaker string
+
+	spanID       uint64
+	traceID      uint64
+	traceIDUpper uint64    // upper 64 bits of the 128-bit trace ID
+	span         *mockspan // context owner
 }
 
 func (sc *spanContext) TraceID() uint64 { return sc.traceID }
 
 func (sc *spanContext) SpanID() uint64 { return sc.spanID }
 
+func (sc *spanContext) TraceID128() string {
+	b := sc.TraceID128Bytes()
+	return hex.EncodeToString(b[:])
+}
+
+func (sc *spanContext) TraceID128Bytes() [16]byte {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], sc.traceIDUpper)
+	binary.BigEndian.PutUint64(b[8:], sc.traceID)
+	return b
+}
+
 func (sc *spanContext) ForeachBaggageItem(handler func(k, v string) bool) {
 	sc.RLock()
 	defer sc.RUnlock()
@@ -61,6 +79,22 @@ func (sc *spanContext) setSamplingPriority(p int) {
 	sc.hasPriority = true
 }
 
+// setSamplingDecision sets the sampling priority along with the mechanism which made
+// the decision.
+func (sc *spanContext) setSamplingDecision(p int, dm string) {
+	sc.Lock()
+	defer sc.Unlock()
+	sc.priority = p
+	sc.hasPriority = true
+	sc.decisionMaker = dm
+}
+
+func (sc *spanContext) samplingDecisionMaker() string {
+	sc.RLock()
+	defer sc.RUnlock()
+	return sc.decisionMaker
+}
+
 func (sc *spanContext) hasSamplingPriority() bool {
 	sc.RLock()
 	defer sc.RUnlock()
@@ -9,10 +9,12 @@
 // in your application.
 //
 // Simply call "Start" at the beginning of your tests to start and obtain an instance
-// of the mock tracer.
+// of the mock tracer. Tests running in parallel should call StartIsolated instead, so
+// that each of them only sees its own spans.
 package mocktracer
 
 import (
+	"math"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -21,7 +23,9 @@ import (
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal/sampling"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	globalinternal "gopkg.in/DataDog/dd-trace-go.v1/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/datastreams"
 
 	"github.com/DataDog/datadog-go/v5/statsd"
@@ -53,8 +57,8 @@ type Tracer interface {
 // which allows querying it. Call Start at the beginning of your tests
 // to activate the mock tracer. When your test runs, use the returned
 // interface to query the tracer's state.
-func Start() Tracer {
-	t := newMockTracer()
+func Start(opts ...Option) Tracer {
+	t := newMockTracer(opts...)
 	internal.SetGlobalTracer(t)
 	internal.Testing = true
 	return t
@@ -66,6 +70,17 @@ type mocktracer struct {
 	openSpans     map[uint64]Span
 	dsmTransport  *mockDSMTransport
 	dsmProcessor  *datastreams.Processor
+
+	// sampler, if not nil, makes the sampling decisions of the traces as the
+	// tracer does, see WithSamplingRules.
+	sampler sampling.RulesSampler
+
+	// traceID128 reports whether the spans get 128-bit trace IDs, as configured by
+	// DD_TRACE_128_BIT_TRACEID_GENERATION_ENABLED when the tracer starts.
+	traceID128 bool
+
+	// onFinish, if not nil, is called after each span of the tracer finishes.
+	onFinish func(Span)
 }
 
 func (t *mocktracer) SentDSMBacklogs() []datastreams.Backlog {
@@ -73,8 +88,20 @@ func (t *mocktracer) SentDSMBacklogs() []datastreams.Backlog {
 	return t.dsmTransport.backlogs
 }
 
-func newMockTracer() *mocktracer {
+func newMockTracer(opts ...Option) *mocktracer {
 	var t mocktracer
+	var cfg config
+	for _, fn := range opts {
+		fn(&cfg)
+	}
+	if cfg.samplingRules != nil || cfg.sampleRate != nil {
+		rate := math.NaN()
+		if cfg.sampleRate != nil {
+			rate = *cfg.sampleRate
+		}
+		t.sampler = tracer.NewRulesSampler(cfg.samplingRules, rate)
+	}
+	t.traceID128 = globalinternal.BoolEnv("DD_TRACE_128_BIT_TRACEID_GENERATION_ENABLED", true)
 	t.openSpans = make(map[uint64]Span)
 	t.dsmTransport = &mockDSMTransport{}
 	client := &http.Client{
@@ -97,7 +124,12 @@ func (t *mocktracer) StartSpan(operationName string, opts ...ddtrace.StartSpanOp
 	for _, fn := range opts {
 		fn(&cfg)
 	}
-	span := newSpan(t, operationName, &cfg)
+	return t.startSpan(operationName, &cfg)
+}
+
+// startSpan starts a span recorded by t with the given configuration.
+func (t *mocktracer) startSpan(operationName string, cfg *ddtrace.StartSpanConfig) *mockspan {
+	span := newSpan(t, operationName, cfg)
 
 	t.Lock()
 	t.openSpans[span.SpanID()] = span
@@ -137,12 +169,15 @@ func (t *mocktracer) Reset() {
 
 func (t *mocktracer) addFinishedSpan(s Span) {
 	t.Lock()
-	defer t.Unlock()
 	delete(t.openSpans, s.SpanID())
 	if t.finishedSpans == nil {
 		t.finishedSpans = make([]Span, 0, 1)
 	}
 	t.finishedSpans = append(t.finishedSpans, s)
+	t.Unlock()
+	if t.onFinish != nil {
+		t.onFinish(s)
+	}
 }
 
 const (
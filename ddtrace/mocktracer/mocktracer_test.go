@@ -15,6 +15,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStart(t *testing.T) {
@@ -291,3 +292,56 @@ func TestTracerExtract(t *testing.T) {
 		assert.Equal("B", got.baggageItem("a"))
 	})
 }
+
+func TestTracerSamplingRules(t *testing.T) {
+	rules := []tracer.SamplingRule{
+		tracer.NameServiceRule("http.request", "drop", 0),
+		tracer.SpanNameServiceRule("db.query", "drop", 1),
+		tracer.ServiceRule("keep", 1),
+	}
+
+	t.Run("keep", func(t *testing.T) {
+		mt := newMockTracer(WithSamplingRules(rules))
+		mt.StartSpan("http.request", tracer.ServiceName("keep")).Finish()
+		s := mt.FinishedSpans()[0].(SpanWithDetails)
+		p, ok := s.SamplingPriority()
+		assert.True(t, ok)
+		assert.Equal(t, ext.PriorityUserKeep, p)
+		assert.Equal(t, "-3", s.DecisionMaker())
+		assert.Equal(t, 1.0, s.Tag("_dd.rule_psr"))
+		assert.Equal(t, ext.PriorityUserKeep, s.Tag(ext.SamplingPriority))
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		mt := newMockTracer(WithSamplingRules(rules))
+		root := mt.StartSpan("http.request", tracer.ServiceName("drop"))
+		mt.StartSpan("db.query", tracer.ChildOf(root.Context())).Finish()
+		mt.StartSpan("cache", tracer.ChildOf(root.Context())).Finish()
+		root.Finish()
+		spans := mt.FinishedSpans()
+		require.Len(t, spans, 3)
+		p, _ := spans[2].(SpanWithDetails).SamplingPriority()
+		assert.Equal(t, ext.PriorityUserReject, p)
+		// single span sampling rules only keep the matching spans of the dropped trace.
+		assert.Equal(t, 8.0, spans[0].Tag("_dd.span_sampling.mechanism"))
+		assert.Nil(t, spans[1].Tag("_dd.span_sampling.mechanism"))
+		assert.Nil(t, spans[2].Tag("_dd.span_sampling.mechanism"))
+	})
+
+	t.Run("manual", func(t *testing.T) {
+		mt := newMockTracer(WithSamplingRules(rules))
+		mt.StartSpan("http.request", tracer.ServiceName("drop"), tracer.Tag(ext.ManualKeep, true)).Finish()
+		s := mt.FinishedSpans()[0].(SpanWithDetails)
+		p, _ := s.SamplingPriority()
+		assert.Equal(t, ext.PriorityUserKeep, p)
+		assert.Equal(t, "-4", s.DecisionMaker())
+		assert.Nil(t, s.Tag("_dd.rule_psr"))
+	})
+
+	t.Run("sample-rate", func(t *testing.T) {
+		mt := newMockTracer(WithSampleRate(0))
+		mt.StartSpan("http.request").Finish()
+		p, _ := mt.FinishedSpans()[0].(SpanWithDetails).SamplingPriority()
+		assert.Equal(t, ext.PriorityUserReject, p)
+	})
+}
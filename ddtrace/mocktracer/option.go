@@ -0,0 +1,39 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package mocktracer
+
+import (
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// config holds the configuration of a mock tracer.
+type config struct {
+	samplingRules []tracer.SamplingRule
+	sampleRate    *float64
+}
+
+// Option configures the mock tracer, see Start and StartIsolated.
+type Option func(*config)
+
+// WithSamplingRules makes the mock tracer run the sampling rules of the tracer, as
+// configured by tracer.WithSamplingRules. When the local root span of a trace finishes
+// without a manual or upstream sampling decision, the trace sampling rules make the
+// decision, which is reported by Span.SamplingPriority and Span.DecisionMaker along
+// with the tags set by the sampler, e.g. _dd.rule_psr. When the trace is dropped,
+// the single span sampling rules are then applied to its finished spans.
+func WithSamplingRules(rules []tracer.SamplingRule) Option {
+	return func(c *config) {
+		c.samplingRules = append(c.samplingRules, rules...)
+	}
+}
+
+// WithSampleRate makes the mock tracer apply the global sample rate, as configured by
+// DD_TRACE_SAMPLE_RATE, along with the sampling rules. See WithSamplingRules.
+func WithSampleRate(rate float64) Option {
+	return func(c *config) {
+		c.sampleRate = &rate
+	}
+}
@@ -0,0 +1,72 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package mocktracer
+
+import (
+	"fmt"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal/sampling"
+)
+
+// sample makes the sampling decision of the trace whose local root just finished,
+// using the sampling rules of the tracer. See WithSamplingRules.
+func (t *mocktracer) sample(root *mockspan) {
+	if root.context.hasSamplingPriority() {
+		// the decision was made by the user or upstream.
+		return
+	}
+	res := t.sampler.SampleTrace(root.samplingInput())
+	root.context.setSamplingDecision(res.Priority, res.DecisionMaker)
+	root.setSamplerTags(res.Tags)
+	root.setSamplerTags(map[string]interface{}{ext.SamplingPriority: res.Priority})
+	if res.Priority > 0 {
+		return
+	}
+	for _, s := range t.FinishedSpans() {
+		ms, ok := s.(*mockspan)
+		if !ok || ms.TraceID() != root.TraceID() {
+			continue
+		}
+		if res := t.sampler.SampleSpan(ms.samplingInput()); res.SpanKept {
+			ms.setSamplerTags(res.Tags)
+		}
+	}
+}
+
+// samplingInput returns the description of s needed by the samplers.
+func (s *mockspan) samplingInput() sampling.Input {
+	tags := s.Tags()
+	return sampling.Input{
+		TraceID:  s.TraceID(),
+		SpanID:   s.SpanID(),
+		Name:     s.OperationName(),
+		Service:  stringTag(tags[ext.ServiceName]),
+		Resource: stringTag(tags[ext.ResourceName]),
+		Tags:     tags,
+		Start:    s.StartTime(),
+		Duration: s.FinishTime().Sub(s.StartTime()),
+	}
+}
+
+// setSamplerTags sets the tags set by the samplers, even though s is finished.
+func (s *mockspan) setSamplerTags(tags map[string]interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	if s.tags == nil {
+		s.tags = make(map[string]interface{}, len(tags))
+	}
+	for k, v := range tags {
+		s.tags[k] = v
+	}
+}
+
+func stringTag(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
@@ -16,6 +16,7 @@ import (
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
 func Example() {
@@ -50,3 +51,24 @@ func Example() {
 		log.Fatal(err)
 	}
 }
+
+func ExampleNewMeterProvider() {
+	// Start the tracer, whose DogStatsD client sends the measurements.
+	ddtracer.Start()
+	defer ddtracer.Stop()
+
+	// Create a MeterProvider sending the measurements to DogStatsD, and defer the
+	// Shutdown method, which flushes them before the tracer stops.
+	provider := ddotel.NewMeterProvider()
+	defer provider.Shutdown(context.Background())
+
+	// Use it with the OpenTelemetry API to set the global MeterProvider.
+	otel.SetMeterProvider(provider)
+
+	// Record measurements with the OpenTelemetry API; histograms are sent as distributions.
+	requests, err := otel.Meter("").Int64Counter("http.requests")
+	if err != nil {
+		log.Fatal(err)
+	}
+	requests.Add(context.Background(), 1, metric.WithAttributes(attribute.String("path", "/")))
+}
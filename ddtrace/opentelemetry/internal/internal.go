@@ -0,0 +1,60 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+// Package internal holds the parts of the opentelemetry package which are shared with
+// the otelsdk module.
+package internal // import "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/opentelemetry/internal"
+
+import (
+	"os"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
+
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Tracer returns an OpenTelemetry tracer starting its spans with the global Datadog
+// tracer, like the tracer of an opentelemetry.TracerProvider, without starting the
+// Datadog tracer. It is set by the opentelemetry package.
+var Tracer func() oteltrace.Tracer
+
+// resourceTagsMapping maps the OpenTelemetry resource attributes to Datadog tags.
+var resourceTagsMapping = map[string]string{
+	"service.name":           "service",
+	"deployment.environment": "env",
+	"service.version":        "version",
+}
+
+// ResourceValues returns the env, service and version of the application, from the
+// given resource attributes, the DD_* environment variables, then the OTEL_* ones.
+func ResourceValues(resource []attribute.KeyValue) map[string]string {
+	values := make(map[string]string)
+	internal.ForEachStringTag(os.Getenv("OTEL_RESOURCE_ATTRIBUTES"), internal.OtelTagsDelimeter, func(key, val string) {
+		if tag, ok := resourceTagsMapping[key]; ok {
+			values[tag] = val
+		}
+	})
+	if v := os.Getenv("OTEL_SERVICE_NAME"); v != "" {
+		values["service"] = v
+	}
+	for tag, env := range map[string]string{"service": "DD_SERVICE", "env": "DD_ENV", "version": "DD_VERSION"} {
+		if v := os.Getenv(env); v != "" {
+			values[tag] = v
+		}
+	}
+	if _, ok := values["service"]; !ok {
+		if v := globalconfig.ServiceName(); v != "" {
+			values["service"] = v
+		}
+	}
+	for _, kv := range resource {
+		if tag, ok := resourceTagsMapping[string(kv.Key)]; ok {
+			values[tag] = kv.Value.Emit()
+		}
+	}
+	return values
+}
@@ -0,0 +1,320 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package opentelemetry
+
+import (
+	"context"
+	"errors"
+
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+var _ otelmetric.Meter = (*meter)(nil)
+
+// meter creates the instruments of a MeterProvider.
+type meter struct {
+	noop.Meter // https://pkg.go.dev/go.opentelemetry.io/otel/metric#hdr-API_Implementations
+	provider   *MeterProvider
+}
+
+func (m *meter) Int64Counter(name string, _ ...otelmetric.Int64CounterOption) (otelmetric.Int64Counter, error) {
+	return &int64Counter{name: name, provider: m.provider}, nil
+}
+
+func (m *meter) Int64UpDownCounter(name string, _ ...otelmetric.Int64UpDownCounterOption) (otelmetric.Int64UpDownCounter, error) {
+	return &int64UpDownCounter{name: name, provider: m.provider}, nil
+}
+
+func (m *meter) Int64Histogram(name string, _ ...otelmetric.Int64HistogramOption) (otelmetric.Int64Histogram, error) {
+	return &int64Histogram{name: name, provider: m.provider}, nil
+}
+
+func (m *meter) Float64Counter(name string, _ ...otelmetric.Float64CounterOption) (otelmetric.Float64Counter, error) {
+	return &float64Counter{name: name, provider: m.provider}, nil
+}
+
+func (m *meter) Float64UpDownCounter(name string, _ ...otelmetric.Float64UpDownCounterOption) (otelmetric.Float64UpDownCounter, error) {
+	return &float64UpDownCounter{name: name, provider: m.provider}, nil
+}
+
+func (m *meter) Float64Histogram(name string, _ ...otelmetric.Float64HistogramOption) (otelmetric.Float64Histogram, error) {
+	return &float64Histogram{name: name, provider: m.provider}, nil
+}
+
+func (m *meter) Int64ObservableCounter(name string, options ...otelmetric.Int64ObservableCounterOption) (otelmetric.Int64ObservableCounter, error) {
+	o := &int64ObservableCounter{observable: &observable{name: name, kind: observableCounter}}
+	m.registerInt64Callbacks(o.observable, otelmetric.NewInt64ObservableCounterConfig(options...).Callbacks())
+	return o, nil
+}
+
+func (m *meter) Int64ObservableUpDownCounter(name string, options ...otelmetric.Int64ObservableUpDownCounterOption) (otelmetric.Int64ObservableUpDownCounter, error) {
+	o := &int64ObservableUpDownCounter{observable: &observable{name: name, kind: observableUpDownCounter}}
+	m.registerInt64Callbacks(o.observable, otelmetric.NewInt64ObservableUpDownCounterConfig(options...).Callbacks())
+	return o, nil
+}
+
+func (m *meter) Int64ObservableGauge(name string, options ...otelmetric.Int64ObservableGaugeOption) (otelmetric.Int64ObservableGauge, error) {
+	o := &int64ObservableGauge{observable: &observable{name: name, kind: observableGauge}}
+	m.registerInt64Callbacks(o.observable, otelmetric.NewInt64ObservableGaugeConfig(options...).Callbacks())
+	return o, nil
+}
+
+func (m *meter) Float64ObservableCounter(name string, options ...otelmetric.Float64ObservableCounterOption) (otelmetric.Float64ObservableCounter, error) {
+	o := &float64ObservableCounter{observable: &observable{name: name, kind: observableCounter}}
+	m.registerFloat64Callbacks(o.observable, otelmetric.NewFloat64ObservableCounterConfig(options...).Callbacks())
+	return o, nil
+}
+
+func (m *meter) Float64ObservableUpDownCounter(name string, options ...otelmetric.Float64ObservableUpDownCounterOption) (otelmetric.Float64ObservableUpDownCounter, error) {
+	o := &float64ObservableUpDownCounter{observable: &observable{name: name, kind: observableUpDownCounter}}
+	m.registerFloat64Callbacks(o.observable, otelmetric.NewFloat64ObservableUpDownCounterConfig(options...).Callbacks())
+	return o, nil
+}
+
+func (m *meter) Float64ObservableGauge(name string, options ...otelmetric.Float64ObservableGaugeOption) (otelmetric.Float64ObservableGauge, error) {
+	o := &float64ObservableGauge{observable: &observable{name: name, kind: observableGauge}}
+	m.registerFloat64Callbacks(o.observable, otelmetric.NewFloat64ObservableGaugeConfig(options...).Callbacks())
+	return o, nil
+}
+
+// RegisterCallback registers f to be called each time the observable instruments are
+// collected. The observations f makes for other instruments than the given ones are
+// dropped.
+func (m *meter) RegisterCallback(f otelmetric.Callback, instruments ...otelmetric.Observable) (otelmetric.Registration, error) {
+	r := &registration{
+		provider:    m.provider,
+		callback:    f,
+		instruments: make(map[*observable]bool, len(instruments)),
+	}
+	for _, inst := range instruments {
+		o, ok := inst.(observableInstrument)
+		if !ok {
+			return nil, errors.New("opentelemetry: instrument not created by this meter provider")
+		}
+		r.instruments[o.instrument()] = true
+	}
+	m.provider.register(r)
+	return r, nil
+}
+
+// registerInt64Callbacks registers the callbacks of the instrument o.
+func (m *meter) registerInt64Callbacks(o *observable, callbacks []otelmetric.Int64Callback) {
+	for _, cb := range callbacks {
+		cb := cb
+		m.provider.register(&registration{
+			provider: m.provider,
+			callback: func(ctx context.Context, obs otelmetric.Observer) error {
+				return cb(ctx, &int64Observer{observable: o, observer: obs.(*observer)})
+			},
+			instruments: map[*observable]bool{o: true},
+		})
+	}
+}
+
+// registerFloat64Callbacks registers the callbacks of the instrument o.
+func (m *meter) registerFloat64Callbacks(o *observable, callbacks []otelmetric.Float64Callback) {
+	for _, cb := range callbacks {
+		cb := cb
+		m.provider.register(&registration{
+			provider: m.provider,
+			callback: func(ctx context.Context, obs otelmetric.Observer) error {
+				return cb(ctx, &float64Observer{observable: o, observer: obs.(*observer)})
+			},
+			instruments: map[*observable]bool{o: true},
+		})
+	}
+}
+
+// register adds the callback registration r.
+func (p *MeterProvider) register(r *registration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.callbacks[r] = struct{}{}
+}
+
+type int64Counter struct {
+	noop.Int64Counter
+	name     string
+	provider *MeterProvider
+}
+
+func (c *int64Counter) Add(_ context.Context, incr int64, options ...otelmetric.AddOption) {
+	c.provider.count(c.name, incr, otelmetric.NewAddConfig(options).Attributes())
+}
+
+type int64UpDownCounter struct {
+	noop.Int64UpDownCounter
+	name     string
+	provider *MeterProvider
+}
+
+func (c *int64UpDownCounter) Add(_ context.Context, incr int64, options ...otelmetric.AddOption) {
+	c.provider.upDownCount(c.name, float64(incr), otelmetric.NewAddConfig(options).Attributes())
+}
+
+type int64Histogram struct {
+	noop.Int64Histogram
+	name     string
+	provider *MeterProvider
+}
+
+func (h *int64Histogram) Record(_ context.Context, v int64, options ...otelmetric.RecordOption) {
+	h.provider.distribution(h.name, float64(v), otelmetric.NewRecordConfig(options).Attributes())
+}
+
+type float64Counter struct {
+	noop.Float64Counter
+	name     string
+	provider *MeterProvider
+}
+
+func (c *float64Counter) Add(_ context.Context, incr float64, options ...otelmetric.AddOption) {
+	c.provider.countFloat(c.name, incr, otelmetric.NewAddConfig(options).Attributes())
+}
+
+type float64UpDownCounter struct {
+	noop.Float64UpDownCounter
+	name     string
+	provider *MeterProvider
+}
+
+func (c *float64UpDownCounter) Add(_ context.Context, incr float64, options ...otelmetric.AddOption) {
+	c.provider.upDownCount(c.name, incr, otelmetric.NewAddConfig(options).Attributes())
+}
+
+type float64Histogram struct {
+	noop.Float64Histogram
+	name     string
+	provider *MeterProvider
+}
+
+func (h *float64Histogram) Record(_ context.Context, v float64, options ...otelmetric.RecordOption) {
+	h.provider.distribution(h.name, v, otelmetric.NewRecordConfig(options).Attributes())
+}
+
+// observableKind is the kind of an observable instrument.
+type observableKind int
+
+const (
+	observableCounter observableKind = iota
+	observableUpDownCounter
+	observableGauge
+)
+
+// observable is an observable instrument.
+type observable struct {
+	name string
+	kind observableKind
+}
+
+// observableInstrument is implemented by the observable instruments of the meter.
+type observableInstrument interface {
+	instrument() *observable
+}
+
+func (o *observable) instrument() *observable { return o }
+
+// record sends the observation v.
+func (o *observable) record(p *MeterProvider, v float64, options []otelmetric.ObserveOption) {
+	attrs := otelmetric.NewObserveConfig(options).Attributes()
+	switch o.kind {
+	case observableCounter:
+		p.observeCounter(o.name, v, attrs)
+	default:
+		// up-down counters are observed as cumulative values.
+		p.gauge(o.name, v, attrs)
+	}
+}
+
+type int64ObservableCounter struct {
+	noop.Int64ObservableCounter
+	*observable
+}
+
+type int64ObservableUpDownCounter struct {
+	noop.Int64ObservableUpDownCounter
+	*observable
+}
+
+type int64ObservableGauge struct {
+	noop.Int64ObservableGauge
+	*observable
+}
+
+type float64ObservableCounter struct {
+	noop.Float64ObservableCounter
+	*observable
+}
+
+type float64ObservableUpDownCounter struct {
+	noop.Float64ObservableUpDownCounter
+	*observable
+}
+
+type float64ObservableGauge struct {
+	noop.Float64ObservableGauge
+	*observable
+}
+
+// registration is a callback registered with the meter.
+type registration struct {
+	noop.Registration
+	provider    *MeterProvider
+	callback    otelmetric.Callback
+	instruments map[*observable]bool // instruments the callback can observe
+}
+
+func (r *registration) Unregister() error {
+	r.provider.mu.Lock()
+	defer r.provider.mu.Unlock()
+	delete(r.provider.callbacks, r)
+	return nil
+}
+
+// observer records the observations made by the callback of a registration.
+type observer struct {
+	noop.Observer
+	provider     *MeterProvider
+	registration *registration
+}
+
+func (o *observer) ObserveFloat64(inst otelmetric.Float64Observable, v float64, options ...otelmetric.ObserveOption) {
+	o.observe(inst, v, options)
+}
+
+func (o *observer) ObserveInt64(inst otelmetric.Int64Observable, v int64, options ...otelmetric.ObserveOption) {
+	o.observe(inst, float64(v), options)
+}
+
+func (o *observer) observe(inst otelmetric.Observable, v float64, options []otelmetric.ObserveOption) {
+	oi, ok := inst.(observableInstrument)
+	if !ok || !o.registration.instruments[oi.instrument()] {
+		// not an instrument of the registration.
+		return
+	}
+	oi.instrument().record(o.provider, v, options)
+}
+
+type int64Observer struct {
+	noop.Int64Observer
+	observable *observable
+	observer   *observer
+}
+
+func (o *int64Observer) Observe(v int64, options ...otelmetric.ObserveOption) {
+	o.observable.record(o.observer.provider, float64(v), options)
+}
+
+type float64Observer struct {
+	noop.Float64Observer
+	observable *observable
+	observer   *observer
+}
+
+func (o *float64Observer) Observe(v float64, options ...otelmetric.ObserveOption) {
+	o.observable.record(o.observer.provider, v, options)
+}
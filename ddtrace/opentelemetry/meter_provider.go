@@ -0,0 +1,326 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package opentelemetry
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	otelinternal "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/opentelemetry/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+var _ otelmetric.MeterProvider = (*MeterProvider)(nil)
+
+// StatsdClient is the subset of the DogStatsD client used by the MeterProvider to send
+// the measurements. It is implemented by *statsd.Client.
+type StatsdClient interface {
+	Count(name string, value int64, tags []string, rate float64) error
+	Gauge(name string, value float64, tags []string, rate float64) error
+	Distribution(name string, value float64, tags []string, rate float64) error
+	Flush() error
+	Close() error
+}
+
+// defaultCollectInterval is the default interval at which the callbacks of the
+// observable instruments are called.
+const defaultCollectInterval = 10 * time.Second
+
+// meterConfig holds the configuration of a MeterProvider.
+type meterConfig struct {
+	statsd          StatsdClient
+	resource        []attribute.KeyValue
+	collectInterval time.Duration
+}
+
+// MeterOption configures a MeterProvider.
+type MeterOption func(*meterConfig)
+
+// WithStatsdClient sets the DogStatsD client the measurements are sent with. By default,
+// they are sent with the DogStatsD client of the running tracer, see tracer.Start, and
+// dropped while the tracer isn't running.
+func WithStatsdClient(c StatsdClient) MeterOption {
+	return func(cfg *meterConfig) {
+		cfg.statsd = c
+	}
+}
+
+// WithResource sets the resource attributes of the application. The service.name,
+// deployment.environment and service.version attributes are mapped to the service, env
+// and version tags of all the metrics. By default, they are read from DD_SERVICE, DD_ENV
+// and DD_VERSION, then from OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES.
+func WithResource(attrs ...attribute.KeyValue) MeterOption {
+	return func(cfg *meterConfig) {
+		cfg.resource = append(cfg.resource, attrs...)
+	}
+}
+
+// WithCollectInterval sets the interval at which the callbacks of the observable
+// instruments are called and their observations sent. It defaults to 10 seconds.
+func WithCollectInterval(d time.Duration) MeterOption {
+	return func(cfg *meterConfig) {
+		cfg.collectInterval = d
+	}
+}
+
+// MeterProvider implements the OpenTelemetry metrics API on top of DogStatsD. The
+// measurements are sent as they are made, with their attributes as tags:
+//   - counters are sent as counts;
+//   - up-down counters are sent as gauges of their running sums;
+//   - histograms are sent as distributions;
+//   - observable gauges and up-down counters are sent as gauges, and observable counters
+//     as counts of the difference between two observations, each time their callbacks
+//     are called, see WithCollectInterval.
+//
+// The instrument names are used as metric names, and their units and descriptions are
+// ignored. Meters all share the configuration of their provider; their names and options
+// are ignored.
+type MeterProvider struct {
+	noop.MeterProvider // https://pkg.go.dev/go.opentelemetry.io/otel/metric#hdr-API_Implementations
+
+	statsd   StatsdClient // set with WithStatsdClient; nil to use the tracer's client
+	baseTags []string
+	meter    *meter
+
+	mu         sync.Mutex                 // guards below fields
+	callbacks  map[*registration]struct{} // registered callbacks
+	remainders map[string]float64         // fractional parts of float counts not sent yet
+	sums       map[string]float64         // running sums of the up-down counters
+	observed   map[string]float64         // last observations of the observable counters
+	stopped    bool                       // whether Shutdown was called
+
+	stop chan struct{}  // closed on Shutdown
+	wg   sync.WaitGroup // waits for the collection loop
+}
+
+// NewMeterProvider returns a MeterProvider sending the measurements to DogStatsD with the
+// client of the running tracer, unless WithStatsdClient is used. It should be shut down
+// before the tracer is stopped, to flush the measurements.
+//
+//	tracer.Start()
+//	defer tracer.Stop()
+//	provider := opentelemetry.NewMeterProvider()
+//	defer provider.Shutdown(context.Background())
+//	otel.SetMeterProvider(provider)
+func NewMeterProvider(opts ...MeterOption) *MeterProvider {
+	cfg := meterConfig{collectInterval: defaultCollectInterval}
+	for _, fn := range opts {
+		fn(&cfg)
+	}
+	p := &MeterProvider{
+		statsd:     cfg.statsd,
+		baseTags:   resourceTags(cfg.resource),
+		callbacks:  make(map[*registration]struct{}),
+		remainders: make(map[string]float64),
+		sums:       make(map[string]float64),
+		observed:   make(map[string]float64),
+		stop:       make(chan struct{}),
+	}
+	p.meter = &meter{provider: p}
+	if cfg.collectInterval > 0 {
+		p.wg.Add(1)
+		go p.collectLoop(cfg.collectInterval)
+	}
+	return p
+}
+
+// Meter returns the meter of the provider, ignoring the provided name and options.
+// If the MeterProvider has already been shut down, it returns a no-op meter.
+func (p *MeterProvider) Meter(_ string, _ ...otelmetric.MeterOption) otelmetric.Meter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return noop.NewMeterProvider().Meter("")
+	}
+	return p.meter
+}
+
+// ForceFlush calls the callbacks of the observable instruments, then flushes the
+// measurements buffered by the DogStatsD client.
+func (p *MeterProvider) ForceFlush(ctx context.Context) error {
+	p.collect(ctx)
+	return p.client().Flush()
+}
+
+// Shutdown collects and flushes the measurements a last time, then stops the provider.
+// The DogStatsD client isn't closed. Subsequent calls are valid but become no-op.
+func (p *MeterProvider) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return nil
+	}
+	p.stopped = true
+	close(p.stop)
+	p.mu.Unlock()
+	p.wg.Wait()
+
+	p.collect(ctx)
+	return p.client().Flush()
+}
+
+// client returns the DogStatsD client the measurements are sent with.
+func (p *MeterProvider) client() StatsdClient {
+	if p.statsd != nil {
+		return p.statsd
+	}
+	switch c := globalconfig.StatsdClient().(type) {
+	case nil:
+		// the tracer isn't running.
+		return noopStatsd
+	case StatsdClient:
+		return c
+	default:
+		// a client set with tracer.WithStatsdClient which can't send distributions.
+		return tracerStatsd{c}
+	}
+}
+
+// noopStatsd drops the measurements made while the tracer isn't running.
+var noopStatsd = &statsd.NoOpClient{}
+
+// tracerStatsd is a StatsdClient sending the measurements with a tracer's DogStatsD
+// client which has no Distribution method. The histograms are dropped.
+type tracerStatsd struct {
+	internal.StatsdClient
+}
+
+// Distribution implements StatsdClient.
+func (tracerStatsd) Distribution(string, float64, []string, float64) error { return nil }
+
+// collectLoop calls the callbacks of the observable instruments every interval.
+func (p *MeterProvider) collectLoop(interval time.Duration) {
+	defer p.wg.Done()
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			p.collect(ctx)
+			cancel()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// collect calls the registered callbacks.
+func (p *MeterProvider) collect(ctx context.Context) {
+	p.mu.Lock()
+	regs := make([]*registration, 0, len(p.callbacks))
+	for r := range p.callbacks {
+		regs = append(regs, r)
+	}
+	p.mu.Unlock()
+	for _, r := range regs {
+		if err := r.callback(ctx, &observer{provider: p, registration: r}); err != nil {
+			log.Warn("OpenTelemetry metrics callback failed: %v", err)
+		}
+	}
+}
+
+// count sends a count of v for the metric name.
+func (p *MeterProvider) count(name string, v int64, attrs attribute.Set) {
+	if v == 0 {
+		return
+	}
+	p.client().Count(name, v, p.tags(attrs), 1)
+}
+
+// countFloat sends a count of v for the metric name. As counts are integers, the
+// fractional parts are accumulated until they add up to a whole number.
+func (p *MeterProvider) countFloat(name string, v float64, attrs attribute.Set) {
+	tags := p.tags(attrs)
+	key := seriesKey(name, tags)
+	p.mu.Lock()
+	v += p.remainders[key]
+	n := int64(v)
+	p.remainders[key] = v - float64(n)
+	p.mu.Unlock()
+	if n != 0 {
+		p.client().Count(name, n, tags, 1)
+	}
+}
+
+// upDownCount adds incr to the running sum of the up-down counter name, and sends the
+// sum as a gauge.
+func (p *MeterProvider) upDownCount(name string, incr float64, attrs attribute.Set) {
+	tags := p.tags(attrs)
+	key := seriesKey(name, tags)
+	p.mu.Lock()
+	sum := p.sums[key] + incr
+	p.sums[key] = sum
+	p.mu.Unlock()
+	p.client().Gauge(name, sum, tags, 1)
+}
+
+// gauge sends the value v of the metric name.
+func (p *MeterProvider) gauge(name string, v float64, attrs attribute.Set) {
+	p.client().Gauge(name, v, p.tags(attrs), 1)
+}
+
+// distribution sends v as a sample of the distribution name.
+func (p *MeterProvider) distribution(name string, v float64, attrs attribute.Set) {
+	p.client().Distribution(name, v, p.tags(attrs), 1)
+}
+
+// observeCounter sends the difference between the cumulative value v of the
+// observable counter name and its previous observation.
+func (p *MeterProvider) observeCounter(name string, v float64, attrs attribute.Set) {
+	key := seriesKey(name, p.tags(attrs))
+	p.mu.Lock()
+	prev, ok := p.observed[key]
+	p.observed[key] = v
+	p.mu.Unlock()
+	if !ok {
+		// the first observation is the reference of the next ones.
+		return
+	}
+	if v < prev {
+		// the counter was reset.
+		prev = 0
+	}
+	p.countFloat(name, v-prev, attrs)
+}
+
+// tags returns the DogStatsD tags of a measurement with the given attributes.
+func (p *MeterProvider) tags(attrs attribute.Set) []string {
+	tags := make([]string, 0, len(p.baseTags)+attrs.Len())
+	tags = append(tags, p.baseTags...)
+	iter := attrs.Iter()
+	for iter.Next() {
+		kv := iter.Attribute()
+		tags = append(tags, string(kv.Key)+":"+kv.Value.Emit())
+	}
+	return tags
+}
+
+func seriesKey(name string, tags []string) string {
+	return name + "|" + strings.Join(tags, ",")
+}
+
+// resourceTags returns the env, service and version tags of the metrics, see
+// otelinternal.ResourceValues.
+func resourceTags(resource []attribute.KeyValue) []string {
+	values := otelinternal.ResourceValues(resource)
+	tags := make([]string, 0, len(values))
+	for tag, v := range values {
+		tags = append(tags, tag+":"+v)
+	}
+	sort.Strings(tags)
+	return tags
+}
@@ -0,0 +1,297 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package opentelemetry
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/statsdtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+)
+
+type statsdCall struct {
+	kind  string
+	name  string
+	value float64
+	tags  []string
+}
+
+// testStatsdClient records the calls made to the DogStatsD client.
+type testStatsdClient struct {
+	mu      sync.Mutex
+	calls   []statsdCall
+	flushed int
+	closed  bool
+}
+
+func (c *testStatsdClient) record(kind, name string, value float64, tags []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, statsdCall{kind: kind, name: name, value: value, tags: tags})
+	return nil
+}
+
+func (c *testStatsdClient) Count(name string, value int64, tags []string, _ float64) error {
+	return c.record("count", name, float64(value), tags)
+}
+
+func (c *testStatsdClient) Gauge(name string, value float64, tags []string, _ float64) error {
+	return c.record("gauge", name, value, tags)
+}
+
+func (c *testStatsdClient) Distribution(name string, value float64, tags []string, _ float64) error {
+	return c.record("distribution", name, value, tags)
+}
+
+func (c *testStatsdClient) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushed++
+	return nil
+}
+
+func (c *testStatsdClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *testStatsdClient) reset() []statsdCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	calls := c.calls
+	c.calls = nil
+	return calls
+}
+
+func newTestMeterProvider(t *testing.T, opts ...MeterOption) (*MeterProvider, *testStatsdClient) {
+	t.Setenv("DD_SERVICE", "svc")
+	t.Setenv("DD_ENV", "")
+	t.Setenv("DD_VERSION", "")
+	client := &testStatsdClient{}
+	opts = append([]MeterOption{WithStatsdClient(client), WithCollectInterval(0)}, opts...)
+	p := NewMeterProvider(opts...)
+	t.Cleanup(func() { p.Shutdown(context.Background()) })
+	return p, client
+}
+
+func TestMeterCounters(t *testing.T) {
+	p, client := newTestMeterProvider(t)
+	m := p.Meter("test")
+	ctx := context.Background()
+
+	c, err := m.Int64Counter("requests")
+	require.NoError(t, err)
+	c.Add(ctx, 2, otelmetric.WithAttributes(attribute.String("path", "/")))
+	c.Add(ctx, 0)
+
+	fc, err := m.Float64Counter("bytes")
+	require.NoError(t, err)
+	fc.Add(ctx, 0.5)
+	fc.Add(ctx, 0.75)
+	fc.Add(ctx, 0.75)
+
+	ud, err := m.Int64UpDownCounter("queue")
+	require.NoError(t, err)
+	ud.Add(ctx, 5)
+	ud.Add(ctx, -3)
+
+	fud, err := m.Float64UpDownCounter("load")
+	require.NoError(t, err)
+	fud.Add(ctx, 0.5)
+	fud.Add(ctx, 0.25)
+
+	assert.Equal(t, []statsdCall{
+		{kind: "count", name: "requests", value: 2, tags: []string{"service:svc", "path:/"}},
+		{kind: "count", name: "bytes", value: 1, tags: []string{"service:svc"}},
+		{kind: "count", name: "bytes", value: 1, tags: []string{"service:svc"}},
+		{kind: "gauge", name: "queue", value: 5, tags: []string{"service:svc"}},
+		{kind: "gauge", name: "queue", value: 2, tags: []string{"service:svc"}},
+		{kind: "gauge", name: "load", value: 0.5, tags: []string{"service:svc"}},
+		{kind: "gauge", name: "load", value: 0.75, tags: []string{"service:svc"}},
+	}, client.reset())
+}
+
+func TestMeterHistograms(t *testing.T) {
+	p, client := newTestMeterProvider(t)
+	m := p.Meter("test")
+	ctx := context.Background()
+
+	h, err := m.Int64Histogram("latency")
+	require.NoError(t, err)
+	h.Record(ctx, 12)
+	fh, err := m.Float64Histogram("size")
+	require.NoError(t, err)
+	fh.Record(ctx, 1.5, otelmetric.WithAttributes(attribute.Bool("ok", true)))
+
+	assert.Equal(t, []statsdCall{
+		{kind: "distribution", name: "latency", value: 12, tags: []string{"service:svc"}},
+		{kind: "distribution", name: "size", value: 1.5, tags: []string{"service:svc", "ok:true"}},
+	}, client.reset())
+}
+
+func TestMeterObservables(t *testing.T) {
+	p, client := newTestMeterProvider(t)
+	m := p.Meter("test")
+	ctx := context.Background()
+
+	var total int64
+	_, err := m.Int64ObservableCounter("total", otelmetric.WithInt64Callback(func(_ context.Context, o otelmetric.Int64Observer) error {
+		o.Observe(total)
+		return nil
+	}))
+	require.NoError(t, err)
+	_, err = m.Float64ObservableGauge("temperature", otelmetric.WithFloat64Callback(func(_ context.Context, o otelmetric.Float64Observer) error {
+		o.Observe(21.5)
+		return nil
+	}))
+	require.NoError(t, err)
+
+	total = 10
+	require.NoError(t, p.ForceFlush(ctx))
+	assert.ElementsMatch(t, []statsdCall{
+		{kind: "gauge", name: "temperature", value: 21.5, tags: []string{"service:svc"}},
+	}, client.reset())
+
+	total = 15
+	require.NoError(t, p.ForceFlush(ctx))
+	assert.ElementsMatch(t, []statsdCall{
+		{kind: "gauge", name: "temperature", value: 21.5, tags: []string{"service:svc"}},
+		{kind: "count", name: "total", value: 5, tags: []string{"service:svc"}},
+	}, client.reset())
+
+	total = 3 // reset
+	require.NoError(t, p.ForceFlush(ctx))
+	assert.ElementsMatch(t, []statsdCall{
+		{kind: "gauge", name: "temperature", value: 21.5, tags: []string{"service:svc"}},
+		{kind: "count", name: "total", value: 3, tags: []string{"service:svc"}},
+	}, client.reset())
+	assert.Equal(t, 3, client.flushed)
+}
+
+func TestMeterRegisterCallback(t *testing.T) {
+	p, client := newTestMeterProvider(t)
+	m := p.Meter("test")
+	ctx := context.Background()
+
+	g, err := m.Int64ObservableGauge("goroutines")
+	require.NoError(t, err)
+	ud, err := m.Float64ObservableUpDownCounter("inflight")
+	require.NoError(t, err)
+	other, err := m.Int64ObservableGauge("other")
+	require.NoError(t, err)
+	reg, err := m.RegisterCallback(func(_ context.Context, o otelmetric.Observer) error {
+		o.ObserveInt64(g, 7, otelmetric.WithAttributes(attribute.Int("shard", 1)))
+		o.ObserveFloat64(ud, 2)
+		o.ObserveInt64(other, 1) // not registered
+		return nil
+	}, g, ud)
+	require.NoError(t, err)
+
+	require.NoError(t, p.ForceFlush(ctx))
+	assert.ElementsMatch(t, []statsdCall{
+		{kind: "gauge", name: "goroutines", value: 7, tags: []string{"service:svc", "shard:1"}},
+		{kind: "gauge", name: "inflight", value: 2, tags: []string{"service:svc"}},
+	}, client.reset())
+
+	require.NoError(t, reg.Unregister())
+	require.NoError(t, p.ForceFlush(ctx))
+	assert.Empty(t, client.reset())
+}
+
+func TestMeterResourceTags(t *testing.T) {
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment=staging,service.version=1.0,team=apm")
+		t.Setenv("OTEL_SERVICE_NAME", "otel-svc")
+		t.Setenv("DD_SERVICE", "")
+		t.Setenv("DD_ENV", "")
+		t.Setenv("DD_VERSION", "")
+		assert.Equal(t, []string{"env:staging", "service:otel-svc", "version:1.0"}, resourceTags(nil))
+	})
+
+	t.Run("dd-env", func(t *testing.T) {
+		t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment=staging")
+		t.Setenv("OTEL_SERVICE_NAME", "otel-svc")
+		t.Setenv("DD_SERVICE", "dd-svc")
+		t.Setenv("DD_ENV", "prod")
+		t.Setenv("DD_VERSION", "")
+		assert.Equal(t, []string{"env:prod", "service:dd-svc"}, resourceTags(nil))
+	})
+
+	t.Run("resource", func(t *testing.T) {
+		p, client := newTestMeterProvider(t, WithResource(
+			attribute.String("service.name", "res-svc"),
+			attribute.String("deployment.environment", "dev"),
+			attribute.String("host.name", "ignored"),
+		))
+		c, err := p.Meter("test").Int64Counter("hits")
+		require.NoError(t, err)
+		c.Add(context.Background(), 1)
+		assert.Equal(t, []statsdCall{
+			{kind: "count", name: "hits", value: 1, tags: []string{"env:dev", "service:res-svc"}},
+		}, client.reset())
+	})
+}
+
+func TestMeterProviderShutdown(t *testing.T) {
+	p, client := newTestMeterProvider(t)
+	ctx := context.Background()
+	_, err := p.Meter("test").Int64ObservableGauge("g", otelmetric.WithInt64Callback(func(_ context.Context, o otelmetric.Int64Observer) error {
+		o.Observe(1)
+		return nil
+	}))
+	require.NoError(t, err)
+
+	require.NoError(t, p.Shutdown(ctx))
+	assert.Len(t, client.reset(), 1)
+	assert.Equal(t, 1, client.flushed)
+	assert.False(t, client.closed, "a client set with WithStatsdClient is not closed")
+
+	// the meter is a no-op once the provider is shut down.
+	c, err := p.Meter("test").Int64Counter("c")
+	require.NoError(t, err)
+	c.Add(ctx, 1)
+	assert.Empty(t, client.reset())
+	assert.NoError(t, p.Shutdown(ctx))
+}
+
+func TestMeterProviderTracerClient(t *testing.T) {
+	t.Setenv("DD_SERVICE", "svc")
+	t.Setenv("DD_ENV", "")
+	t.Setenv("DD_VERSION", "")
+	p := NewMeterProvider(WithCollectInterval(0))
+	m := p.Meter("test")
+	ctx := context.Background()
+	c, err := m.Int64Counter("hits")
+	require.NoError(t, err)
+	h, err := m.Float64Histogram("latency")
+	require.NoError(t, err)
+
+	// the measurements are dropped while the tracer isn't running.
+	c.Add(ctx, 1)
+
+	// the measurements are sent with the client of the running tracer.
+	client := &statsdtest.TestStatsdClient{}
+	globalconfig.SetStatsdClient(client)
+	defer globalconfig.SetStatsdClient(nil)
+	c.Add(ctx, 2)
+	// the client can't send distributions.
+	h.Record(ctx, 1.5)
+	assert.Equal(t, map[string]int64{"hits": 2}, client.Counts())
+	assert.Equal(t, []string{"hits"}, client.CallNames())
+
+	require.NoError(t, p.Shutdown(ctx))
+	assert.Equal(t, 1, client.Flushed())
+	assert.False(t, client.Closed(), "the tracer's client is not closed")
+}
@@ -0,0 +1,12 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+// Package otelsdk provides the OpenTelemetry support which depends on newer
+// OpenTelemetry modules than the opentelemetry package, some of them experimental:
+// a LoggerProvider implementing the OpenTelemetry logs API, which sends the logs
+// correlated with the active traces, and a SpanExporter sending the spans of the
+// OpenTelemetry SDK through the Datadog tracer. It is a separate module, so that
+// the applications which don't use it don't depend on these modules.
+package otelsdk // import "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/opentelemetry/otelsdk"
@@ -0,0 +1,354 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package otelsdk
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/opentelemetry"
+	otelinternal "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/opentelemetry/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+var _ sdktrace.SpanExporter = (*SpanExporter)(nil)
+
+// errExporterShutdown is returned by ExportSpans once the exporter is shut down.
+var errExporterShutdown = errors.New("otelsdk: span exporter is shut down")
+
+// SpanExporter implements the OpenTelemetry SDK SpanExporter interface on top of the
+// Datadog tracer, for libraries which create their own sdktrace.TracerProvider. The
+// exported spans are converted like the spans of opentelemetry.TracerProvider,
+// so that they show up identically, and then go through the sampling, stats and
+// payloads of the global tracer, which must be started, e.g. with tracer.Start:
+//
+//	tracer.Start()
+//	defer tracer.Stop()
+//	provider := sdktrace.NewTracerProvider(
+//		sdktrace.WithBatcher(otelsdk.NewSpanExporter()),
+//	)
+//
+// The trace and span IDs of the OpenTelemetry spans are kept, and the service.name,
+// deployment.environment and service.version resource attributes are mapped to the
+// service, env and version of the spans. The sampling decision of the OpenTelemetry
+// spans is kept too, as well as their W3C tracestate, in the w3c.tracestate tag.
+//
+// As the spans usually end before their parent, they are exported in several batches:
+// the spans are buffered by trace until their local root span is exported, so that
+// the trace is sent as a single chunk. The spans whose parent isn't exported within a
+// minute are sent without it.
+type SpanExporter struct {
+	mu       sync.Mutex                          // guards below fields
+	stopped  bool                                // whether Shutdown was called
+	pending  map[oteltrace.TraceID]*pendingTrace // traces waiting for some parents
+	npending int                                 // number of spans in pending
+	exported map[spanKey]struct{}                // recently exported spans, see exportedKeys
+	// exportedKeys holds the keys of exported, in the order they were exported, so
+	// that the oldest ones are forgotten first.
+	exportedKeys []spanKey
+	timer        *time.Timer // exports the expired pending traces
+}
+
+const (
+	// pendingSpanTimeout is how long the spans wait for their parent to be exported.
+	pendingSpanTimeout = time.Minute
+
+	// maxPendingSpans is the number of spans waiting for their parent, beyond which
+	// the oldest traces are exported without waiting.
+	maxPendingSpans = 10000
+
+	// maxExportedSpans is the number of exported spans remembered, so that their late
+	// children don't wait for them.
+	maxExportedSpans = 10000
+)
+
+// pendingTrace holds the spans of a trace waiting for some parents to be exported.
+type pendingTrace struct {
+	spans []sdktrace.ReadOnlySpan
+	since time.Time // when the first span was buffered
+}
+
+// NewSpanExporter returns a SpanExporter sending the spans through the global tracer.
+func NewSpanExporter() *SpanExporter {
+	return &SpanExporter{
+		pending:  make(map[oteltrace.TraceID]*pendingTrace),
+		exported: make(map[spanKey]struct{}),
+	}
+}
+
+// spanKey identifies an OpenTelemetry span.
+type spanKey struct {
+	traceID oteltrace.TraceID
+	spanID  oteltrace.SpanID
+}
+
+// exportedSpan is an OpenTelemetry span started with the Datadog tracer.
+type exportedSpan struct {
+	ctx  context.Context // holds the span, for its children
+	span oteltrace.Span
+}
+
+// ExportSpans sends the spans through the global tracer. The spans are sent by trace,
+// once the parents of all the spans of the trace are exported.
+func (e *SpanExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return errExporterShutdown
+	}
+	now := time.Now()
+	for _, ro := range spans {
+		id := ro.SpanContext().TraceID()
+		pt, ok := e.pending[id]
+		if !ok {
+			pt = &pendingTrace{since: now}
+			e.pending[id] = pt
+		}
+		pt.spans = append(pt.spans, ro)
+		e.npending++
+	}
+	for id, pt := range e.pending {
+		if e.complete(pt) {
+			e.exportLocked(id)
+		}
+	}
+	for e.npending > maxPendingSpans {
+		e.exportLocked(e.oldestLocked())
+	}
+	e.scheduleLocked()
+	return nil
+}
+
+// Shutdown sends the pending spans, then flushes the spans buffered by the global
+// tracer. Subsequent calls to ExportSpans fail.
+func (e *SpanExporter) Shutdown(_ context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return nil
+	}
+	e.stopped = true
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	for id := range e.pending {
+		e.exportLocked(id)
+	}
+	tracer.Flush()
+	return nil
+}
+
+// complete returns whether the parents of the spans of pt are all exported, or part of
+// pt.
+func (e *SpanExporter) complete(pt *pendingTrace) bool {
+	keys := make(map[spanKey]struct{}, len(pt.spans))
+	for _, ro := range pt.spans {
+		keys[keyOf(ro.SpanContext())] = struct{}{}
+	}
+	for _, ro := range pt.spans {
+		p := ro.Parent()
+		if !p.IsValid() || p.IsRemote() {
+			continue
+		}
+		if _, ok := keys[keyOf(p)]; ok {
+			continue
+		}
+		if _, ok := e.exported[keyOf(p)]; ok {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// oldestLocked returns the ID of the trace pending for the longest time.
+func (e *SpanExporter) oldestLocked() oteltrace.TraceID {
+	var (
+		oldest oteltrace.TraceID
+		since  time.Time
+	)
+	for id, pt := range e.pending {
+		if since.IsZero() || pt.since.Before(since) {
+			oldest, since = id, pt.since
+		}
+	}
+	return oldest
+}
+
+// scheduleLocked schedules the export of the oldest pending trace once it expires.
+func (e *SpanExporter) scheduleLocked() {
+	if e.timer != nil || len(e.pending) == 0 {
+		return
+	}
+	d := time.Until(e.pending[e.oldestLocked()].since.Add(pendingSpanTimeout))
+	e.timer = time.AfterFunc(d, func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if e.stopped {
+			return
+		}
+		e.timer = nil
+		for id, pt := range e.pending {
+			if time.Since(pt.since) >= pendingSpanTimeout {
+				e.exportLocked(id)
+			}
+		}
+		e.scheduleLocked()
+	})
+}
+
+// exportLocked sends the spans of the pending trace id through the global tracer. The
+// spans whose parent is part of the trace are attached to it, so that they form a single
+// trace chunk, and the other ones start a new chunk.
+func (e *SpanExporter) exportLocked(id oteltrace.TraceID) {
+	pt := e.pending[id]
+	delete(e.pending, id)
+	e.npending -= len(pt.spans)
+
+	tr := otelinternal.Tracer()
+	batch := make(map[spanKey]sdktrace.ReadOnlySpan, len(pt.spans))
+	for _, ro := range pt.spans {
+		batch[keyOf(ro.SpanContext())] = ro
+	}
+	started := make(map[spanKey]exportedSpan, len(pt.spans))
+	var start func(ro sdktrace.ReadOnlySpan) exportedSpan
+	start = func(ro sdktrace.ReadOnlySpan) exportedSpan {
+		key := keyOf(ro.SpanContext())
+		if s, ok := started[key]; ok {
+			return s
+		}
+		// the parents are started before their children.
+		ctx := context.Background()
+		var ddopts []ddtrace.StartSpanOption
+		if p := ro.Parent(); !p.IsValid() {
+			// a root span, which keeps the trace ID of its OpenTelemetry span.
+			ddopts = append(ddopts, tracer.ChildOf(traceIDContext(ro.SpanContext().TraceID())))
+			ddopts = append(ddopts, chunkRootOptions(ro.SpanContext())...)
+		} else if pro, ok := batch[keyOf(p)]; ok {
+			ctx = start(pro).ctx
+		} else {
+			// a remote parent, or a parent which was exported already.
+			ctx = oteltrace.ContextWithSpanContext(ctx, p)
+			ddopts = append(ddopts, chunkRootOptions(ro.SpanContext())...)
+		}
+		s := startExportedSpan(ctx, tr, ro, ddopts...)
+		started[key] = s
+		return s
+	}
+	for _, ro := range pt.spans {
+		start(ro)
+		e.rememberLocked(keyOf(ro.SpanContext()))
+	}
+	// the spans are all started before any of them finishes, so that the trace
+	// chunk is complete when it's flushed.
+	for _, ro := range pt.spans {
+		started[keyOf(ro.SpanContext())].span.End(oteltrace.WithTimestamp(ro.EndTime()))
+	}
+}
+
+// rememberLocked records that the span key was exported, forgetting the oldest exported
+// spans beyond maxExportedSpans.
+func (e *SpanExporter) rememberLocked(key spanKey) {
+	e.exported[key] = struct{}{}
+	e.exportedKeys = append(e.exportedKeys, key)
+	if len(e.exportedKeys) > maxExportedSpans {
+		delete(e.exported, e.exportedKeys[0])
+		e.exportedKeys = e.exportedKeys[1:]
+	}
+}
+
+// chunkRootOptions returns the options of the Datadog spans starting a trace chunk,
+// which keep the sampling decision and the tracestate of their OpenTelemetry span
+// context sctx.
+func chunkRootOptions(sctx oteltrace.SpanContext) []ddtrace.StartSpanOption {
+	priority := ext.PriorityAutoReject
+	if sctx.IsSampled() {
+		priority = ext.PriorityAutoKeep
+	}
+	opts := []ddtrace.StartSpanOption{tracer.Tag(ext.SamplingPriority, internal.SamplingDecision{
+		Priority: priority,
+		Sampler:  samplernames.OTel,
+	})}
+	if ts := sctx.TraceState(); ts.Len() > 0 {
+		opts = append(opts, tracer.Tag("w3c.tracestate", ts.String()))
+	}
+	return opts
+}
+
+func keyOf(sctx oteltrace.SpanContext) spanKey {
+	return spanKey{sctx.TraceID(), sctx.SpanID()}
+}
+
+// startExportedSpan starts the Datadog span of the OpenTelemetry span ro with tr, from
+// ctx and with the additional options ddopts. The returned span holds the attributes,
+// events and status of ro, and is converted like the spans of the TracerProvider when
+// it is ended.
+func startExportedSpan(ctx context.Context, tr oteltrace.Tracer, ro sdktrace.ReadOnlySpan, ddopts ...ddtrace.StartSpanOption) exportedSpan {
+	spanID := ro.SpanContext().SpanID()
+	ddopts = append(ddopts, tracer.WithSpanID(binary.BigEndian.Uint64(spanID[:])))
+	opts := []oteltrace.SpanStartOption{
+		oteltrace.WithTimestamp(ro.StartTime()),
+		oteltrace.WithSpanKind(ro.SpanKind()),
+	}
+	if len(ro.Links()) > 0 {
+		links := make([]oteltrace.Link, 0, len(ro.Links()))
+		for _, l := range ro.Links() {
+			links = append(links, oteltrace.Link{SpanContext: l.SpanContext, Attributes: l.Attributes})
+		}
+		opts = append(opts, oteltrace.WithLinks(links...))
+	}
+	ctx, s := tr.Start(opentelemetry.ContextWithStartOptions(ctx, ddopts...), ro.Name(), opts...)
+	if res := ro.Resource(); res != nil {
+		for _, kv := range res.Attributes() {
+			switch v := kv.Value.Emit(); kv.Key {
+			case "service.name":
+				if !strings.HasPrefix(v, "unknown_service") {
+					// the default service name of the SDK is ignored.
+					s.SetAttributes(attribute.String(ext.ServiceName, v))
+				}
+			case "deployment.environment":
+				s.SetAttributes(attribute.String(ext.Environment, v))
+			case "service.version":
+				s.SetAttributes(attribute.String(ext.Version, v))
+			}
+		}
+	}
+	s.SetAttributes(ro.Attributes()...)
+	for _, ev := range ro.Events() {
+		s.AddEvent(ev.Name, oteltrace.WithTimestamp(ev.Time), oteltrace.WithAttributes(ev.Attributes...))
+	}
+	s.SetStatus(ro.Status().Code, ro.Status().Description)
+	return exportedSpan{ctx: ctx, span: s}
+}
+
+// traceIDContext is the parent of the exported root spans, so that they keep the trace
+// ID of their OpenTelemetry span.
+type traceIDContext oteltrace.TraceID
+
+var _ ddtrace.SpanContextW3C = traceIDContext{}
+
+func (c traceIDContext) SpanID() uint64 { return 0 }
+
+func (c traceIDContext) TraceID() uint64 { return binary.BigEndian.Uint64(c[8:]) }
+
+func (c traceIDContext) ForeachBaggageItem(_ func(k, v string) bool) {}
+
+func (c traceIDContext) TraceID128() string { return oteltrace.TraceID(c).String() }
+
+func (c traceIDContext) TraceID128Bytes() [16]byte { return c }
@@ -0,0 +1,246 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package otelsdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/httpmem"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tinylib/msgp/msgp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+type traces [][]map[string]interface{}
+
+// startTestTracer starts the global tracer with a test agent, and returns the traces
+// it receives. The tracer is stopped when the test completes.
+func startTestTracer(t *testing.T) chan traces {
+	payloads := make(chan traces)
+	s, c := httpmem.ServerAndClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v0.4/traces" {
+			if r.Method == "GET" {
+				w.Write([]byte("{}"))
+			}
+			return
+		}
+		if r.Header.Get("X-Datadog-Trace-Count") == "0" {
+			return
+		}
+		buf, err := io.ReadAll(r.Body)
+		if err != nil || len(buf) == 0 {
+			t.Errorf("Test agent: Error receiving traces: %v", err)
+			return
+		}
+		var payload bytes.Buffer
+		if _, err := msgp.UnmarshalAsJSON(&payload, buf); err != nil {
+			t.Errorf("Failed to unmarshal payload bytes as JSON: %v", err)
+			return
+		}
+		var tr traces
+		if err := json.Unmarshal(payload.Bytes(), &tr); err != nil || len(tr) == 0 {
+			t.Errorf("Failed to unmarshal payload bytes as trace: %v", err)
+			return
+		}
+		payloads <- tr
+	}))
+	tracer.Start(tracer.WithHTTPClient(c), tracer.WithLogStartup(false))
+	t.Cleanup(func() {
+		tracer.Stop()
+		s.Close()
+	})
+	return payloads
+}
+
+func waitForPayload(payloads chan traces) (traces, error) {
+	select {
+	case p := <-payloads:
+		return p, nil
+	case <-time.After(10 * time.Second):
+		return nil, fmt.Errorf("Timed out waiting for traces")
+	}
+}
+
+func TestSpanExporter(t *testing.T) {
+	assert := assert.New(t)
+	payloads := startTestTracer(t)
+
+	exporter := NewSpanExporter()
+	sdk := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", "sdk-service"),
+			attribute.String("deployment.environment", "staging"),
+		)),
+	)
+	tr := sdk.Tracer("lib")
+
+	ctx, parent := tr.Start(context.Background(), "GET /users", oteltrace.WithSpanKind(oteltrace.SpanKindServer),
+		oteltrace.WithAttributes(attribute.String("http.request.method", "GET"), attribute.Int("http.response.status_code", 200)))
+	_, child := tr.Start(ctx, "SELECT", oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+		oteltrace.WithAttributes(attribute.String("db.system", "postgresql")))
+	child.AddEvent("retry", oteltrace.WithAttributes(attribute.Int("attempt", 2)))
+	child.SetStatus(codes.Error, "timeout")
+	child.End()
+	parent.End()
+	require.NoError(t, sdk.ForceFlush(context.Background()))
+
+	traces, err := waitForPayload(payloads)
+	require.NoError(t, err)
+	require.Len(t, traces, 1)
+	require.Len(t, traces[0], 2)
+	var p, c map[string]interface{}
+	for _, s := range traces[0] {
+		if s["resource"] == "GET /users" {
+			p = s
+		} else {
+			c = s
+		}
+	}
+	require.NotNil(t, p)
+	require.NotNil(t, c)
+
+	traceID := parent.SpanContext().TraceID()
+	parentID, childID := parent.SpanContext().SpanID(), child.SpanContext().SpanID()
+	assert.Equal(float64(binary.BigEndian.Uint64(traceID[8:])), p["trace_id"])
+	assert.Equal(float64(binary.BigEndian.Uint64(parentID[:])), p["span_id"])
+	assert.Equal(float64(binary.BigEndian.Uint64(childID[:])), c["span_id"])
+	assert.Equal(p["span_id"], c["parent_id"])
+
+	assert.Equal("http.server.request", p["name"])
+	assert.Equal("sdk-service", p["service"])
+	meta := p["meta"].(map[string]interface{})
+	assert.Equal("server", meta["span.kind"])
+	assert.Equal("staging", meta["env"])
+	assert.Equal("200", meta["http.status_code"])
+	assert.Equal(traceID.String()[:16], meta["_dd.p.tid"])
+	assert.Contains(p["metrics"], "_sampling_priority_v1")
+
+	assert.Equal("postgresql.query", c["name"])
+	assert.Equal("SELECT", c["resource"])
+	assert.Equal(float64(1), c["error"])
+	meta = c["meta"].(map[string]interface{})
+	assert.Equal("timeout", meta["error.message"])
+	assert.Contains(meta["events"], `"name":"retry"`)
+	assert.Equal(float64(child.(sdktrace.ReadOnlySpan).EndTime().Sub(child.(sdktrace.ReadOnlySpan).StartTime())), c["duration"])
+
+	require.NoError(t, sdk.Shutdown(context.Background()))
+	assert.True(errors.Is(exporter.ExportSpans(context.Background(), nil), errExporterShutdown))
+}
+
+func TestSpanExporterRemoteParent(t *testing.T) {
+	assert := assert.New(t)
+	payloads := startTestTracer(t)
+
+	sdk := sdktrace.NewTracerProvider(sdktrace.WithSyncer(NewSpanExporter()))
+	defer sdk.Shutdown(context.Background())
+	ts, err := oteltrace.ParseTraceState("vendor=value")
+	require.NoError(t, err)
+	remote := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID:    oteltrace.TraceID{0xaa, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+		SpanID:     oteltrace.SpanID{0, 0, 0, 0, 0, 0, 0, 2},
+		TraceFlags: oteltrace.FlagsSampled,
+		TraceState: ts,
+		Remote:     true,
+	})
+	link := oteltrace.Link{SpanContext: remote, Attributes: []attribute.KeyValue{attribute.String("k", "v")}}
+	_, sp := sdk.Tracer("").Start(oteltrace.ContextWithRemoteSpanContext(context.Background(), remote), "consume",
+		oteltrace.WithLinks(link), oteltrace.WithTimestamp(time.Now().Add(-time.Second)))
+	sp.End()
+
+	traces, err := waitForPayload(payloads)
+	require.NoError(t, err)
+	s := traces[0][0]
+	assert.Equal(float64(1), s["trace_id"])
+	assert.Equal(float64(2), s["parent_id"])
+	assert.Equal("internal", s["name"])
+	meta := s["meta"].(map[string]interface{})
+	assert.Equal("aa00000000000000", meta["_dd.p.tid"])
+	assert.Equal("vendor=value", meta["w3c.tracestate"])
+	assert.Equal("-9", meta["_dd.p.dm"], "the sampling decision is made by the SDK")
+	assert.Equal(float64(ext.PriorityAutoKeep), s["metrics"].(map[string]interface{})["_sampling_priority_v1"])
+	assert.Len(s["span_links"], 1)
+}
+
+func TestSpanExporterBatches(t *testing.T) {
+	assert := assert.New(t)
+	payloads := startTestTracer(t)
+
+	// with a syncer, the child and its parent are exported in separate batches.
+	exporter := NewSpanExporter()
+	sdk := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer sdk.Shutdown(context.Background())
+	tr := sdk.Tracer("")
+	ctx, parent := tr.Start(context.Background(), "parent")
+	_, child := tr.Start(ctx, "child")
+	child.End()
+	exporter.mu.Lock()
+	assert.Equal(1, exporter.npending, "the child waits for its parent")
+	exporter.mu.Unlock()
+	parent.End()
+
+	traces, err := waitForPayload(payloads)
+	require.NoError(t, err)
+	require.Len(t, traces, 1)
+	require.Len(t, traces[0], 2, "the trace is sent as a single chunk")
+	var p, c map[string]interface{}
+	for _, s := range traces[0] {
+		if s["resource"] == "parent" {
+			p = s
+		} else {
+			c = s
+		}
+	}
+	require.NotNil(t, p)
+	require.NotNil(t, c)
+	assert.Equal(p["span_id"], c["parent_id"])
+	assert.Equal(float64(ext.PriorityAutoKeep), p["metrics"].(map[string]interface{})["_sampling_priority_v1"])
+	assert.NotContains(c["metrics"], "_dd.top_level", "the child is part of the chunk of its parent")
+	exporter.mu.Lock()
+	assert.Zero(exporter.npending)
+	assert.Empty(exporter.pending)
+	exporter.mu.Unlock()
+}
+
+func TestSpanExporterMissingParent(t *testing.T) {
+	assert := assert.New(t)
+	payloads := startTestTracer(t)
+
+	exporter := NewSpanExporter()
+	sdk := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tr := sdk.Tracer("")
+	ctx, parent := tr.Start(context.Background(), "parent")
+	_, child := tr.Start(ctx, "child")
+	child.End()
+
+	// the pending child is sent without its parent on shutdown.
+	require.NoError(t, sdk.Shutdown(context.Background()))
+	traces, err := waitForPayload(payloads)
+	require.NoError(t, err)
+	require.Len(t, traces, 1)
+	require.Len(t, traces[0], 1)
+	parentID := parent.SpanContext().SpanID()
+	assert.Equal(float64(binary.BigEndian.Uint64(parentID[:])), traces[0][0]["parent_id"])
+	assert.Equal(float64(ext.PriorityAutoKeep), traces[0][0]["metrics"].(map[string]interface{})["_sampling_priority_v1"])
+	parent.End()
+}
@@ -0,0 +1,123 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package otelsdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogEntry is a log record in the Datadog format, as sent to a LogSink.
+type LogEntry map[string]interface{}
+
+// LogSink receives the log entries of a LoggerProvider.
+type LogSink interface {
+	// Send sends a batch of log entries.
+	Send(entries []LogEntry) error
+	// Close releases the resources of the sink, once all the entries are sent.
+	Close() error
+}
+
+// writerLogSink writes the log entries as JSON lines.
+type writerLogSink struct {
+	mu     sync.Mutex // guards w
+	w      io.Writer
+	closer io.Closer // closed on Close, if not nil
+}
+
+// NewWriterLogSink returns a LogSink writing the log entries to w, one JSON object
+// per line, as expected by the Datadog Agent when it tails log files or the output of
+// containers.
+func NewWriterLogSink(w io.Writer) LogSink {
+	return &writerLogSink{w: w}
+}
+
+// NewFileLogSink returns a LogSink appending the log entries to the file at path, one
+// JSON object per line. The file is created if it doesn't exist, and closed along with
+// the sink.
+func NewFileLogSink(path string) (LogSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &writerLogSink{w: f, closer: f}, nil
+}
+
+func (s *writerLogSink) Send(entries []LogEntry) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+func (s *writerLogSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// httpLogSink posts the log entries to a log intake.
+type httpLogSink struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewHTTPLogSink returns a LogSink posting the log entries as JSON arrays to the
+// log intake at url, such as https://http-intake.logs.datadoghq.com/api/v2/logs, or
+// a Datadog Agent or proxy forwarding the logs to it. The API key is sent in the
+// DD-API-KEY header, if not empty.
+func NewHTTPLogSink(url, apiKey string) LogSink {
+	return &httpLogSink{
+		url:    url,
+		apiKey: apiKey,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *httpLogSink) Send(entries []LogEntry) error {
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("DD-API-KEY", s.apiKey)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("log intake responded with status %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *httpLogSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
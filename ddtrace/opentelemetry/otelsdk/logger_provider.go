@@ -0,0 +1,386 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package otelsdk
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	otelinternal "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/opentelemetry/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	lognoop "go.opentelemetry.io/otel/log/noop"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+var _ otellog.LoggerProvider = (*LoggerProvider)(nil)
+
+const (
+	// defaultLogFlushInterval is the default interval at which the log entries are
+	// sent to the sink.
+	defaultLogFlushInterval = time.Second
+
+	// logBatchSize is the number of buffered log entries which triggers a flush.
+	logBatchSize = 100
+
+	// maxPendingLogBatches is the number of full batches waiting to be sent, beyond
+	// which new batches are dropped.
+	maxPendingLogBatches = 10
+)
+
+// logConfig holds the configuration of a LoggerProvider.
+type logConfig struct {
+	sink          LogSink
+	resource      []attribute.KeyValue
+	flushInterval time.Duration
+}
+
+// LogOption configures a LoggerProvider.
+type LogOption func(*logConfig)
+
+// WithLogSink sets the sink the log entries are sent to. It defaults to the standard
+// output, see NewWriterLogSink.
+func WithLogSink(s LogSink) LogOption {
+	return func(cfg *logConfig) {
+		cfg.sink = s
+	}
+}
+
+// WithLogResource sets the resource attributes of the application. The service.name,
+// deployment.environment and service.version attributes are mapped to the service, env
+// and version of the log entries. By default, they are read from DD_SERVICE, DD_ENV and
+// DD_VERSION, then from OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES.
+func WithLogResource(attrs ...attribute.KeyValue) LogOption {
+	return func(cfg *logConfig) {
+		cfg.resource = append(cfg.resource, attrs...)
+	}
+}
+
+// WithLogFlushInterval sets the interval at which the buffered log entries are sent to
+// the sink. It defaults to 1 second. If d <= 0, the entries are only sent once there
+// are enough of them to fill a batch, or when the LoggerProvider is flushed.
+func WithLogFlushInterval(d time.Duration) LogOption {
+	return func(cfg *logConfig) {
+		cfg.flushInterval = d
+	}
+}
+
+// LoggerProvider implements the OpenTelemetry logs API. It converts the log records to
+// entries following the Datadog conventions, and sends them to a LogSink:
+//   - the body is the message, and the attributes are top-level attributes;
+//   - the severity is mapped to the status, e.g. SeverityWarn to "warning";
+//   - the service, env and version come from the resource, see WithLogResource;
+//   - the dd.trace_id and dd.span_id attributes correlate the entry with the active span
+//     of the context it is emitted with, be it a Datadog or an OpenTelemetry span, as
+//     with the log/slog and sirupsen/logrus integrations.
+//
+// The entries are buffered and sent in batches by a background goroutine, see
+// WithLogFlushInterval. Emitting a record never waits for the sink: if the sink can't
+// keep up, the batches are dropped and reported in the logs.
+type LoggerProvider struct {
+	lognoop.LoggerProvider // https://pkg.go.dev/go.opentelemetry.io/otel/log#hdr-API_Implementations
+
+	sink    LogSink
+	service string
+	ddtags  string
+
+	mu      sync.Mutex // guards below fields
+	entries []LogEntry // entries not sent yet
+	stopped bool       // whether Shutdown was called
+
+	batches chan []LogEntry // full batches, sent by the flush loop
+	dropped atomic.Uint32   // number of batches dropped since the last report
+
+	flushMu sync.Mutex     // serializes the calls to the sink
+	stop    chan struct{}  // closed on Shutdown
+	wg      sync.WaitGroup // waits for the flush loop
+}
+
+// NewLoggerProvider returns a LoggerProvider sending the log entries to the configured
+// sink. It should be shut down when the application exits, to flush the entries.
+//
+//	provider := otelsdk.NewLoggerProvider()
+//	defer provider.Shutdown(context.Background())
+//	global.SetLoggerProvider(provider)
+func NewLoggerProvider(opts ...LogOption) *LoggerProvider {
+	cfg := logConfig{flushInterval: defaultLogFlushInterval}
+	for _, fn := range opts {
+		fn(&cfg)
+	}
+	if cfg.sink == nil {
+		cfg.sink = NewWriterLogSink(os.Stdout)
+	}
+	values := otelinternal.ResourceValues(cfg.resource)
+	var tags []string
+	for _, tag := range []string{"env", "version"} {
+		if v, ok := values[tag]; ok {
+			tags = append(tags, tag+":"+v)
+		}
+	}
+	p := &LoggerProvider{
+		sink:    cfg.sink,
+		service: values["service"],
+		ddtags:  strings.Join(tags, ","),
+		batches: make(chan []LogEntry, maxPendingLogBatches),
+		stop:    make(chan struct{}),
+	}
+	p.wg.Add(1)
+	go p.flushLoop(cfg.flushInterval)
+	return p
+}
+
+// Logger returns a logger whose entries are named name. If the LoggerProvider has
+// already been shut down, it returns a no-op logger.
+func (p *LoggerProvider) Logger(name string, _ ...otellog.LoggerOption) otellog.Logger {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return lognoop.NewLoggerProvider().Logger(name)
+	}
+	return &logger{provider: p, name: name}
+}
+
+// ForceFlush sends the buffered log entries to the sink.
+func (p *LoggerProvider) ForceFlush(_ context.Context) error {
+	return p.flush()
+}
+
+// Shutdown sends the buffered log entries, then closes the sink. Subsequent calls are
+// valid but become no-op.
+func (p *LoggerProvider) Shutdown(_ context.Context) error {
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return nil
+	}
+	p.stopped = true
+	close(p.stop)
+	p.mu.Unlock()
+	p.wg.Wait()
+
+	return errors.Join(p.flush(), p.sink.Close())
+}
+
+// flushLoop sends the full batches as they come, and the buffered log entries every
+// interval, if interval > 0.
+func (p *LoggerProvider) flushLoop(interval time.Duration) {
+	defer p.wg.Done()
+	var tick <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		var err error
+		select {
+		case entries := <-p.batches:
+			err = p.send(entries)
+		case <-tick:
+			err = p.flush()
+		case <-p.stop:
+			return
+		}
+		if err != nil {
+			log.Error("Error sending OpenTelemetry logs: %v", err)
+		}
+	}
+}
+
+// flush sends the full batches not sent yet, then the buffered log entries to the sink.
+func (p *LoggerProvider) flush() error {
+	var errs []error
+drain:
+	for {
+		select {
+		case entries := <-p.batches:
+			errs = append(errs, p.send(entries))
+		default:
+			break drain
+		}
+	}
+	p.mu.Lock()
+	entries := p.entries
+	p.entries = nil
+	p.mu.Unlock()
+	errs = append(errs, p.send(entries))
+	return errors.Join(errs...)
+}
+
+// send sends the entries to the sink, and reports the batches dropped since the last
+// call.
+func (p *LoggerProvider) send(entries []LogEntry) error {
+	if n := p.dropped.Swap(0); n > 0 {
+		log.Warn("Dropped %d batches of OpenTelemetry logs, as the sink can't keep up", n)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	p.flushMu.Lock()
+	defer p.flushMu.Unlock()
+	return p.sink.Send(entries)
+}
+
+// add buffers the entry e, and hands the buffered entries to the flush loop once there
+// are enough of them. The batch is dropped if too many batches are waiting already.
+func (p *LoggerProvider) add(e LogEntry) {
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return
+	}
+	p.entries = append(p.entries, e)
+	var batch []LogEntry
+	if len(p.entries) >= logBatchSize {
+		batch = p.entries
+		p.entries = nil
+	}
+	p.mu.Unlock()
+	if batch == nil {
+		return
+	}
+	select {
+	case p.batches <- batch:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+// logger converts the records it emits to log entries.
+type logger struct {
+	lognoop.Logger // https://pkg.go.dev/go.opentelemetry.io/otel/log#hdr-API_Implementations
+	provider       *LoggerProvider
+	name           string
+}
+
+// Emit converts the record to a log entry, correlated with the span of ctx, if any.
+func (l *logger) Emit(ctx context.Context, r otellog.Record) {
+	l.provider.add(l.entry(ctx, r))
+}
+
+// Enabled reports whether the records are emitted, that is until the provider is shut
+// down.
+func (l *logger) Enabled(_ context.Context, _ otellog.Record) bool {
+	l.provider.mu.Lock()
+	defer l.provider.mu.Unlock()
+	return !l.provider.stopped
+}
+
+// entry returns the log entry of the record r emitted with ctx.
+func (l *logger) entry(ctx context.Context, r otellog.Record) LogEntry {
+	e := make(LogEntry, r.AttributesLen()+8)
+	r.WalkAttributes(func(kv otellog.KeyValue) bool {
+		e[kv.Key] = logValue(kv.Value)
+		return true
+	})
+	ts := r.Timestamp()
+	if ts.IsZero() {
+		ts = r.ObservedTimestamp()
+	}
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	e["timestamp"] = ts.UnixMilli()
+	if body := r.Body(); body.Kind() == otellog.KindString {
+		e["message"] = body.AsString()
+	} else if !body.Empty() {
+		e["message"] = body.String()
+	}
+	if status := logStatus(r.Severity(), r.SeverityText()); status != "" {
+		e["status"] = status
+	}
+	if l.name != "" {
+		e["logger.name"] = l.name
+	}
+	if l.provider.service != "" {
+		e["service"] = l.provider.service
+	}
+	if l.provider.ddtags != "" {
+		e["ddtags"] = l.provider.ddtags
+	}
+	if traceID, spanID, ok := spanIDs(ctx); ok {
+		e[ext.LogKeyTraceID] = traceID
+		e[ext.LogKeySpanID] = spanID
+	}
+	return e
+}
+
+// spanIDs returns the IDs of the Datadog or OpenTelemetry span of ctx.
+func spanIDs(ctx context.Context) (traceID, spanID uint64, ok bool) {
+	if ctx == nil {
+		return 0, 0, false
+	}
+	if s, ok := tracer.SpanFromContext(ctx); ok {
+		return s.Context().TraceID(), s.Context().SpanID(), true
+	}
+	sctx := oteltrace.SpanContextFromContext(ctx)
+	if !sctx.IsValid() {
+		return 0, 0, false
+	}
+	tid, sid := sctx.TraceID(), sctx.SpanID()
+	return binary.BigEndian.Uint64(tid[8:]), binary.BigEndian.Uint64(sid[:]), true
+}
+
+// logStatus maps the severity of a log record to the status of a log entry. When the
+// severity is not set, the severity text is used.
+func logStatus(s otellog.Severity, text string) string {
+	switch {
+	case s >= otellog.SeverityFatal1:
+		return "critical"
+	case s >= otellog.SeverityError1:
+		return "error"
+	case s >= otellog.SeverityWarn1:
+		return "warning"
+	case s >= otellog.SeverityInfo1:
+		return "info"
+	case s >= otellog.SeverityDebug1:
+		return "debug"
+	case s >= otellog.SeverityTrace1:
+		return "trace"
+	}
+	return strings.ToLower(text)
+}
+
+// logValue returns the Go value of v, suited to JSON encoding.
+func logValue(v otellog.Value) interface{} {
+	switch v.Kind() {
+	case otellog.KindBool:
+		return v.AsBool()
+	case otellog.KindFloat64:
+		return v.AsFloat64()
+	case otellog.KindInt64:
+		return v.AsInt64()
+	case otellog.KindString:
+		return v.AsString()
+	case otellog.KindBytes:
+		return v.AsBytes()
+	case otellog.KindSlice:
+		vs := v.AsSlice()
+		s := make([]interface{}, len(vs))
+		for i, v := range vs {
+			s[i] = logValue(v)
+		}
+		return s
+	case otellog.KindMap:
+		kvs := v.AsMap()
+		m := make(map[string]interface{}, len(kvs))
+		for _, kv := range kvs {
+			m[kv.Key] = logValue(kv.Value)
+		}
+		return m
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package otelsdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// testLogSink records the log entries it receives.
+type testLogSink struct {
+	mu      sync.Mutex
+	batches [][]LogEntry
+	closed  bool
+}
+
+func (s *testLogSink) Send(entries []LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, entries)
+	return nil
+}
+
+func (s *testLogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *testLogSink) entries() []LogEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []LogEntry
+	for _, b := range s.batches {
+		entries = append(entries, b...)
+	}
+	return entries
+}
+
+func newTestLoggerProvider(t *testing.T, opts ...LogOption) (*LoggerProvider, *testLogSink) {
+	t.Setenv("DD_SERVICE", "svc")
+	t.Setenv("DD_ENV", "prod")
+	t.Setenv("DD_VERSION", "1.2")
+	sink := &testLogSink{}
+	opts = append([]LogOption{WithLogSink(sink), WithLogFlushInterval(0)}, opts...)
+	p := NewLoggerProvider(opts...)
+	t.Cleanup(func() { p.Shutdown(context.Background()) })
+	return p, sink
+}
+
+func newRecord(severity otellog.Severity, body string, attrs ...otellog.KeyValue) otellog.Record {
+	var r otellog.Record
+	r.SetTimestamp(time.UnixMilli(1700000000000))
+	r.SetSeverity(severity)
+	r.SetBody(otellog.StringValue(body))
+	r.AddAttributes(attrs...)
+	return r
+}
+
+func TestLoggerEntry(t *testing.T) {
+	p, sink := newTestLoggerProvider(t)
+	l := p.Logger("app")
+	l.Emit(context.Background(), newRecord(otellog.SeverityWarn, "disk almost full",
+		otellog.Int("usage", 95),
+		otellog.Map("disk", otellog.String("name", "sda"), otellog.Bool("ssd", true)),
+	))
+	require.NoError(t, p.ForceFlush(context.Background()))
+
+	assert.Equal(t, []LogEntry{{
+		"timestamp":   int64(1700000000000),
+		"message":     "disk almost full",
+		"status":      "warning",
+		"logger.name": "app",
+		"service":     "svc",
+		"ddtags":      "env:prod,version:1.2",
+		"usage":       int64(95),
+		"disk":        map[string]interface{}{"name": "sda", "ssd": true},
+	}}, sink.entries())
+}
+
+func TestLoggerCorrelation(t *testing.T) {
+	t.Run("datadog", func(t *testing.T) {
+		mt := mocktracer.Start()
+		defer mt.Stop()
+		p, sink := newTestLoggerProvider(t)
+		s, ctx := tracer.StartSpanFromContext(context.Background(), "op")
+		defer s.Finish()
+
+		p.Logger("").Emit(ctx, newRecord(otellog.SeverityInfo, "msg"))
+		require.NoError(t, p.ForceFlush(ctx))
+		entries := sink.entries()
+		require.Len(t, entries, 1)
+		assert.Equal(t, s.Context().TraceID(), entries[0]["dd.trace_id"])
+		assert.Equal(t, s.Context().SpanID(), entries[0]["dd.span_id"])
+	})
+
+	t.Run("opentelemetry", func(t *testing.T) {
+		p, sink := newTestLoggerProvider(t)
+		sctx := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+			TraceID: oteltrace.TraceID{0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 2},
+			SpanID:  oteltrace.SpanID{0, 0, 0, 0, 0, 0, 0, 3},
+		})
+		ctx := oteltrace.ContextWithSpanContext(context.Background(), sctx)
+
+		p.Logger("").Emit(ctx, newRecord(otellog.SeverityInfo, "msg"))
+		require.NoError(t, p.ForceFlush(ctx))
+		entries := sink.entries()
+		require.Len(t, entries, 1)
+		assert.Equal(t, uint64(2), entries[0]["dd.trace_id"])
+		assert.Equal(t, uint64(3), entries[0]["dd.span_id"])
+	})
+
+	t.Run("none", func(t *testing.T) {
+		p, sink := newTestLoggerProvider(t)
+		p.Logger("").Emit(context.Background(), newRecord(otellog.SeverityInfo, "msg"))
+		require.NoError(t, p.ForceFlush(context.Background()))
+		entries := sink.entries()
+		require.Len(t, entries, 1)
+		assert.NotContains(t, entries[0], "dd.trace_id")
+		assert.NotContains(t, entries[0], "dd.span_id")
+	})
+}
+
+func TestLogStatus(t *testing.T) {
+	for _, tt := range []struct {
+		severity otellog.Severity
+		text     string
+		status   string
+	}{
+		{otellog.SeverityTrace2, "", "trace"},
+		{otellog.SeverityDebug, "", "debug"},
+		{otellog.SeverityInfo4, "", "info"},
+		{otellog.SeverityWarn, "", "warning"},
+		{otellog.SeverityError3, "ignored", "error"},
+		{otellog.SeverityFatal, "", "critical"},
+		{otellog.SeverityUndefined, "NOTICE", "notice"},
+		{otellog.SeverityUndefined, "", ""},
+	} {
+		assert.Equal(t, tt.status, logStatus(tt.severity, tt.text), "%v %q", tt.severity, tt.text)
+	}
+}
+
+func TestLoggerResource(t *testing.T) {
+	p, sink := newTestLoggerProvider(t, WithLogResource(
+		attribute.String("service.name", "res-svc"),
+		attribute.String("deployment.environment", "dev"),
+	))
+	p.Logger("").Emit(context.Background(), newRecord(otellog.SeverityInfo, "msg"))
+	require.NoError(t, p.ForceFlush(context.Background()))
+	entries := sink.entries()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "res-svc", entries[0]["service"])
+	assert.Equal(t, "env:dev,version:1.2", entries[0]["ddtags"])
+}
+
+func TestLoggerBatching(t *testing.T) {
+	p, sink := newTestLoggerProvider(t)
+	l := p.Logger("")
+	for i := 0; i < logBatchSize+1; i++ {
+		l.Emit(context.Background(), newRecord(otellog.SeverityInfo, "msg"))
+	}
+	// the full batch is sent by the flush loop.
+	assert.Eventually(t, func() bool { return len(sink.entries()) == logBatchSize }, 5*time.Second, time.Millisecond)
+	sink.mu.Lock()
+	assert.Len(t, sink.batches, 1)
+	sink.mu.Unlock()
+
+	require.NoError(t, p.Shutdown(context.Background()))
+	assert.Len(t, sink.entries(), logBatchSize+1)
+	assert.True(t, sink.closed)
+
+	// the logger is disabled once the provider is shut down.
+	assert.False(t, l.Enabled(context.Background(), otellog.Record{}))
+	l.Emit(context.Background(), newRecord(otellog.SeverityInfo, "msg"))
+	p.Logger("").Emit(context.Background(), newRecord(otellog.SeverityInfo, "msg"))
+	assert.Len(t, sink.entries(), logBatchSize+1)
+	assert.NoError(t, p.Shutdown(context.Background()))
+}
+
+// blockingLogSink blocks the calls to Send until unblock is closed.
+type blockingLogSink struct {
+	testLogSink
+	unblock chan struct{}
+}
+
+func (s *blockingLogSink) Send(entries []LogEntry) error {
+	<-s.unblock
+	return s.testLogSink.Send(entries)
+}
+
+func TestLoggerDroppedBatches(t *testing.T) {
+	testLog := new(log.RecordLogger)
+	defer log.UseLogger(testLog)()
+	sink := &blockingLogSink{unblock: make(chan struct{})}
+	p := NewLoggerProvider(WithLogSink(sink), WithLogFlushInterval(0))
+	l := p.Logger("")
+	// the flush loop blocks on at most one batch, and maxPendingLogBatches batches
+	// wait to be sent: the other ones are dropped.
+	for i := 0; i < (maxPendingLogBatches+3)*logBatchSize; i++ {
+		l.Emit(context.Background(), newRecord(otellog.SeverityInfo, "msg"))
+	}
+	close(sink.unblock)
+	require.NoError(t, p.Shutdown(context.Background()))
+	assert.Zero(t, p.dropped.Load())
+	require.NotEmpty(t, testLog.Logs())
+	assert.Contains(t, testLog.Logs()[0], "batches of OpenTelemetry logs, as the sink can't keep up")
+	assert.Less(t, len(sink.entries()), (maxPendingLogBatches+3)*logBatchSize)
+	assert.GreaterOrEqual(t, len(sink.entries()), maxPendingLogBatches*logBatchSize)
+}
+
+func TestLogSinks(t *testing.T) {
+	entries := []LogEntry{{"message": "a <b>"}, {"message": "c", "status": "error"}}
+
+	t.Run("writer", func(t *testing.T) {
+		var buf bytes.Buffer
+		s := NewWriterLogSink(&buf)
+		require.NoError(t, s.Send(entries))
+		require.NoError(t, s.Close())
+		assert.Equal(t, "{\"message\":\"a \\u003cb\\u003e\"}\n{\"message\":\"c\",\"status\":\"error\"}\n", buf.String())
+	})
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+		s, err := NewFileLogSink(path)
+		require.NoError(t, err)
+		require.NoError(t, s.Send(entries[:1]))
+		require.NoError(t, s.Send(entries[1:]))
+		require.NoError(t, s.Close())
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, 2, bytes.Count(b, []byte("\n")))
+	})
+
+	t.Run("http", func(t *testing.T) {
+		var (
+			got    []LogEntry
+			apiKey string
+		)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiKey = r.Header.Get("DD-API-KEY")
+			b, _ := io.ReadAll(r.Body)
+			json.Unmarshal(b, &got)
+		}))
+		defer srv.Close()
+		s := NewHTTPLogSink(srv.URL, "key")
+		require.NoError(t, s.Send(entries))
+		require.NoError(t, s.Close())
+		assert.Equal(t, "key", apiKey)
+		assert.Equal(t, entries, got)
+	})
+
+	t.Run("http-error", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		defer srv.Close()
+		err := NewHTTPLogSink(srv.URL, "").Send(entries)
+		assert.ErrorContains(t, err, "403")
+	})
+}
@@ -0,0 +1,174 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package otelsdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	otelinternal "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/opentelemetry/internal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// The tests below check that the spans of opentelemetry.TracerProvider behave like the
+// spans of the OpenTelemetry SDK, by running the same operations on both.
+
+type customError struct{}
+
+func (customError) Error() string { return "custom" }
+
+// sdkSpan runs f on a span of the OpenTelemetry SDK, and returns the span once ended.
+func sdkSpan(t *testing.T, f func(oteltrace.Span)) sdktrace.ReadOnlySpan {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	defer tp.Shutdown(context.Background())
+	_, sp := tp.Tracer("").Start(context.Background(), "sdk")
+	f(sp)
+	sp.End()
+	ended := rec.Ended()
+	require.Len(t, ended, 1)
+	return ended[0]
+}
+
+// ddSpan runs f on a span of opentelemetry.TracerProvider, and returns the Datadog
+// span once ended, as recorded by the mock tracer.
+func ddSpan(t *testing.T, f func(oteltrace.Span)) mocktracer.SpanWithDetails {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	_, sp := otelinternal.Tracer().Start(context.Background(), "dd")
+	f(sp)
+	sp.End()
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 1)
+	return spans[0].(mocktracer.SpanWithDetails)
+}
+
+// spanEvent is a span event, as encoded in the events tag of the Datadog spans.
+type spanEvent struct {
+	Name       string                 `json:"name"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+func TestSpanRecordErrorConformance(t *testing.T) {
+	for name, f := range map[string]func(oteltrace.Span){
+		"errors.New":  func(sp oteltrace.Span) { sp.RecordError(errors.New("boom")) },
+		"value-type":  func(sp oteltrace.Span) { sp.RecordError(customError{}) },
+		"pointer":     func(sp oteltrace.Span) { sp.RecordError(&fs.PathError{Op: "open", Path: "/x", Err: fs.ErrNotExist}) },
+		"nil":         func(sp oteltrace.Span) { sp.RecordError(nil) },
+		"stack-trace": func(sp oteltrace.Span) { sp.RecordError(errors.New("boom"), oteltrace.WithStackTrace(true)) },
+		"attributes": func(sp oteltrace.Span) {
+			sp.RecordError(errors.New("boom"), oteltrace.WithAttributes(attribute.String("key", "val")))
+		},
+		"after-end": func(sp oteltrace.Span) {
+			sp.End()
+			sp.RecordError(errors.New("boom"))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			want := sdkSpan(t, f)
+			got := ddSpan(t, f)
+			var events []spanEvent
+			if v, ok := got.Tag("events").(string); ok {
+				require.NoError(t, json.Unmarshal([]byte(v), &events))
+			}
+			require.Len(t, events, len(want.Events()))
+			for i, we := range want.Events() {
+				ge := events[i]
+				assert.Equal(t, we.Name, ge.Name)
+				require.Len(t, ge.Attributes, len(we.Attributes))
+				for _, kv := range we.Attributes {
+					if kv.Key == "exception.stacktrace" {
+						assert.Contains(t, ge.Attributes[string(kv.Key)], "TestSpanRecordErrorConformance")
+						continue
+					}
+					assert.EqualValues(t, kv.Value.AsInterface(), ge.Attributes[string(kv.Key)], kv.Key)
+				}
+			}
+			// recording an error doesn't change the status.
+			assert.Equal(t, want.Status().Code == codes.Error, got.Tag(ext.Error) != nil)
+		})
+	}
+}
+
+func TestSpanSetStatusConformance(t *testing.T) {
+	type status struct {
+		code codes.Code
+		desc string
+	}
+	for name, calls := range map[string][]status{
+		"unset":              {{codes.Unset, "ignored"}},
+		"error":              {{codes.Error, "a"}},
+		"error-then-error":   {{codes.Error, "a"}, {codes.Error, "b"}},
+		"error-then-unset":   {{codes.Error, "a"}, {codes.Unset, "b"}},
+		"ok-description":     {{codes.Ok, "ignored"}},
+		"ok-is-final":        {{codes.Ok, ""}, {codes.Error, "a"}, {codes.Unset, ""}},
+		"error-then-ok":      {{codes.Error, "a"}, {codes.Ok, "b"}},
+		"unset-then-error":   {{codes.Unset, ""}, {codes.Error, "a"}},
+		"ok-then-ok":         {{codes.Ok, "a"}, {codes.Ok, "b"}},
+		"error-empty-detail": {{codes.Error, ""}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f := func(sp oteltrace.Span) {
+				for _, c := range calls {
+					sp.SetStatus(c.code, c.desc)
+				}
+			}
+			want := sdkSpan(t, f)
+			got := ddSpan(t, f)
+			// only the error status is reported by the Datadog spans, along with
+			// its description.
+			if want.Status().Code != codes.Error {
+				assert.Nil(t, got.Tag(ext.Error))
+				return
+			}
+			assert.NotNil(t, got.Tag(ext.Error))
+			msg, _ := got.Tag(ext.ErrorMsg).(string)
+			assert.Equal(t, want.Status().Description, msg)
+		})
+	}
+}
+
+func TestSpanAddLinkConformance(t *testing.T) {
+	valid := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID: oteltrace.TraceID{1},
+		SpanID:  oteltrace.SpanID{2},
+	})
+	for name, f := range map[string]func(oteltrace.Span){
+		"valid": func(sp oteltrace.Span) {
+			sp.AddLink(oteltrace.Link{SpanContext: valid, Attributes: []attribute.KeyValue{attribute.String("k", "v")}})
+		},
+		"empty": func(sp oteltrace.Span) { sp.AddLink(oteltrace.Link{}) },
+		"attr-only": func(sp oteltrace.Span) {
+			sp.AddLink(oteltrace.Link{Attributes: []attribute.KeyValue{attribute.Int("n", 1)}})
+		},
+		"multiple": func(sp oteltrace.Span) {
+			sp.AddLink(oteltrace.Link{SpanContext: valid})
+			sp.AddLink(oteltrace.Link{SpanContext: valid})
+		},
+		"after-end": func(sp oteltrace.Span) {
+			sp.End()
+			sp.AddLink(oteltrace.Link{SpanContext: valid})
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			want := sdkSpan(t, f)
+			got := ddSpan(t, f)
+			assert.Len(t, got.Links(), len(want.Links()))
+		})
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -90,7 +92,8 @@ func (s *span) End(options ...oteltrace.SpanEndOption) {
 	}
 	var finishCfg = oteltrace.NewSpanEndConfig(options...)
 	var opts []tracer.FinishOption
-	if s.statusInfo.code == otelcodes.Error {
+	if s.statusInfo.code == otelcodes.Error && !s.setExceptionErrorTags() {
+		// without exception event, the error is described by the status.
 		s.DD.SetTag(ext.ErrorMsg, s.statusInfo.description)
 		opts = append(opts, tracer.WithError(errors.New(s.statusInfo.description)))
 	}
@@ -103,6 +106,32 @@ func (s *span) End(options ...oteltrace.SpanEndOption) {
 	s.DD.Finish(opts...)
 }
 
+// setExceptionErrorTags marks the span as an error described by the last exception event
+// recorded with RecordError, falling back to the status description for the message. It
+// reports whether there is such an event.
+func (s *span) setExceptionErrorTags() bool {
+	for i := len(s.events) - 1; i >= 0; i-- {
+		e := s.events[i]
+		if e.Name != exceptionEventName {
+			continue
+		}
+		s.DD.SetTag(ext.Error, true)
+		msg := s.statusInfo.description
+		if v, ok := e.Attributes[exceptionMessageKey].(string); ok && v != "" {
+			msg = v
+		}
+		s.DD.SetTag(ext.ErrorMsg, msg)
+		if v, ok := e.Attributes[exceptionTypeKey].(string); ok && v != "" {
+			s.DD.SetTag(ext.ErrorType, v)
+		}
+		if v, ok := e.Attributes[exceptionStacktraceKey].(string); ok && v != "" {
+			s.DD.SetTag(ext.ErrorStack, v)
+		}
+		return true
+	}
+	return false
+}
+
 // EndOptions sets tracer.FinishOption on a given span to be executed when span is finished.
 func EndOptions(sp oteltrace.Span, options ...tracer.FinishOption) {
 	s, ok := sp.(*span)
@@ -166,6 +195,8 @@ func uint64ToByte(n uint64, b []byte) {
 // IsRecording returns the recording state of the Span. It will return
 // true if the Span is active and events can be recorded.
 func (s *span) IsRecording() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return !s.finished
 }
 
@@ -178,18 +209,29 @@ type statusInfo struct {
 // whether the span has recorded errors. This will be done by setting
 // `error.message` tag on the span. If the code has been set to a higher
 // value before (OK > Error > Unset), the code will not be changed.
+// The description is only kept with the Error code.
 // The code and description are set once when the span is finished.
 func (s *span) SetStatus(code otelcodes.Code, description string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if code >= s.statusInfo.code {
-		s.statusInfo = statusInfo{code, description}
+	if s.finished || code < s.statusInfo.code {
+		return
+	}
+	s.statusInfo = statusInfo{code: code}
+	if code == otelcodes.Error {
+		s.statusInfo.description = description
 	}
 }
 
 // AddEvent adds a span event onto the span with the provided name and EventOptions
 func (s *span) AddEvent(name string, opts ...oteltrace.EventOption) {
-	if !s.IsRecording() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addEvent(name, opts...)
+}
+
+func (s *span) addEvent(name string, opts ...oteltrace.EventOption) {
+	if s.finished {
 		return
 	}
 	c := oteltrace.NewEventConfig(opts...)
@@ -205,6 +247,66 @@ func (s *span) AddEvent(name string, opts ...oteltrace.EventOption) {
 	s.events = append(s.events, e)
 }
 
+// Semantic conventions of the exception events, see
+// https://opentelemetry.io/docs/specs/semconv/exceptions/exceptions-spans/
+const (
+	exceptionEventName     = "exception"
+	exceptionTypeKey       = "exception.type"
+	exceptionMessageKey    = "exception.message"
+	exceptionStacktraceKey = "exception.stacktrace"
+)
+
+// RecordError records err as an exception span event, as the OpenTelemetry SDK does:
+// the event holds the type and message of err, and its stack trace when the
+// oteltrace.WithStackTrace option is set. It doesn't change the status of the span, but
+// when the status is set to Error, the last exception event provides the error.message,
+// error.type and error.stack tags of the span. Without exception event, they describe an
+// error whose message is the status description, as tracer.WithError does.
+func (s *span) RecordError(err error, opts ...oteltrace.EventOption) {
+	if err == nil {
+		return
+	}
+	opts = append(opts, oteltrace.WithAttributes(
+		attribute.String(exceptionTypeKey, errorType(err)),
+		attribute.String(exceptionMessageKey, err.Error()),
+	))
+	if c := oteltrace.NewEventConfig(opts...); c.StackTrace() {
+		stack := make([]byte, 2048)
+		n := runtime.Stack(stack, false)
+		opts = append(opts, oteltrace.WithAttributes(attribute.String(exceptionStacktraceKey, string(stack[:n]))))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addEvent(exceptionEventName, opts...)
+}
+
+// errorType returns the type of err, qualified by its package path.
+func errorType(err error) string {
+	t := reflect.TypeOf(err)
+	if t.PkgPath() == "" && t.Name() == "" {
+		// e.g. a pointer type.
+		return t.String()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
+
+// AddLink adds a span link to the span, along with the ones set at start with
+// oteltrace.WithLinks. Links to invalid span contexts without attributes nor trace
+// state are ignored.
+func (s *span) AddLink(link oteltrace.Link) {
+	if !link.SpanContext.IsValid() && len(link.Attributes) == 0 && link.SpanContext.TraceState().Len() == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		return
+	}
+	if dd, ok := s.DD.(ddtrace.SpanWithLinks); ok {
+		dd.AddSpanLink(toSpanLinks([]oteltrace.Link{link})[0])
+	}
+}
+
 // SetAttributes sets the key-value pairs as tags on the span.
 // Every value is propagated as an interface.
 // Some attribute keys are reserved and will be remapped to Datadog reserved tags.
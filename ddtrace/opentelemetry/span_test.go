@@ -23,6 +23,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/httpmem"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/tinylib/msgp/msgp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -830,3 +831,91 @@ func TestRemapWithMultipleSetAttributes(t *testing.T) {
 	meta := fmt.Sprintf("%v", p[0]["meta"])
 	assert.Contains(meta, fmt.Sprintf("%s:%s", "http.status_code", "200"))
 }
+
+type customError struct{}
+
+func (customError) Error() string { return "custom" }
+
+func TestSpanAddLink(t *testing.T) {
+	assert := assert.New(t)
+	_, payloads, cleanup := mockTracerProvider(t)
+	defer cleanup()
+
+	linked := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID:    oteltrace.TraceID{0, 0, 0, 0, 0, 0, 0, 0xaa, 0, 0, 0, 0, 0, 0, 0, 1},
+		SpanID:     oteltrace.SpanID{0, 0, 0, 0, 0, 0, 0, 2},
+		TraceFlags: oteltrace.FlagsSampled,
+	})
+	_, sp := otel.Tracer("").Start(context.Background(), "span_with_link")
+	// AddLink is part of the Span interface of the newer versions of the OpenTelemetry API.
+	sp.(interface{ AddLink(oteltrace.Link) }).AddLink(oteltrace.Link{SpanContext: linked, Attributes: []attribute.KeyValue{attribute.String("link.name", "late")}})
+	sp.End()
+
+	tracer.Flush()
+	traces, err := waitForPayload(payloads)
+	require.NoError(t, err)
+	b, _ := json.Marshal(traces[0][0]["span_links"])
+	var links []ddtrace.SpanLink
+	require.NoError(t, json.Unmarshal(b, &links))
+	require.Len(t, links, 1)
+	assert.Equal(uint64(1), links[0].TraceID)
+	assert.Equal(uint64(0xaa), links[0].TraceIDHigh)
+	assert.Equal(uint64(2), links[0].SpanID)
+	assert.Equal(map[string]string{"link.name": "late"}, links[0].Attributes)
+	assert.Equal(uint32(1)|1<<31, links[0].Flags)
+}
+
+func TestSpanRecordErrorTags(t *testing.T) {
+	_, payloads, cleanup := mockTracerProvider(t)
+	defer cleanup()
+
+	t.Run("error-status", func(t *testing.T) {
+		_, sp := otel.Tracer("").Start(context.Background(), "op")
+		sp.RecordError(errors.New("first"))
+		sp.RecordError(customError{}, oteltrace.WithStackTrace(true))
+		sp.SetStatus(codes.Error, "description")
+		sp.End()
+
+		tracer.Flush()
+		traces, err := waitForPayload(payloads)
+		require.NoError(t, err)
+		s := traces[0][0]
+		assert.Equal(t, 1.0, s["error"])
+		meta := s["meta"].(map[string]interface{})
+		assert.Equal(t, "custom", meta["error.message"])
+		assert.Equal(t, "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/opentelemetry.customError", meta["error.type"])
+		assert.Contains(t, meta["error.stack"], "TestSpanRecordErrorTags")
+		assert.Contains(t, meta["events"], `"exception.message":"first"`)
+	})
+
+	t.Run("description", func(t *testing.T) {
+		_, sp := otel.Tracer("").Start(context.Background(), "op")
+		sp.SetStatus(codes.Error, "description")
+		sp.End()
+
+		tracer.Flush()
+		traces, err := waitForPayload(payloads)
+		require.NoError(t, err)
+		s := traces[0][0]
+		assert.Equal(t, 1.0, s["error"])
+		meta := s["meta"].(map[string]interface{})
+		assert.Equal(t, "description", meta["error.message"])
+		assert.Equal(t, "*errors.errorString", meta["error.type"])
+		assert.Contains(t, meta["error.stack"], "TestSpanRecordErrorTags")
+	})
+
+	t.Run("unset-status", func(t *testing.T) {
+		_, sp := otel.Tracer("").Start(context.Background(), "op")
+		sp.RecordError(errors.New("handled"))
+		sp.End()
+
+		tracer.Flush()
+		traces, err := waitForPayload(payloads)
+		require.NoError(t, err)
+		s := traces[0][0]
+		assert.Equal(t, 0.0, s["error"])
+		meta := s["meta"].(map[string]interface{})
+		assert.NotContains(t, meta, "error.message")
+		assert.Contains(t, meta["events"], `"name":"exception"`)
+	})
+}
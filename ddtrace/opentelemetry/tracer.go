@@ -64,26 +64,7 @@ func (t *oteltracer) Start(ctx context.Context, spanName string, opts ...oteltra
 	}
 	// Add provide OTel Span Links to the underlying Datadog span.
 	if len(ssConfig.Links()) > 0 {
-		links := make([]ddtrace.SpanLink, 0, len(ssConfig.Links()))
-		for _, link := range ssConfig.Links() {
-			ctx := otelCtxToDDCtx{link.SpanContext}
-			attrs := make(map[string]string, len(link.Attributes))
-			for _, attr := range link.Attributes {
-				attrs[string(attr.Key)] = attr.Value.Emit()
-			}
-			links = append(links, ddtrace.SpanLink{
-				TraceID:     ctx.TraceID(),
-				TraceIDHigh: ctx.TraceIDUpper(),
-				SpanID:      ctx.SpanID(),
-				Tracestate:  link.SpanContext.TraceState().String(),
-				Attributes:  attrs,
-				// To distinguish between "not sampled" and "not set", Datadog
-				// will rely on the highest bit being set. The OTel API doesn't
-				// differentiate this, so we will just always mark it as set.
-				Flags: uint32(link.SpanContext.TraceFlags()) | (1 << 31),
-			})
-		}
-		ddopts = append(ddopts, tracer.WithSpanLinks(links))
+		ddopts = append(ddopts, tracer.WithSpanLinks(toSpanLinks(ssConfig.Links())))
 	}
 	// Since there is no way to see if and how the span operation name was set,
 	// we have to record the attributes  locally.
@@ -102,6 +83,30 @@ func (t *oteltracer) Start(ctx context.Context, spanName string, opts ...oteltra
 	return ctx, os
 }
 
+// toSpanLinks converts OTel Span Links to Datadog span links.
+func toSpanLinks(otelLinks []oteltrace.Link) []ddtrace.SpanLink {
+	links := make([]ddtrace.SpanLink, 0, len(otelLinks))
+	for _, link := range otelLinks {
+		ctx := otelCtxToDDCtx{link.SpanContext}
+		attrs := make(map[string]string, len(link.Attributes))
+		for _, attr := range link.Attributes {
+			attrs[string(attr.Key)] = attr.Value.Emit()
+		}
+		links = append(links, ddtrace.SpanLink{
+			TraceID:     ctx.TraceID(),
+			TraceIDHigh: ctx.TraceIDUpper(),
+			SpanID:      ctx.SpanID(),
+			Tracestate:  link.SpanContext.TraceState().String(),
+			Attributes:  attrs,
+			// To distinguish between "not sampled" and "not set", Datadog
+			// will rely on the highest bit being set. The OTel API doesn't
+			// differentiate this, so we will just always mark it as set.
+			Flags: uint32(link.SpanContext.TraceFlags()) | (1 << 31),
+		})
+	}
+	return links
+}
+
 type otelCtxToDDCtx struct {
 	oc oteltrace.SpanContext
 }
@@ -26,6 +26,9 @@
 // the OpenTelemetry Tracing API (https://opentelemetry.io/docs/reference/specification/trace/api)
 // to allow users to send traces to Datadog using existing OpenTelemetry code with minimal changes to the application.
 // Span events (https://opentelemetry.io/docs/concepts/signals/traces/#span-events) are not supported at this time.
+//
+// The package also implements the OpenTelemetry metrics API: see NewMeterProvider, which sends the
+// measurements to DogStatsD. The otelsdk module provides the support for the OpenTelemetry logs API and SDK.
 package opentelemetry
 
 import (
@@ -34,6 +37,7 @@ import (
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	otelinternal "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/opentelemetry/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 
@@ -43,6 +47,12 @@ import (
 
 var _ oteltrace.TracerProvider = (*TracerProvider)(nil)
 
+func init() {
+	otelinternal.Tracer = func() oteltrace.Tracer {
+		return &oteltracer{DD: internal.GetGlobalTracer()}
+	}
+}
+
 // TracerProvider provides implementation of OpenTelemetry TracerProvider interface.
 // TracerProvider provides Tracers that are used by instrumentation code to
 // trace computational workflows.
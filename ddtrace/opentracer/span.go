@@ -6,14 +6,18 @@
 package opentracer
 
 import (
+	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 
 	opentracing "github.com/opentracing/opentracing-go"
-	"github.com/opentracing/opentracing-go/log"
+	otlog "github.com/opentracing/opentracing-go/log"
 )
 
 var _ opentracing.Span = (*span)(nil)
@@ -22,33 +26,113 @@ var _ opentracing.Span = (*span)(nil)
 type span struct {
 	ddtrace.Span
 	*opentracer
+
+	mu     sync.Mutex  // guards events
+	events []spanEvent // events logged on the span
+}
+
+// spanEvent holds a set of fields logged on the span. It is encoded like the span
+// events of the OpenTelemetry API.
+type spanEvent struct {
+	Name         string                 `json:"name"`
+	TimeUnixNano int64                  `json:"time_unix_nano"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
 }
 
 func (s *span) Context() opentracing.SpanContext            { return s.Span.Context() }
-func (s *span) Finish()                                     { s.Span.Finish() }
 func (s *span) Tracer() opentracing.Tracer                  { return s.opentracer }
 func (s *span) LogEvent(_ string)                           { /* deprecated */ }
 func (s *span) LogEventWithPayload(_ string, _ interface{}) { /* deprecated */ }
 func (s *span) Log(_ opentracing.LogData)                   { /* deprecated */ }
 
+func (s *span) Finish() {
+	s.setEvents()
+	s.Span.Finish()
+}
+
 func (s *span) FinishWithOptions(opts opentracing.FinishOptions) {
 	for _, lr := range opts.LogRecords {
 		if len(lr.Fields) > 0 {
-			s.LogFields(lr.Fields...)
+			s.logFields(lr.Timestamp, lr.Fields)
 		}
 	}
+	s.setEvents()
 	s.Span.Finish(tracer.FinishTime(opts.FinishTime))
 }
 
-func (s *span) LogFields(fields ...log.Field) {
-	// catch standard opentracing keys and adjust to internal ones as per spec:
-	// https://github.com/opentracing/specification/blob/master/semantic_conventions.md#log-fields-table
+// setEvents sets the events logged on the span as its "events" tag.
+func (s *span) setEvents() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.events) == 0 {
+		return
+	}
+	b, err := json.Marshal(s.events)
+	if err != nil {
+		log.Debug("opentracer: unable to marshal span events, dropping them: %v", err)
+		return
+	}
+	s.Span.SetTag("events", string(b))
+}
+
+func (s *span) LogFields(fields ...otlog.Field) {
+	s.logFields(time.Now(), fields)
+}
+
+// logFields records the fields as a span event logged at time t, or now if t is zero.
+// The event is named after the "event" field, and the other fields are its attributes.
+func (s *span) logFields(t time.Time, fields []otlog.Field) {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	e := spanEvent{
+		Name:         "log",
+		TimeUnixNano: t.UnixNano(),
+		Attributes:   make(map[string]interface{}, len(fields)),
+	}
+	for _, f := range fields {
+		switch v := f.Value().(type) {
+		case string:
+			if f.Key() == "event" {
+				e.Name = v
+				continue
+			}
+			e.Attributes[f.Key()] = v
+		case error:
+			e.Attributes[f.Key()] = v.Error()
+		default:
+			e.Attributes[f.Key()] = v
+		}
+	}
+	if len(e.Attributes) == 0 {
+		e.Attributes = nil
+	}
+	s.setErrorTags(fields)
+	s.mu.Lock()
+	s.events = append(s.events, e)
+	s.mu.Unlock()
+}
+
+// setErrorTags marks the span as errored when the fields describe an error, following
+// the standard opentracing keys as per spec:
+// https://github.com/opentracing/specification/blob/master/semantic_conventions.md#log-fields-table
+func (s *span) setErrorTags(fields []otlog.Field) {
+	var isError bool
 	for _, f := range fields {
 		switch f.Key() {
 		case "event":
-			if v, ok := f.Value().(string); ok && v == "error" {
-				s.SetTag("error", true)
-			}
+			isError = isError || f.Value() == "error"
+		case "error", "error.object":
+			_, ok := f.Value().(error)
+			isError = isError || ok
+		}
+	}
+	if !isError {
+		return
+	}
+	s.SetTag("error", true)
+	for _, f := range fields {
+		switch f.Key() {
 		case "error", "error.object":
 			if err, ok := f.Value().(error); ok {
 				s.SetTag("error", err)
@@ -57,14 +141,12 @@ func (s *span) LogFields(fields ...log.Field) {
 			s.SetTag(ext.ErrorMsg, fmt.Sprint(f.Value()))
 		case "stack":
 			s.SetTag(ext.ErrorStack, fmt.Sprint(f.Value()))
-		default:
-			// not implemented
 		}
 	}
 }
 
 func (s *span) LogKV(keyVals ...interface{}) {
-	fields, err := log.InterleavedKVToFields(keyVals...)
+	fields, err := otlog.InterleavedKVToFields(keyVals...)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,81 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package opentracer
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+
+	"github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpanLogFields(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	ot := &opentracer{internal.GetGlobalTracer()}
+
+	before := time.Now()
+	s := ot.StartSpan("op")
+	s.LogFields(otlog.String("event", "cache.miss"), otlog.String("key", "user:1"), otlog.Int("size", 3))
+	s.LogKV("message", "retrying")
+	ts := time.Unix(1700000000, 0)
+	s.FinishWithOptions(opentracing.FinishOptions{
+		LogRecords: []opentracing.LogRecord{{Timestamp: ts, Fields: []otlog.Field{otlog.String("event", "done")}}},
+	})
+
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 1)
+	// logging fields doesn't overwrite the tags of the span.
+	assert.Nil(t, spans[0].Tag(ext.ErrorMsg))
+	assert.Nil(t, spans[0].Tag(ext.Error))
+
+	var events []spanEvent
+	require.NoError(t, json.Unmarshal([]byte(spans[0].Tag("events").(string)), &events))
+	require.Len(t, events, 3)
+	assert.Equal(t, "cache.miss", events[0].Name)
+	assert.Equal(t, map[string]interface{}{"key": "user:1", "size": 3.0}, events[0].Attributes)
+	assert.GreaterOrEqual(t, events[0].TimeUnixNano, before.UnixNano())
+	assert.Equal(t, "log", events[1].Name)
+	assert.Equal(t, map[string]interface{}{"message": "retrying"}, events[1].Attributes)
+	assert.Equal(t, "done", events[2].Name)
+	assert.Nil(t, events[2].Attributes)
+	assert.Equal(t, ts.UnixNano(), events[2].TimeUnixNano)
+}
+
+func TestSpanLogError(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	ot := &opentracer{internal.GetGlobalTracer()}
+
+	s := ot.StartSpan("op")
+	s.LogFields(
+		otlog.String("event", "error"),
+		otlog.Error(errors.New("boom")),
+		otlog.String("message", "request failed"),
+		otlog.String("stack", "main.go:12"),
+	)
+	s.Finish()
+
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 1)
+	assert.NotNil(t, spans[0].Tag(ext.Error))
+	assert.Equal(t, "request failed", spans[0].Tag(ext.ErrorMsg))
+	assert.Equal(t, "main.go:12", spans[0].Tag(ext.ErrorStack))
+	var events []spanEvent
+	require.NoError(t, json.Unmarshal([]byte(spans[0].Tag("events").(string)), &events))
+	require.Len(t, events, 1)
+	assert.Equal(t, "error", events[0].Name)
+	assert.Equal(t, "boom", events[0].Attributes["error.object"])
+}
@@ -26,6 +26,7 @@ package opentracer
 
 import (
 	"context"
+	"encoding/binary"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
@@ -56,13 +57,12 @@ func (t *opentracer) StartSpan(operationName string, options ...opentracing.Star
 		o.Apply(&sso)
 	}
 	opts := []ddtrace.StartSpanOption{tracer.StartTime(sso.StartTime)}
-	for _, ref := range sso.References {
-		if v, ok := ref.ReferencedContext.(ddtrace.SpanContext); ok {
-			// opentracing.ChildOfRef and opentracing.FollowsFromRef will both be represented as
-			// children because Datadog APM does not have a concept of FollowsFrom references.
-			opts = append(opts, tracer.ChildOf(v))
-			break // can only have one parent
-		}
+	parent, links := references(sso.References)
+	if parent != nil {
+		opts = append(opts, tracer.ChildOf(parent))
+	}
+	if len(links) > 0 {
+		opts = append(opts, tracer.WithSpanLinks(links))
 	}
 	for k, v := range sso.Tags {
 		opts = append(opts, tracer.Tag(k, v))
@@ -74,6 +74,68 @@ func (t *opentracer) StartSpan(operationName string, options ...opentracing.Star
 	}
 }
 
+// references returns the parent of a span started with the given references, along
+// with the span links representing the other references. Datadog spans can only have
+// one parent: it is the first ChildOf reference, or the first FollowsFrom reference
+// if there is none, so that the span stays in the trace it was started from. All the
+// other references, as well as the FollowsFrom reference used as parent, are recorded
+// as span links since Datadog APM does not have a concept of FollowsFrom references.
+func references(refs []opentracing.SpanReference) (parent ddtrace.SpanContext, links []ddtrace.SpanLink) {
+	var ctxs []ddtrace.SpanContext
+	var types []opentracing.SpanReferenceType
+	for _, ref := range refs {
+		v, ok := ref.ReferencedContext.(ddtrace.SpanContext)
+		if !ok {
+			continue
+		}
+		ctxs = append(ctxs, v)
+		types = append(types, ref.Type)
+	}
+	p := -1
+	for i, typ := range types {
+		if typ == opentracing.ChildOfRef {
+			p = i
+			break
+		}
+	}
+	if p == -1 && len(ctxs) > 0 {
+		p = 0
+	}
+	for i, ctx := range ctxs {
+		if i == p {
+			parent = ctx
+			if types[i] == opentracing.ChildOfRef {
+				continue
+			}
+		}
+		links = append(links, spanLink(ctx, types[i]))
+	}
+	return parent, links
+}
+
+// spanLink returns a span link to ctx, recording the type of the reference.
+func spanLink(ctx ddtrace.SpanContext, typ opentracing.SpanReferenceType) ddtrace.SpanLink {
+	link := ddtrace.SpanLink{
+		TraceID:    ctx.TraceID(),
+		SpanID:     ctx.SpanID(),
+		Attributes: map[string]string{"opentracing.ref_type": refType(typ)},
+	}
+	if w3c, ok := ctx.(ddtrace.SpanContextW3C); ok {
+		id := w3c.TraceID128Bytes()
+		link.TraceIDHigh = binary.BigEndian.Uint64(id[:8])
+	}
+	return link
+}
+
+// refType returns the name of the reference type typ, as defined by the OpenTracing
+// semantic conventions.
+func refType(typ opentracing.SpanReferenceType) string {
+	if typ == opentracing.FollowsFromRef {
+		return "follows_from"
+	}
+	return "child_of"
+}
+
 // Inject implements opentracing.Tracer.
 func (t *opentracer) Inject(ctx opentracing.SpanContext, format interface{}, carrier interface{}) error {
 	sctx, ok := ctx.(ddtrace.SpanContext)
@@ -7,10 +7,12 @@ package opentracer
 
 import (
 	"context"
+	"encoding/binary"
 	"testing"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry/telemetrytest"
@@ -122,3 +124,48 @@ func TestSpanTelemetry(t *testing.T) {
 	telemetryClient.AssertCalled(t, "Count", telemetry.NamespaceTracers, "spans_created", 1.0, telemetryTags, true)
 	telemetryClient.AssertNumberOfCalls(t, "Count", 1)
 }
+
+func TestStartSpanReferences(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+	ot := &opentracer{internal.GetGlobalTracer()}
+
+	a := ot.StartSpan("a")
+	b := ot.StartSpan("b")
+	c := ot.StartSpan("c")
+	ctxA := a.Context().(ddtrace.SpanContext)
+	ctxB := b.Context().(ddtrace.SpanContext)
+	ctxC := c.Context().(ddtrace.SpanContext)
+
+	t.Run("child-of", func(t *testing.T) {
+		s := ot.StartSpan("s", opentracing.ChildOf(ctxA), opentracing.ChildOf(ctxB), opentracing.FollowsFrom(ctxC))
+		ms := s.(*span).Span.(mocktracer.SpanWithDetails)
+		assert.Equal(t, ctxA.SpanID(), ms.ParentID())
+		assert.Equal(t, []ddtrace.SpanLink{
+			{TraceID: ctxB.TraceID(), TraceIDHigh: traceIDHigh(ctxB), SpanID: ctxB.SpanID(), Attributes: map[string]string{"opentracing.ref_type": "child_of"}},
+			{TraceID: ctxC.TraceID(), TraceIDHigh: traceIDHigh(ctxC), SpanID: ctxC.SpanID(), Attributes: map[string]string{"opentracing.ref_type": "follows_from"}},
+		}, ms.Links())
+	})
+
+	t.Run("follows-from", func(t *testing.T) {
+		s := ot.StartSpan("s", opentracing.FollowsFrom(ctxA))
+		ms := s.(*span).Span.(mocktracer.SpanWithDetails)
+		assert.Equal(t, ctxA.SpanID(), ms.ParentID())
+		assert.Equal(t, ctxA.TraceID(), ms.TraceID())
+		assert.Equal(t, []ddtrace.SpanLink{
+			{TraceID: ctxA.TraceID(), TraceIDHigh: traceIDHigh(ctxA), SpanID: ctxA.SpanID(), Attributes: map[string]string{"opentracing.ref_type": "follows_from"}},
+		}, ms.Links())
+	})
+
+	t.Run("single-child-of", func(t *testing.T) {
+		s := ot.StartSpan("s", opentracing.ChildOf(ctxA))
+		ms := s.(*span).Span.(mocktracer.SpanWithDetails)
+		assert.Equal(t, ctxA.SpanID(), ms.ParentID())
+		assert.Empty(t, ms.Links())
+	})
+}
+
+func traceIDHigh(ctx ddtrace.SpanContext) uint64 {
+	id := ctx.(ddtrace.SpanContextW3C).TraceID128Bytes()
+	return binary.BigEndian.Uint64(id[:8])
+}
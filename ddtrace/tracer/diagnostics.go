@@ -0,0 +1,394 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+	globalinternal "gopkg.in/DataDog/dd-trace-go.v1/internal"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// maxClockSkew is the difference between the clocks of the application and of the
+// agent above which a warning is reported. Larger skews shift the spans in time.
+const maxClockSkew = 5 * time.Second
+
+// DiagnosticStatus is the outcome of a diagnostic check.
+type DiagnosticStatus string
+
+const (
+	// DiagnosticOK reports that the check succeeded.
+	DiagnosticOK DiagnosticStatus = "ok"
+	// DiagnosticWarning reports an issue that may cause traces to be missing or incorrect.
+	DiagnosticWarning DiagnosticStatus = "warning"
+	// DiagnosticError reports an issue preventing traces from being received.
+	DiagnosticError DiagnosticStatus = "error"
+	// DiagnosticSkipped reports that the check didn't apply, or couldn't run.
+	DiagnosticSkipped DiagnosticStatus = "skipped"
+)
+
+// DiagnosticResult holds the result of a single diagnostic check.
+type DiagnosticResult struct {
+	// Check is the name of the check, e.g. "agent_info" or "test_trace".
+	Check string `json:"check"`
+	// Status is the outcome of the check.
+	Status DiagnosticStatus `json:"status"`
+	// Message explains the outcome of the check.
+	Message string `json:"message,omitempty"`
+}
+
+// DiagnosticReport holds the results of the checks run by Diagnose.
+type DiagnosticReport struct {
+	Date     string             `json:"date"`      // ISO 8601 date and time of the checks
+	AgentURL string             `json:"agent_url"` // The address of the agent
+	Results  []DiagnosticResult `json:"results"`   // The results of the checks, in the order they ran
+}
+
+// OK reports whether none of the checks of the report failed or warned.
+func (r DiagnosticReport) OK() bool {
+	for _, res := range r.Results {
+		if res.Status == DiagnosticError || res.Status == DiagnosticWarning {
+			return false
+		}
+	}
+	return true
+}
+
+// Diagnose actively checks the setup of the tracer, to explain why traces would not
+// show up. It checks that the agent and its trace, stats, data streams, telemetry and
+// remote configuration endpoints are reachable, that the clock of the agent matches
+// the local one, that the agent accepts a test trace, that its unix domain sockets
+// can be used, and that the configuration set in code doesn't silently override the
+// environment. The checks use the configuration of the started tracer, or the one
+// resulting from the environment if the tracer is not started.
+//
+// Diagnose can also be run in the background when the tracer starts, and its results
+// added to the startup log, by setting DD_TRACE_STARTUP_DIAGNOSTICS=true. The startup
+// log is then written once the checks complete.
+func Diagnose(ctx context.Context) DiagnosticReport {
+	if t, ok := internal.GetGlobalTracer().(*tracer); ok {
+		return diagnose(ctx, t.config)
+	}
+	return diagnose(ctx, diagnosticsConfig())
+}
+
+// diagnosticsConfig returns the configuration resulting from the environment, as far
+// as the checks of Diagnose are concerned. Unlike newConfig, it doesn't change any
+// global state, nor contacts the agent.
+func diagnosticsConfig() *config {
+	c := &config{
+		serviceName:   os.Getenv("DD_SERVICE"),
+		env:           os.Getenv("DD_ENV"),
+		version:       os.Getenv("DD_VERSION"),
+		agentURL:      globalinternal.AgentURLFromEnv(),
+		dogstatsdAddr: defaultDogstatsdAddr(),
+		enabled:       dynamicConfig[bool]{current: globalinternal.BoolEnv("DD_TRACE_ENABLED", true)},
+	}
+	if c.serviceName == "" {
+		c.serviceName = filepath.Base(os.Args[0])
+	}
+	if _, ok := os.LookupEnv("AWS_LAMBDA_FUNCTION_NAME"); ok {
+		c.logToStdout = true
+	}
+	if c.agentURL.Scheme == "unix" {
+		c.agentSocket = c.agentURL.Path
+		c.httpClient = udsClient(c.agentSocket, 0)
+		c.agentURL = udsAgentURL(c.agentSocket)
+	} else {
+		c.httpClient = defaultHTTPClient(0)
+	}
+	return c
+}
+
+// logDiagnostics runs the checks of Diagnose with the configuration of t, logs those
+// which failed or warned, then the startup log of t along with their results, unless
+// startup logs are disabled. The checks are canceled if t is stopped before they
+// complete.
+func logDiagnostics(t *tracer) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultHTTPTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-t.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	report := diagnose(ctx, t.config)
+	for _, r := range report.Results {
+		if r.Status == DiagnosticError || r.Status == DiagnosticWarning {
+			log.Warn("DIAGNOSTICS %s %s: %s", r.Check, r.Status, r.Message)
+		}
+	}
+	if t.config.logStartup {
+		logStartupInfo(t, &report)
+	}
+}
+
+// diagnose runs the diagnostic checks against the configuration c.
+func diagnose(ctx context.Context, c *config) DiagnosticReport {
+	d := diagnostics{
+		ctx:       ctx,
+		c:         c,
+		transport: newHTTPTransport(c.agentURL.String(), c.httpClient),
+		report: DiagnosticReport{
+			Date:     time.Now().Format(time.RFC3339),
+			AgentURL: c.agentURL.String(),
+		},
+	}
+	if c.agentSocket != "" {
+		d.report.AgentURL = (&url.URL{Scheme: "unix", Path: c.agentSocket}).String()
+	}
+	d.checkSockets()
+	if c.logToStdout {
+		d.skip("agent_info", "traces are written to stdout")
+	} else {
+		d.checkAgentInfo()
+		d.checkEndpoint("stats_endpoint", "/v0.6/stats")
+		d.checkEndpoint("data_streams_endpoint", "/v0.1/pipeline_stats")
+		d.checkEndpoint("telemetry_endpoint", "/telemetry/proxy/api/v2/apmtelemetry")
+		d.checkEndpoint("remote_config_endpoint", "/v0.7/config")
+		d.checkTestTrace()
+	}
+	d.checkConfigConflicts()
+	return d.report
+}
+
+// diagnostics runs the diagnostic checks and accumulates their results.
+type diagnostics struct {
+	ctx       context.Context
+	c         *config
+	transport *httpTransport
+	report    DiagnosticReport
+
+	// agentDate is the date reported by the agent in its /info response, along
+	// with the local time at which it was received.
+	agentDate, localDate time.Time
+	// agentErr is the error which occurred when fetching the /info endpoint, if any.
+	agentErr error
+}
+
+func (d *diagnostics) add(check string, status DiagnosticStatus, format string, args ...interface{}) {
+	if err := d.ctx.Err(); err != nil && status != DiagnosticOK {
+		status, format, args = DiagnosticSkipped, "%v", []interface{}{err}
+	}
+	d.report.Results = append(d.report.Results, DiagnosticResult{
+		Check:   check,
+		Status:  status,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (d *diagnostics) skip(check, reason string) {
+	d.add(check, DiagnosticSkipped, "%s", reason)
+}
+
+// get sends a GET request to the given path of the agent.
+func (d *diagnostics) get(path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(d.ctx, "GET", d.c.agentURL.String()+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return d.c.httpClient.Do(req)
+}
+
+// checkAgentInfo checks that the agent is reachable, and that its clock isn't skewed.
+func (d *diagnostics) checkAgentInfo() {
+	start := time.Now()
+	resp, err := d.get("/info")
+	if err != nil {
+		d.agentErr = err
+		d.add("agent_info", DiagnosticError, "unable to reach the agent: %v", err)
+		d.skip("clock_skew", "the agent is not reachable")
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	// the date of the agent is compared to the local date halfway through the request.
+	d.localDate = start.Add(time.Since(start) / 2)
+	d.agentDate, _ = http.ParseTime(resp.Header.Get("Date"))
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		d.add("agent_info", DiagnosticWarning, "the agent is reachable but older than 7.28.0, its features are not discoverable")
+	case resp.StatusCode >= 400:
+		d.add("agent_info", DiagnosticError, "the agent responded with status %s", resp.Status)
+	default:
+		d.add("agent_info", DiagnosticOK, "the agent is reachable")
+	}
+	if d.agentDate.IsZero() {
+		d.skip("clock_skew", "the agent didn't report its date")
+		return
+	}
+	// the Date header has a resolution of one second.
+	skew := d.agentDate.Sub(d.localDate).Round(time.Second)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxClockSkew {
+		d.add("clock_skew", DiagnosticWarning, "the clocks of the application and of the agent differ by %s", skew)
+		return
+	}
+	d.add("clock_skew", DiagnosticOK, "the clocks of the application and of the agent differ by %s", skew)
+}
+
+// checkEndpoint checks that the agent serves the given endpoint, by sending it an
+// empty GET request. Any response other than 404 means that the endpoint exists.
+func (d *diagnostics) checkEndpoint(check, path string) {
+	if d.agentErr != nil {
+		d.skip(check, "the agent is not reachable")
+		return
+	}
+	resp, err := d.get(path)
+	if err != nil {
+		d.add(check, DiagnosticError, "unable to reach %s: %v", path, err)
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode == http.StatusNotFound {
+		d.add(check, DiagnosticWarning, "%s is not supported by the agent, the features using it are disabled", path)
+		return
+	}
+	d.add(check, DiagnosticOK, "%s is reachable", path)
+}
+
+// checkTestTrace sends a test trace to the agent, and checks that it is accepted. The
+// trace is marked as rejected by the user, so that it isn't kept by the agent, and as
+// having its stats computed by the client, so that the agent doesn't count it in the
+// stats of the service.
+func (d *diagnostics) checkTestTrace() {
+	if d.agentErr != nil {
+		d.skip("test_trace", "the agent is not reachable")
+		return
+	}
+	start := now()
+	id := generateSpanID(start)
+	s := &span{
+		Name:     "datadog.diagnostics",
+		Service:  d.c.serviceName,
+		Resource: "Diagnose",
+		Start:    start,
+		Duration: 1,
+		SpanID:   id,
+		TraceID:  id,
+		Meta:     map[string]string{ext.Environment: d.c.env, ext.Version: d.c.version},
+		Metrics:  map[string]float64{keySamplingPriority: ext.PriorityUserReject},
+	}
+	p := newPayload()
+	if err := p.push(spanList{s}); err != nil {
+		d.add("test_trace", DiagnosticError, "unable to encode the test trace: %v", err)
+		return
+	}
+	req, err := d.transport.newTraceRequest(p, p.itemCount(), p.size())
+	if err != nil {
+		d.add("test_trace", DiagnosticError, "%v", err)
+		return
+	}
+	req.Header.Set("Datadog-Client-Computed-Stats", "yes")
+	body, err := d.transport.do(req.WithContext(d.ctx))
+	if err != nil {
+		d.add("test_trace", DiagnosticError, "the agent didn't accept the test trace: %v", err)
+		return
+	}
+	body.Close()
+	d.add("test_trace", DiagnosticOK, "the agent accepted the test trace")
+}
+
+// checkSockets checks that the unix domain sockets of the agent and of DogStatsD, if
+// any, exist and can be connected to.
+func (d *diagnostics) checkSockets() {
+	var sockets []struct{ network, path string }
+	if d.c.agentSocket != "" {
+		sockets = append(sockets, struct{ network, path string }{"unix", d.c.agentSocket})
+	}
+	if path := strings.TrimPrefix(d.c.dogstatsdAddr, "unix://"); path != d.c.dogstatsdAddr {
+		sockets = append(sockets, struct{ network, path string }{"unixgram", path})
+	}
+	if len(sockets) == 0 {
+		d.skip("uds", "no unix domain socket is used")
+		return
+	}
+	for _, s := range sockets {
+		if err := checkSocket(d.ctx, s.network, s.path); err != nil {
+			d.add("uds", DiagnosticError, "%v", err)
+			continue
+		}
+		d.add("uds", DiagnosticOK, "%s can be connected to", s.path)
+	}
+}
+
+// checkSocket returns an error explaining why the unix domain socket at path can't be
+// connected to with the given network, if so.
+func checkSocket(ctx context.Context, network, path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("unable to use %s: %v", path, err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s is not a unix domain socket", path)
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, path)
+	if err != nil {
+		if os.IsPermission(err) {
+			return fmt.Errorf("permission denied connecting to %s (mode %s): %v", path, fi.Mode(), err)
+		}
+		return fmt.Errorf("unable to connect to %s: %v", path, err)
+	}
+	conn.Close()
+	return nil
+}
+
+// checkConfigConflicts reports the settings of the environment which are overridden
+// by the configuration set in code.
+func (d *diagnostics) checkConfigConflicts() {
+	var conflicts []string
+	for _, s := range []struct {
+		env, value string
+	}{
+		{"DD_SERVICE", d.c.serviceName},
+		{"DD_ENV", d.c.env},
+		{"DD_VERSION", d.c.version},
+	} {
+		if v := os.Getenv(s.env); v != "" && v != s.value {
+			conflicts = append(conflicts, fmt.Sprintf("%s=%q is overridden by %q", s.env, v, s.value))
+		}
+	}
+	if v, ok := os.LookupEnv("DD_TRACE_ENABLED"); ok && globalinternal.BoolEnv("DD_TRACE_ENABLED", true) != d.c.enabled.current {
+		conflicts = append(conflicts, fmt.Sprintf("DD_TRACE_ENABLED=%q is overridden by %t", v, d.c.enabled.current))
+	}
+	if envAgentURLSet() {
+		configured := d.report.AgentURL
+		if v := globalinternal.AgentURLFromEnv().String(); v != configured {
+			conflicts = append(conflicts, fmt.Sprintf("the agent URL %q of the environment is overridden by %q", v, configured))
+		}
+	}
+	if len(conflicts) > 0 {
+		d.add("config_conflicts", DiagnosticWarning, "%s", strings.Join(conflicts, "; "))
+		return
+	}
+	d.add("config_conflicts", DiagnosticOK, "the configuration set in code doesn't conflict with the environment")
+}
+
+// envAgentURLSet reports whether the agent URL is set in the environment.
+func envAgentURLSet() bool {
+	for _, env := range []string{"DD_TRACE_AGENT_URL", "DD_AGENT_HOST", "DD_TRACE_AGENT_PORT"} {
+		if os.Getenv(env) != "" {
+			return true
+		}
+	}
+	return false
+}
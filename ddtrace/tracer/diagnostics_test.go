@@ -0,0 +1,212 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package tracer
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// diagnosticAgent returns a handler behaving like an agent whose clock is shifted by
+// skew, and which doesn't support the data streams endpoint.
+func diagnosticAgent(skew time.Duration, traces *int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(skew).UTC().Format(http.TimeFormat))
+		switch r.URL.Path {
+		case "/info":
+			w.Write([]byte(`{"endpoints":["/v0.4/traces","/v0.6/stats"]}`))
+		case "/v0.4/traces":
+			if r.Method != "POST" || r.Header.Get(traceCountHeader) != "1" || r.Header.Get("Datadog-Client-Computed-Stats") != "yes" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			atomic.AddInt32(traces, 1)
+			w.Write([]byte(`{}`))
+		case "/v0.1/pipeline_stats":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// results indexes the statuses of the results of r by check.
+func results(r DiagnosticReport) map[string]DiagnosticStatus {
+	m := make(map[string]DiagnosticStatus, len(r.Results))
+	for _, res := range r.Results {
+		m[res.Check] = res.Status
+	}
+	return m
+}
+
+func TestDiagnose(t *testing.T) {
+	t.Run("healthy", func(t *testing.T) {
+		var traces int32
+		srv := httptest.NewServer(diagnosticAgent(0, &traces))
+		defer srv.Close()
+		c := newConfig(WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")))
+
+		r := diagnose(context.Background(), c)
+		assert.Equal(t, map[string]DiagnosticStatus{
+			"uds":                    DiagnosticSkipped,
+			"agent_info":             DiagnosticOK,
+			"clock_skew":             DiagnosticOK,
+			"stats_endpoint":         DiagnosticOK,
+			"data_streams_endpoint":  DiagnosticWarning,
+			"telemetry_endpoint":     DiagnosticOK,
+			"remote_config_endpoint": DiagnosticOK,
+			"test_trace":             DiagnosticOK,
+			"config_conflicts":       DiagnosticOK,
+		}, results(r))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&traces))
+		assert.False(t, r.OK())
+	})
+
+	t.Run("clock-skew", func(t *testing.T) {
+		var traces int32
+		srv := httptest.NewServer(diagnosticAgent(time.Hour, &traces))
+		defer srv.Close()
+		c := newConfig(WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")))
+
+		r := diagnose(context.Background(), c)
+		assert.Equal(t, DiagnosticWarning, results(r)["clock_skew"])
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		c := newConfig(WithAgentAddr("localhost:9"))
+		r := diagnose(context.Background(), c)
+		res := results(r)
+		assert.Equal(t, DiagnosticError, res["agent_info"])
+		assert.Equal(t, DiagnosticSkipped, res["clock_skew"])
+		assert.Equal(t, DiagnosticSkipped, res["stats_endpoint"])
+		assert.Equal(t, DiagnosticSkipped, res["test_trace"])
+		assert.False(t, r.OK())
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		var traces int32
+		srv := httptest.NewServer(diagnosticAgent(0, &traces))
+		defer srv.Close()
+		c := newConfig(WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		res := results(diagnose(ctx, c))
+		assert.Equal(t, DiagnosticSkipped, res["agent_info"])
+		assert.Equal(t, DiagnosticSkipped, res["test_trace"])
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+		t.Setenv("DD_SERVICE", "env-service")
+		t.Setenv("DD_ENV", "prod")
+		c := newConfig(WithService("code-service"))
+		defer globalconfig.SetServiceName("")
+		c.logToStdout = true
+
+		r := diagnose(context.Background(), c)
+		assert.Equal(t, DiagnosticSkipped, results(r)["agent_info"])
+		require.Equal(t, "config_conflicts", r.Results[len(r.Results)-1].Check)
+		assert.Equal(t, `DD_SERVICE="env-service" is overridden by "code-service"`, r.Results[len(r.Results)-1].Message)
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		var traces int32
+		srv := httptest.NewServer(diagnosticAgent(0, &traces))
+		defer srv.Close()
+		t.Setenv("DD_TRACE_AGENT_URL", srv.URL)
+		t.Setenv("DD_SERVICE", "env-service")
+
+		// without a started tracer, the configuration of the environment is used,
+		// without changing the global one.
+		r := Diagnose(context.Background())
+		assert.Equal(t, srv.URL, r.AgentURL)
+		res := results(r)
+		assert.Equal(t, DiagnosticOK, res["agent_info"])
+		assert.Equal(t, DiagnosticOK, res["test_trace"])
+		assert.Equal(t, DiagnosticOK, res["config_conflicts"])
+		assert.Equal(t, "", globalconfig.ServiceName())
+	})
+
+	t.Run("uds", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "apm.socket")
+		l, err := net.Listen("unix", path)
+		require.NoError(t, err)
+		var traces int32
+		srv := &http.Server{Handler: diagnosticAgent(0, &traces)}
+		go srv.Serve(l)
+		defer srv.Close()
+
+		c := newConfig(WithUDS(path), WithDogstatsdAddress("unix://"+filepath.Join(t.TempDir(), "missing.socket")))
+		r := diagnose(context.Background(), c)
+		assert.Equal(t, "unix://"+path, r.AgentURL)
+		var uds []DiagnosticResult
+		for _, res := range r.Results {
+			if res.Check == "uds" {
+				uds = append(uds, res)
+			}
+		}
+		require.Len(t, uds, 2)
+		assert.Equal(t, DiagnosticOK, uds[0].Status)
+		assert.Equal(t, DiagnosticError, uds[1].Status)
+		assert.Contains(t, uds[1].Message, "missing.socket")
+		assert.Equal(t, DiagnosticOK, results(r)["test_trace"])
+	})
+}
+
+func TestStartupDiagnostics(t *testing.T) {
+	var traces int32
+	srv := httptest.NewServer(diagnosticAgent(0, &traces))
+	defer srv.Close()
+	t.Setenv("DD_TRACE_STARTUP_DIAGNOSTICS", "true")
+
+	t.Run("startup-logs", func(t *testing.T) {
+		tp := new(log.RecordLogger)
+		tp.Ignore("appsec: ", telemetry.LogPrefix)
+		Start(WithLogger(tp), WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")))
+		defer Stop()
+
+		// the results of the checks are part of the startup log.
+		var logs string
+		assert.Eventually(t, func() bool {
+			logs = strings.Join(tp.Logs(), "\n")
+			return strings.Contains(logs, "DATADOG TRACER CONFIGURATION")
+		}, 10*time.Second, 10*time.Millisecond)
+		assert.Regexp(t, `DATADOG TRACER CONFIGURATION {.*"diagnostics":{"date":"[^"]*","agent_url":"[^"]*","results":\[{"check":"uds","status":"skipped"`, logs)
+		assert.Contains(t, logs, "DIAGNOSTICS data_streams_endpoint warning")
+	})
+
+	t.Run("no-startup-logs", func(t *testing.T) {
+		t.Setenv("DD_TRACE_STARTUP_LOGS", "false")
+		tp := new(log.RecordLogger)
+		tp.Ignore("appsec: ", telemetry.LogPrefix)
+		Start(WithLogger(tp), WithAgentAddr(strings.TrimPrefix(srv.URL, "http://")))
+		defer Stop()
+
+		// only the failed checks are logged.
+		var logs string
+		assert.Eventually(t, func() bool {
+			logs = strings.Join(tp.Logs(), "\n")
+			return strings.Contains(logs, "DIAGNOSTICS data_streams_endpoint warning")
+		}, 10*time.Second, 10*time.Millisecond)
+		Stop()
+		assert.NotContains(t, strings.Join(tp.Logs(), "\n"), "DATADOG TRACER CONFIGURATION")
+	})
+	assert.Equal(t, int32(2), atomic.LoadInt32(&traces))
+}
@@ -42,6 +42,9 @@
 //	      tracer.SpanNameServiceRule("web.*", "test-*", 0.5),
 //	      // sample 50% of spans when service and name match these glob patterns up to 100 spans per second
 //	      tracer.SpanNameServiceMPSRule("web.*", "test-*", 0.5, 100),
+//	      // keep all the error spans, and the spans lasting more than a second
+//	      tracer.SpanErrorRule("*", "*", 1.0),
+//	      tracer.SpanDurationRule("*", "*", time.Second, 1.0),
 //	}
 //	tracer.Start(tracer.WithSamplingRules(rules))
 //	defer tracer.Stop()
@@ -55,9 +58,31 @@
 // and "?" character matches exactly one of any character.
 // The "sample_rate" field is optional, and if not specified, defaults to "1.0", sampling 100% of the spans.
 // The "max_per_second" field is optional, and if not specified, defaults to 0, keeping all the previously sampled spans.
+// Both types of rules can also match on the error status ("error": true or false), on a minimum
+// duration ("min_duration", e.g. "500ms"), on the span kind ("span_kind", a glob pattern) and on
+// numeric comparisons of metrics or tags ("metrics", a list of conditions which must all be
+// satisfied, e.g. [{"key": "http.status_code", "op": ">=", "value": 500}]).
+// Trace sampling rules only match on error status and duration when the sampling decision
+// is taken as the root span finishes, meaning when the trace context was not propagated before.
+//
+// Trace sampling rules can also match on the trace context extracted from incoming requests:
+// its origin ("origin", e.g. "synthetics" or "rum"), the upstream decision maker ("decision_maker",
+// as in the _dd.p.dm tag), whether an explicit sampling priority was received ("upstream_priority")
+// and the propagation style it was extracted with ("propagation_style", e.g. "tracecontext").
+// Once any rule uses these conditions or "override_upstream", sampling decisions received from
+// upstream are kept, unless a rule setting "override_upstream" matches, which allows re-sampling
+// the traces of untrusted callers at service boundaries.
 //
 //	export DD_TRACE_SAMPLING_RULES='[{"name": "web.request", "sample_rate": 1.0}]'
 //	export DD_SPAN_SAMPLING_RULES='[{"service":"test.?","name": "web.*", "sample_rate": 1.0, "max_per_second":100}]'
+//	export DD_TRACE_SAMPLING_RULES='[{"error": true, "sample_rate": 1.0}, {"metrics": [{"key": "http.status_code", "op": ">=", "value": 500}], "sample_rate": 1.0}, {"sample_rate": 0.1}]'
+//	export DD_TRACE_SAMPLING_RULES='[{"propagation_style": "tracecontext", "override_upstream": true, "sample_rate": 0.1}]'
+//
+// To understand why a trace was kept or dropped, ExplainSampling returns the sampler which
+// took the decision, along with the rate applied. Setting DD_TRACE_SAMPLING_DEBUG=true (or using
+// WithSamplingDebug) additionally records the matched rule index, the effective rate and the
+// rate limiter state on root spans as _dd.sampling.* tags, and logs the explanation of dropped traces at
+// debug level.
 //
 // To create spans, use the functions StartSpan and StartSpanFromContext. Both accept
 // StartSpanOptions that can be used to configure the span. A span that is started
@@ -57,8 +57,10 @@ type startupInfo struct {
 	PartialFlushMinSpans        int                          `json:"partial_flush_min_spans"`        // The min number of spans to trigger a partial flush
 	Orchestrion                 orchestrionConfig            `json:"orchestrion"`                    // Orchestrion (auto-instrumentation) configuration.
 	FeatureFlags                []string                     `json:"feature_flags"`
+	ProfilerSpanLabelKeys       []string                     `json:"profiler_span_label_keys,omitempty"`
 	PropagationStyleInject      string                       `json:"propagation_style_inject"`  // Propagation style for inject
 	PropagationStyleExtract     string                       `json:"propagation_style_extract"` // Propagation style for extract
+	Diagnostics                 *DiagnosticReport            `json:"diagnostics,omitempty"`     // Results of Diagnose, when DD_TRACE_STARTUP_DIAGNOSTICS is set
 }
 
 // checkEndpoint tries to connect to the URL specified by endpoint.
@@ -82,6 +84,11 @@ func checkEndpoint(c *http.Client, endpoint string) error {
 // logStartup generates a startupInfo for a tracer and writes it to the log in
 // JSON format.
 func logStartup(t *tracer) {
+	logStartupInfo(t, nil)
+}
+
+// logStartupInfo is logStartup, with the results of the startup diagnostics, if any.
+func logStartupInfo(t *tracer, diagnostics *DiagnosticReport) {
 	tags := make(map[string]string)
 	for k, v := range t.config.globalTags.get() {
 		tags[k] = fmt.Sprintf("%v", v)
@@ -117,6 +124,7 @@ func logStartup(t *tracer) {
 		ApplicationVersion:          t.config.version,
 		ProfilerCodeHotspotsEnabled: t.config.profilerHotspots,
 		ProfilerEndpointsEnabled:    t.config.profilerEndpoints,
+		ProfilerSpanLabelKeys:       t.config.profilerLabelKeys,
 		Architecture:                runtime.GOARCH,
 		GlobalService:               globalconfig.ServiceName(),
 		LambdaMode:                  fmt.Sprintf("%t", t.config.logToStdout),
@@ -142,6 +150,7 @@ func logStartup(t *tracer) {
 			log.Warn("DIAGNOSTICS Unable to reach agent intake: %s", err)
 		}
 	}
+	info.Diagnostics = diagnostics
 	bs, err := json.Marshal(info)
 	if err != nil {
 		log.Warn("DIAGNOSTICS Failed to serialize json for startup log (%v) %#v\n", err, info)
@@ -137,6 +137,11 @@ type config struct {
 	// when the tracer starts.
 	logStartup bool
 
+	// startupDiagnostics, when true, causes the checks of Diagnose to be run in
+	// the background when the tracer starts, and their results to be added to the
+	// startup log.
+	startupDiagnostics bool
+
 	// serviceName specifies the name of this application.
 	serviceName string
 
@@ -156,6 +161,10 @@ type config struct {
 	// agentURL is the agent URL that receives traces from the tracer.
 	agentURL *url.URL
 
+	// agentSocket is the path of the unix domain socket of the agent, when the
+	// tracer connects to it over UDS.
+	agentSocket string
+
 	// serviceMappings holds a set of service mappings to dynamically rename services
 	serviceMappings map[string]string
 
@@ -166,6 +175,15 @@ type config struct {
 	// transport specifies the Transport interface which will be used to send data to the agent.
 	transport transport
 
+	// writerMemoryLimit is the maximum number of bytes used by the trace payloads
+	// buffered, queued or being sent by a trace writer.
+	// Value from DD_TRACE_WRITER_MEMORY_LIMIT_BYTES, default defaultWriterMemoryLimit.
+	writerMemoryLimit int
+
+	// extraTransports holds additional destinations to which traces are sent,
+	// as set by WithTransport.
+	extraTransports []extraTransport
+
 	// httpClientTimeout specifies the timeout for the HTTP client.
 	httpClientTimeout time.Duration
 
@@ -217,6 +235,10 @@ type config struct {
 	// profilerEndpoints specifies whether profiler endpoint filtering is enabled.
 	profilerEndpoints bool
 
+	// profilerLabelKeys holds the keys of the span tags and baggage items applied
+	// as pprof labels.
+	profilerLabelKeys []string
+
 	// enabled reports whether tracing is enabled.
 	enabled dynamicConfig[bool]
 
@@ -239,6 +261,10 @@ type config struct {
 	// misconfiguration
 	spanTimeout time.Duration
 
+	// samplingDebug controls if the samplers should record the explanation of their
+	// decisions on root spans, and if dropped traces should be logged.
+	samplingDebug bool
+
 	// partialFlushMinSpans is the number of finished spans in a single trace to trigger a
 	// partial flush, or 0 if partial flushing is disabled.
 	// Value from DD_TRACE_PARTIAL_FLUSH_MIN_SPANS, default 1000.
@@ -278,6 +304,12 @@ type config struct {
 	ciVisibilityEnabled bool
 }
 
+// extraTransport holds an additional trace destination and its sample rate.
+type extraTransport struct {
+	transport  TraceDestination
+	sampleRate float64
+}
+
 // orchestrionConfig contains Orchestrion configuration.
 type orchestrionConfig struct {
 	// Enabled indicates whether this tracer was instanciated via Orchestrion.
@@ -302,6 +334,10 @@ const maxPropagatedTagsLength = 512
 // partialFlushMinSpansDefault is the default number of spans for partial flushing, if enabled.
 const partialFlushMinSpansDefault = 1000
 
+// defaultWriterMemoryLimit is the default memory limit of the trace writer, 100 MiB.
+// It allows for about ten full payloads waiting for, or being sent to, the agent.
+const defaultWriterMemoryLimit = 100 << 20
+
 // newConfig renders the tracer configuration based on defaults, environment variables
 // and passed user opts.
 func newConfig(opts ...StartOption) *config {
@@ -377,6 +413,7 @@ func newConfig(opts ...StartOption) *config {
 		c.logToStdout = true
 	}
 	c.logStartup = internal.BoolEnv("DD_TRACE_STARTUP_LOGS", true)
+	c.startupDiagnostics = internal.BoolEnv("DD_TRACE_STARTUP_DIAGNOSTICS", false)
 	c.runtimeMetrics = internal.BoolVal(getDDorOtelConfig("metrics"), false)
 	c.debug = internal.BoolVal(getDDorOtelConfig("debugMode"), false)
 	c.enabled = newDynamicConfig("tracing_enabled", internal.BoolVal(getDDorOtelConfig("enabled"), true), func(b bool) bool { return true }, equal[bool])
@@ -385,6 +422,9 @@ func newConfig(opts ...StartOption) *config {
 	}
 	c.profilerEndpoints = internal.BoolEnv(traceprof.EndpointEnvVar, true)
 	c.profilerHotspots = internal.BoolEnv(traceprof.CodeHotspotsEnvVar, true)
+	if v := os.Getenv(traceprof.SpanLabelKeysEnvVar); v != "" {
+		WithProfilerSpanLabels(strings.Split(v, ",")...)(c)
+	}
 	if compatMode := os.Getenv("DD_TRACE_CLIENT_HOSTNAME_COMPAT"); compatMode != "" {
 		if semver.IsValid(compatMode) {
 			c.enableHostnameDetection = semver.Compare(semver.MajorMinor(compatMode), "v1.66") <= 0
@@ -396,6 +436,7 @@ func newConfig(opts ...StartOption) *config {
 	if c.debugAbandonedSpans {
 		c.spanTimeout = internal.DurationEnv("DD_TRACE_ABANDONED_SPAN_TIMEOUT", 10*time.Minute)
 	}
+	c.samplingDebug = internal.BoolEnv("DD_TRACE_SAMPLING_DEBUG", false)
 	c.statsComputationEnabled = internal.BoolEnv("DD_TRACE_STATS_COMPUTATION_ENABLED", false)
 	c.dataStreamsMonitoringEnabled = internal.BoolEnv("DD_DATA_STREAMS_ENABLED", false)
 	c.partialFlushEnabled = internal.BoolEnv("DD_TRACE_PARTIAL_FLUSH_ENABLED", false)
@@ -407,6 +448,11 @@ func newConfig(opts ...StartOption) *config {
 		log.Warn("DD_TRACE_PARTIAL_FLUSH_MIN_SPANS=%d is above the max number of spans that can be kept in memory for a single trace (%d spans), so partial flushing will never trigger, setting to default %d", c.partialFlushMinSpans, traceMaxSize, partialFlushMinSpansDefault)
 		c.partialFlushMinSpans = partialFlushMinSpansDefault
 	}
+	c.writerMemoryLimit = internal.IntEnv("DD_TRACE_WRITER_MEMORY_LIMIT_BYTES", defaultWriterMemoryLimit)
+	if c.writerMemoryLimit <= 0 {
+		log.Warn("DD_TRACE_WRITER_MEMORY_LIMIT_BYTES=%d is not a valid value, setting to default %d", c.writerMemoryLimit, defaultWriterMemoryLimit)
+		c.writerMemoryLimit = defaultWriterMemoryLimit
+	}
 	// TODO(partialFlush): consider logging a warning if DD_TRACE_PARTIAL_FLUSH_MIN_SPANS
 	// is set, but DD_TRACE_PARTIAL_FLUSH_ENABLED is not true. Or just assume it should be enabled
 	// if it's explicitly set, and don't require both variables to be configured.
@@ -445,11 +491,9 @@ func newConfig(opts ...StartOption) *config {
 	if c.agentURL.Scheme == "unix" {
 		// If we're connecting over UDS we can just rely on the agent to provide the hostname
 		log.Debug("connecting to agent over unix, do not set hostname on any traces")
+		c.agentSocket = c.agentURL.Path
 		c.httpClient = udsClient(c.agentURL.Path, c.httpClientTimeout)
-		c.agentURL = &url.URL{
-			Scheme: "http",
-			Host:   fmt.Sprintf("UDS_%s", strings.NewReplacer(":", "_", "/", "_", `\`, "_").Replace(c.agentURL.Path)),
-		}
+		c.agentURL = udsAgentURL(c.agentURL.Path)
 	} else if c.httpClient == nil {
 		c.httpClient = defaultHTTPClient(c.httpClientTimeout)
 	}
@@ -561,6 +605,15 @@ func newStatsdClient(c *config) (internal.StatsdClient, error) {
 	return internal.NewStatsdClient(c.dogstatsdAddr, statsTags(c))
 }
 
+// udsAgentURL returns the URL of the agent listening on the unix domain socket at
+// socketPath, to be used with the client returned by udsClient.
+func udsAgentURL(socketPath string) *url.URL {
+	return &url.URL{
+		Scheme: "http",
+		Host:   fmt.Sprintf("UDS_%s", strings.NewReplacer(":", "_", "/", "_", `\`, "_").Replace(socketPath)),
+	}
+}
+
 // udsClient returns a new http.Client which connects using the given UDS socket path.
 func udsClient(socketPath string, timeout time.Duration) *http.Client {
 	if timeout == 0 {
@@ -958,6 +1011,44 @@ func WithHTTPClient(client *http.Client) StartOption {
 	}
 }
 
+// WithWriterMemoryLimit sets the maximum number of bytes used by the trace payloads
+// waiting to be sent, or being sent, to the agent. When the limit is reached, traces
+// are dropped starting with those the samplers rejected, then those they kept, and
+// last those the user kept. Dropped traces are reported in the
+// datadog.tracer.traces_dropped health metric with the reason:memory_limit tag.
+// Each destination added with WithTransport has its own limit. It defaults to
+// 100 MiB, or the value of DD_TRACE_WRITER_MEMORY_LIMIT_BYTES.
+func WithWriterMemoryLimit(bytes int) StartOption {
+	return func(c *config) {
+		if bytes <= 0 {
+			log.Warn("ignoring WithWriterMemoryLimit: %d is not a valid value", bytes)
+			return
+		}
+		c.writerMemoryLimit = bytes
+	}
+}
+
+// WithTransport adds t as an additional destination to which traces are sent,
+// alongside the Datadog Agent. sampleRate, between 0 and 1, is the rate at which
+// the traces kept by the tracer are also sent to t: traces with a sampling
+// priority of zero or less are never sent to t, even when they are sent to the
+// agent to compute the trace metrics. Sampling is deterministic based on the
+// trace ID. Each destination buffers and retries its payloads on
+// its own, so that a slow or failing destination does not delay the others.
+// This option can be used several times to add several destinations.
+func WithTransport(t TraceDestination, sampleRate float64) StartOption {
+	return func(c *config) {
+		if t == nil {
+			return
+		}
+		if sampleRate < 0.0 || sampleRate > 1.0 || math.IsNaN(sampleRate) {
+			log.Warn("ignoring WithTransport sample rate: out of range %f, using 1", sampleRate)
+			sampleRate = 1.0
+		}
+		c.extraTransports = append(c.extraTransports, extraTransport{transport: t, sampleRate: sampleRate})
+	}
+}
+
 // WithUDS configures the HTTP client to dial the Datadog Agent via the specified Unix Domain Socket path.
 func WithUDS(socketPath string) StartOption {
 	return func(c *config) {
@@ -1093,6 +1184,30 @@ func WithProfilerEndpoints(enabled bool) StartOption {
 	}
 }
 
+// WithProfilerSpanLabels attaches pprof labels holding the values of the span
+// tags or, when the tag is not set, of the baggage items with the given keys,
+// e.g. "tenant" or "customer.tier". The labels are applied whenever a span
+// starts, like the code hotspots labels, and inherited by the children of the
+// span started with StartSpanFromContext. Baggage items are only read when the
+// span starts. The tags set after the span started don't change the labels of
+// the running goroutine, as it may not be the one running the span: they only
+// apply to the children of the span started with ChildOf.
+//
+// The profiler makes these label keys available for filtering flame graphs, as
+// with profiler.WithCustomProfilerLabelKeys. The keys default to the value of
+// the comma-separated DD_PROFILING_SPAN_LABEL_KEYS env variable, which they
+// override.
+func WithProfilerSpanLabels(keys ...string) StartOption {
+	return func(c *config) {
+		c.profilerLabelKeys = nil
+		for _, k := range keys {
+			if k = strings.TrimSpace(k); k != "" {
+				c.profilerLabelKeys = append(c.profilerLabelKeys, k)
+			}
+		}
+	}
+}
+
 // WithDebugSpansMode enables debugging old spans that may have been
 // abandoned, which may prevent traces from being set to the Datadog
 // Agent, especially if partial flushing is off.
@@ -1108,6 +1223,19 @@ func WithDebugSpansMode(timeout time.Duration) StartOption {
 	}
 }
 
+// WithSamplingDebug enables recording the explanation of sampling decisions on root
+// spans, as _dd.sampling.* tags: the sampler which took the decision, the index of the
+// matched sampling rule, the effective rate and the state of the rate limiter. Dropped
+// traces are also logged along with their explanation, at debug level (see WithDebugMode).
+// The explanation of any trace can be retrieved using ExplainSampling.
+// This setting can also be configured by setting DD_TRACE_SAMPLING_DEBUG to true.
+// This feature is disabled by default, and should only be enabled for debugging purposes.
+func WithSamplingDebug(enabled bool) StartOption {
+	return func(c *config) {
+		c.samplingDebug = enabled
+	}
+}
+
 // WithPartialFlushing enables flushing of partially finished traces.
 // This is done after "numSpans" have finished in a single local trace at
 // which point all finished spans in that trace will be flushed, freeing up
@@ -1463,6 +1463,30 @@ func TestPartialFlushing(t *testing.T) {
 	})
 }
 
+func TestWriterMemoryLimit(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		c := newConfig()
+		assert.Equal(t, defaultWriterMemoryLimit, c.writerMemoryLimit)
+	})
+	t.Run("Env", func(t *testing.T) {
+		t.Setenv("DD_TRACE_WRITER_MEMORY_LIMIT_BYTES", "1048576")
+		c := newConfig()
+		assert.Equal(t, 1048576, c.writerMemoryLimit)
+	})
+	t.Run("EnvInvalid", func(t *testing.T) {
+		t.Setenv("DD_TRACE_WRITER_MEMORY_LIMIT_BYTES", "-1")
+		c := newConfig()
+		assert.Equal(t, defaultWriterMemoryLimit, c.writerMemoryLimit)
+	})
+	t.Run("Option", func(t *testing.T) {
+		t.Setenv("DD_TRACE_WRITER_MEMORY_LIMIT_BYTES", "1048576")
+		c := newConfig(WithWriterMemoryLimit(2048))
+		assert.Equal(t, 2048, c.writerMemoryLimit)
+		WithWriterMemoryLimit(0)(c)
+		assert.Equal(t, 2048, c.writerMemoryLimit)
+	})
+}
+
 func TestWithStatsComputation(t *testing.T) {
 	t.Run("default", func(t *testing.T) {
 		assert := assert.New(t)
@@ -48,6 +48,8 @@ func (r *rulesSampler) SampleTrace(s *span) bool { return r.traces.sampleRules(s
 
 func (r *rulesSampler) SampleTraceGlobalRate(s *span) bool { return r.traces.sampleGlobalRate(s) }
 
+func (r *rulesSampler) SampleTraceUpstream(s *span) bool { return r.traces.sampleUpstreamRules(s) }
+
 func (r *rulesSampler) SampleSpan(s *span) bool { return r.spans.apply(s) }
 
 func (r *rulesSampler) HasSpanRules() bool { return r.spans.enabled() }
@@ -126,6 +128,46 @@ type SamplingRule struct {
 	// Tags specifies the map of key-value patterns that span tags must match.
 	Tags map[string]*regexp.Regexp
 
+	// Error, if not nil, specifies whether spans must be errors (true) or not (false)
+	// to match the rule.
+	Error *bool
+
+	// MinDuration, if not zero, specifies the minimum duration of the spans matching the rule.
+	// As trace sampling rules are applied to the root span, a trace sampling rule only matches
+	// on duration when the sampling decision is taken as the root span finishes, meaning when
+	// the trace context was not propagated before.
+	MinDuration time.Duration
+
+	// SpanKind specifies the regex pattern that the span.kind tag of a span must match.
+	SpanKind *regexp.Regexp
+
+	// Metrics specifies numeric comparisons that span metrics, or tags holding numbers, must satisfy.
+	Metrics []MetricCondition
+
+	// Origin specifies the regex pattern that the origin of the trace, as propagated by the
+	// x-datadog-origin header (e.g. "synthetics" or "rum"), must match.
+	Origin *regexp.Regexp
+
+	// DecisionMaker specifies the regex pattern that the sampling decision maker received
+	// from upstream, as propagated by the _dd.p.dm tag (e.g. "-3"), must match.
+	DecisionMaker *regexp.Regexp
+
+	// PropagationStyle specifies the regex pattern that the style the trace context was
+	// extracted with must match: "datadog", "tracecontext", "b3multi" or "b3 single header".
+	PropagationStyle *regexp.Regexp
+
+	// UpstreamPriority, if not nil, specifies whether the trace context must have been
+	// extracted with an explicit sampling priority (true) or not (false) to match the rule.
+	UpstreamPriority *bool
+
+	// OverrideUpstream allows a trace sampling rule to replace the sampling decision received
+	// from upstream when the trace context is extracted, e.g. to re-sample the traces coming
+	// from untrusted callers at service boundaries. Once any rule uses Origin, DecisionMaker,
+	// PropagationStyle, UpstreamPriority or OverrideUpstream, the other rules only apply to
+	// traces for which no sampling decision was taken yet. Otherwise, as before these fields
+	// existed, all the rules apply again when the local root span finishes.
+	OverrideUpstream bool
+
 	Provenance provenance
 
 	ruleType SamplingRuleType
@@ -134,6 +176,53 @@ type SamplingRule struct {
 	globRule *jsonRule
 }
 
+// MetricCondition is a numeric comparison between the value of the span metric, or tag,
+// named Key and Value. For instance, MetricCondition{"http.status_code", ">=", 500}
+// matches spans with a status code of 500 or more. In JSON sampling rules, it is written
+// as {"key": "http.status_code", "op": ">=", "value": 500}.
+type MetricCondition struct {
+	// Key is the name of the metric or tag.
+	Key string `json:"key"`
+
+	// Op is the comparison operator, one of "==", "!=", ">", ">=", "<" and "<=".
+	Op string `json:"op"`
+
+	// Value is the value the metric is compared to.
+	Value float64 `json:"value"`
+}
+
+// validate returns an error if the condition has no key or an unsupported operator.
+func (mc MetricCondition) validate() error {
+	if mc.Key == "" {
+		return fmt.Errorf("missing key in metric condition")
+	}
+	switch mc.Op {
+	case "==", "!=", ">", ">=", "<", "<=":
+		return nil
+	}
+	return fmt.Errorf("invalid operator %q in metric condition on %q", mc.Op, mc.Key)
+}
+
+// matches reports whether v satisfies the condition.
+func (mc MetricCondition) matches(v float64) bool {
+	switch mc.Op {
+	case "==":
+		return v == mc.Value
+	case "!=":
+		return v != mc.Value
+	case ">":
+		return v > mc.Value
+	case ">=":
+		return v >= mc.Value
+	case "<":
+		return v < mc.Value
+	case "<=":
+		return v <= mc.Value
+	default:
+		return false
+	}
+}
+
 // Poor-man's comparison of two regex for equality without resorting to fancy symbolic computation.
 // The result is false negative: whenever the function returns true, we know the two regex must be
 // equal. The reverse is not true. Two regex can be equivalent while reported as not.
@@ -159,7 +248,18 @@ func (sr *SamplingRule) EqualsFalseNegative(other *SamplingRule) bool {
 		!regexEqualsFalseNegative(sr.Service, other.Service) ||
 		!regexEqualsFalseNegative(sr.Name, other.Name) ||
 		!regexEqualsFalseNegative(sr.Resource, other.Resource) ||
-		len(sr.Tags) != len(other.Tags) {
+		!regexEqualsFalseNegative(sr.SpanKind, other.SpanKind) ||
+		!regexEqualsFalseNegative(sr.Origin, other.Origin) ||
+		!regexEqualsFalseNegative(sr.DecisionMaker, other.DecisionMaker) ||
+		!regexEqualsFalseNegative(sr.PropagationStyle, other.PropagationStyle) ||
+		(sr.UpstreamPriority == nil) != (other.UpstreamPriority == nil) ||
+		(sr.UpstreamPriority != nil && *sr.UpstreamPriority != *other.UpstreamPriority) ||
+		sr.OverrideUpstream != other.OverrideUpstream ||
+		(sr.Error == nil) != (other.Error == nil) ||
+		(sr.Error != nil && *sr.Error != *other.Error) ||
+		sr.MinDuration != other.MinDuration ||
+		len(sr.Tags) != len(other.Tags) ||
+		len(sr.Metrics) != len(other.Metrics) {
 		return false
 	}
 	for k, v := range sr.Tags {
@@ -167,9 +267,21 @@ func (sr *SamplingRule) EqualsFalseNegative(other *SamplingRule) bool {
 			return false
 		}
 	}
+	for i, mc := range sr.Metrics {
+		if mc != other.Metrics[i] {
+			return false
+		}
+	}
 	return true
 }
 
+// usesUpstream reports whether the rule uses any of the conditions on the trace context
+// extracted from upstream, or may override the upstream decision.
+func (sr *SamplingRule) usesUpstream() bool {
+	return sr.Origin != nil || sr.DecisionMaker != nil || sr.PropagationStyle != nil ||
+		sr.UpstreamPriority != nil || sr.OverrideUpstream
+}
+
 // match returns true when the span's details match all the expected values in the rule.
 func (sr *SamplingRule) match(s *span) bool {
 	if sr.Service != nil && !sr.Service.MatchString(s.Service) {
@@ -204,9 +316,75 @@ func (sr *SamplingRule) match(s *span) bool {
 			}
 		}
 	}
+	if sr.Error != nil && (s.Error != 0) != *sr.Error {
+		return false
+	}
+	if sr.MinDuration > 0 {
+		d := s.Duration
+		if !s.finished {
+			// the span is being finished, or it is still running
+			d = now() - s.Start
+		}
+		if time.Duration(d) < sr.MinDuration {
+			return false
+		}
+	}
+	if sr.SpanKind != nil && !sr.SpanKind.MatchString(s.Meta[ext.SpanKind]) {
+		return false
+	}
+	for _, mc := range sr.Metrics {
+		v, ok := s.numericTag(mc.Key)
+		if !ok || !mc.matches(v) {
+			return false
+		}
+	}
+	return sr.matchUpstream(s)
+}
+
+// matchUpstream returns true when the trace context s was extracted from matches the
+// upstream conditions of the rule. s must be locked.
+func (sr *SamplingRule) matchUpstream(s *span) bool {
+	if sr.Origin == nil && sr.DecisionMaker == nil && sr.PropagationStyle == nil && sr.UpstreamPriority == nil {
+		return true
+	}
+	var (
+		origin   string
+		upstream upstreamContext
+	)
+	if s.context != nil {
+		origin = s.context.origin
+		if s.context.upstream != nil {
+			upstream = *s.context.upstream
+		}
+	}
+	if sr.Origin != nil && !sr.Origin.MatchString(origin) {
+		return false
+	}
+	if sr.DecisionMaker != nil && !sr.DecisionMaker.MatchString(upstream.decisionMaker) {
+		return false
+	}
+	if sr.PropagationStyle != nil && !sr.PropagationStyle.MatchString(upstream.propagationStyle) {
+		return false
+	}
+	if sr.UpstreamPriority != nil && upstream.hasPriority != *sr.UpstreamPriority {
+		return false
+	}
 	return true
 }
 
+// numericTag returns the value of the metric key, or of the tag key if it holds a number.
+// s must be locked.
+func (s *span) numericTag(key string) (float64, bool) {
+	if v, ok := s.Metrics[key]; ok {
+		return v, true
+	}
+	if v, ok := s.Meta[key]; ok {
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
 // SamplingRuleType represents a type of sampling rule spans are matched against.
 type SamplingRuleType int
 
@@ -299,6 +477,50 @@ func TagsResourceRule(tags map[string]string, resource, name, service string, ra
 	}
 }
 
+// ErrorRule returns a SamplingRule that applies the provided sampling rate to traces
+// whose root span is an error and matches the service and name glob patterns provided.
+func ErrorRule(name, service string, rate float64) SamplingRule {
+	isError := true
+	return SamplingRule{
+		Service:  globMatch(service),
+		Name:     globMatch(name),
+		Error:    &isError,
+		Rate:     rate,
+		ruleType: SamplingRuleTrace,
+		globRule: &jsonRule{Name: name, Service: service, Error: &isError},
+	}
+}
+
+// SpanErrorRule returns a SamplingRule of type SamplingRuleSpan that applies the provided
+// sampling rate to error spans matching the operation and service name glob patterns provided.
+func SpanErrorRule(name, service string, rate float64) SamplingRule {
+	isError := true
+	return SamplingRule{
+		Service:  globMatch(service),
+		Name:     globMatch(name),
+		Error:    &isError,
+		Rate:     rate,
+		ruleType: SamplingRuleSpan,
+		limiter:  newSingleSpanRateLimiter(0),
+		globRule: &jsonRule{Name: name, Service: service, Error: &isError},
+	}
+}
+
+// SpanDurationRule returns a SamplingRule of type SamplingRuleSpan that applies the provided
+// sampling rate to spans lasting at least minDuration and matching the operation and service
+// name glob patterns provided.
+func SpanDurationRule(name, service string, minDuration time.Duration, rate float64) SamplingRule {
+	return SamplingRule{
+		Service:     globMatch(service),
+		Name:        globMatch(name),
+		MinDuration: minDuration,
+		Rate:        rate,
+		ruleType:    SamplingRuleSpan,
+		limiter:     newSingleSpanRateLimiter(0),
+		globRule:    &jsonRule{Name: name, Service: service, MinDuration: minDuration.String()},
+	}
+}
+
 // SpanTagsResourceRule returns a SamplingRule that applies the provided sampling rate to spans that match
 // resource, name, service and tags provided. Values of the tags map are expected to be in glob format.
 func SpanTagsResourceRule(tags map[string]string, resource, name, service string, rate float64) SamplingRule {
@@ -368,6 +590,7 @@ type traceRulesSampler struct {
 	rules      []SamplingRule // the rules to match spans with
 	globalRate float64        // a rate to apply when no rules match a span
 	limiter    *rateLimiter   // used to limit the volume of spans sampled
+	debug      bool           // whether to record sampling debug tags on root spans
 }
 
 // newTraceRulesSampler configures a *traceRulesSampler instance using the given set of rules.
@@ -386,6 +609,19 @@ func (rs *traceRulesSampler) enabled() bool {
 	return len(rs.rules) > 0 || !math.IsNaN(rs.globalRate)
 }
 
+// hasUpstreamRules reports whether any of the rules uses the conditions on the trace
+// context extracted from upstream, see SamplingRule.usesUpstream.
+func (rs *traceRulesSampler) hasUpstreamRules() bool {
+	rs.m.RLock()
+	defer rs.m.RUnlock()
+	for i := range rs.rules {
+		if rs.rules[i].usesUpstream() {
+			return true
+		}
+	}
+	return false
+}
+
 // Tests whether two sets of the rules are the same.
 // This returns result that can be false negative. If the result is true, then the two sets of rules
 // are guaranteed to be the same.
@@ -457,7 +693,7 @@ func (rs *traceRulesSampler) sampleGlobalRate(span *span) bool {
 	// being deprecated in favor of sampling rules.
 	// Note that this just preserves an existing behavior even though it is not correct.
 	sampler := samplernames.RuleRate
-	rs.applyRate(span, rate, time.Now(), sampler)
+	rs.applyRate(span, rate, time.Now(), sampler, -1, nil)
 	return true
 }
 
@@ -465,19 +701,41 @@ func (rs *traceRulesSampler) sampleGlobalRate(span *span) bool {
 // provided span. If the rules don't match, then it returns false and the span is not
 // modified.
 func (rs *traceRulesSampler) sampleRules(span *span) bool {
+	return rs.sampleMatchingRule(span, false)
+}
+
+// sampleUpstreamRules uses the sampling rules allowed to override upstream decisions
+// (see SamplingRule.OverrideUpstream) to determine the sampling rate for the provided
+// span, the local root of a trace whose sampling decision was extracted from a carrier.
+// If the rules don't match, then it returns false and the upstream decision is kept.
+func (rs *traceRulesSampler) sampleUpstreamRules(span *span) bool {
+	return rs.sampleMatchingRule(span, true)
+}
+
+// sampleMatchingRule applies the first rule matching span, only considering the rules
+// which can override upstream decisions if overrideOnly is true.
+func (rs *traceRulesSampler) sampleMatchingRule(span *span, overrideOnly bool) bool {
 	if !rs.enabled() {
 		// short path when disabled
 		return false
 	}
 
-	var matched bool
+	var (
+		matched     bool
+		index       int
+		matchedRule *SamplingRule
+	)
 	rs.m.RLock()
 	rate := rs.globalRate
 	rs.m.RUnlock()
 	sampler := samplernames.RuleRate
-	for _, rule := range rs.rules {
+	for i, rule := range rs.rules {
+		if overrideOnly && !rule.OverrideUpstream {
+			continue
+		}
 		if rule.match(span) {
 			matched = true
+			index, matchedRule = i, &rs.rules[i]
 			rate = rule.Rate
 			if rule.Provenance == Customer {
 				sampler = samplernames.RemoteUserRule
@@ -493,18 +751,36 @@ func (rs *traceRulesSampler) sampleRules(span *span) bool {
 		return false
 	}
 
-	rs.applyRate(span, rate, time.Now(), sampler)
+	rs.applyRate(span, rate, time.Now(), sampler, index, matchedRule)
 	return true
 }
 
-func (rs *traceRulesSampler) applyRate(span *span, rate float64, now time.Time, sampler samplernames.SamplerName) {
+// applyRate applies the given rate and the rate limiter to span. The index and rule
+// are the matched trace sampling rule, or -1 and nil when the global rate is applied.
+func (rs *traceRulesSampler) applyRate(span *span, rate float64, now time.Time, sampler samplernames.SamplerName, index int, rule *SamplingRule) {
 	span.Lock()
 	defer span.Unlock()
 
+	debug := rs != nil && rs.debug
+	if debug {
+		span.resetSamplingDebugTags()
+		if rule != nil {
+			span.setMeta(keySamplingDebugSampler, SamplerRule)
+			span.setMetric(keySamplingDebugRuleIndex, float64(index))
+			span.setMeta(keySamplingDebugRule, rule.String())
+		} else {
+			span.setMeta(keySamplingDebugSampler, SamplerGlobalRate)
+		}
+		span.setMetric(keySamplingDebugRate, rate)
+		span.setMetric(keySamplingDebugLimit, float64(rs.limiter.limiter.Limit()))
+	}
 	span.setMetric(keyRulesSamplerAppliedRate, rate)
 	delete(span.Metrics, keySamplingPriorityRate)
 	if !sampledByRate(span.TraceID, rate) {
 		span.setSamplingPriorityLocked(ext.PriorityUserReject, sampler)
+		if debug {
+			span.setMeta(keySamplingDebugLimiter, LimiterNotApplied)
+		}
 		return
 	}
 
@@ -515,6 +791,13 @@ func (rs *traceRulesSampler) applyRate(span *span, rate float64, now time.Time,
 		span.setSamplingPriorityLocked(ext.PriorityUserReject, sampler)
 	}
 	span.setMetric(keyRulesSamplerLimiterRate, rate)
+	if debug {
+		if sampled {
+			span.setMeta(keySamplingDebugLimiter, LimiterAllowed)
+		} else {
+			span.setMeta(keySamplingDebugLimiter, LimiterRejected)
+		}
+	}
 }
 
 // limit returns the rate limit set in the rules sampler, controlled by DD_TRACE_RATE_LIMIT, and
@@ -750,14 +1033,23 @@ func (sr *SamplingRule) UnmarshalJSON(b []byte) error {
 }
 
 type jsonRule struct {
-	Service      string            `json:"service"`
-	Name         string            `json:"name"`
-	Rate         json.Number       `json:"sample_rate"`
-	MaxPerSecond float64           `json:"max_per_second"`
-	Resource     string            `json:"resource"`
-	Tags         map[string]string `json:"tags"`
-	Type         *SamplingRuleType `json:"type,omitempty"`
-	Provenance   provenance        `json:"provenance,omitempty"`
+	Service          string            `json:"service"`
+	Name             string            `json:"name"`
+	Rate             json.Number       `json:"sample_rate"`
+	MaxPerSecond     float64           `json:"max_per_second"`
+	Resource         string            `json:"resource"`
+	Tags             map[string]string `json:"tags"`
+	Type             *SamplingRuleType `json:"type,omitempty"`
+	Provenance       provenance        `json:"provenance,omitempty"`
+	Error            *bool             `json:"error,omitempty"`
+	MinDuration      string            `json:"min_duration,omitempty"`
+	SpanKind         string            `json:"span_kind,omitempty"`
+	Metrics          []MetricCondition `json:"metrics,omitempty"`
+	Origin           string            `json:"origin,omitempty"`
+	DecisionMaker    string            `json:"decision_maker,omitempty"`
+	PropagationStyle string            `json:"propagation_style,omitempty"`
+	UpstreamPriority *bool             `json:"upstream_priority,omitempty"`
+	OverrideUpstream bool              `json:"override_upstream,omitempty"`
 }
 
 func (j jsonRule) String() string {
@@ -786,6 +1078,33 @@ func (j jsonRule) String() string {
 	if j.Provenance != Local {
 		s = append(s, fmt.Sprintf("Provenance: %v", j.Provenance.String()))
 	}
+	if j.Error != nil {
+		s = append(s, fmt.Sprintf("Error:%t", *j.Error))
+	}
+	if j.MinDuration != "" {
+		s = append(s, fmt.Sprintf("MinDuration:%s", j.MinDuration))
+	}
+	if j.SpanKind != "" {
+		s = append(s, fmt.Sprintf("SpanKind:%s", j.SpanKind))
+	}
+	if len(j.Metrics) != 0 {
+		s = append(s, fmt.Sprintf("Metrics:%v", j.Metrics))
+	}
+	if j.Origin != "" {
+		s = append(s, fmt.Sprintf("Origin:%s", j.Origin))
+	}
+	if j.DecisionMaker != "" {
+		s = append(s, fmt.Sprintf("DecisionMaker:%s", j.DecisionMaker))
+	}
+	if j.PropagationStyle != "" {
+		s = append(s, fmt.Sprintf("PropagationStyle:%s", j.PropagationStyle))
+	}
+	if j.UpstreamPriority != nil {
+		s = append(s, fmt.Sprintf("UpstreamPriority:%t", *j.UpstreamPriority))
+	}
+	if j.OverrideUpstream {
+		s = append(s, "OverrideUpstream:true")
+	}
 	return fmt.Sprintf("{%s}", strings.Join(s, " "))
 }
 
@@ -826,21 +1145,42 @@ func validateRules(jsonRules []jsonRule, spanType SamplingRuleType) ([]SamplingR
 			)
 			continue
 		}
+		var minDuration time.Duration
+		if v.MinDuration != "" {
+			minDuration, err = time.ParseDuration(v.MinDuration)
+			if err != nil || minDuration < 0 {
+				errs = append(errs, fmt.Sprintf("at index %d: ignoring rule %s: invalid min_duration", i, v.String()))
+				continue
+			}
+		}
+		if err := validateMetricConditions(v.Metrics); err != nil {
+			errs = append(errs, fmt.Sprintf("at index %d: ignoring rule %s: %v", i, v.String(), err))
+			continue
+		}
 		tagGlobs := make(map[string]*regexp.Regexp, len(v.Tags))
 		for k, g := range v.Tags {
 			tagGlobs[k] = globMatch(g)
 		}
 		rules = append(rules, SamplingRule{
-			Service:      globMatch(v.Service),
-			Name:         globMatch(v.Name),
-			Rate:         rate,
-			MaxPerSecond: v.MaxPerSecond,
-			Resource:     globMatch(v.Resource),
-			Tags:         tagGlobs,
-			Provenance:   v.Provenance,
-			ruleType:     spanType,
-			limiter:      newSingleSpanRateLimiter(v.MaxPerSecond),
-			globRule:     &jsonRules[i],
+			Service:          globMatch(v.Service),
+			Name:             globMatch(v.Name),
+			Rate:             rate,
+			MaxPerSecond:     v.MaxPerSecond,
+			Resource:         globMatch(v.Resource),
+			Tags:             tagGlobs,
+			Error:            v.Error,
+			MinDuration:      minDuration,
+			SpanKind:         globMatch(v.SpanKind),
+			Metrics:          v.Metrics,
+			Origin:           globMatch(v.Origin),
+			DecisionMaker:    globMatch(v.DecisionMaker),
+			PropagationStyle: globMatch(v.PropagationStyle),
+			UpstreamPriority: v.UpstreamPriority,
+			OverrideUpstream: v.OverrideUpstream,
+			Provenance:       v.Provenance,
+			ruleType:         spanType,
+			limiter:          newSingleSpanRateLimiter(v.MaxPerSecond),
+			globRule:         &jsonRules[i],
 		})
 	}
 	if len(errs) != 0 {
@@ -849,22 +1189,46 @@ func validateRules(jsonRules []jsonRule, spanType SamplingRuleType) ([]SamplingR
 	return rules, nil
 }
 
+// validateMetricConditions returns an error if any of the conditions of the "metrics"
+// field of a JSON rule is invalid.
+func validateMetricConditions(conds []MetricCondition) error {
+	for _, mc := range conds {
+		if err := mc.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // MarshalJSON implements the json.Marshaler interface.
 func (sr SamplingRule) MarshalJSON() ([]byte, error) {
 	s := struct {
-		Service      string            `json:"service,omitempty"`
-		Name         string            `json:"name,omitempty"`
-		Resource     string            `json:"resource,omitempty"`
-		Rate         float64           `json:"sample_rate"`
-		Tags         map[string]string `json:"tags,omitempty"`
-		MaxPerSecond *float64          `json:"max_per_second,omitempty"`
-		Provenance   string            `json:"provenance,omitempty"`
+		Service          string            `json:"service,omitempty"`
+		Name             string            `json:"name,omitempty"`
+		Resource         string            `json:"resource,omitempty"`
+		Rate             float64           `json:"sample_rate"`
+		Tags             map[string]string `json:"tags,omitempty"`
+		MaxPerSecond     *float64          `json:"max_per_second,omitempty"`
+		Provenance       string            `json:"provenance,omitempty"`
+		Error            *bool             `json:"error,omitempty"`
+		MinDuration      string            `json:"min_duration,omitempty"`
+		SpanKind         string            `json:"span_kind,omitempty"`
+		Metrics          []MetricCondition `json:"metrics,omitempty"`
+		Origin           string            `json:"origin,omitempty"`
+		DecisionMaker    string            `json:"decision_maker,omitempty"`
+		PropagationStyle string            `json:"propagation_style,omitempty"`
+		UpstreamPriority *bool             `json:"upstream_priority,omitempty"`
+		OverrideUpstream bool              `json:"override_upstream,omitempty"`
 	}{}
 	if sr.globRule != nil {
 		s.Service = sr.globRule.Service
 		s.Name = sr.globRule.Name
 		s.Resource = sr.globRule.Resource
 		s.Tags = sr.globRule.Tags
+		s.SpanKind = sr.globRule.SpanKind
+		s.Origin = sr.globRule.Origin
+		s.DecisionMaker = sr.globRule.DecisionMaker
+		s.PropagationStyle = sr.globRule.PropagationStyle
 	} else {
 		if sr.Service != nil {
 			s.Service = sr.Service.String()
@@ -881,10 +1245,29 @@ func (sr SamplingRule) MarshalJSON() ([]byte, error) {
 				s.Tags[k] = v.String()
 			}
 		}
+		if sr.SpanKind != nil {
+			s.SpanKind = sr.SpanKind.String()
+		}
+		if sr.Origin != nil {
+			s.Origin = sr.Origin.String()
+		}
+		if sr.DecisionMaker != nil {
+			s.DecisionMaker = sr.DecisionMaker.String()
+		}
+		if sr.PropagationStyle != nil {
+			s.PropagationStyle = sr.PropagationStyle.String()
+		}
 	}
+	s.UpstreamPriority = sr.UpstreamPriority
+	s.OverrideUpstream = sr.OverrideUpstream
 	if sr.MaxPerSecond != 0 {
 		s.MaxPerSecond = &sr.MaxPerSecond
 	}
+	s.Error = sr.Error
+	if sr.MinDuration != 0 {
+		s.MinDuration = sr.MinDuration.String()
+	}
+	s.Metrics = sr.Metrics
 	s.Rate = sr.Rate
 	if sr.Provenance != Local {
 		s.Provenance = sr.Provenance.String()
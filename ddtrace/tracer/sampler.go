@@ -96,6 +96,7 @@ type prioritySampler struct {
 	mu          sync.RWMutex
 	rates       map[string]float64
 	defaultRate float64
+	debug       bool // whether to record sampling debug tags on root spans
 }
 
 func newPrioritySampler() *prioritySampler {
@@ -128,23 +129,44 @@ func (ps *prioritySampler) readRatesJSON(rc io.ReadCloser) error {
 // getRate returns the sampling rate to be used for the given span. Callers must
 // guard the span.
 func (ps *prioritySampler) getRate(spn *span) float64 {
+	rate, _ := ps.getRateAndKey(spn)
+	return rate
+}
+
+// getRateAndKey returns the sampling rate to be used for the given span, along with
+// the key of the agent rate, or "" if the default rate applies. Callers must guard the span.
+func (ps *prioritySampler) getRateAndKey(spn *span) (float64, string) {
 	key := "service:" + spn.Service + ",env:" + spn.Meta[ext.Environment]
 	ps.mu.RLock()
 	defer ps.mu.RUnlock()
 	if rate, ok := ps.rates[key]; ok {
-		return rate
+		return rate, key
 	}
-	return ps.defaultRate
+	return ps.defaultRate, ""
 }
 
 // apply applies sampling priority to the given span. Caller must ensure it is safe
 // to modify the span.
 func (ps *prioritySampler) apply(spn *span) {
-	rate := ps.getRate(spn)
+	rate, key := ps.getRateAndKey(spn)
 	if sampledByRate(spn.TraceID, rate) {
 		spn.setSamplingPriority(ext.PriorityAutoKeep, samplernames.AgentRate)
 	} else {
 		spn.setSamplingPriority(ext.PriorityAutoReject, samplernames.AgentRate)
 	}
 	spn.SetTag(keySamplingPriorityRate, rate)
+	if ps.debug {
+		spn.Lock()
+		defer spn.Unlock()
+		spn.resetSamplingDebugTags()
+		if key != "" {
+			spn.setMeta(keySamplingDebugSampler, SamplerAgentRate)
+			spn.setMeta(keySamplingDebugAgentRateKey, key)
+		} else {
+			spn.setMeta(keySamplingDebugSampler, SamplerDefaultRate)
+			spn.setMeta(keySamplingDebugAgentRateKey, "default")
+		}
+		spn.setMetric(keySamplingDebugRate, rate)
+		spn.setMeta(keySamplingDebugLimiter, LimiterNotApplied)
+	}
 }
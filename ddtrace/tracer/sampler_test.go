@@ -7,6 +7,7 @@ package tracer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -21,6 +22,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/time/rate"
 )
 
@@ -1241,7 +1243,7 @@ func TestRulesSamplerInternals(t *testing.T) {
 		now := time.Now()
 		rs := &rulesSampler{}
 		span := makeSpanAt("http.request", "test-service", now)
-		rs.traces.applyRate(span, 0.0, now, samplernames.RuleRate)
+		rs.traces.applyRate(span, 0.0, now, samplernames.RuleRate, -1, nil)
 		assert.Equal(0.0, span.Metrics[keyRulesSamplerAppliedRate])
 		_, ok := span.Metrics[keyRulesSamplerLimiterRate]
 		assert.False(ok)
@@ -1257,7 +1259,7 @@ func TestRulesSamplerInternals(t *testing.T) {
 		rs.traces.limiter.seen = 1
 
 		span := makeSpanAt("http.request", "test-service", now)
-		rs.traces.applyRate(span, 1.0, now, samplernames.RuleRate)
+		rs.traces.applyRate(span, 1.0, now, samplernames.RuleRate, -1, nil)
 		assert.Equal(1.0, span.Metrics[keyRulesSamplerAppliedRate])
 		assert.Equal(1.0, span.Metrics[keyRulesSamplerLimiterRate])
 	})
@@ -1273,12 +1275,12 @@ func TestRulesSamplerInternals(t *testing.T) {
 		rs.traces.limiter.seen = 2
 		// first span kept, second dropped
 		span := makeSpanAt("http.request", "test-service", now)
-		rs.traces.applyRate(span, 1.0, now, samplernames.RuleRate)
+		rs.traces.applyRate(span, 1.0, now, samplernames.RuleRate, -1, nil)
 		assert.EqualValues(ext.PriorityUserKeep, span.Metrics[keySamplingPriority])
 		assert.Equal(1.0, span.Metrics[keyRulesSamplerAppliedRate])
 		assert.Equal(1.0, span.Metrics[keyRulesSamplerLimiterRate])
 		span = makeSpanAt("http.request", "test-service", now)
-		rs.traces.applyRate(span, 1.0, now, samplernames.RuleRate)
+		rs.traces.applyRate(span, 1.0, now, samplernames.RuleRate, -1, nil)
 		assert.EqualValues(ext.PriorityUserReject, span.Metrics[keySamplingPriority])
 		assert.Equal(1.0, span.Metrics[keyRulesSamplerAppliedRate])
 		assert.Equal(0.75, span.Metrics[keyRulesSamplerLimiterRate])
@@ -1500,6 +1502,278 @@ func TestGlobMatch(t *testing.T) {
 	}
 }
 
+func TestSamplingRuleConditions(t *testing.T) {
+	makeFinishedSpan := func(err bool, d time.Duration, tags map[string]interface{}) *span {
+		s := newSpan("http.request", "web.service", "GET /", randUint64(), randUint64(), 0)
+		for k, v := range tags {
+			s.SetTag(k, v)
+		}
+		if err {
+			s.Error = 1
+		}
+		s.Duration = int64(d)
+		s.finished = true
+		return s
+	}
+
+	for name, tt := range map[string]struct {
+		rules string
+		span  *span
+		match bool
+	}{
+		"error":                  {`[{"error": true}]`, makeFinishedSpan(true, 0, nil), true},
+		"error-no-match":         {`[{"error": true}]`, makeFinishedSpan(false, 0, nil), false},
+		"no-error":               {`[{"error": false}]`, makeFinishedSpan(false, 0, nil), true},
+		"no-error-no-match":      {`[{"error": false}]`, makeFinishedSpan(true, 0, nil), false},
+		"duration":               {`[{"min_duration": "1s"}]`, makeFinishedSpan(false, 2*time.Second, nil), true},
+		"duration-equal":         {`[{"min_duration": "1s"}]`, makeFinishedSpan(false, time.Second, nil), true},
+		"duration-no-match":      {`[{"min_duration": "1s"}]`, makeFinishedSpan(false, time.Millisecond, nil), false},
+		"span-kind":              {`[{"span_kind": "serv*"}]`, makeFinishedSpan(false, 0, map[string]interface{}{ext.SpanKind: ext.SpanKindServer}), true},
+		"span-kind-no-match":     {`[{"span_kind": "client"}]`, makeFinishedSpan(false, 0, map[string]interface{}{ext.SpanKind: ext.SpanKindServer}), false},
+		"span-kind-missing":      {`[{"span_kind": "client"}]`, makeFinishedSpan(false, 0, nil), false},
+		"metric-tag":             {`[{"metrics": [{"key": "http.status_code", "op": ">=", "value": 500}]}]`, makeFinishedSpan(false, 0, map[string]interface{}{ext.HTTPCode: 503}), true},
+		"metric-tag-no-match":    {`[{"metrics": [{"key": "http.status_code", "op": ">=", "value": 500}]}]`, makeFinishedSpan(false, 0, map[string]interface{}{ext.HTTPCode: 404}), false},
+		"metric":                 {`[{"metrics": [{"key": "retries", "op": ">", "value": 2}]}]`, makeFinishedSpan(false, 0, map[string]interface{}{"retries": 3}), true},
+		"metric-missing":         {`[{"metrics": [{"key": "retries", "op": "<", "value": 2}]}]`, makeFinishedSpan(false, 0, nil), false},
+		"metric-not-a-number":    {`[{"metrics": [{"key": "retries", "op": "!=", "value": 2}]}]`, makeFinishedSpan(false, 0, map[string]interface{}{"retries": "many"}), false},
+		"metrics-all":            {`[{"metrics": [{"key": "a", "op": "==", "value": 1}, {"key": "b", "op": "<=", "value": 2}]}]`, makeFinishedSpan(false, 0, map[string]interface{}{"a": 1, "b": 2}), true},
+		"metrics-all-no-match":   {`[{"metrics": [{"key": "a", "op": "==", "value": 1}, {"key": "b", "op": "<", "value": 2}]}]`, makeFinishedSpan(false, 0, map[string]interface{}{"a": 1, "b": 2}), false},
+		"metrics-range":          {`[{"metrics": [{"key": "http.status_code", "op": ">=", "value": 400}, {"key": "http.status_code", "op": "<", "value": 500}]}]`, makeFinishedSpan(false, 0, map[string]interface{}{ext.HTTPCode: 404}), true},
+		"metrics-range-no-match": {`[{"metrics": [{"key": "http.status_code", "op": ">=", "value": 400}, {"key": "http.status_code", "op": "<", "value": 500}]}]`, makeFinishedSpan(false, 0, map[string]interface{}{ext.HTTPCode: 503}), false},
+		"combined":               {`[{"service": "web.*", "error": true, "min_duration": "100ms"}]`, makeFinishedSpan(true, time.Second, nil), true},
+		"combined-no-match":      {`[{"service": "web.*", "error": true, "min_duration": "100ms"}]`, makeFinishedSpan(true, time.Millisecond, nil), false},
+		"first-matching-rule":    {`[{"error": true, "sample_rate": 0}, {"sample_rate": 1}]`, makeFinishedSpan(false, 0, nil), true},
+		"first-matching-rule-2":  {`[{"error": true, "sample_rate": 1}, {"sample_rate": 0}]`, makeFinishedSpan(true, 0, nil), true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Run("trace", func(t *testing.T) {
+				t.Setenv("DD_TRACE_SAMPLING_RULES", tt.rules)
+				rules, _, err := samplingRulesFromEnv()
+				require.NoError(t, err)
+				rs := newRulesSampler(rules, nil, math.NaN())
+				assert.Equal(t, tt.match, rs.SampleTrace(tt.span))
+			})
+			t.Run("span", func(t *testing.T) {
+				t.Setenv("DD_SPAN_SAMPLING_RULES", tt.rules)
+				_, rules, err := samplingRulesFromEnv()
+				require.NoError(t, err)
+				rs := newRulesSampler(nil, rules, math.NaN())
+				matched := false
+				for _, r := range rs.spans.rules {
+					if r.match(tt.span) {
+						matched = true
+						break
+					}
+				}
+				assert.Equal(t, tt.match, matched)
+			})
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, rules := range []string{
+			`[{"min_duration": "1 second"}]`,
+			`[{"min_duration": "-1s"}]`,
+			`[{"metrics": [{"key": "http.status_code", "value": 500}]}]`,
+			`[{"metrics": [{"key": "http.status_code", "op": "=>", "value": 500}]}]`,
+			`[{"metrics": [{"op": ">=", "value": 500}]}]`,
+			`[{"metrics": [{"key": "http.status_code", "op": ">=", "value": "5xx"}]}]`,
+			`[{"metrics": {"http.status_code": ">=500"}}]`,
+		} {
+			t.Run("", func(t *testing.T) {
+				t.Setenv("DD_TRACE_SAMPLING_RULES", rules)
+				rules, _, err := samplingRulesFromEnv()
+				assert.Error(t, err)
+				assert.Empty(t, rules)
+			})
+		}
+	})
+
+	t.Run("go-api", func(t *testing.T) {
+		assert := assert.New(t)
+		rs := newRulesSampler(nil, []SamplingRule{
+			SpanErrorRule("http.*", "", 1),
+			SpanDurationRule("", "web.*", time.Second, 1),
+			{Metrics: []MetricCondition{{Key: ext.HTTPCode, Op: ">", Value: 499}}, Rate: 1, ruleType: SamplingRuleSpan},
+		}, math.NaN())
+		assert.True(rs.SampleSpan(makeFinishedSpan(true, 0, nil)))
+		assert.True(rs.SampleSpan(makeFinishedSpan(false, time.Minute, nil)))
+		assert.True(rs.SampleSpan(makeFinishedSpan(false, 0, map[string]interface{}{ext.HTTPCode: "500"})))
+		assert.False(rs.SampleSpan(makeFinishedSpan(false, time.Millisecond, map[string]interface{}{ext.HTTPCode: "200"})))
+	})
+
+	t.Run("root-finish", func(t *testing.T) {
+		// Trace sampling rules are applied again when the root span finishes, so that
+		// rules on the error status and duration can apply.
+		t.Setenv("DD_TRACE_SAMPLING_RULES", `[{"error": true, "sample_rate": 1}, {"min_duration": "1h", "sample_rate": 1}, {"sample_rate": 0}]`)
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+
+		ok := tracer.StartSpan("ok").(*span)
+		ok.Finish()
+		p, _ := ok.context.SamplingPriority()
+		assert.Equal(t, ext.PriorityUserReject, p)
+
+		failed := tracer.StartSpan("failed").(*span)
+		failed.Finish(WithError(errors.New("oops")))
+		p, _ = failed.context.SamplingPriority()
+		assert.Equal(t, ext.PriorityUserKeep, p)
+
+		slow := tracer.StartSpan("slow", StartTime(time.Now().Add(-2*time.Hour))).(*span)
+		slow.Finish()
+		p, _ = slow.context.SamplingPriority()
+		assert.Equal(t, ext.PriorityUserKeep, p)
+	})
+}
+
+func TestSamplingRulesUpstream(t *testing.T) {
+	datadogHeaders := func(priority, dm, origin string) TextMapCarrier {
+		c := TextMapCarrier{
+			DefaultTraceIDHeader:  "1",
+			DefaultParentIDHeader: "2",
+		}
+		if priority != "" {
+			c[DefaultPriorityHeader] = priority
+		}
+		if dm != "" {
+			c[traceTagsHeader] = keyDecisionMaker + "=" + dm
+		}
+		if origin != "" {
+			c[originHeader] = origin
+		}
+		return c
+	}
+	w3cHeaders := TextMapCarrier{
+		traceparentHeader: "00-00000000000000000000000000000001-0000000000000002-01",
+	}
+
+	for name, tt := range map[string]struct {
+		rules    string
+		carrier  TextMapCarrier
+		priority int
+	}{
+		"origin": {
+			rules:    `[{"origin": "synthetics*", "sample_rate": 0}]`,
+			carrier:  datadogHeaders("", "", "synthetics-browser"),
+			priority: ext.PriorityUserReject,
+		},
+		"origin-no-match": {
+			rules:    `[{"origin": "rum", "sample_rate": 0}, {"sample_rate": 1}]`,
+			carrier:  datadogHeaders("", "", "synthetics"),
+			priority: ext.PriorityUserKeep,
+		},
+		"no-upstream-priority": {
+			rules:    `[{"upstream_priority": false, "sample_rate": 0}]`,
+			carrier:  datadogHeaders("", "", ""),
+			priority: ext.PriorityUserReject,
+		},
+		"upstream-decision-kept": {
+			rules:    `[{"sample_rate": 0}]`,
+			carrier:  datadogHeaders("2", "-4", ""),
+			priority: ext.PriorityUserKeep,
+		},
+		"override": {
+			rules:    `[{"upstream_priority": true, "override_upstream": true, "sample_rate": 0}]`,
+			carrier:  datadogHeaders("2", "-4", ""),
+			priority: ext.PriorityUserReject,
+		},
+		"override-decision-maker": {
+			rules:    `[{"decision_maker": "-4", "override_upstream": true, "sample_rate": 0}]`,
+			carrier:  datadogHeaders("2", "-4", ""),
+			priority: ext.PriorityUserReject,
+		},
+		"override-decision-maker-no-match": {
+			rules:    `[{"decision_maker": "-4", "override_upstream": true, "sample_rate": 0}]`,
+			carrier:  datadogHeaders("1", "-1", ""),
+			priority: ext.PriorityAutoKeep,
+		},
+		"override-propagation-style": {
+			rules:    `[{"propagation_style": "tracecontext", "override_upstream": true, "sample_rate": 0}]`,
+			carrier:  w3cHeaders,
+			priority: ext.PriorityUserReject,
+		},
+		"override-propagation-style-no-match": {
+			rules:    `[{"propagation_style": "tracecontext", "override_upstream": true, "sample_rate": 0}]`,
+			carrier:  datadogHeaders("1", "", ""),
+			priority: ext.PriorityAutoKeep,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("DD_TRACE_SAMPLING_RULES", tt.rules)
+			tracer, _, _, stop := startTestTracer(t)
+			defer stop()
+
+			sctx, err := tracer.Extract(tt.carrier)
+			require.NoError(t, err)
+			root := tracer.StartSpan("http.request", ChildOf(sctx)).(*span)
+			p, ok := root.context.SamplingPriority()
+			require.True(t, ok)
+			assert.Equal(t, tt.priority, p)
+
+			// the rules only consider the local root span of the trace
+			child := tracer.StartSpan("child", ChildOf(root.Context())).(*span)
+			p, _ = child.context.SamplingPriority()
+			assert.Equal(t, tt.priority, p)
+		})
+	}
+
+	t.Run("finish", func(t *testing.T) {
+		for name, tt := range map[string]struct {
+			rules    string
+			priority int
+		}{
+			// once the rules use the upstream conditions, the rules applied again
+			// when the local root span finishes must not override the upstream
+			// decision either.
+			"upstream-rules": {`[{"upstream_priority": false, "sample_rate": 1}, {"sample_rate": 0}]`, ext.PriorityAutoKeep},
+			// rules without the upstream conditions apply again, as they always did.
+			"legacy-rules": {`[{"sample_rate": 0}]`, ext.PriorityUserReject},
+		} {
+			t.Run(name, func(t *testing.T) {
+				t.Setenv("DD_TRACE_SAMPLING_RULES", tt.rules)
+				tracer, _, _, stop := startTestTracer(t)
+				defer stop()
+
+				sctx, err := tracer.Extract(datadogHeaders("1", "", ""))
+				require.NoError(t, err)
+				root := tracer.StartSpan("http.request", ChildOf(sctx)).(*span)
+				root.Finish()
+				p, ok := root.context.SamplingPriority()
+				require.True(t, ok)
+				assert.Equal(t, tt.priority, p)
+			})
+		}
+	})
+
+	t.Run("local-trace", func(t *testing.T) {
+		t.Setenv("DD_TRACE_SAMPLING_RULES", `[{"upstream_priority": true, "sample_rate": 0}, {"propagation_style": "*", "origin": "*", "sample_rate": 1}]`)
+		tracer, _, _, stop := startTestTracer(t)
+		defer stop()
+
+		root := tracer.StartSpan("http.request").(*span)
+		p, _ := root.context.SamplingPriority()
+		assert.Equal(t, ext.PriorityUserKeep, p)
+	})
+
+	t.Run("unmarshal", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv("DD_TRACE_SAMPLING_RULES", `[{"origin": "rum", "decision_maker": "-4", "propagation_style": "b3*", "upstream_priority": true, "override_upstream": true, "sample_rate": 0.5}]`)
+		rules, _, err := samplingRulesFromEnv()
+		require.NoError(t, err)
+		require.Len(t, rules, 1)
+		r := rules[0]
+		assert.Regexp(r.Origin, "rum")
+		assert.Regexp(r.DecisionMaker, "-4")
+		assert.Regexp(r.PropagationStyle, "b3 single header")
+		require.NotNil(t, r.UpstreamPriority)
+		assert.True(*r.UpstreamPriority)
+		assert.True(r.OverrideUpstream)
+		assert.Equal(`{"sample_rate":0.5,"origin":"rum","decision_maker":"-4","propagation_style":"b3*","upstream_priority":true,"override_upstream":true}`, r.String())
+		assert.True(r.EqualsFalseNegative(&rules[0]))
+		assert.False(r.EqualsFalseNegative(&SamplingRule{Rate: 0.5, Origin: r.Origin}))
+	})
+}
+
 func TestSamplingRuleMarshall(t *testing.T) {
 	for i, tt := range []struct {
 		in  SamplingRule
@@ -1514,6 +1788,10 @@ func TestSamplingRuleMarshall(t *testing.T) {
 		{SpanNameServiceMPSRule("ops.*", "srv.*", 0.55, 1000), `{"service":"srv.*","name":"ops.*","sample_rate":0.55,"max_per_second":1000}`},
 		{TagsResourceRule(nil, "//bar", "", "", 1), `{"resource":"//bar","sample_rate":1}`},
 		{TagsResourceRule(map[string]string{"tag_key": "tag_value.*"}, "//bar", "", "", 1), `{"resource":"//bar","sample_rate":1,"tags":{"tag_key":"tag_value.*"}}`},
+		{ErrorRule("ops.*", "srv.*", 1), `{"service":"srv.*","name":"ops.*","sample_rate":1,"error":true}`},
+		{SpanErrorRule("ops.*", "", 0.5), `{"name":"ops.*","sample_rate":0.5,"error":true}`},
+		{SpanDurationRule("", "srv.*", 1500*time.Millisecond, 1), `{"service":"srv.*","sample_rate":1,"min_duration":"1.5s"}`},
+		{SamplingRule{Rate: 1, Metrics: []MetricCondition{{Key: "http.status_code", Op: ">=", Value: 500}}}, `{"sample_rate":1,"metrics":[{"key":"http.status_code","op":"\u003e=","value":500}]}`},
 	} {
 		m, err := tt.in.MarshalJSON()
 		assert.Nil(t, err)
@@ -0,0 +1,248 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package tracer
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
+)
+
+// The following set of tags is set on root spans by the samplers when sampling debug
+// is enabled, to explain the sampling decision of the trace. See WithSamplingDebug.
+const (
+	// keySamplingDebugSampler names the sampler which took the decision (see Sampler* constants).
+	keySamplingDebugSampler = "_dd.sampling.sampler"
+	// keySamplingDebugRuleIndex holds the index of the trace sampling rule that matched the root span.
+	keySamplingDebugRuleIndex = "_dd.sampling.rule_index"
+	// keySamplingDebugRule holds the JSON representation of the trace sampling rule that matched the root span.
+	keySamplingDebugRule = "_dd.sampling.rule"
+	// keySamplingDebugRate holds the sampling rate applied by the sampler.
+	keySamplingDebugRate = "_dd.sampling.rate"
+	// keySamplingDebugAgentRateKey holds the key of the agent rate applied, or "default".
+	keySamplingDebugAgentRateKey = "_dd.sampling.agent_rate_key"
+	// keySamplingDebugLimiter holds the decision of the rate limiter (see Limiter* constants).
+	keySamplingDebugLimiter = "_dd.sampling.limiter"
+	// keySamplingDebugLimit holds the number of traces per second allowed by the rate limiter.
+	keySamplingDebugLimit = "_dd.sampling.limit"
+)
+
+// Names of the samplers reported by ExplainSampling.
+const (
+	// SamplerRule is a trace sampling rule.
+	SamplerRule = "rule"
+	// SamplerGlobalRate is the global sample rate set with DD_TRACE_SAMPLE_RATE.
+	SamplerGlobalRate = "global_rate"
+	// SamplerAgentRate is a sample rate provided by the Datadog Agent.
+	SamplerAgentRate = "agent_rate"
+	// SamplerDefaultRate is the default sample rate, used until the agent provides rates.
+	SamplerDefaultRate = "default_rate"
+	// SamplerCustom is the Sampler set with WithSampler.
+	SamplerCustom = "sampler"
+	// SamplerManual is a manual decision, for instance using ext.ManualKeep.
+	SamplerManual = "manual"
+	// SamplerAppSec is a decision taken by Application Security Monitoring.
+	SamplerAppSec = "appsec"
+	// SamplerUpstream is a decision propagated by an upstream service.
+	SamplerUpstream = "upstream"
+	// SamplerUnknown is reported when the sampler can't be determined.
+	SamplerUnknown = "unknown"
+)
+
+// Decisions of the rate limiter reported by ExplainSampling.
+const (
+	// LimiterNotApplied is reported when the rate limiter wasn't consulted.
+	LimiterNotApplied = "not_applied"
+	// LimiterAllowed is reported when the rate limiter allowed the trace.
+	LimiterAllowed = "allowed"
+	// LimiterRejected is reported when the rate limiter rejected the trace.
+	LimiterRejected = "rejected"
+)
+
+var samplingDebugKeys = []string{
+	keySamplingDebugSampler,
+	keySamplingDebugRuleIndex,
+	keySamplingDebugRule,
+	keySamplingDebugRate,
+	keySamplingDebugAgentRateKey,
+	keySamplingDebugLimiter,
+	keySamplingDebugLimit,
+}
+
+// resetSamplingDebugTags removes the sampling debug tags of s, before a sampler
+// records a new decision. s must be locked.
+func (s *span) resetSamplingDebugTags() {
+	for _, k := range samplingDebugKeys {
+		delete(s.Meta, k)
+		delete(s.Metrics, k)
+	}
+}
+
+// SamplingExplanation explains the sampling decision of a trace.
+type SamplingExplanation struct {
+	// Priority is the sampling priority of the trace. It is only valid when Decided is true.
+	Priority int
+
+	// Decided reports whether a sampling decision was taken for the trace.
+	Decided bool
+
+	// DecisionMaker is the sampling mechanism propagated with kept traces, as in
+	// the _dd.p.dm tag (e.g. "-3" for sampling rules). It is empty for dropped traces.
+	DecisionMaker string
+
+	// Sampler names the sampler which took the decision, as one of the Sampler* constants.
+	Sampler string
+
+	// RuleIndex is the index of the trace sampling rule which matched, or -1 if none did.
+	RuleIndex int
+
+	// Rule is the JSON representation of the trace sampling rule which matched, if any.
+	Rule string
+
+	// Rate is the sampling rate applied by the sampler, or NaN if unknown.
+	Rate float64
+
+	// Limiter is the decision of the rate limiter, as one of the Limiter* constants.
+	Limiter string
+
+	// LimiterRate is the effective rate of the rate limiter, or NaN if it wasn't applied.
+	LimiterRate float64
+
+	// Limit is the number of traces per second allowed by the rate limiter, or NaN if unknown.
+	Limit float64
+
+	// AgentRateKey is the key of the agent rate which was applied, or "default".
+	AgentRateKey string
+
+	// Debug reports whether the explanation was recorded with sampling debug enabled.
+	// When false, the sampler, rule and limiter details are inferred and may be incomplete.
+	Debug bool
+}
+
+// String returns the explanation in a format suited for logs.
+func (e SamplingExplanation) String() string {
+	if !e.Decided {
+		return "no sampling decision"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "priority:%d sampler:%s", e.Priority, e.Sampler)
+	if e.DecisionMaker != "" {
+		fmt.Fprintf(&b, " decision_maker:%s", e.DecisionMaker)
+	}
+	if e.RuleIndex >= 0 {
+		fmt.Fprintf(&b, " rule_index:%d rule:%s", e.RuleIndex, e.Rule)
+	}
+	if e.AgentRateKey != "" {
+		fmt.Fprintf(&b, " agent_rate_key:%s", e.AgentRateKey)
+	}
+	if !math.IsNaN(e.Rate) {
+		fmt.Fprintf(&b, " rate:%g", e.Rate)
+	}
+	fmt.Fprintf(&b, " limiter:%s", e.Limiter)
+	if !math.IsNaN(e.LimiterRate) {
+		fmt.Fprintf(&b, " limiter_rate:%g", e.LimiterRate)
+	}
+	if !math.IsNaN(e.Limit) {
+		fmt.Fprintf(&b, " limit:%g", e.Limit)
+	}
+	return b.String()
+}
+
+// ExplainSampling returns the explanation of the sampling decision of the trace the
+// span s belongs to, and false if s was not created by this package's tracer. The most
+// detailed explanations are available when sampling debug is enabled using WithSamplingDebug
+// or DD_TRACE_SAMPLING_DEBUG.
+func ExplainSampling(s ddtrace.Span) (SamplingExplanation, bool) {
+	sp, ok := s.(*span)
+	if !ok || sp.context == nil || sp.context.trace == nil {
+		return SamplingExplanation{}, false
+	}
+	e := SamplingExplanation{
+		RuleIndex:   -1,
+		Rate:        math.NaN(),
+		Limiter:     LimiterNotApplied,
+		LimiterRate: math.NaN(),
+		Limit:       math.NaN(),
+	}
+	trace := sp.context.trace
+	e.Priority, e.Decided = sp.context.SamplingPriority()
+	e.DecisionMaker = trace.propagatingTag(keyDecisionMaker)
+	root := sp.root()
+	if root == nil {
+		root = sp
+	}
+	root.Lock()
+	defer root.Unlock()
+	if sampler, ok := root.Meta[keySamplingDebugSampler]; ok {
+		e.Debug = true
+		e.Sampler = sampler
+		if v, ok := root.Metrics[keySamplingDebugRuleIndex]; ok {
+			e.RuleIndex = int(v)
+		}
+		e.Rule = root.Meta[keySamplingDebugRule]
+		if v, ok := root.Metrics[keySamplingDebugRate]; ok {
+			e.Rate = v
+		}
+		e.AgentRateKey = root.Meta[keySamplingDebugAgentRateKey]
+		if v, ok := root.Meta[keySamplingDebugLimiter]; ok {
+			e.Limiter = v
+		}
+		if v, ok := root.Metrics[keySamplingDebugLimit]; ok {
+			e.Limit = v
+		}
+	} else {
+		e.Sampler = inferSampler(e.DecisionMaker, root)
+		if v, ok := root.Metrics[keyRulesSamplerAppliedRate]; ok {
+			e.Rate = v
+		} else if v, ok := root.Metrics[keySamplingPriorityRate]; ok {
+			e.Rate = v
+		}
+	}
+	switch e.DecisionMaker {
+	case samplerToDM(samplernames.Manual):
+		// manual decisions override the samplers.
+		e.Sampler = SamplerManual
+	case samplerToDM(samplernames.AppSec):
+		e.Sampler = SamplerAppSec
+	}
+	if v, ok := root.Metrics[keyRulesSamplerLimiterRate]; ok {
+		e.LimiterRate = v
+	}
+	if !e.Decided {
+		e.Sampler = ""
+	}
+	return e, true
+}
+
+// inferSampler infers the sampler which took the decision from the decision maker
+// dm and the tags of the root span, when sampling debug is disabled. root must be locked.
+func inferSampler(dm string, root *span) string {
+	switch dm {
+	case samplerToDM(samplernames.RuleRate), samplerToDM(samplernames.RemoteUserRule), samplerToDM(samplernames.RemoteDynamicRule):
+		return SamplerRule
+	case samplerToDM(samplernames.AgentRate):
+		return SamplerAgentRate
+	case samplerToDM(samplernames.Default):
+		return SamplerDefaultRate
+	case samplerToDM(samplernames.Manual):
+		return SamplerManual
+	case samplerToDM(samplernames.AppSec):
+		return SamplerAppSec
+	}
+	if _, ok := root.Metrics[keyRulesSamplerAppliedRate]; ok {
+		return SamplerRule
+	}
+	if _, ok := root.Metrics[keySamplingPriorityRate]; ok {
+		return SamplerAgentRate
+	}
+	if root.ParentID != 0 {
+		return SamplerUpstream
+	}
+	return SamplerUnknown
+}
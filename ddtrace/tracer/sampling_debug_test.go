@@ -0,0 +1,186 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package tracer
+
+import (
+	"math"
+	"testing"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainSampling(t *testing.T) {
+	t.Run("rule", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, _, stop := startTestTracer(t,
+			WithSamplingDebug(true),
+			WithSamplingRules([]SamplingRule{
+				ServiceRule("other", 1),
+				NameServiceRule("http.request", "web", 0),
+			}),
+		)
+		defer stop()
+
+		sp := tracer.StartSpan("http.request", ServiceName("web")).(*span)
+		e, ok := ExplainSampling(sp)
+		require.True(t, ok)
+		assert.True(e.Debug)
+		assert.True(e.Decided)
+		assert.Equal(ext.PriorityUserReject, e.Priority)
+		assert.Equal(SamplerRule, e.Sampler)
+		assert.Equal(1, e.RuleIndex)
+		assert.Contains(e.Rule, `"service":"web"`)
+		assert.Equal(0., e.Rate)
+		assert.Equal(LimiterNotApplied, e.Limiter)
+		assert.Equal(100., e.Limit)
+
+		assert.Equal(SamplerRule, sp.Meta[keySamplingDebugSampler])
+		assert.Equal(1., sp.Metrics[keySamplingDebugRuleIndex])
+		assert.Equal(0., sp.Metrics[keySamplingDebugRate])
+		assert.Equal(LimiterNotApplied, sp.Meta[keySamplingDebugLimiter])
+
+		// the explanation is the one of the trace, from any span.
+		child := tracer.StartSpan("child", ChildOf(sp.Context()))
+		ce, ok := ExplainSampling(child)
+		require.True(t, ok)
+		assert.Equal(e.String(), ce.String())
+		assert.NotContains(child.(*span).Meta, keySamplingDebugSampler)
+	})
+
+	t.Run("limiter", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv("DD_TRACE_RATE_LIMIT", "0")
+		tracer, _, _, stop := startTestTracer(t,
+			WithSamplingDebug(true),
+			WithSamplingRules([]SamplingRule{ServiceRule("web", 1)}),
+		)
+		defer stop()
+
+		e, ok := ExplainSampling(tracer.StartSpan("http.request", ServiceName("web")))
+		require.True(t, ok)
+		assert.Equal(ext.PriorityUserReject, e.Priority)
+		assert.Equal(SamplerRule, e.Sampler)
+		assert.Equal(0, e.RuleIndex)
+		assert.Equal(1., e.Rate)
+		assert.Equal(LimiterRejected, e.Limiter)
+		assert.Equal(0., e.Limit)
+		assert.Equal(0., e.LimiterRate)
+	})
+
+	t.Run("global-rate", func(t *testing.T) {
+		assert := assert.New(t)
+		t.Setenv("DD_TRACE_SAMPLE_RATE", "1")
+		tracer, _, _, stop := startTestTracer(t, WithSamplingDebug(true))
+		defer stop()
+
+		e, ok := ExplainSampling(tracer.StartSpan("http.request"))
+		require.True(t, ok)
+		assert.Equal(ext.PriorityUserKeep, e.Priority)
+		assert.Equal(SamplerGlobalRate, e.Sampler)
+		assert.Equal("-3", e.DecisionMaker)
+		assert.Equal(-1, e.RuleIndex)
+		assert.Equal(1., e.Rate)
+		assert.Equal(LimiterAllowed, e.Limiter)
+	})
+
+	t.Run("agent-rate", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, _, stop := startTestTracer(t, WithSamplingDebug(true), WithEnv("prod"))
+		defer stop()
+		tracer.prioritySampling.mu.Lock()
+		tracer.prioritySampling.rates = map[string]float64{"service:web,env:prod": 1}
+		tracer.prioritySampling.mu.Unlock()
+
+		e, ok := ExplainSampling(tracer.StartSpan("http.request", ServiceName("web")))
+		require.True(t, ok)
+		assert.Equal(ext.PriorityAutoKeep, e.Priority)
+		assert.Equal(SamplerAgentRate, e.Sampler)
+		assert.Equal("service:web,env:prod", e.AgentRateKey)
+		assert.Equal(1., e.Rate)
+
+		e, ok = ExplainSampling(tracer.StartSpan("http.request", ServiceName("other")))
+		require.True(t, ok)
+		assert.Equal(SamplerDefaultRate, e.Sampler)
+		assert.Equal("default", e.AgentRateKey)
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, _, stop := startTestTracer(t, WithSamplingDebug(true), WithSampler(NewRateSampler(0)))
+		defer stop()
+
+		e, ok := ExplainSampling(tracer.StartSpan("http.request"))
+		require.True(t, ok)
+		assert.Equal(ext.PriorityAutoReject, e.Priority)
+		assert.Equal(SamplerCustom, e.Sampler)
+		assert.Equal(0., e.Rate)
+	})
+
+	t.Run("manual", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, _, stop := startTestTracer(t, WithSamplingDebug(true))
+		defer stop()
+
+		sp := tracer.StartSpan("http.request")
+		sp.SetTag(ext.ManualKeep, true)
+		e, ok := ExplainSampling(sp)
+		require.True(t, ok)
+		assert.Equal(ext.PriorityUserKeep, e.Priority)
+		assert.Equal(SamplerManual, e.Sampler)
+		assert.Equal("-4", e.DecisionMaker)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		assert := assert.New(t)
+		tracer, _, _, stop := startTestTracer(t, WithSamplingRules([]SamplingRule{ServiceRule("web", 1)}))
+		defer stop()
+
+		sp := tracer.StartSpan("http.request", ServiceName("web")).(*span)
+		for _, k := range samplingDebugKeys {
+			assert.NotContains(sp.Meta, k)
+			assert.NotContains(sp.Metrics, k)
+		}
+		e, ok := ExplainSampling(sp)
+		require.True(t, ok)
+		assert.False(e.Debug)
+		assert.Equal(SamplerRule, e.Sampler)
+		assert.Equal(-1, e.RuleIndex)
+		assert.Equal(1., e.Rate)
+		assert.Equal(LimiterNotApplied, e.Limiter)
+		assert.True(math.IsNaN(e.Limit))
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("DD_TRACE_SAMPLING_DEBUG", "true")
+		assert.True(t, newConfig().samplingDebug)
+	})
+
+	t.Run("foreign", func(t *testing.T) {
+		_, ok := ExplainSampling(internal.NoopSpan{})
+		assert.False(t, ok)
+	})
+}
+
+func TestSamplingExplanationString(t *testing.T) {
+	assert.Equal(t, "no sampling decision", SamplingExplanation{}.String())
+	e := SamplingExplanation{
+		Priority:      2,
+		Decided:       true,
+		DecisionMaker: "-3",
+		Sampler:       SamplerRule,
+		RuleIndex:     0,
+		Rule:          `{"service":"web","sample_rate":1}`,
+		Rate:          1,
+		Limiter:       LimiterAllowed,
+		LimiterRate:   1,
+		Limit:         100,
+	}
+	assert.Equal(t, `priority:2 sampler:rule decision_maker:-3 rule_index:0 rule:{"service":"web","sample_rate":1} rate:1 limiter:allowed limiter_rate:1 limit:100`, e.String())
+}
@@ -48,9 +48,9 @@ type (
 )
 
 var (
-	_ ddtrace.Span   = (*span)(nil)
-	_ msgp.Encodable = (*spanList)(nil)
-	_ msgp.Decodable = (*spanLists)(nil)
+	_ ddtrace.SpanWithLinks = (*span)(nil)
+	_ msgp.Encodable        = (*spanList)(nil)
+	_ msgp.Decodable        = (*spanLists)(nil)
 )
 
 // errorConfig holds customization options for setting error tags.
@@ -87,6 +87,7 @@ type span struct {
 
 	pprofCtxActive  context.Context `msg:"-"` // contains pprof.WithLabel labels to tell the profiler more about this span
 	pprofCtxRestore context.Context `msg:"-"` // contains pprof.WithLabel labels of the parent span (if any) that need to be restored when this span finishes
+	pprofLabelKeys  []string        `msg:"-"` // keys of the tags applied as pprof labels, see WithProfilerSpanLabels
 
 	taskEnd func() // ends execution tracer (runtime/trace) task, if started
 }
@@ -128,6 +129,12 @@ func (s *span) SetTag(key string, value interface{}) {
 			noDebugStack: s.noDebugStack,
 		})
 		return
+	case ext.SamplingPriority:
+		// reserved for internal use only
+		if v, ok := value.(sharedinternal.SamplingDecision); ok {
+			s.setSamplingPriorityLocked(v.Priority, v.Sampler)
+			return
+		}
 	}
 	if v, ok := value.(bool); ok {
 		s.setTagBool(key, v)
@@ -144,6 +151,13 @@ func (s *span) SetTag(key string, value interface{}) {
 			s.pprofCtxActive = pprof.WithLabels(s.pprofCtxActive, pprof.Labels(traceprof.TraceEndpoint, v))
 			pprof.SetGoroutineLabels(s.pprofCtxActive)
 		}
+		if s.pprofCtxActive != nil && s.isPPROFLabel(key) {
+			// Likewise, update the label of the tag for the children of the
+			// span started with ChildOf, see WithProfilerSpanLabels. The
+			// labels of the current goroutine are left as is, as it may not
+			// be the one running the span.
+			s.pprofCtxActive = pprof.WithLabels(s.pprofCtxActive, pprof.Labels(key, v))
+		}
 		s.setMeta(key, v)
 		return
 	}
@@ -199,6 +213,17 @@ func (s *span) SetTag(key string, value interface{}) {
 	s.setMeta(key, fmt.Sprint(value))
 }
 
+// isPPROFLabel reports whether the tag with the given key is applied as a pprof
+// label, see WithProfilerSpanLabels.
+func (s *span) isPPROFLabel(key string) bool {
+	for _, k := range s.pprofLabelKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
 // setSamplingPriority locks then span, then updates the sampling priority.
 // It also updates the trace's sampling priority.
 func (s *span) setSamplingPriority(priority int, sampler samplernames.SamplerName) {
@@ -501,9 +526,23 @@ func (s *span) Finish(opts ...ddtrace.FinishOption) {
 	}
 
 	if s.root() == s {
-		if tr, ok := internal.GetGlobalTracer().(*tracer); ok && tr.rulesSampling.traces.enabled() {
-			if !s.context.trace.isLocked() && s.context.trace.propagatingTag(keyDecisionMaker) != "-4" {
-				tr.rulesSampling.SampleTrace(s)
+		if tr, ok := internal.GetGlobalTracer().(*tracer); ok {
+			if tr.rulesSampling.traces.enabled() && !s.context.trace.isLocked() && s.context.trace.propagatingTag(keyDecisionMaker) != "-4" {
+				if s.context.upstream != nil && s.context.upstream.hasPriority && tr.rulesSampling.traces.hasUpstreamRules() {
+					// only the rules allowed to override the upstream decision apply,
+					// once the rules take the upstream context into account.
+					tr.rulesSampling.SampleTraceUpstream(s)
+				} else {
+					tr.rulesSampling.SampleTrace(s)
+				}
+			}
+			if tr.config.samplingDebug {
+				if p, ok := s.context.SamplingPriority(); ok && p <= 0 {
+					// Logged at debug level, as there can be one log per
+					// dropped trace.
+					e, _ := ExplainSampling(s)
+					log.Debug("Sampling debug: dropped trace %d with root span %q: %s", s.TraceID, s.Name, e)
+				}
 			}
 		}
 	}
@@ -512,6 +551,16 @@ func (s *span) Finish(opts ...ddtrace.FinishOption) {
 	orchestrion.GLSPopValue(sharedinternal.ActiveSpanKey)
 }
 
+// AddSpanLink implements ddtrace.SpanWithLinks.
+func (s *span) AddSpanLink(link ddtrace.SpanLink) {
+	s.Lock()
+	defer s.Unlock()
+	if s.finished {
+		return
+	}
+	s.SpanLinks = append(s.SpanLinks, link)
+}
+
 // SetOperationName sets or changes the operation name.
 func (s *span) SetOperationName(operationName string) {
 	s.Lock()
@@ -574,6 +623,10 @@ func (s *span) finish(finishTime int64) {
 				log.Error("Abandoned spans channel full, disregarding span.")
 			}
 		}
+		if t.config.profilerEndpoints && s.root() == s {
+			// inform the profiler of the latency of the endpoints it watches.
+			traceprof.GlobalEndpointLatencies().Observe(s.Resource, time.Duration(s.Duration))
+		}
 	}
 	if keep {
 		// a single kept span keeps the whole trace.
@@ -15,6 +15,7 @@ import (
 	"testing"
 	"time"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal"
 	sharedinternal "gopkg.in/DataDog/dd-trace-go.v1/internal"
@@ -98,7 +99,7 @@ func TestSpanFinishTwice(t *testing.T) {
 	tracer, _, _, stop := startTestTracer(t)
 	defer stop()
 
-	assert.Equal(tracer.traceWriter.(*agentTraceWriter).payload.itemCount(), 0)
+	assert.Equal(tracer.traceWriter.(*agentTraceWriter).bufferedTraces(), 0)
 
 	// the finish must be idempotent
 	span := tracer.newRootSpan("pylons.request", "pylons", "/")
@@ -281,6 +282,19 @@ func (p *panicStringer) String() string {
 	panic("This should not be handled.")
 }
 
+func TestSpanAddSpanLink(t *testing.T) {
+	assert := assert.New(t)
+	tracer := newTracer(withTransport(newDefaultTransport()))
+	defer tracer.Stop()
+
+	span := tracer.StartSpan("test.request", WithSpanLinks([]ddtrace.SpanLink{{TraceID: 1, SpanID: 2}})).(*span)
+	span.AddSpanLink(ddtrace.SpanLink{TraceID: 3, SpanID: 4})
+	span.Finish()
+	span.AddSpanLink(ddtrace.SpanLink{TraceID: 5, SpanID: 6})
+
+	assert.Equal([]ddtrace.SpanLink{{TraceID: 1, SpanID: 2}, {TraceID: 3, SpanID: 4}}, span.SpanLinks)
+}
+
 func TestSpanSetTag(t *testing.T) {
 	assert := assert.New(t)
 
@@ -741,6 +755,25 @@ func TestSpanSamplingPriority(t *testing.T) {
 	}
 }
 
+func TestSpanSamplingDecision(t *testing.T) {
+	assert := assert.New(t)
+	tracer := newTracer(withTransport(newDefaultTransport()))
+	defer tracer.Stop()
+
+	span := tracer.newRootSpan("my.name", "my.service", "my.resource")
+	span.SetTag(ext.SamplingPriority, sharedinternal.SamplingDecision{
+		Priority: ext.PriorityAutoKeep,
+		Sampler:  samplernames.OTel,
+	})
+	assert.EqualValues(ext.PriorityAutoKeep, span.Metrics[keySamplingPriority])
+	assert.Equal("-9", span.context.trace.propagatingTag(keyDecisionMaker))
+	assert.NotContains(span.Meta, ext.SamplingPriority)
+
+	// other values are manual decisions.
+	span.SetTag(ext.SamplingPriority, ext.PriorityUserKeep)
+	assert.Equal("-4", span.context.trace.propagatingTag(keyDecisionMaker))
+}
+
 func TestSpanLog(t *testing.T) {
 	// this test is executed multiple times to ensure we clean up global state correctly
 	noServiceTest := func(t *testing.T) {
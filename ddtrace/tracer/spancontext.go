@@ -102,6 +102,10 @@ type spanContext struct {
 	reparentID string
 	isRemote   bool
 
+	// upstream holds the properties of the trace context as it was extracted from
+	// a carrier, if it was, to be matched by trace sampling rules.
+	upstream *upstreamContext
+
 	// the below group should propagate cross-process
 
 	traceID traceID
@@ -130,6 +134,7 @@ func newSpanContext(span *span, parent *spanContext) *spanContext {
 		context.trace = parent.trace
 		context.origin = parent.origin
 		context.errors = parent.errors
+		context.upstream = parent.upstream
 		parent.ForeachBaggageItem(func(k, v string) bool {
 			context.setBaggageItem(k, v)
 			return true
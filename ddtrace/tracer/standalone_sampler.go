@@ -0,0 +1,137 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package tracer
+
+import (
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal/sampling"
+)
+
+// NewRulesSampler returns a sampler applying the given trace and single span sampling
+// rules, as configured by WithSamplingRules, and the global sample rate to spans which
+// were not started by the tracer, such as the spans of the mock tracer. A NaN rate
+// means that it is not set.
+func NewRulesSampler(rules []SamplingRule, globalRate float64) sampling.RulesSampler {
+	return newStandaloneSampler(rules, globalRate)
+}
+
+// standaloneSampler applies trace and single span sampling rules to spans which were
+// not started by the tracer, such as the spans of the mock tracer, see
+// sampling.RulesSampler. The rate limit is controlled by DD_TRACE_RATE_LIMIT.
+type standaloneSampler struct {
+	rules    *rulesSampler
+	priority *prioritySampler
+}
+
+// newStandaloneSampler returns a standaloneSampler applying the given trace and single
+// span sampling rules, as configured by WithSamplingRules, and the global sample rate,
+// as configured by DD_TRACE_SAMPLE_RATE. A NaN rate means that it is not set.
+func newStandaloneSampler(rules []SamplingRule, globalRate float64) *standaloneSampler {
+	var traceRules, spanRules []SamplingRule
+	for _, rule := range rules {
+		if rule.ruleType == SamplingRuleSpan {
+			spanRules = append(spanRules, rule)
+		} else {
+			traceRules = append(traceRules, rule)
+		}
+	}
+	return &standaloneSampler{
+		rules:    newRulesSampler(traceRules, spanRules, globalRate),
+		priority: newPrioritySampler(),
+	}
+}
+
+// SampleTrace implements sampling.RulesSampler: the global sample rate then the trace
+// sampling rules are applied, falling back to the default rate.
+func (r *standaloneSampler) SampleTrace(in sampling.Input) sampling.Result {
+	s := samplingInputSpan(in)
+	initial := tagKeys(s)
+	if !r.rules.SampleTraceGlobalRate(s) && !r.rules.SampleTrace(s) {
+		r.priority.apply(s)
+	}
+	p, _ := s.context.SamplingPriority()
+	return sampling.Result{
+		Priority:      p,
+		DecisionMaker: s.context.trace.propagatingTag(keyDecisionMaker),
+		Tags:          samplingTags(s, initial),
+	}
+}
+
+// SampleSpan implements sampling.RulesSampler.
+func (r *standaloneSampler) SampleSpan(in sampling.Input) sampling.Result {
+	s := samplingInputSpan(in)
+	initial := tagKeys(s)
+	kept := r.rules.SampleSpan(s)
+	return sampling.Result{
+		SpanKept: kept,
+		Tags:     samplingTags(s, initial),
+	}
+}
+
+// samplingInputSpan returns a span holding the properties of in, suited to the samplers.
+func samplingInputSpan(in sampling.Input) *span {
+	start := in.Start
+	if start.IsZero() {
+		start = time.Now()
+	}
+	s := &span{
+		Name:     in.Name,
+		Service:  in.Service,
+		Resource: in.Resource,
+		Meta:     map[string]string{},
+		Metrics:  map[string]float64{},
+		SpanID:   in.SpanID,
+		TraceID:  in.TraceID,
+		Start:    start.UnixNano(),
+	}
+	s.context = newSpanContext(s, nil)
+	for k, v := range in.Tags {
+		switch k {
+		case ext.SamplingPriority, ext.ManualKeep, ext.ManualDrop:
+			// the sampling decision is being made.
+			continue
+		}
+		s.SetTag(k, v)
+	}
+	if in.Duration > 0 {
+		// the samplers run before the span is marked as finished, and then measure
+		// its duration from its start.
+		s.Start = now() - int64(in.Duration)
+		s.Duration = int64(in.Duration)
+	}
+	return s
+}
+
+// tagKeys returns the keys of the meta and metrics of s.
+func tagKeys(s *span) map[string]bool {
+	keys := make(map[string]bool, len(s.Meta)+len(s.Metrics))
+	for k := range s.Meta {
+		keys[k] = true
+	}
+	for k := range s.Metrics {
+		keys[k] = true
+	}
+	return keys
+}
+
+// samplingTags returns the tags set on s by the samplers, that is those which are not
+// part of the initial tags, except for the sampling priority.
+func samplingTags(s *span, initial map[string]bool) map[string]interface{} {
+	tags := make(map[string]interface{})
+	for k, v := range s.Meta {
+		if !initial[k] {
+			tags[k] = v
+		}
+	}
+	for k, v := range s.Metrics {
+		if !initial[k] && k != keySamplingPriority {
+			tags[k] = v
+		}
+	}
+	return tags
+}
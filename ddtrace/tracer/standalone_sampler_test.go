@@ -0,0 +1,70 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package tracer
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/internal/sampling"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStandaloneRulesSampler(t *testing.T) {
+	isError := true
+	rules := []SamplingRule{
+		{Service: regexp.MustCompile("^web$"), Error: &isError, Rate: 1},
+		ServiceRule("web", 0),
+		SpanNameServiceRule("db.query", "web", 1),
+	}
+	rs := newStandaloneSampler(rules, math.NaN())
+
+	t.Run("rule", func(t *testing.T) {
+		res := rs.SampleTrace(sampling.Input{TraceID: 1, Name: "http.request", Service: "web"})
+		assert.Equal(t, ext.PriorityUserReject, res.Priority)
+		assert.Empty(t, res.DecisionMaker)
+		assert.Equal(t, 0.0, res.Tags[keyRulesSamplerAppliedRate])
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		res := rs.SampleTrace(sampling.Input{
+			TraceID:  2,
+			Name:     "http.request",
+			Service:  "web",
+			Tags:     map[string]interface{}{ext.Error: errors.New("boom"), ext.ManualDrop: true},
+			Duration: time.Second,
+		})
+		assert.Equal(t, ext.PriorityUserKeep, res.Priority)
+		assert.Equal(t, "-3", res.DecisionMaker)
+		assert.Equal(t, 1.0, res.Tags[keyRulesSamplerAppliedRate])
+		assert.NotContains(t, res.Tags, ext.ErrorMsg)
+	})
+
+	t.Run("default", func(t *testing.T) {
+		res := rs.SampleTrace(sampling.Input{TraceID: 3, Name: "http.request", Service: "other"})
+		assert.Equal(t, ext.PriorityAutoKeep, res.Priority)
+		assert.Equal(t, "-1", res.DecisionMaker)
+	})
+
+	t.Run("global-rate", func(t *testing.T) {
+		res := newStandaloneSampler(nil, 0).SampleTrace(sampling.Input{TraceID: 4, Name: "http.request"})
+		assert.Equal(t, ext.PriorityUserReject, res.Priority)
+	})
+
+	t.Run("span", func(t *testing.T) {
+		res := rs.SampleSpan(sampling.Input{TraceID: 5, SpanID: 6, Name: "db.query", Service: "web"})
+		assert.True(t, res.SpanKept)
+		assert.Equal(t, 8.0, res.Tags[keySpanSamplingMechanism])
+
+		res = rs.SampleSpan(sampling.Input{TraceID: 5, SpanID: 7, Name: "http.request", Service: "web"})
+		assert.False(t, res.SpanKept)
+	})
+}
@@ -266,7 +266,7 @@ func (sb *rawBucket) Export() statsBucket {
 	csb := statsBucket{
 		Start:    sb.start,
 		Duration: sb.duration,
-		Stats:    make([]groupedStats, len(sb.data)),
+		Stats:    make([]groupedStats, 0, len(sb.data)),
 	}
 	for k, v := range sb.data {
 		b, err := v.export(k)
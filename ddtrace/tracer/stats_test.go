@@ -119,6 +119,20 @@ func TestConcentrator(t *testing.T) {
 		c.Stop()
 	})
 
+	t.Run("export", func(t *testing.T) {
+		// the exported bucket must only hold the grouped stats of its spans,
+		// without any zero value preallocated for them.
+		b := newRawBucket(uint64(alignTs(ss1.Start, defaultStatsBucketSize)), defaultStatsBucketSize)
+		b.handleSpan(ss1)
+		b.handleSpan(ss2)
+		sb := b.Export()
+		assert.Len(t, sb.Stats, 2)
+		for _, gs := range sb.Stats {
+			assert.NotEmpty(t, gs.Name)
+			assert.EqualValues(t, 1, gs.Hits)
+		}
+	})
+
 	t.Run("flusher", func(t *testing.T) {
 		t.Run("old", func(t *testing.T) {
 			transport := newDummyTransport()
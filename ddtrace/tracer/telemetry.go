@@ -41,6 +41,7 @@ func startTelemetry(c *config) {
 		{Name: "lambda_mode", Value: c.logToStdout},
 		{Name: "send_retries", Value: c.sendRetries},
 		{Name: "trace_startup_logs_enabled", Value: c.logStartup},
+		{Name: "trace_startup_diagnostics_enabled", Value: c.startupDiagnostics},
 		{Name: "service", Value: c.serviceName},
 		{Name: "universal_version", Value: c.universalVersion},
 		{Name: "env", Value: c.env},
@@ -51,6 +52,7 @@ func startTelemetry(c *config) {
 		{Name: "debug_stack_enabled", Value: !c.noDebugStack},
 		{Name: "profiling_hotspots_enabled", Value: c.profilerHotspots},
 		{Name: "profiling_endpoints_enabled", Value: c.profilerEndpoints},
+		{Name: "profiling_span_label_keys", Value: strings.Join(c.profilerLabelKeys, ",")},
 		{Name: "trace_span_attribute_schema", Value: c.spanAttributeSchemaVersion},
 		{Name: "trace_peer_service_defaults_enabled", Value: c.peerServiceDefaultsEnabled},
 		{Name: "orchestrion_enabled", Value: c.orchestrionCfg.Enabled},
@@ -269,7 +269,10 @@ func (p *chainedPropagator) Inject(spanCtx ddtrace.SpanContext, carrier interfac
 // stored in the local trace context even if a previous propagator has already succeeded
 // so long as the trace-ids match.
 func (p *chainedPropagator) Extract(carrier interface{}) (ddtrace.SpanContext, error) {
-	var ctx ddtrace.SpanContext
+	var (
+		ctx   ddtrace.SpanContext
+		style string
+	)
 	for _, v := range p.extractors {
 		if ctx != nil {
 			// A local trace context has already been extracted.
@@ -295,9 +298,11 @@ func (p *chainedPropagator) Extract(carrier interface{}) (ddtrace.SpanContext, e
 		var err error
 		ctx, err = v.Extract(carrier)
 		if ctx != nil {
+			style = propagatorStyle(v)
 			if p.onlyExtractFirst {
 				// Return early if the customer configured that only the first successful
 				// extraction should occur.
+				setUpstreamContext(ctx, style)
 				return ctx, nil
 			}
 		} else if err != ErrSpanContextNotFound {
@@ -307,10 +312,51 @@ func (p *chainedPropagator) Extract(carrier interface{}) (ddtrace.SpanContext, e
 	if ctx == nil {
 		return nil, ErrSpanContextNotFound
 	}
+	setUpstreamContext(ctx, style)
 	log.Debug("Extracted span context: %#v", ctx)
 	return ctx, nil
 }
 
+// upstreamContext holds the properties of a trace context at the time it was extracted,
+// which can be matched by trace sampling rules. See SamplingRule.
+type upstreamContext struct {
+	propagationStyle string // name of the propagator which extracted the context
+	hasPriority      bool   // whether an explicit sampling priority was extracted
+	decisionMaker    string // value of the extracted _dd.p.dm tag
+}
+
+// setUpstreamContext records the upstream properties of the extracted context ctx,
+// before any local span alters them.
+func setUpstreamContext(ctx ddtrace.SpanContext, style string) {
+	sctx, ok := ctx.(*spanContext)
+	if !ok {
+		return
+	}
+	u := &upstreamContext{propagationStyle: style}
+	_, u.hasPriority = sctx.SamplingPriority()
+	if sctx.trace != nil {
+		u.decisionMaker = sctx.trace.propagatingTag(keyDecisionMaker)
+	}
+	sctx.upstream = u
+}
+
+// propagatorStyle returns the name of the propagation style implemented by p, as
+// accepted by DD_TRACE_PROPAGATION_STYLE, or an empty string for custom propagators.
+func propagatorStyle(p Propagator) string {
+	switch p.(type) {
+	case *propagator:
+		return "datadog"
+	case *propagatorW3c:
+		return "tracecontext"
+	case *propagatorB3:
+		return "b3multi"
+	case *propagatorB3SingleHeader:
+		return "b3 single header"
+	default:
+		return ""
+	}
+}
+
 // propagateTracestate will add the tracestate propagating tag to the given
 // *spanContext. The W3C trace context will be extracted from the provided
 // carrier. The trace id of this W3C trace context must match the trace id
@@ -24,6 +24,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/appsec"
 	appsecConfig "gopkg.in/DataDog/dd-trace-go.v1/internal/appsec/config"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/datastreams"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/globalconfig"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/hostname"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/remoteconfig"
@@ -147,7 +148,16 @@ func Start(opts ...StartOption) {
 		return
 	}
 	internal.SetGlobalTracer(t)
-	if t.config.logStartup {
+	globalconfig.SetStatsdClient(t.statsd)
+	traceprof.SetSpanLabelKeys(t.config.profilerLabelKeys)
+	if t.config.startupDiagnostics {
+		// the startup log waits for the checks, which run in the background.
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			logDiagnostics(t)
+		}()
+	} else if t.config.logStartup {
 		logStartup(t)
 	}
 	if t.dataStreams != nil {
@@ -242,6 +252,9 @@ func newUnstartedTracer(opts ...StartOption) *tracer {
 	} else {
 		writer = newAgentTraceWriter(c, sampler, statsd)
 	}
+	if len(c.extraTransports) > 0 {
+		writer = newMultiTraceWriter(writer, c, statsd)
+	}
 	traces, spans, err := samplingRulesFromEnv()
 	if err != nil {
 		log.Warn("DIAGNOSTICS Error(s) parsing sampling rules: found errors:%s", err)
@@ -253,6 +266,8 @@ func newUnstartedTracer(opts ...StartOption) *tracer {
 		c.spanRules = spans
 	}
 	rulesSampler := newRulesSampler(c.traceRules, c.spanRules, c.globalSampleRate)
+	rulesSampler.traces.debug = c.samplingDebug
+	sampler.debug = c.samplingDebug
 	c.traceSampleRate = newDynamicConfig("trace_sample_rate", c.globalSampleRate, rulesSampler.traces.setGlobalSampleRate, equal[float64])
 	// If globalSampleRate returns NaN, it means the environment variable was not set or valid.
 	// We could always set the origin to "env_var" inconditionally, but then it wouldn't be possible
@@ -583,9 +598,12 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 	if _, ok := span.context.SamplingPriority(); !ok {
 		// if not already sampled or a brand new trace, sample it
 		t.sample(span)
+	} else if context != nil && context.span == nil && context.upstream != nil {
+		// the sampling decision was received from upstream, which some rules may override.
+		t.rulesSampling.SampleTraceUpstream(span)
 	}
 	pprofContext, span.taskEnd = startExecutionTracerTask(pprofContext, span)
-	if t.config.profilerHotspots || t.config.profilerEndpoints {
+	if t.config.profilerHotspots || t.config.profilerEndpoints || len(t.config.profilerLabelKeys) > 0 {
 		t.applyPPROFLabels(pprofContext, span)
 	}
 	if t.config.serviceMappings != nil {
@@ -609,8 +627,8 @@ func (t *tracer) StartSpan(operationName string, options ...ddtrace.StartSpanOpt
 	return span
 }
 
-// applyPPROFLabels applies pprof labels for the profiler's code hotspots and
-// endpoint filtering feature to span. When span finishes, any pprof labels
+// applyPPROFLabels applies pprof labels for the profiler's code hotspots,
+// endpoint filtering and span labels features to span. When span finishes, any pprof labels
 // found in ctx are restored. Additionally, this func informs the profiler how
 // many times each endpoint is called.
 func (t *tracer) applyPPROFLabels(ctx gocontext.Context, span *span) {
@@ -635,7 +653,15 @@ func (t *tracer) applyPPROFLabels(ctx gocontext.Context, span *span) {
 			}
 		}
 	}
-	if len(labels) > 0 {
+	for _, k := range t.config.profilerLabelKeys {
+		if v, ok := span.Meta[k]; ok {
+			labels = append(labels, k, v)
+		} else if v := span.context.baggageItem(k); v != "" {
+			labels = append(labels, k, v)
+		}
+	}
+	if len(labels) > 0 || len(t.config.profilerLabelKeys) > 0 {
+		span.pprofLabelKeys = t.config.profilerLabelKeys
 		span.pprofCtxRestore = ctx
 		span.pprofCtxActive = pprof.WithLabels(ctx, pprof.Labels(labels...))
 		pprof.SetGoroutineLabels(span.pprofCtxActive)
@@ -659,12 +685,14 @@ func (t *tracer) Stop() {
 	t.stats.Stop()
 	t.wg.Wait()
 	t.traceWriter.stop()
+	globalconfig.SetStatsdClient(nil)
 	t.statsd.Close()
 	if t.dataStreams != nil {
 		t.dataStreams.Stop()
 	}
 	appsec.Stop()
 	remoteconfig.Stop()
+	traceprof.SetSpanLabelKeys(nil)
 }
 
 // Inject uses the configured or default TextMap Propagator.
@@ -727,6 +755,16 @@ func (t *tracer) sample(span *span) {
 	if !sampler.Sample(span) {
 		span.context.trace.drop()
 		span.context.trace.setSamplingPriority(ext.PriorityAutoReject, samplernames.RuleRate)
+		if t.config.samplingDebug {
+			span.Lock()
+			span.resetSamplingDebugTags()
+			span.setMeta(keySamplingDebugSampler, SamplerCustom)
+			if rs, ok := sampler.(RateSampler); ok {
+				span.setMetric(keySamplingDebugRate, rs.Rate())
+			}
+			span.setMeta(keySamplingDebugLimiter, LimiterNotApplied)
+			span.Unlock()
+		}
 		return
 	}
 	if rs, ok := sampler.(RateSampler); ok && rs.Rate() < 1 {
@@ -17,6 +17,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"runtime"
+	"runtime/pprof"
 	rt "runtime/trace"
 	"strconv"
 	"strings"
@@ -32,6 +33,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/statsdtest"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/telemetry"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -90,7 +92,7 @@ loop:
 		case <-timeout:
 			tst.Fatalf("timed out waiting for payload to contain %d", n)
 		default:
-			if t.traceWriter.(*agentTraceWriter).payload.itemCount() == n {
+			if t.traceWriter.(*agentTraceWriter).bufferedTraces() == n {
 				break loop
 			}
 			time.Sleep(10 * time.Millisecond)
@@ -1280,7 +1282,7 @@ func TestTracerEdgeSampler(t *testing.T) {
 		span1.Finish()
 	}
 
-	assert.Equal(tracer0.traceWriter.(*agentTraceWriter).payload.itemCount(), 0)
+	assert.Equal(tracer0.traceWriter.(*agentTraceWriter).bufferedTraces(), 0)
 	tracer1.awaitPayload(t, count)
 }
 
@@ -2593,3 +2595,63 @@ func TestExecutionTraceSpanTagged(t *testing.T) {
 	assert.Equal(t, partialSpan.Meta["go_execution_traced"], "partial")
 	assert.NotContains(t, untracedSpan.Meta, "go_execution_traced")
 }
+
+func TestProfilerSpanLabels(t *testing.T) {
+	label := func(ctx context.Context, key string) string {
+		v, _ := pprof.Label(ctx, key)
+		return v
+	}
+
+	t.Run("labels", func(t *testing.T) {
+		t.Setenv(traceprof.SpanLabelKeysEnvVar, "tenant, tier")
+		tracer := newTracer(WithProfilerCodeHotspots(false), WithProfilerEndpoints(false))
+		internal.SetGlobalTracer(tracer)
+		defer internal.SetGlobalTracer(&internal.NoopTracer{})
+		defer tracer.Stop()
+		assert.Equal(t, []string{"tenant", "tier"}, tracer.config.profilerLabelKeys)
+
+		parent, ctx := StartSpanFromContext(context.Background(), "parent", Tag("tenant", "acme"))
+		parent.SetBaggageItem("tier", "gold")
+		assert.Equal(t, "acme", label(ctx, "tenant"))
+		assert.Equal(t, "", label(ctx, "tier"))
+
+		// the tags of the parent are inherited, and the baggage is read when the
+		// span starts.
+		child, ctx := StartSpanFromContext(ctx, "child")
+		assert.Equal(t, "acme", label(ctx, "tenant"))
+		assert.Equal(t, "gold", label(ctx, "tier"))
+		assert.Equal(t, "", label(ctx, traceprof.SpanID))
+
+		child.SetTag("tier", "silver")
+		child.SetTag("other", "value")
+		s := child.(*span)
+		assert.Equal(t, "silver", label(s.pprofCtxActive, "tier"))
+		assert.Equal(t, "", label(s.pprofCtxActive, "other"))
+
+		// the tags set after the span started apply to its children started
+		// with ChildOf.
+		child.SetTag("tenant", "globex")
+		grandchild := StartSpan("grandchild", ChildOf(child.Context())).(*span)
+		assert.Equal(t, "globex", label(grandchild.pprofCtxActive, "tenant"))
+	})
+
+	t.Run("override", func(t *testing.T) {
+		t.Setenv(traceprof.SpanLabelKeysEnvVar, "tenant")
+		c := newConfig(WithProfilerSpanLabels("region"))
+		assert.Equal(t, []string{"region"}, c.profilerLabelKeys)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		tracer := newTracer(WithProfilerCodeHotspots(false), WithProfilerEndpoints(false))
+		defer tracer.Stop()
+		s := tracer.StartSpan("op", Tag("tenant", "acme")).(*span)
+		assert.Nil(t, s.pprofCtxActive)
+	})
+
+	t.Run("profiler", func(t *testing.T) {
+		Start(WithProfilerSpanLabels("tenant"), withNoopStats())
+		assert.Equal(t, []string{"tenant"}, traceprof.SpanLabelKeys())
+		Stop()
+		assert.Empty(t, traceprof.SpanLabelKeys())
+	})
+}
@@ -73,6 +73,48 @@ type transport interface {
 	endpoint() string
 }
 
+// TraceDestination is a destination for encoded trace payloads. Destinations
+// registered using WithTransport receive traces in addition to the Datadog Agent,
+// allowing traces to be sent to several destinations at once.
+type TraceDestination interface {
+	// Send sends a payload holding count traces, encoded in the msgpack format
+	// accepted by the Datadog Agent's /v0.4/traces endpoint. When no error is
+	// returned, a non-nil body will be closed by the tracer.
+	Send(payload io.Reader, count int) (body io.ReadCloser, err error)
+}
+
+// NewAgentTransport returns a TraceDestination which sends traces to the Datadog Agent
+// located at agentURL (e.g. "http://localhost:8126") using the given client. If client
+// is nil, a default client is used. It can be used with WithTransport to send traces
+// to a second agent, for instance during a migration.
+func NewAgentTransport(agentURL string, client *http.Client) TraceDestination {
+	if client == nil {
+		client = defaultHTTPClient(0)
+	}
+	return newHTTPTransport(strings.TrimSuffix(agentURL, "/"), client)
+}
+
+// transportAdapter adapts a user-provided TraceDestination to the transport interface.
+type transportAdapter struct {
+	TraceDestination
+}
+
+func (t *transportAdapter) send(p *payload) (io.ReadCloser, error) {
+	return t.Send(p, p.itemCount())
+}
+
+// sendStats is a no-op: client-side stats are only sent to the main agent.
+func (t *transportAdapter) sendStats(_ *statsPayload) error {
+	return nil
+}
+
+func (t *transportAdapter) endpoint() string {
+	if t, ok := t.TraceDestination.(*httpTransport); ok {
+		return t.endpoint()
+	}
+	return fmt.Sprintf("%T", t.TraceDestination)
+}
+
 type httpTransport struct {
 	traceURL string            // the delivery URL for traces
 	statsURL string            // the delivery URL for stats
@@ -139,16 +181,10 @@ func (t *httpTransport) sendStats(p *statsPayload) error {
 }
 
 func (t *httpTransport) send(p *payload) (body io.ReadCloser, err error) {
-	req, err := http.NewRequest("POST", t.traceURL, p)
+	req, err := t.newTraceRequest(p, p.itemCount(), p.size())
 	if err != nil {
-		return nil, fmt.Errorf("cannot create http request: %v", err)
-	}
-	for header, value := range t.headers {
-		req.Header.Set(header, value)
+		return nil, err
 	}
-	req.Header.Set(traceCountHeader, strconv.Itoa(p.itemCount()))
-	req.Header.Set("Content-Length", strconv.Itoa(p.size()))
-	req.Header.Set(headerComputedTopLevel, "yes")
 	if t, ok := traceinternal.GetGlobalTracer().(*tracer); ok {
 		if t.config.canComputeStats() {
 			req.Header.Set("Datadog-Client-Computed-Stats", "yes")
@@ -164,6 +200,43 @@ func (t *httpTransport) send(p *payload) (body io.ReadCloser, err error) {
 		req.Header.Set("Datadog-Client-Dropped-P0-Traces", strconv.Itoa(droppedTraces))
 		req.Header.Set("Datadog-Client-Dropped-P0-Spans", strconv.Itoa(droppedSpans))
 	}
+	return t.do(req)
+}
+
+// Send implements TraceDestination. Unlike send, it does not report client-side
+// stats or dropped P0 counts, which are only reported to the main agent.
+func (t *httpTransport) Send(p io.Reader, count int) (body io.ReadCloser, err error) {
+	size := -1
+	if p, ok := p.(*payload); ok {
+		size = p.size()
+	}
+	req, err := t.newTraceRequest(p, count, size)
+	if err != nil {
+		return nil, err
+	}
+	return t.do(req)
+}
+
+// newTraceRequest creates a request sending the trace payload p, which holds count
+// traces. If size is negative, the Content-Length header is left unset.
+func (t *httpTransport) newTraceRequest(p io.Reader, count, size int) (*http.Request, error) {
+	req, err := http.NewRequest("POST", t.traceURL, p)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create http request: %v", err)
+	}
+	for header, value := range t.headers {
+		req.Header.Set(header, value)
+	}
+	req.Header.Set(traceCountHeader, strconv.Itoa(count))
+	if size >= 0 {
+		req.Header.Set("Content-Length", strconv.Itoa(size))
+	}
+	req.Header.Set(headerComputedTopLevel, "yes")
+	return req, nil
+}
+
+// do sends req and returns the response body when the agent accepted it.
+func (t *httpTransport) do(req *http.Request) (io.ReadCloser, error) {
 	response, err := t.client.Do(req)
 	if err != nil {
 		return nil, err
@@ -16,6 +16,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -197,6 +198,38 @@ func TestTraceCountHeader(t *testing.T) {
 	assert.Equal(hits, len(testCases))
 }
 
+func TestAgentTransport(t *testing.T) {
+	assert := assert.New(t)
+
+	var (
+		mu   sync.Mutex // guards reqs
+		reqs []*http.Request
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		reqs = append(reqs, r)
+		mu.Unlock()
+		w.Write([]byte(`{"rate_by_service":{}}`))
+	}))
+	defer srv.Close()
+
+	transport := NewAgentTransport(srv.URL+"/", nil)
+	p, err := encode(getTestTrace(3, 1))
+	assert.NoError(err)
+	rc, err := transport.Send(p, p.itemCount())
+	assert.NoError(err)
+	rc.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(reqs, 1)
+	req := reqs[0]
+	assert.Equal("/v0.4/traces", req.URL.Path)
+	assert.Equal("3", req.Header.Get(traceCountHeader))
+	// dropped P0 counts are only reported to the main agent
+	assert.Empty(req.Header.Get("Datadog-Client-Dropped-P0-Traces"))
+}
+
 type recordingRoundTripper struct {
 	reqs []*http.Request
 	rt   http.RoundTripper
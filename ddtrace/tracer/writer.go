@@ -14,8 +14,10 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	globalinternal "gopkg.in/DataDog/dd-trace-go.v1/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 )
@@ -36,10 +38,7 @@ type agentTraceWriter struct {
 	config *config
 
 	// payload encodes and buffers traces in msgpack format
-	payload *payload
-
-	// climit limits the number of concurrent outgoing connections
-	climit chan struct{}
+	payload *classifiedPayload
 
 	// wg waits for all uploads to finish
 	wg sync.WaitGroup
@@ -50,80 +49,431 @@ type agentTraceWriter struct {
 
 	// statsd is used to send metrics
 	statsd globalinternal.StatsdClient
+
+	// transport is the transport to which payloads are sent.
+	transport transport
+
+	// tags holds additional tags attached to the writer's metrics.
+	tags []string
+
+	// ignoreRates causes the sampling rates returned by the transport to be
+	// discarded instead of being read into prioritySampling.
+	ignoreRates bool
+
+	// memoryLimit is the maximum number of bytes used by buffered, queued and
+	// in-flight payloads. Above it, the traces of the lowest priority class
+	// are dropped first.
+	memoryLimit int
+
+	// dropWhenBusy causes flushed payloads to be dropped instead of being queued
+	// when the concurrent connection limit has been reached.
+	dropWhenBusy bool
+
+	mu sync.Mutex // guards below fields
+
+	// queue holds the flushed payloads waiting for a connection, oldest first.
+	queue []*queuedPayload
+
+	// inflight is the number of payloads being sent. It is limited to
+	// concurrentConnectionLimit.
+	inflight int
+
+	// memoryDrops and busyDrops throttle the logs of the traces dropped because
+	// of the memory limit and of dropWhenBusy.
+	memoryDrops, busyDrops dropLog
+
+	// buffered is the number of bytes used by buffered, queued and in-flight payloads.
+	buffered int
+}
+
+// priorityClass groups traces by sampling priority, to decide which traces
+// to drop first when the writer runs out of memory.
+type priorityClass int
+
+const (
+	// classReject holds traces with a sampling priority of zero or less.
+	classReject priorityClass = iota
+	// classAutoKeep holds traces kept by the samplers, or without a priority.
+	classAutoKeep
+	// classUserKeep holds traces kept by the user.
+	classUserKeep
+
+	numPriorityClasses
+)
+
+// tag returns the metric tag identifying the class.
+func (c priorityClass) tag() string {
+	switch c {
+	case classReject:
+		return "priority:reject"
+	case classUserKeep:
+		return "priority:user_keep"
+	default:
+		return "priority:auto_keep"
+	}
+}
+
+// traceClass returns the priority class of trace.
+func traceClass(trace []*span) priorityClass {
+	if len(trace) == 0 || trace[0].context == nil {
+		return classAutoKeep
+	}
+	p, ok := trace[0].context.SamplingPriority()
+	switch {
+	case !ok:
+		return classAutoKeep
+	case p <= ext.PriorityAutoReject:
+		return classReject
+	case p >= ext.PriorityUserKeep:
+		return classUserKeep
+	default:
+		return classAutoKeep
+	}
+}
+
+// classifiedPayload is a payload which records the priority class of each of
+// its traces, so that the traces of a class can be dropped from it when the
+// writer runs out of memory.
+type classifiedPayload struct {
+	*payload
+
+	// traces holds the end offset in the payload buffer and the priority
+	// class of each trace, in order.
+	traces []classifiedTrace
+}
+
+type classifiedTrace struct {
+	end   int
+	class priorityClass
+}
+
+func newClassifiedPayload() *classifiedPayload {
+	return &classifiedPayload{payload: newPayload()}
+}
+
+// push pushes trace into the payload.
+func (p *classifiedPayload) push(trace []*span) error {
+	if err := p.payload.push(trace); err != nil {
+		return err
+	}
+	p.traces = append(p.traces, classifiedTrace{end: p.buf.Len(), class: traceClass(trace)})
+	return nil
+}
+
+// has reports whether the payload holds traces of the given class.
+func (p *classifiedPayload) has(class priorityClass) bool {
+	for _, t := range p.traces {
+		if t.class == class {
+			return true
+		}
+	}
+	return false
+}
+
+// drop removes the traces of the given class from the payload, keeping the
+// others in order, and returns the number of dropped traces. It must not be
+// called once the payload is being sent.
+func (p *classifiedPayload) drop(class priorityClass) int {
+	var (
+		data    = p.buf.Bytes()
+		kept    = newPayload()
+		traces  []classifiedTrace
+		dropped int
+		start   int
+	)
+	for _, t := range p.traces {
+		if t.class == class {
+			dropped++
+		} else {
+			kept.buf.Write(data[start:t.end])
+			atomic.AddUint32(&kept.count, 1)
+			traces = append(traces, classifiedTrace{end: kept.buf.Len(), class: t.class})
+		}
+		start = t.end
+	}
+	kept.updateHeader()
+	p.payload, p.traces = kept, traces
+	return dropped
+}
+
+// queuedPayload is a flushed payload waiting to be sent.
+type queuedPayload struct {
+	p    *classifiedPayload
+	size int
 }
 
 func newAgentTraceWriter(c *config, s *prioritySampler, statsdClient globalinternal.StatsdClient) *agentTraceWriter {
-	return &agentTraceWriter{
+	h := &agentTraceWriter{
 		config:           c,
-		payload:          newPayload(),
-		climit:           make(chan struct{}, concurrentConnectionLimit),
 		prioritySampling: s,
 		statsd:           statsdClient,
+		transport:        c.transport,
+		memoryLimit:      c.writerMemoryLimit,
+		payload:          newClassifiedPayload(),
+	}
+	return h
+}
+
+// metricTags returns tags along with the writer's additional tags.
+func (h *agentTraceWriter) metricTags(tags ...string) []string {
+	if len(h.tags) == 0 {
+		return tags
 	}
+	return append(tags, h.tags...)
 }
 
 func (h *agentTraceWriter) add(trace []*span) {
-	if err := h.payload.push(trace); err != nil {
-		h.statsd.Incr("datadog.tracer.traces_dropped", []string{"reason:encoding_error"}, 1)
+	p := h.payload
+	size := p.size()
+	if err := p.push(trace); err != nil {
+		h.statsd.Incr("datadog.tracer.traces_dropped", h.metricTags("reason:encoding_error"), 1)
 		log.Error("Error encoding msgpack: %v", err)
 	}
+	h.mu.Lock()
+	h.buffered += p.size() - size
+	h.enforceMemoryLimitLocked()
+	h.mu.Unlock()
 	if h.payload.size() > payloadSizeLimit {
-		h.statsd.Incr("datadog.tracer.flush_triggered", []string{"reason:size"}, 1)
+		h.statsd.Incr("datadog.tracer.flush_triggered", h.metricTags("reason:size"), 1)
 		h.flush()
 	}
 }
 
+// enforceMemoryLimitLocked drops traces until the memory used by the writer
+// is below its limit. The traces of the lowest priority class are dropped
+// first, from the queued payloads before the buffered one. In-flight payloads
+// are never dropped. h.mu must be held.
+func (h *agentTraceWriter) enforceMemoryLimitLocked() {
+	for h.memoryLimit > 0 && h.buffered > h.memoryLimit {
+		if !h.dropLowestPriorityLocked() {
+			return
+		}
+	}
+}
+
+// dropLowestPriorityLocked drops the traces of the lowest priority class from
+// the oldest payload holding some, reporting whether traces were dropped. h.mu
+// must be held.
+func (h *agentTraceWriter) dropLowestPriorityLocked() bool {
+	for class := priorityClass(0); class < numPriorityClasses; class++ {
+		for i, q := range h.queue {
+			if !q.p.has(class) {
+				continue
+			}
+			n := q.p.drop(class)
+			size := q.p.size()
+			h.buffered -= q.size - size
+			q.size = size
+			if q.p.itemCount() == 0 {
+				h.queue = append(h.queue[:i], h.queue[i+1:]...)
+				h.buffered -= q.size
+				h.wg.Done()
+			}
+			h.reportMemoryDrop(n, class)
+			return true
+		}
+		if p := h.payload; p.has(class) {
+			size := p.size()
+			n := p.drop(class)
+			h.buffered -= size - p.size()
+			h.reportMemoryDrop(n, class)
+			return true
+		}
+	}
+	return false
+}
+
+func (h *agentTraceWriter) reportMemoryDrop(count int, class priorityClass) {
+	h.statsd.Count("datadog.tracer.traces_dropped", int64(count), h.metricTags("reason:memory_limit", class.tag()), 1)
+	if n, ok := h.memoryDrops.add(count, time.Now()); ok {
+		log.Error("lost %d traces: trace writer memory limit of %d bytes reached", n, h.memoryLimit)
+	}
+}
+
+// dropLogInterval is the minimum interval between two logs of the traces dropped
+// by a trace writer for the same reason.
+const dropLogInterval = time.Minute
+
+// dropLog throttles the logs of dropped traces, so that a writer which drops
+// traces continuously doesn't log on every drop.
+type dropLog struct {
+	dropped int       // number of traces dropped since the last log
+	last    time.Time // time of the last log
+}
+
+// add records count dropped traces. It reports whether they should be logged,
+// along with the number of traces dropped since the last log.
+func (l *dropLog) add(count int, now time.Time) (int, bool) {
+	l.dropped += count
+	if now.Sub(l.last) < dropLogInterval {
+		return 0, false
+	}
+	n := l.dropped
+	l.dropped, l.last = 0, now
+	return n, true
+}
+
 func (h *agentTraceWriter) stop() {
-	h.statsd.Incr("datadog.tracer.flush_triggered", []string{"reason:shutdown"}, 1)
+	h.statsd.Incr("datadog.tracer.flush_triggered", h.metricTags("reason:shutdown"), 1)
 	h.flush()
 	h.wg.Wait()
 }
 
-// flush will push any currently buffered traces to the server.
+// flush will push any currently buffered traces to the server. It does not
+// block: payloads wait in a queue when the concurrent connection limit is reached,
+// or are dropped if dropWhenBusy is set.
 func (h *agentTraceWriter) flush() {
-	if h.payload.itemCount() == 0 {
-		return
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if p := h.payload; p.itemCount() > 0 {
+		h.payload = newClassifiedPayload()
+		if h.dropWhenBusy && h.inflight+len(h.queue) >= concurrentConnectionLimit {
+			h.buffered -= p.size()
+			h.statsd.Count("datadog.tracer.traces_dropped", int64(p.itemCount()), h.metricTags("reason:busy"), 1)
+			if n, ok := h.busyDrops.add(p.itemCount(), time.Now()); ok {
+				log.Error("lost %d traces: too many concurrent connections to %s", n, h.transport.endpoint())
+			}
+			return
+		}
+		h.wg.Add(1)
+		h.queue = append(h.queue, &queuedPayload{p: p, size: p.size()})
 	}
-	h.wg.Add(1)
-	h.climit <- struct{}{}
-	oldp := h.payload
-	h.payload = newPayload()
-	go func(p *payload) {
-		defer func(start time.Time) {
-			// Once the payload has been used, clear the buffer for garbage
-			// collection to avoid a memory leak when references to this object
-			// may still be kept by faulty transport implementations or the
-			// standard library. See dd-trace-go#976
-			p.clear()
-
-			<-h.climit
-			h.statsd.Timing("datadog.tracer.flush_duration", time.Since(start), nil, 1)
-			h.wg.Done()
-		}(time.Now())
-
-		var count, size int
-		var err error
-		for attempt := 0; attempt <= h.config.sendRetries; attempt++ {
-			size, count = p.size(), p.itemCount()
-			log.Debug("Sending payload: size: %d traces: %d\n", size, count)
-			var rc io.ReadCloser
-			rc, err = h.config.transport.send(p)
-			if err == nil {
-				log.Debug("sent traces after %d attempts", attempt+1)
-				h.statsd.Count("datadog.tracer.flush_bytes", int64(size), nil, 1)
-				h.statsd.Count("datadog.tracer.flush_traces", int64(count), nil, 1)
-				if err := h.prioritySampling.readRatesJSON(rc); err != nil {
-					h.statsd.Incr("datadog.tracer.decode_error", nil, 1)
+	h.dispatchLocked()
+}
+
+// dispatchLocked starts sending queued payloads, oldest first, as long as the
+// concurrent connection limit allows it. h.mu must be held.
+func (h *agentTraceWriter) dispatchLocked() {
+	for h.inflight < concurrentConnectionLimit && len(h.queue) > 0 {
+		q := h.queue[0]
+		h.queue = h.queue[1:]
+		h.inflight++
+		go h.send(q)
+	}
+}
+
+// send sends the queued payload q to the transport, retrying on failure.
+func (h *agentTraceWriter) send(q *queuedPayload) {
+	p := q.p.payload
+	defer func(start time.Time) {
+		// Once the payload has been used, clear the buffer for garbage
+		// collection to avoid a memory leak when references to this object
+		// may still be kept by faulty transport implementations or the
+		// standard library. See dd-trace-go#976
+		p.clear()
+
+		h.mu.Lock()
+		h.inflight--
+		h.buffered -= q.size
+		h.dispatchLocked()
+		h.mu.Unlock()
+		h.statsd.Timing("datadog.tracer.flush_duration", time.Since(start), h.metricTags(), 1)
+		h.wg.Done()
+	}(time.Now())
+
+	var count, size int
+	var err error
+	for attempt := 0; attempt <= h.config.sendRetries; attempt++ {
+		size, count = p.size(), p.itemCount()
+		log.Debug("Sending payload: size: %d traces: %d\n", size, count)
+		var rc io.ReadCloser
+		rc, err = h.transport.send(p)
+		if err == nil {
+			log.Debug("sent traces after %d attempts", attempt+1)
+			h.statsd.Count("datadog.tracer.flush_bytes", int64(size), h.metricTags(), 1)
+			h.statsd.Count("datadog.tracer.flush_traces", int64(count), h.metricTags(), 1)
+			if h.ignoreRates {
+				if rc != nil {
+					rc.Close()
 				}
 				return
 			}
-			log.Error("failure sending traces (attempt %d), will retry: %v", attempt+1, err)
-			p.reset()
-			time.Sleep(time.Millisecond)
+			if err := h.prioritySampling.readRatesJSON(rc); err != nil {
+				h.statsd.Incr("datadog.tracer.decode_error", h.metricTags(), 1)
+			}
+			return
+		}
+		log.Error("failure sending traces (attempt %d), will retry: %v", attempt+1, err)
+		p.reset()
+		time.Sleep(time.Millisecond)
+	}
+	h.statsd.Count("datadog.tracer.traces_dropped", int64(count), h.metricTags("reason:send_failed"), 1)
+	log.Error("lost %d traces: %v", count, err)
+}
+
+// multiTraceWriter fans out traces to a main traceWriter and to a set of
+// additional destinations registered using WithTransport. Each destination has
+// its own payloads, connection limit, memory limit and sample rate, so that a slow or failing
+// destination does not affect the delivery of traces to the others. The payloads
+// of a destination are dropped rather than queued while all its connections are busy.
+type multiTraceWriter struct {
+	// main is the writer sending traces to the agent (or its replacement).
+	main traceWriter
+
+	// dests holds the additional destinations.
+	dests []*destinationWriter
+}
+
+// destinationWriter sends traces to one additional destination.
+type destinationWriter struct {
+	*agentTraceWriter
+
+	// sampleRate is the rate at which traces are sent to this destination.
+	sampleRate float64
+}
+
+func newMultiTraceWriter(main traceWriter, c *config, statsdClient globalinternal.StatsdClient) *multiTraceWriter {
+	w := &multiTraceWriter{main: main}
+	for i, d := range c.extraTransports {
+		tw := newAgentTraceWriter(c, nil, statsdClient)
+		tw.transport = &transportAdapter{d.transport}
+		tw.tags = []string{"destination:" + strconv.Itoa(i+1)}
+		tw.ignoreRates = true
+		tw.dropWhenBusy = true
+		w.dests = append(w.dests, &destinationWriter{agentTraceWriter: tw, sampleRate: d.sampleRate})
+	}
+	return w
+}
+
+func (w *multiTraceWriter) add(trace []*span) {
+	w.main.add(trace)
+	if len(trace) == 0 {
+		return
+	}
+	if traceClass(trace) == classReject {
+		// The additional destinations only receive the traces kept by the
+		// samplers, even when the main writer receives all of them to compute
+		// the stats, see canDropP0s.
+		return
+	}
+	for _, d := range w.dests {
+		// Sampling on the trace ID guarantees that all the chunks of a trace
+		// get the same decision.
+		if !sampledByRate(trace[0].TraceID, d.sampleRate) {
+			continue
 		}
-		h.statsd.Count("datadog.tracer.traces_dropped", int64(count), []string{"reason:send_failed"}, 1)
-		log.Error("lost %d traces: %v", count, err)
-	}(oldp)
+		d.add(trace)
+	}
+}
+
+func (w *multiTraceWriter) flush() {
+	w.main.flush()
+	for _, d := range w.dests {
+		d.flush()
+	}
+}
+
+func (w *multiTraceWriter) stop() {
+	var wg sync.WaitGroup
+	for _, d := range w.dests {
+		wg.Add(1)
+		go func(d *destinationWriter) {
+			defer wg.Done()
+			d.stop()
+		}(d)
+	}
+	w.main.stop()
+	wg.Wait()
 }
 
 // logWriter specifies the output target of the logTraceWriter; replaced in tests.
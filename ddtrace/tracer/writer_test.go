@@ -13,18 +13,29 @@ import (
 	"io"
 	"math"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/statsdtest"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/tinylib/msgp/msgp"
 )
 
 func TestImplementsTraceWriter(t *testing.T) {
 	assert.Implements(t, (*traceWriter)(nil), &agentTraceWriter{})
 	assert.Implements(t, (*traceWriter)(nil), &logTraceWriter{})
+	assert.Implements(t, (*traceWriter)(nil), &multiTraceWriter{})
+}
+
+// bufferedTraces returns the number of traces buffered by the writer and not yet flushed.
+func (h *agentTraceWriter) bufferedTraces() int {
+	return h.payload.itemCount()
 }
 
 // makeSpan returns a span, adding n entries to meta and metrics each.
@@ -423,6 +434,253 @@ func TestTraceWriterFlushRetries(t *testing.T) {
 	}
 }
 
+// recordingTransport is a TraceDestination which records the traces it receives.
+type recordingTransport struct {
+	mu       sync.Mutex
+	traces   spanLists
+	payloads int
+	err      error
+	block    chan struct{}
+}
+
+func (t *recordingTransport) Send(p io.Reader, count int) (io.ReadCloser, error) {
+	if t.block != nil {
+		<-t.block
+	}
+	if t.err != nil {
+		return nil, t.err
+	}
+	var traces spanLists
+	if err := msgp.Decode(p, &traces); err != nil {
+		return nil, err
+	}
+	if len(traces) != count {
+		return nil, fmt.Errorf("expected %d traces, got %d", count, len(traces))
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.traces = append(t.traces, traces...)
+	t.payloads++
+	return nil, nil
+}
+
+func (t *recordingTransport) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.traces)
+}
+
+func TestMultiTraceWriter(t *testing.T) {
+	traces := func(n int) [][]*span {
+		ts := make([][]*span, n)
+		for i := range ts {
+			ts[i] = []*span{makeSpan(0), makeSpan(0)}
+		}
+		return ts
+	}
+
+	t.Run("fan-out", func(t *testing.T) {
+		assert := assert.New(t)
+		main := newDummyTransport()
+		all, none, half := &recordingTransport{}, &recordingTransport{}, &recordingTransport{}
+		c := newConfig(withTransport(main), WithTransport(all, 1), WithTransport(none, 0), WithTransport(half, 0.5))
+		var statsd statsdtest.TestStatsdClient
+		h := newMultiTraceWriter(newAgentTraceWriter(c, newPrioritySampler(), &statsd), c, &statsd)
+		for _, tr := range traces(100) {
+			h.add(tr)
+		}
+		h.stop()
+
+		assert.Equal(100, main.Len())
+		assert.Equal(100, all.Len())
+		assert.Equal(0, none.Len())
+		assert.Greater(half.Len(), 0)
+		assert.Less(half.Len(), 100)
+		for _, tr := range half.traces {
+			assert.Len(tr, 2)
+			assert.True(sampledByRate(tr[0].TraceID, 0.5))
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		assert := assert.New(t)
+		main := newDummyTransport()
+		dest := &recordingTransport{}
+		c := newConfig(withTransport(main), WithTransport(dest, 1))
+		var statsd statsdtest.TestStatsdClient
+		h := newMultiTraceWriter(newAgentTraceWriter(c, newPrioritySampler(), &statsd), c, &statsd)
+		for _, p := range []int{ext.PriorityUserReject, ext.PriorityAutoReject, ext.PriorityAutoKeep, ext.PriorityUserKeep} {
+			h.add([]*span{newPrioritySpan(p)})
+		}
+		h.stop()
+
+		assert.Equal(4, main.Len(), "the agent receives all the traces")
+		assert.Equal(2, dest.Len(), "only the kept traces are sent to the destination")
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		assert := assert.New(t)
+		main := newDummyTransport()
+		failing := &recordingTransport{err: errors.New("unavailable")}
+		c := newConfig(withTransport(main), WithTransport(failing, 1))
+		var statsd statsdtest.TestStatsdClient
+		h := newMultiTraceWriter(newAgentTraceWriter(c, newPrioritySampler(), &statsd), c, &statsd)
+		for _, tr := range traces(10) {
+			h.add(tr)
+		}
+		h.stop()
+
+		assert.Equal(10, main.Len())
+		assert.Equal(int64(10), statsd.Counts()["datadog.tracer.traces_dropped"])
+		assert.Contains(statsd.Tags(), "destination:1")
+	})
+
+	t.Run("slow", func(t *testing.T) {
+		assert := assert.New(t)
+		main := newDummyTransport()
+		slow := &recordingTransport{block: make(chan struct{})}
+		c := newConfig(withTransport(main), WithTransport(slow, 1))
+		var statsd statsdtest.TestStatsdClient
+		h := newMultiTraceWriter(newAgentTraceWriter(c, newPrioritySampler(), &statsd), c, &statsd)
+		for i := 0; i < concurrentConnectionLimit+10; i++ {
+			h.add([]*span{makeSpan(0)})
+			h.flush()
+		}
+		close(slow.block)
+		h.stop()
+
+		// the payloads flushed while all the connections are busy are dropped
+		// instead of piling up.
+		assert.Equal(concurrentConnectionLimit+10, main.Len())
+		assert.Equal(concurrentConnectionLimit, slow.Len())
+		assert.Equal(int64(10), statsd.Counts()["datadog.tracer.traces_dropped"])
+	})
+}
+
+func TestWithTransport(t *testing.T) {
+	assert := assert.New(t)
+	tr := &recordingTransport{}
+	c := newConfig(WithTransport(tr, 0.2), WithTransport(tr, 2), WithTransport(nil, 1))
+	assert.Equal([]extraTransport{{tr, 0.2}, {tr, 1}}, c.extraTransports)
+}
+
+// newPrioritySpan returns a span whose trace has the given sampling priority.
+func newPrioritySpan(priority int) *span {
+	s := makeSpan(0)
+	s.context.trace.setSamplingPriority(priority, samplernames.Manual)
+	return s
+}
+
+func TestTraceWriterMemoryLimit(t *testing.T) {
+	// blockingTransport blocks sends until its channel is closed.
+	block := make(chan struct{})
+	transport := &recordingTransport{block: block}
+	c := newConfig(func(c *config) {
+		c.transport = &transportAdapter{transport}
+	})
+	var statsd statsdtest.TestStatsdClient
+	h := newAgentTraceWriter(c, nil, &statsd)
+	h.ignoreRates = true
+
+	size := func(s *span) int {
+		p, err := encode([][]*span{{s}})
+		require.NoError(t, err)
+		return p.size()
+	}
+	h.memoryLimit = 3*size(newPrioritySpan(ext.PriorityUserKeep)) + 16
+
+	h.add([]*span{newPrioritySpan(ext.PriorityAutoReject)})
+	h.add([]*span{newPrioritySpan(ext.PriorityAutoKeep)})
+	h.add([]*span{newPrioritySpan(ext.PriorityUserKeep)})
+	assert.Equal(t, 3, h.bufferedTraces())
+	assert.Empty(t, statsd.Counts())
+
+	// the rejected trace is dropped first
+	h.add([]*span{newPrioritySpan(ext.PriorityUserKeep)})
+	assert.Equal(t, 3, h.bufferedTraces())
+	assert.Equal(t, map[string]int64{"datadog.tracer.traces_dropped": 1}, statsd.Counts())
+	assert.Equal(t, []string{"reason:memory_limit", "priority:reject"}, statsd.Tags())
+
+	// then the auto-kept one
+	h.add([]*span{newPrioritySpan(ext.PriorityUserKeep)})
+	assert.Equal(t, 3, h.bufferedTraces())
+	assert.Equal(t, map[string]int64{"datadog.tracer.traces_dropped": 2}, statsd.Counts())
+	assert.False(t, h.payload.has(classAutoKeep))
+	assert.True(t, h.payload.has(classUserKeep))
+
+	close(block)
+	h.stop()
+	assert.Equal(t, 3, transport.Len())
+	assert.Equal(t, 0, h.buffered)
+}
+
+func TestDropLog(t *testing.T) {
+	assert := assert.New(t)
+	var l dropLog
+	now := time.Now()
+
+	n, ok := l.add(2, now)
+	assert.True(ok)
+	assert.Equal(2, n)
+
+	// the drops are only counted until the interval elapses
+	_, ok = l.add(3, now.Add(time.Second))
+	assert.False(ok)
+	_, ok = l.add(4, now.Add(dropLogInterval-time.Second))
+	assert.False(ok)
+
+	n, ok = l.add(1, now.Add(dropLogInterval))
+	assert.True(ok)
+	assert.Equal(8, n)
+}
+
+func TestTraceWriterSinglePayload(t *testing.T) {
+	transport := &recordingTransport{}
+	c := newConfig(func(c *config) {
+		c.transport = &transportAdapter{transport}
+	})
+	var statsd statsdtest.TestStatsdClient
+	h := newAgentTraceWriter(c, nil, &statsd)
+	h.ignoreRates = true
+
+	var ids []uint64
+	for _, p := range []int{ext.PriorityUserKeep, ext.PriorityAutoReject, ext.PriorityAutoKeep, ext.PriorityAutoReject} {
+		s := newPrioritySpan(p)
+		ids = append(ids, s.SpanID)
+		h.add([]*span{s})
+	}
+	h.stop()
+
+	// the traces of all the priority classes are sent in a single payload,
+	// in order
+	assert.Equal(t, 1, transport.payloads)
+	var sent []uint64
+	for _, tr := range transport.traces {
+		sent = append(sent, tr[0].SpanID)
+	}
+	assert.Equal(t, ids, sent)
+}
+
+func TestClassifiedPayloadDrop(t *testing.T) {
+	p := newClassifiedPayload()
+	var kept []uint64
+	for _, prio := range []int{ext.PriorityAutoReject, ext.PriorityUserKeep, ext.PriorityAutoReject, ext.PriorityAutoKeep} {
+		s := newPrioritySpan(prio)
+		if prio > 0 {
+			kept = append(kept, s.SpanID)
+		}
+		require.NoError(t, p.push([]*span{s}))
+	}
+	assert.Equal(t, 2, p.drop(classReject))
+	assert.Equal(t, 2, p.itemCount())
+	assert.False(t, p.has(classReject))
+
+	var traces spanLists
+	require.NoError(t, msgp.Decode(p, &traces))
+	require.Len(t, traces, 2)
+	assert.Equal(t, kept, []uint64{traces[0][0].SpanID, traces[1][0].SpanID})
+}
+
 func BenchmarkJsonEncodeSpan(b *testing.B) {
 	s := makeSpan(10)
 	s.Metrics["nan"] = math.NaN()
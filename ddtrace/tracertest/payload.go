@@ -0,0 +1,167 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package tracertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// keySamplingPriority is the metric holding the sampling priority of a trace chunk.
+const keySamplingPriority = "_sampling_priority_v1"
+
+// Span is a span as it was encoded by the tracer and received by the agent.
+type Span struct {
+	Name       string             `json:"name"`
+	Service    string             `json:"service"`
+	Resource   string             `json:"resource"`
+	Type       string             `json:"type"`
+	Start      int64              `json:"start"`
+	Duration   int64              `json:"duration"`
+	Meta       map[string]string  `json:"meta"`
+	MetaStruct map[string]any     `json:"-"`
+	Metrics    map[string]float64 `json:"metrics"`
+	SpanID     uint64             `json:"span_id"`
+	TraceID    uint64             `json:"trace_id"`
+	ParentID   uint64             `json:"parent_id"`
+	Error      int32              `json:"error"`
+	SpanLinks  []ddtrace.SpanLink `json:"span_links"`
+}
+
+// Tag returns the value of the tag key, looking in the meta then in the metrics of
+// the span, or nil if the span has no such tag.
+func (s *Span) Tag(key string) interface{} {
+	if v, ok := s.Meta[key]; ok {
+		return v
+	}
+	if v, ok := s.Metrics[key]; ok {
+		return v
+	}
+	return nil
+}
+
+// SamplingPriority returns the sampling priority of the span and true, or false if the
+// span doesn't carry it. Only the first span of each trace chunk carries the priority.
+func (s *Span) SamplingPriority() (int, bool) {
+	v, ok := s.Metrics[keySamplingPriority]
+	return int(v), ok
+}
+
+// IsError reports whether the span is an error.
+func (s *Span) IsError() bool {
+	return s.Error != 0 || s.Meta[ext.ErrorMsg] != ""
+}
+
+// String returns a short description of the span, for test failure messages.
+func (s *Span) String() string {
+	return fmt.Sprintf("name=%q service=%q resource=%q trace_id=%d span_id=%d parent_id=%d",
+		s.Name, s.Service, s.Resource, s.TraceID, s.SpanID, s.ParentID)
+}
+
+// Trace is a trace chunk: the spans of a trace which were flushed together.
+type Trace []*Span
+
+// Payload is a trace payload received by the agent.
+type Payload struct {
+	// Header holds the headers of the request, such as X-Datadog-Trace-Count.
+	Header http.Header
+
+	// Traces holds the trace chunks of the payload.
+	Traces []Trace
+}
+
+// StatsPayload is a payload of client computed stats received by the agent.
+type StatsPayload struct {
+	Hostname string
+	Env      string
+	Version  string
+	Stats    []StatsBucket
+}
+
+// StatsBucket is a set of stats computed over a duration.
+type StatsBucket struct {
+	Start    uint64
+	Duration uint64
+	Stats    []GroupedStats
+}
+
+// GroupedStats is a set of stats grouped under an aggregation key. The summaries are
+// encoded DDSketches.
+type GroupedStats struct {
+	Service        string
+	Name           string
+	Resource       string
+	HTTPStatusCode uint32
+	Type           string
+	DBType         string
+	Hits           uint64
+	Errors         uint64
+	Duration       uint64
+	OkSummary      []byte
+	ErrorSummary   []byte
+	Synthetics     bool
+	TopLevelHits   uint64
+	IsTraceRoot    int32
+}
+
+// decodeTraces decodes a msgpack encoded trace payload, as sent to the /v0.4/traces endpoint.
+func decodeTraces(r io.Reader) ([]Trace, error) {
+	var raw [][]struct {
+		Span
+		MetaStruct map[string][]byte `json:"meta_struct"`
+	}
+	if err := decodeMsgpack(r, &raw); err != nil {
+		return nil, fmt.Errorf("decoding traces: %v", err)
+	}
+	traces := make([]Trace, 0, len(raw))
+	for _, rt := range raw {
+		trace := make(Trace, 0, len(rt))
+		for i := range rt {
+			s := rt[i].Span
+			if len(rt[i].MetaStruct) > 0 {
+				s.MetaStruct = make(map[string]any, len(rt[i].MetaStruct))
+				for k, b := range rt[i].MetaStruct {
+					// meta_struct values are msgpack messages wrapped in byte arrays.
+					v, _, err := msgp.ReadIntfBytes(b)
+					if err != nil {
+						return nil, fmt.Errorf("decoding meta_struct %q: %v", k, err)
+					}
+					s.MetaStruct[k] = v
+				}
+			}
+			trace = append(trace, &s)
+		}
+		traces = append(traces, trace)
+	}
+	return traces, nil
+}
+
+// decodeStats decodes a msgpack encoded stats payload, as sent to the /v0.6/stats endpoint.
+func decodeStats(r io.Reader) (*StatsPayload, error) {
+	var p StatsPayload
+	if err := decodeMsgpack(r, &p); err != nil {
+		return nil, fmt.Errorf("decoding stats: %v", err)
+	}
+	return &p, nil
+}
+
+// decodeMsgpack decodes the msgpack message read from r into v, going through its
+// JSON representation.
+func decodeMsgpack(r io.Reader, v interface{}) error {
+	var buf bytes.Buffer
+	if _, err := msgp.CopyToJSON(&buf, r); err != nil {
+		return err
+	}
+	return json.Unmarshal(buf.Bytes(), v)
+}
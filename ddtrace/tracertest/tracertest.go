@@ -0,0 +1,283 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+// Package tracertest provides a test harness running the real tracer against an
+// in-memory agent. Unlike the mocktracer package, the spans go through the whole tracer
+// pipeline (sampling, peer.service computation, partial flushing, stats computation and
+// msgpack encoding), and tests can assert exactly what the Datadog Agent would receive.
+//
+// Simply call "Start" at the beginning of your tests to start the global tracer and
+// obtain the agent receiving its payloads:
+//
+//	func TestHandler(t *testing.T) {
+//		agent := tracertest.Start(t, tracer.WithService("web"))
+//		// ... exercise the instrumented code
+//		spans := agent.WaitForSpans(2)
+//		// ... assert on the spans
+//	}
+//
+// As the global tracer is used, tests using this package can't run in parallel.
+package tracertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// DefaultTimeout is the default duration the WaitFor* methods wait for before failing the test.
+const DefaultTimeout = 10 * time.Second
+
+// agentAddr is the address of the in-memory agent. It is never resolved, as the
+// requests of the tracer are served by the agent's http.RoundTripper.
+const agentAddr = "tracertest.invalid:8126"
+
+// AgentOption configures an Agent.
+type AgentOption func(*Agent)
+
+// WithRates sets the sampling rates returned to the tracer by the agent, keyed as
+// "service:<service>,env:<env>". The "service:,env:" key sets the default rate.
+func WithRates(rates map[string]float64) AgentOption {
+	return func(a *Agent) {
+		a.rates = rates
+	}
+}
+
+// WithClientDropP0s makes the agent advertise that the tracer can drop the traces with
+// a sampling priority lower than or equal to zero, when it computes the stats.
+func WithClientDropP0s(enabled bool) AgentOption {
+	return func(a *Agent) {
+		a.dropP0s = enabled
+	}
+}
+
+// WithTimeout sets the duration the WaitFor* methods wait for before failing the test.
+// The default is DefaultTimeout.
+func WithTimeout(d time.Duration) AgentOption {
+	return func(a *Agent) {
+		a.timeout = d
+	}
+}
+
+// Agent is an in-memory Datadog Agent receiving the payloads of the tracer. It implements
+// http.RoundTripper, serving the requests of the tracer without any network involved.
+type Agent struct {
+	tb       testing.TB
+	timeout  time.Duration
+	rates    map[string]float64
+	dropP0s  bool
+	stopOnce sync.Once
+
+	mu       sync.Mutex // guards below fields
+	payloads []*Payload
+	stats    []*StatsPayload
+	errs     []error
+}
+
+// NewAgent returns an in-memory agent configured with the given options. The agent
+// receives payloads once the tracer is started using Start.
+func NewAgent(tb testing.TB, opts ...AgentOption) *Agent {
+	a := &Agent{
+		tb:      tb,
+		timeout: DefaultTimeout,
+	}
+	for _, fn := range opts {
+		fn(a)
+	}
+	return a
+}
+
+// Start starts the global tracer configured with opts, sending its payloads to a new
+// in-memory agent which is returned. The tracer is stopped when the test finishes.
+func Start(tb testing.TB, opts ...tracer.StartOption) *Agent {
+	a := NewAgent(tb)
+	a.Start(opts...)
+	return a
+}
+
+// Start starts the global tracer configured with opts, sending its payloads to a.
+// The tracer is stopped when the test finishes.
+func (a *Agent) Start(opts ...tracer.StartOption) {
+	tracer.Start(append([]tracer.StartOption{
+		tracer.WithAgentAddr(agentAddr),
+		tracer.WithHTTPClient(&http.Client{Transport: a}),
+		tracer.WithLogStartup(false),
+	}, opts...)...)
+	a.tb.Cleanup(a.Stop)
+}
+
+// Stop stops the global tracer, flushing the remaining traces and stats to the agent.
+// It fails the test if the agent received payloads it could not decode. It is safe to
+// call Stop multiple times.
+func (a *Agent) Stop() {
+	a.stopOnce.Do(func() {
+		tracer.Stop()
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		for _, err := range a.errs {
+			a.tb.Errorf("tracertest: %v", err)
+		}
+	})
+}
+
+// RoundTrip implements http.RoundTripper.
+func (a *Agent) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	switch path := req.URL.Path; {
+	case path == "/info":
+		return jsonResponse(req, http.StatusOK, map[string]interface{}{
+			"endpoints":       []string{"/v0.4/traces", "/v0.6/stats"},
+			"client_drop_p0s": a.dropP0s,
+		}), nil
+	case strings.HasSuffix(path, "/traces"):
+		traces, err := decodeTraces(req.Body)
+		if err != nil {
+			a.addError(err)
+			return textResponse(req, http.StatusBadRequest, err.Error()), nil
+		}
+		a.mu.Lock()
+		a.payloads = append(a.payloads, &Payload{Header: req.Header.Clone(), Traces: traces})
+		a.mu.Unlock()
+		return jsonResponse(req, http.StatusOK, map[string]interface{}{
+			"rate_by_service": a.rates,
+		}), nil
+	case strings.HasSuffix(path, "/stats"):
+		stats, err := decodeStats(req.Body)
+		if err != nil {
+			a.addError(err)
+			return textResponse(req, http.StatusBadRequest, err.Error()), nil
+		}
+		a.mu.Lock()
+		a.stats = append(a.stats, stats)
+		a.mu.Unlock()
+		return textResponse(req, http.StatusOK, "OK"), nil
+	default:
+		// other products (telemetry, remote configuration, data streams) are not supported.
+		return textResponse(req, http.StatusNotFound, "404 page not found"), nil
+	}
+}
+
+func (a *Agent) addError(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.errs = append(a.errs, err)
+}
+
+// Payloads returns the trace payloads received so far.
+func (a *Agent) Payloads() []*Payload {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]*Payload(nil), a.payloads...)
+}
+
+// Traces returns the trace chunks received so far.
+func (a *Agent) Traces() []Trace {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var traces []Trace
+	for _, p := range a.payloads {
+		traces = append(traces, p.Traces...)
+	}
+	return traces
+}
+
+// Spans returns the spans received so far.
+func (a *Agent) Spans() []*Span {
+	var spans []*Span
+	for _, t := range a.Traces() {
+		spans = append(spans, t...)
+	}
+	return spans
+}
+
+// Stats returns the stats payloads received so far.
+func (a *Agent) Stats() []*StatsPayload {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]*StatsPayload(nil), a.stats...)
+}
+
+// Reset discards the payloads received so far.
+func (a *Agent) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.payloads = nil
+	a.stats = nil
+}
+
+// WaitForSpans flushes the tracer until the agent received at least n spans, and
+// returns them. It fails the test if they are not received in time.
+func (a *Agent) WaitForSpans(n int) []*Span {
+	a.tb.Helper()
+	a.waitFor(fmt.Sprintf("%d span(s)", n), func() bool { return len(a.Spans()) >= n })
+	return a.Spans()
+}
+
+// WaitForTraces flushes the tracer until the agent received at least n trace chunks,
+// and returns them. It fails the test if they are not received in time.
+func (a *Agent) WaitForTraces(n int) []Trace {
+	a.tb.Helper()
+	a.waitFor(fmt.Sprintf("%d trace(s)", n), func() bool { return len(a.Traces()) >= n })
+	return a.Traces()
+}
+
+// WaitForStats flushes the tracer until the agent received at least n stats payloads,
+// and returns them. It fails the test if they are not received in time. The tracer only
+// computes stats when enabled, e.g. using tracer.WithStatsComputation.
+func (a *Agent) WaitForStats(n int) []*StatsPayload {
+	a.tb.Helper()
+	a.waitFor(fmt.Sprintf("%d stats payload(s)", n), func() bool { return len(a.Stats()) >= n })
+	return a.Stats()
+}
+
+// waitFor flushes the tracer until cond returns true, failing the test after a.timeout.
+func (a *Agent) waitFor(what string, cond func() bool) {
+	a.tb.Helper()
+	deadline := time.Now().Add(a.timeout)
+	for {
+		// flushing is asynchronous: the payloads may be received after Flush returns.
+		tracer.Flush()
+		if cond() {
+			return
+		}
+		if time.Now().After(deadline) {
+			a.tb.Fatalf("tracertest: timed out after %s waiting for %s", a.timeout, what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func jsonResponse(req *http.Request, code int, v interface{}) *http.Response {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return textResponse(req, http.StatusInternalServerError, err.Error())
+	}
+	resp := textResponse(req, code, string(b))
+	resp.Header.Set("Content-Type", "application/json")
+	return resp
+}
+
+func textResponse(req *http.Request, code int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/plain"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
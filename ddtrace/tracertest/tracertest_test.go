@@ -0,0 +1,186 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package tracertest
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStart(t *testing.T) {
+	assert := assert.New(t)
+	agent := Start(t, tracer.WithService("web"), tracer.WithEnv("test"))
+
+	root := tracer.StartSpan("http.request", tracer.ResourceName("GET /"))
+	child := tracer.StartSpan("db.query", tracer.ChildOf(root.Context()))
+	child.Finish()
+	root.Finish()
+
+	traces := agent.WaitForTraces(1)
+	require.Len(t, traces, 1)
+	require.Len(t, traces[0], 2)
+	spans := agent.Spans()
+	var gotRoot, gotChild *Span
+	for _, s := range spans {
+		switch s.Name {
+		case "http.request":
+			gotRoot = s
+		case "db.query":
+			gotChild = s
+		}
+	}
+	require.NotNil(t, gotRoot)
+	require.NotNil(t, gotChild)
+	assert.Equal("web", gotRoot.Service)
+	assert.Equal("GET /", gotRoot.Resource)
+	assert.Equal("test", gotRoot.Tag(ext.Environment))
+	assert.Equal(root.Context().SpanID(), gotRoot.SpanID)
+	assert.Equal(gotRoot.SpanID, gotChild.ParentID)
+	assert.Equal(gotRoot.TraceID, gotChild.TraceID)
+	assert.NotZero(gotRoot.Duration)
+
+	p, ok := traces[0][0].SamplingPriority()
+	assert.True(ok)
+	assert.Equal(ext.PriorityAutoKeep, p)
+
+	payloads := agent.Payloads()
+	require.Len(t, payloads, 1)
+	assert.Equal("1", payloads[0].Header.Get("X-Datadog-Trace-Count"))
+
+	agent.Reset()
+	assert.Empty(agent.Spans())
+}
+
+func TestSampling(t *testing.T) {
+	t.Run("rules", func(t *testing.T) {
+		agent := Start(t, tracer.WithSamplingRules([]tracer.SamplingRule{tracer.ServiceRule("web", 0)}))
+		tracer.StartSpan("http.request", tracer.ServiceName("web")).Finish()
+
+		spans := agent.WaitForSpans(1)
+		p, ok := spans[0].SamplingPriority()
+		assert.True(t, ok)
+		assert.Equal(t, ext.PriorityUserReject, p)
+		assert.Equal(t, 0., spans[0].Tag("_dd.rule_psr"))
+	})
+
+	t.Run("agent-rates", func(t *testing.T) {
+		agent := NewAgent(t, WithRates(map[string]float64{"service:,env:": 0}))
+		agent.Start()
+		tracer.StartSpan("first").Finish()
+		agent.WaitForSpans(1)
+
+		// the rates returned by the agent apply to the following traces.
+		tracer.StartSpan("second").Finish()
+		spans := agent.WaitForSpans(2)
+		p, _ := spans[1].SamplingPriority()
+		assert.Equal(t, ext.PriorityAutoReject, p)
+	})
+}
+
+func TestPeerService(t *testing.T) {
+	agent := Start(t, tracer.WithPeerServiceDefaults(true))
+	s := tracer.StartSpan("redis.command", tracer.Tag(ext.SpanKind, ext.SpanKindClient), tracer.Tag(ext.DBSystem, "redis"),
+		tracer.Tag(ext.TargetHost, "cache.local"))
+	s.Finish()
+
+	spans := agent.WaitForSpans(1)
+	assert.Equal(t, "cache.local", spans[0].Tag(ext.PeerService))
+	assert.Equal(t, ext.TargetHost, spans[0].Tag("_dd.peer.service.source"))
+}
+
+func TestPartialFlushing(t *testing.T) {
+	agent := Start(t, tracer.WithPartialFlushing(2))
+	root := tracer.StartSpan("root")
+	for i := 0; i < 2; i++ {
+		tracer.StartSpan("child", tracer.ChildOf(root.Context())).Finish()
+	}
+
+	traces := agent.WaitForTraces(1)
+	require.Len(t, traces[0], 2)
+	assert.Equal(t, "child", traces[0][0].Name)
+
+	root.Finish()
+	traces = agent.WaitForTraces(2)
+	require.Len(t, traces[1], 1)
+	assert.Equal(t, "root", traces[1][0].Name)
+}
+
+func TestSpanLinksAndMetaStruct(t *testing.T) {
+	agent := Start(t)
+	link := ddtrace.SpanLink{TraceID: 1, TraceIDHigh: 2, SpanID: 3, Attributes: map[string]string{"link.kind": "follows"}}
+	s := tracer.StartSpan("linked", tracer.WithSpanLinks([]ddtrace.SpanLink{link}))
+	s.SetTag("appsec", internal.MetaStructValue{Value: map[string]any{"triggers": []any{"rule-1"}}})
+	s.Finish()
+
+	spans := agent.WaitForSpans(1)
+	require.Len(t, spans[0].SpanLinks, 1)
+	assert.Equal(t, link, spans[0].SpanLinks[0])
+	assert.Equal(t, map[string]any{"triggers": []any{"rule-1"}}, spans[0].MetaStruct["appsec"])
+}
+
+func TestStats(t *testing.T) {
+	agent := Start(t, tracer.WithStatsComputation(true), tracer.WithService("web"), tracer.WithEnv("test"))
+	s := tracer.StartSpan("http.request", tracer.ResourceName("GET /"))
+	s.Finish()
+	s = tracer.StartSpan("http.request", tracer.ResourceName("GET /"), tracer.StartTime(time.Now().Add(-time.Second)))
+	s.Finish(tracer.WithError(assert.AnError))
+
+	stats := agent.WaitForStats(1)
+	require.NotEmpty(t, stats[0].Stats)
+	assert.Equal(t, "test", stats[0].Env)
+	var hits, errors uint64
+	for _, b := range stats[0].Stats {
+		for _, gs := range b.Stats {
+			assert.Equal(t, "web", gs.Service)
+			assert.Equal(t, "GET /", gs.Resource)
+			assert.NotEmpty(t, gs.OkSummary)
+			hits += gs.Hits
+			errors += gs.Errors
+		}
+	}
+	assert.Equal(t, uint64(2), hits)
+	assert.Equal(t, uint64(1), errors)
+}
+
+func TestWaitTimeout(t *testing.T) {
+	ft := &fakeTB{TB: t}
+	agent := NewAgent(ft, WithTimeout(50*time.Millisecond))
+	agent.Start()
+	defer agent.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		// Fatalf calls runtime.Goexit, which must happen in its own goroutine.
+		defer close(done)
+		agent.WaitForSpans(1)
+	}()
+	<-done
+	assert.True(t, ft.failed)
+}
+
+// fakeTB records test failures instead of failing the test.
+type fakeTB struct {
+	testing.TB
+	failed bool
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(format string, args ...interface{}) { f.failed = true }
+
+func (f *fakeTB) Fatalf(format string, args ...interface{}) {
+	f.failed = true
+	runtime.Goexit()
+}
@@ -30,6 +30,7 @@ type config struct {
 	headersAsTags *internal.LockMap
 	dogstatsdAddr string
 	statsTags     []string
+	statsdClient  internal.StatsdClient
 }
 
 // AnalyticsRate returns the sampling rate at which events should be marked. It uses
@@ -76,6 +77,22 @@ func SetDogstatsdAddr(addr string) {
 	cfg.dogstatsdAddr = addr
 }
 
+// StatsdClient returns the DogStatsD client of the running tracer, or nil if the tracer
+// isn't running.
+func StatsdClient() internal.StatsdClient {
+	cfg.mu.RLock()
+	defer cfg.mu.RUnlock()
+	return cfg.statsdClient
+}
+
+// SetStatsdClient sets the DogStatsD client of the running tracer, which contribs may
+// share. It should only be called by the tracer package.
+func SetStatsdClient(c internal.StatsdClient) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cfg.statsdClient = c
+}
+
 // StatsTags returns a list of tags that apply to statsd payloads for both tracer and contribs
 func StatsTags() []string {
 	cfg.mu.RLock()
@@ -5,8 +5,18 @@
 
 package internal
 
+import "gopkg.in/DataDog/dd-trace-go.v1/internal/samplernames"
+
 // MetaStructValue is a custom type wrapper used to send metadata to the agent via the `meta_struct` field
 // instead of the `meta` inside a span.
 type MetaStructValue struct {
 	Value any // TODO: further constraining Value's type, especially if it becomes public
 }
+
+// SamplingDecision is a custom type wrapper used to set the sampling priority of a span
+// along with the sampler which decided it, as the value of the ext.SamplingPriority tag.
+// Other values of the tag are considered as manual decisions.
+type SamplingDecision struct {
+	Priority int
+	Sampler  samplernames.SamplerName
+}
@@ -34,7 +34,9 @@ const (
 	// SingleSpan specifies that the span was sampled by single
 	// span sampling rules.
 	SingleSpan SamplerName = 8
-	// Sampler name 9 is reserved/used by OTel ingestion.
+	// OTel specifies that the span was sampled by an OpenTelemetry SDK, whose
+	// spans are ingested by Datadog.
+	OTel SamplerName = 9
 	// Sampler name 10 is reserved for Data jobs (spark, databricks etc)
 	// RemoteUserRule specifies that the span was sampled by a rule the user configured remotely
 	// through Datadog UI.
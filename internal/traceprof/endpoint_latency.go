@@ -0,0 +1,89 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package traceprof
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// globalEndpointLatencies is shared between the profiler and the tracer.
+var globalEndpointLatencies = NewEndpointLatencies(1000)
+
+// GlobalEndpointLatencies returns the endpoint latency recorder that is shared
+// between tracing and profiling to support latency triggered profiling.
+func GlobalEndpointLatencies() *EndpointLatencies {
+	return globalEndpointLatencies
+}
+
+// NewEndpointLatencies returns a new EndpointLatencies that will keep up to
+// limit durations per watched endpoint. Once the limit is reached, the oldest
+// durations are overwritten.
+func NewEndpointLatencies(limit int) *EndpointLatencies {
+	return &EndpointLatencies{limit: limit, durations: map[string]*latencyWindow{}}
+}
+
+// EndpointLatencies records the durations of the requests served by a set of
+// watched endpoints. Only watched endpoints are recorded, which keeps the
+// overhead for the tracer close to zero when no endpoint is watched.
+type EndpointLatencies struct {
+	watching  uint64 // 1 if at least one endpoint is watched
+	mu        sync.Mutex
+	durations map[string]*latencyWindow
+	limit     int
+}
+
+// latencyWindow holds the last durations of an endpoint.
+type latencyWindow struct {
+	durations []time.Duration
+	next      int // index of the next duration to overwrite once full
+}
+
+// Watch replaces the set of watched endpoints. Watching no endpoint disables
+// the recording.
+func (e *EndpointLatencies) Watch(endpoints ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.durations = make(map[string]*latencyWindow, len(endpoints))
+	for _, ep := range endpoints {
+		e.durations[ep] = &latencyWindow{}
+	}
+	atomic.StoreUint64(&e.watching, boolToUint64(len(endpoints) > 0))
+}
+
+// Observe records the duration d of a request to endpoint, if it is watched.
+func (e *EndpointLatencies) Observe(endpoint string, d time.Duration) {
+	// Fast-path return if no endpoint is watched.
+	if atomic.LoadUint64(&e.watching) == 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	w, ok := e.durations[endpoint]
+	if !ok {
+		return
+	}
+	if len(w.durations) < e.limit {
+		w.durations = append(w.durations, d)
+		return
+	}
+	w.durations[w.next] = d
+	w.next = (w.next + 1) % e.limit
+}
+
+// GetAndReset returns the durations recorded for each watched endpoint since
+// the previous call, and resets them.
+func (e *EndpointLatencies) GetAndReset() map[string][]time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	res := make(map[string][]time.Duration, len(e.durations))
+	for ep, w := range e.durations {
+		res[ep] = w.durations
+		e.durations[ep] = &latencyWindow{}
+	}
+	return res
+}
@@ -0,0 +1,35 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package traceprof
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointLatencies(t *testing.T) {
+	t.Run("watched", func(t *testing.T) {
+		el := NewEndpointLatencies(2)
+		el.Observe("foo", time.Second) // not watched yet
+		el.Watch("foo")
+		el.Observe("foo", 1)
+		el.Observe("bar", 2)
+		el.Observe("foo", 3)
+		el.Observe("foo", 4) // overwrites the oldest duration
+		require.Equal(t, map[string][]time.Duration{"foo": {4, 3}}, el.GetAndReset())
+		require.Equal(t, map[string][]time.Duration{"foo": nil}, el.GetAndReset())
+	})
+
+	t.Run("unwatched", func(t *testing.T) {
+		el := NewEndpointLatencies(2)
+		el.Watch("foo")
+		el.Watch()
+		el.Observe("foo", 1)
+		require.Empty(t, el.GetAndReset())
+	})
+}
@@ -10,7 +10,8 @@ import (
 )
 
 var profiler struct {
-	enabled uint32
+	enabled       uint32
+	spanLabelKeys atomic.Value // []string
 }
 
 func SetProfilerEnabled(val bool) bool {
@@ -28,6 +29,19 @@ func boolToUint32(b bool) uint32 {
 	return 0
 }
 
+// SetSpanLabelKeys sets the keys of the span tags and baggage items applied as
+// pprof labels by the tracer, for the profiler to make them available as custom
+// attributes.
+func SetSpanLabelKeys(keys []string) {
+	profiler.spanLabelKeys.Store(keys)
+}
+
+// SpanLabelKeys returns the keys set with SetSpanLabelKeys.
+func SpanLabelKeys() []string {
+	keys, _ := profiler.spanLabelKeys.Load().([]string)
+	return keys
+}
+
 func SetProfilerRootTags(localRootSpan TagSetter) {
 	localRootSpan.SetTag("_dd.profiling.enabled", profilerEnabled())
 }
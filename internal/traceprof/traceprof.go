@@ -18,4 +18,5 @@ const (
 	CodeHotspotsEnvVar  = "DD_PROFILING_CODE_HOTSPOTS_COLLECTION_ENABLED" // aka code hotspots
 	EndpointEnvVar      = "DD_PROFILING_ENDPOINT_COLLECTION_ENABLED"      // aka endpoint profiling
 	EndpointCountEnvVar = "DD_PROFILING_ENDPOINT_COUNT_ENABLED"           // aka unit of work
+	SpanLabelKeysEnvVar = "DD_PROFILING_SPAN_LABEL_KEYS"                  // aka span labels
 )
@@ -0,0 +1,178 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"runtime"
+	"time"
+
+	pprofile "github.com/google/pprof/profile"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+const (
+	// sampledEventCost is a rough estimate of the CPU cost of recording a
+	// sampled mutex contention or blocking event: unwinding the stack of the
+	// event, and adding it to the profile under a global lock.
+	sampledEventCost = 5 * time.Microsecond
+
+	// maxAdaptiveMutexFraction and maxAdaptiveBlockRate bound the sampling rates
+	// set by the adaptive sampling, so that the profiles keep some data.
+	maxAdaptiveMutexFraction = 1000000
+	maxAdaptiveBlockRate     = int(time.Second)
+)
+
+// WithAdaptiveSampling adjusts the sampling rates of the mutex and block profiles
+// at every profiling period, to keep the estimated CPU overhead of each of them
+// within budget, as a fraction of one CPU core, e.g. 0.001 for 0.1%. The overhead
+// is estimated from the number of events recorded by the profiles during the last
+// period.
+//
+// The rates set with MutexProfileFraction and BlockProfileRate, or their defaults,
+// are the most precise rates used: the rates are raised when the contention or
+// blocking events are too frequent, and lowered back when they become rarer. The
+// effective rates of each period are added to the profiles as the
+// "mutex_profile_fraction" and "block_profile_rate" tags. As the runtime scales
+// the values of the profiles according to the rates, the values remain comparable
+// across periods.
+//
+// It can also be enabled with the DD_PROFILING_ADAPTIVE_SAMPLING_BUDGET env
+// variable.
+func WithAdaptiveSampling(budget float64) Option {
+	return func(cfg *config) {
+		cfg.adaptiveSamplingBudget = budget
+	}
+}
+
+// adaptiveSampler adjusts the sampling rates of the mutex and block profiles to
+// stay within a CPU overhead budget.
+type adaptiveSampler struct {
+	budget     float64 // CPU overhead budget of each profile, as a fraction of one CPU core
+	cumulative bool    // whether the profiles are cumulative rather than delta profiles
+
+	minMutexFraction, mutexFraction int
+	minBlockRate, blockRate         int
+
+	// last holds the totals of the cumulative profiles of the previous period.
+	last map[ProfileType]contentionTotals
+}
+
+// contentionTotals are the totals of a mutex or block profile.
+type contentionTotals struct {
+	events float64 // estimated number of events
+	delay  float64 // estimated delay of the events, in nanoseconds
+}
+
+func newAdaptiveSampler(cfg *config) *adaptiveSampler {
+	return &adaptiveSampler{
+		budget:           cfg.adaptiveSamplingBudget,
+		cumulative:       !cfg.deltaProfiles,
+		minMutexFraction: cfg.mutexFraction,
+		mutexFraction:    cfg.mutexFraction,
+		minBlockRate:     cfg.blockRate,
+		blockRate:        cfg.blockRate,
+		last:             make(map[ProfileType]contentionTotals),
+	}
+}
+
+// tags returns the tags holding the effective sampling rates of the enabled
+// profile types.
+func (s *adaptiveSampler) tags(types map[ProfileType]struct{}) []string {
+	var tags []string
+	if _, ok := types[MutexProfile]; ok {
+		tags = append(tags, fmt.Sprintf("mutex_profile_fraction:%d", s.mutexFraction))
+	}
+	if _, ok := types[BlockProfile]; ok {
+		tags = append(tags, fmt.Sprintf("block_profile_rate:%d", s.blockRate))
+	}
+	return tags
+}
+
+// adjust sets the sampling rates of the next period from the mutex and block
+// profiles among the completed profiles of a period of the given duration.
+func (s *adaptiveSampler) adjust(completed []*profile, period time.Duration) {
+	// the number of sampled events which fits in the budget of each profile
+	target := s.budget * float64(period) / float64(sampledEventCost)
+	for _, prof := range completed {
+		if prof.pt != MutexProfile && prof.pt != BlockProfile {
+			continue
+		}
+		t, err := s.totals(prof)
+		if err != nil {
+			log.Debug("profiler: adaptive sampling: %s profile: %v", prof.pt, err)
+			continue
+		}
+		switch prof.pt {
+		case MutexProfile:
+			// 1 in mutexFraction events is sampled.
+			f := clampRate(math.Ceil(t.events/target), s.minMutexFraction, maxAdaptiveMutexFraction)
+			if f != s.mutexFraction {
+				log.Debug("profiler: adaptive sampling: %.0f mutex contention events, setting the mutex profile fraction to %d", t.events, f)
+				s.mutexFraction = f
+				runtime.SetMutexProfileFraction(f)
+			}
+		case BlockProfile:
+			// events shorter than blockRate are sampled with a probability
+			// of duration/blockRate, longer ones are always sampled.
+			r := s.minBlockRate
+			if t.events > target {
+				r = clampRate(t.delay/target, s.minBlockRate, maxAdaptiveBlockRate)
+			}
+			if r != s.blockRate {
+				log.Debug("profiler: adaptive sampling: %.0f blocking events, setting the block profile rate to %d", t.events, r)
+				s.blockRate = r
+				runtime.SetBlockProfileRate(r)
+			}
+		}
+	}
+}
+
+// totals returns the totals of the events of prof during the period.
+func (s *adaptiveSampler) totals(prof *profile) (contentionTotals, error) {
+	pp, err := pprofile.Parse(bytes.NewReader(prof.data))
+	if err != nil {
+		return contentionTotals{}, err
+	}
+	var t contentionTotals
+	for _, sample := range pp.Sample {
+		for i, st := range pp.SampleType {
+			switch st.Type {
+			case "contentions":
+				t.events += float64(sample.Value[i])
+			case "delay":
+				t.delay += float64(sample.Value[i])
+			}
+		}
+	}
+	if !s.cumulative {
+		return t, nil
+	}
+	last, ok := s.last[prof.pt]
+	s.last[prof.pt] = t
+	if !ok {
+		return contentionTotals{}, fmt.Errorf("no previous profile")
+	}
+	return contentionTotals{events: t.events - last.events, delay: t.delay - last.delay}, nil
+}
+
+// clampRate returns v rounded up, within [min, max].
+func clampRate(v float64, min, max int) int {
+	if min > max {
+		max = min
+	}
+	switch {
+	case math.IsNaN(v) || v <= float64(min):
+		return min
+	case v >= float64(max):
+		return max
+	default:
+		return int(math.Ceil(v))
+	}
+}
@@ -0,0 +1,119 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/internal/pprofutils"
+)
+
+// contentionProfile returns a mutex or block profile of type pt with the given
+// number of contention events and total delay.
+func contentionProfile(t *testing.T, pt ProfileType, events int, delay time.Duration) *profile {
+	t.Helper()
+	text := fmt.Sprintf("contentions/count delay/nanoseconds\nmain;foo %d %d", events, delay.Nanoseconds())
+	pp, err := pprofutils.Text{}.Convert(strings.NewReader(text))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, pp.Write(&buf))
+	return &profile{name: pt.Filename(), pt: pt, data: buf.Bytes()}
+}
+
+func TestAdaptiveSampler(t *testing.T) {
+	defer runtime.SetMutexProfileFraction(runtime.SetMutexProfileFraction(-1))
+	defer runtime.SetBlockProfileRate(0)
+
+	// a budget of 1% over a second fits 2000 sampled events of 5µs.
+	newSampler := func(t *testing.T, opts ...Option) *adaptiveSampler {
+		cfg, err := defaultConfig()
+		require.NoError(t, err)
+		opts = append(opts, WithAdaptiveSampling(0.01), MutexProfileFraction(10), BlockProfileRate(1000))
+		for _, opt := range opts {
+			opt(cfg)
+		}
+		return newAdaptiveSampler(cfg)
+	}
+
+	t.Run("mutex", func(t *testing.T) {
+		s := newSampler(t)
+		s.adjust([]*profile{contentionProfile(t, MutexProfile, 100000, time.Second)}, time.Second)
+		assert.Equal(t, 50, s.mutexFraction)
+		assert.Equal(t, 50, runtime.SetMutexProfileFraction(-1))
+		assert.Equal(t, []string{"mutex_profile_fraction:50"}, s.tags(map[ProfileType]struct{}{MutexProfile: {}}))
+
+		// lowered back to the configured fraction
+		s.adjust([]*profile{contentionProfile(t, MutexProfile, 100, time.Second)}, time.Second)
+		assert.Equal(t, 10, s.mutexFraction)
+		assert.Equal(t, 10, runtime.SetMutexProfileFraction(-1))
+	})
+
+	t.Run("block", func(t *testing.T) {
+		s := newSampler(t)
+		s.adjust([]*profile{contentionProfile(t, BlockProfile, 10000, 4*time.Second)}, time.Second)
+		// 2000 sampled events of 2ms each
+		assert.Equal(t, int(2*time.Millisecond), s.blockRate)
+		assert.Equal(t, []string{"block_profile_rate:2000000"}, s.tags(map[ProfileType]struct{}{BlockProfile: {}}))
+
+		s.adjust([]*profile{contentionProfile(t, BlockProfile, 1000, 4*time.Second)}, time.Second)
+		assert.Equal(t, 1000, s.blockRate)
+	})
+
+	t.Run("max", func(t *testing.T) {
+		s := newSampler(t)
+		s.adjust([]*profile{
+			contentionProfile(t, MutexProfile, 1e12, time.Second),
+			contentionProfile(t, BlockProfile, 1e12, 1e6*time.Hour),
+		}, time.Second)
+		assert.Equal(t, maxAdaptiveMutexFraction, s.mutexFraction)
+		assert.Equal(t, maxAdaptiveBlockRate, s.blockRate)
+	})
+
+	t.Run("cumulative", func(t *testing.T) {
+		s := newSampler(t, WithDeltaProfiles(false))
+		s.adjust([]*profile{contentionProfile(t, MutexProfile, 100000, time.Second)}, time.Second)
+		assert.Equal(t, 10, s.mutexFraction, "the first cumulative profile has no period")
+		s.adjust([]*profile{contentionProfile(t, MutexProfile, 200000, time.Second)}, time.Second)
+		assert.Equal(t, 50, s.mutexFraction)
+	})
+}
+
+func TestAdaptiveSamplingTags(t *testing.T) {
+	defer runtime.SetMutexProfileFraction(runtime.SetMutexProfileFraction(-1))
+
+	t.Run("enabled", func(t *testing.T) {
+		profile := <-startTestProfiler(t, 1,
+			WithProfileTypes(MutexProfile),
+			WithPeriod(10*time.Millisecond),
+			WithAdaptiveSampling(0.01),
+		)
+		assert.Contains(t, profile.tags, "mutex_profile_fraction:10")
+		assert.Contains(t, profile.event.Attachments, "delta-mutex.pprof")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		profile := <-startTestProfiler(t, 1,
+			WithProfileTypes(MutexProfile),
+			WithPeriod(10*time.Millisecond),
+		)
+		for _, tag := range profile.tags {
+			assert.False(t, strings.HasPrefix(tag, "mutex_profile_fraction:"), tag)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := unstartedProfiler(WithAdaptiveSampling(-1))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,258 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/trace"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// captureCPUProfileRate is the sampling frequency of the CPU profiles of the
+// captures, which is higher than the default rate of 100 Hz to get more precise
+// profiles out of short captures.
+const captureCPUProfileRate = 500
+
+// defaultCaptureTypes are the profile types collected by captures which don't
+// specify any.
+var defaultCaptureTypes = []ProfileType{CPUProfile, executionTrace, GoroutineProfile}
+
+var (
+	errProfilerNotRunning = errors.New("profiler: the profiler is not running")
+	errCaptureInProgress  = errors.New("profiler: a capture is already in progress")
+)
+
+// CaptureNow immediately collects the given profile types during the given
+// duration with the running profiler, independently of its profiling period, and
+// uploads them as a separate profile tagged with "profile_trigger:manual". It blocks
+// until the profiles are collected, ctx is done or the profiler is stopped.
+//
+// If no profile type is given, a high-resolution CPU profile, an execution trace and
+// a goroutine dump are collected. The CPU profile and the execution trace of the
+// periodic profiles are stopped early to make room for the ones of the capture.
+// Only one capture can run at a time, and the metrics profile can't be captured.
+func CaptureNow(ctx context.Context, types []ProfileType, duration time.Duration) error {
+	mu.Lock()
+	p := activeProfiler
+	mu.Unlock()
+	if p == nil {
+		return errProfilerNotRunning
+	}
+	return p.capture(ctx, "manual", types, duration)
+}
+
+// capture collects the given profile types during duration, and enqueues them for
+// upload tagged with the reason of the capture.
+func (p *profiler) capture(ctx context.Context, reason string, types []ProfileType, duration time.Duration) error {
+	if len(types) == 0 {
+		types = defaultCaptureTypes
+	}
+	for _, t := range types {
+		if _, ok := captureFuncs[t]; !ok {
+			return fmt.Errorf("profiler: %s profiles can't be captured", t)
+		}
+	}
+	if !atomic.CompareAndSwapInt32(&p.capturing, 0, 1) {
+		return errCaptureInProgress
+	}
+	defer atomic.StoreInt32(&p.capturing, 0)
+
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	bat := batch{
+		seq:              atomic.AddUint64(&p.seq, 1) - 1,
+		host:             p.cfg.hostname,
+		start:            now(),
+		extraTags:        []string{"profile_trigger:" + reason, pgoTag()},
+		customAttributes: p.customAttributes(),
+	}
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex // guards errs
+		errs     []error
+		profiles = make([]*profile, len(types))
+	)
+	for i, t := range types {
+		wg.Add(1)
+		go func(i int, t ProfileType) {
+			defer wg.Done()
+			data, err := captureFuncs[t](p, ctx)
+			if err != nil {
+				errMu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %v", t, err))
+				errMu.Unlock()
+				return
+			}
+			profiles[i] = &profile{name: t.Filename(), pt: t, data: data}
+		}(i, t)
+	}
+	wg.Wait()
+	select {
+	case <-p.exit:
+		return errProfilerNotRunning
+	default:
+	}
+	if err := parent.Err(); err != nil {
+		return err
+	}
+	var completed []*profile
+	for _, prof := range profiles {
+		if prof != nil {
+			completed = append(completed, prof)
+		}
+	}
+	if p.cfg.traceAnalyzer != nil {
+		var err error
+		if completed, err = analyzeExecutionTrace(p.cfg.traceAnalyzer, completed); err != nil {
+			errs = append(errs, fmt.Errorf("analyzing execution trace: %v", err))
+		}
+	}
+	for _, prof := range completed {
+		if prof.pt == executionTrace {
+			bat.extraTags = append(bat.extraTags, "go_execution_traced:yes")
+		}
+		bat.addProfile(prof)
+	}
+	bat.end = now()
+	if len(bat.profiles) > 0 {
+		p.outMu.Lock()
+		if !p.outClosed {
+			p.enqueueUpload(bat)
+		}
+		p.outMu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// captureFuncs collects the profile types which can be captured, until ctx is done.
+var captureFuncs = map[ProfileType]func(p *profiler, ctx context.Context) ([]byte, error){
+	CPUProfile: func(p *profiler, ctx context.Context) ([]byte, error) {
+		if !p.cpuProfiler.preemptAndAcquire(ctx, p.exit) {
+			return nil, ctx.Err()
+		}
+		defer p.cpuProfiler.release()
+		var buf bytes.Buffer
+		runtime.SetCPUProfileRate(captureCPUProfileRate)
+		if err := p.startCPUProfile(&buf); err != nil {
+			return nil, err
+		}
+		p.waitCapture(ctx)
+		p.stopCPUProfile()
+		return buf.Bytes(), nil
+	},
+	executionTrace: func(p *profiler, ctx context.Context) ([]byte, error) {
+		if !p.executionTracer.preemptAndAcquire(ctx, p.exit) {
+			return nil, ctx.Err()
+		}
+		defer p.executionTracer.release()
+		buf := new(bytes.Buffer)
+		lt := newLimitedTraceCollector(buf, atomic.LoadInt64(&p.traceLimit))
+		if err := trace.Start(lt); err != nil {
+			return nil, err
+		}
+		traceLogCPUProfileRate(captureCPUProfileRate)
+		select {
+		case <-ctx.Done():
+		case <-p.exit:
+		case <-lt.done:
+		}
+		trace.Stop()
+		return buf.Bytes(), nil
+	},
+	GoroutineProfile: captureGenericProfile("goroutine"),
+	HeapProfile:      captureGenericProfile("heap"),
+	BlockProfile:     captureGenericProfile("block"),
+	MutexProfile:     captureGenericProfile("mutex"),
+}
+
+// captureGenericProfile returns a function collecting the named profile at the end
+// of the capture. Unlike the periodic profiles, these are never delta profiles.
+func captureGenericProfile(name string) func(p *profiler, ctx context.Context) ([]byte, error) {
+	return func(p *profiler, ctx context.Context) ([]byte, error) {
+		p.waitCapture(ctx)
+		var buf bytes.Buffer
+		err := p.lookupProfile(name, &buf, 0)
+		return buf.Bytes(), err
+	}
+}
+
+// waitCapture waits until the end of the capture, or until the profiler is stopped.
+func (p *profiler) waitCapture(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-p.exit:
+	}
+}
+
+// runtimeProfiler serializes the use of a runtime profiler which can only run
+// once at a time, such as the CPU profiler or the execution tracer, between the
+// periodic profiles and the captures. Captures preempt the periodic profiles.
+type runtimeProfiler struct {
+	sem     chan struct{} // holds a value while the runtime profiler is in use
+	preempt chan struct{} // signals the periodic profile to stop early
+}
+
+func newRuntimeProfiler() *runtimeProfiler {
+	return &runtimeProfiler{
+		sem:     make(chan struct{}, 1),
+		preempt: make(chan struct{}, 1),
+	}
+}
+
+// acquire waits until the runtime profiler is available, and reports whether it
+// was acquired before done was closed.
+func (r *runtimeProfiler) acquire(done <-chan struct{}) bool {
+	select {
+	case r.sem <- struct{}{}:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// preemptAndAcquire signals the periodic profile using the runtime profiler to stop,
+// and waits until the runtime profiler is available. It reports whether it was
+// acquired before ctx was done or exit was closed.
+func (r *runtimeProfiler) preemptAndAcquire(ctx context.Context, exit <-chan struct{}) bool {
+	select {
+	case r.preempt <- struct{}{}:
+	default:
+	}
+	select {
+	case r.sem <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	case <-exit:
+		return false
+	}
+	// the runtime profiler may have been free, in which case the signal is
+	// discarded so that it doesn't stop the next periodic profile.
+	select {
+	case <-r.preempt:
+	default:
+	}
+	return true
+}
+
+func (r *runtimeProfiler) release() {
+	<-r.sem
+}
+
+// logCaptureError logs the error of a capture, if any.
+func logCaptureError(reason string, err error) {
+	if err != nil {
+		log.Warn("profiler: %s capture: %v", reason, err)
+	}
+}
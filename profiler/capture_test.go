@@ -0,0 +1,166 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package profiler
+
+import (
+	"context"
+	"io"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForTrigger returns the first profile of profiles tagged with the reason of a
+// capture.
+func waitForTrigger(t *testing.T, profiles <-chan profileMeta, reason string) profileMeta {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case m := <-profiles:
+			if sliceContains(m.tags, "profile_trigger:"+reason) {
+				return m
+			}
+		case <-timeout:
+			t.Fatalf("no profile captured for %s", reason)
+		}
+	}
+}
+
+func TestCaptureNow(t *testing.T) {
+	t.Run("not-running", func(t *testing.T) {
+		err := CaptureNow(context.Background(), nil, 10*time.Millisecond)
+		assert.Equal(t, errProfilerNotRunning, err)
+	})
+
+	t.Run("default", func(t *testing.T) {
+		profiles := startTestProfiler(t, 10,
+			WithProfileTypes(CPUProfile, HeapProfile),
+			WithPeriod(time.Hour),
+		)
+		require.NoError(t, CaptureNow(context.Background(), nil, 100*time.Millisecond))
+
+		m := waitForTrigger(t, profiles, "manual")
+		assert.Contains(t, m.tags, "go_execution_traced:yes")
+		assert.ElementsMatch(t, []string{"cpu.pprof", "go.trace", "goroutines.pprof"}, m.event.Attachments)
+		assertContainsCPUProfileRateLog(t, m.attachments["go.trace"], captureCPUProfileRate)
+	})
+
+	t.Run("types", func(t *testing.T) {
+		profiles := startTestProfiler(t, 10,
+			WithProfileTypes(HeapProfile),
+			WithPeriod(time.Hour),
+		)
+		require.NoError(t, CaptureNow(context.Background(), []ProfileType{HeapProfile, MutexProfile}, 10*time.Millisecond))
+
+		m := waitForTrigger(t, profiles, "manual")
+		assert.NotContains(t, m.tags, "go_execution_traced:yes")
+		assert.ElementsMatch(t, []string{"heap.pprof", "mutex.pprof"}, m.event.Attachments)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		startTestProfiler(t, 10, WithPeriod(time.Hour))
+		err := CaptureNow(context.Background(), []ProfileType{MetricsProfile}, 10*time.Millisecond)
+		assert.ErrorContains(t, err, "can't be captured")
+	})
+
+	t.Run("in-progress", func(t *testing.T) {
+		startTestProfiler(t, 10, WithProfileTypes(HeapProfile), WithPeriod(time.Hour))
+		done := make(chan error)
+		go func() {
+			done <- CaptureNow(context.Background(), []ProfileType{GoroutineProfile}, time.Second)
+		}()
+		require.Eventually(t, func() bool {
+			return CaptureNow(context.Background(), []ProfileType{GoroutineProfile}, time.Millisecond) == errCaptureInProgress
+		}, 5*time.Second, time.Millisecond)
+		require.NoError(t, <-done)
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		// the execution trace config is refreshed every period while the
+		// captures run.
+		t.Setenv("DD_PROFILING_EXECUTION_TRACE_ENABLED", "true")
+		t.Setenv("DD_PROFILING_EXECUTION_TRACE_PERIOD", "1ms")
+		// the profiles aren't checked, and uploads to the mock backend
+		// would be interrupted when the profiler stops.
+		startTestProfiler(t, 10,
+			WithProfileTypes(HeapProfile),
+			WithPeriod(10*time.Millisecond),
+			WithUploader(uploaderFunc(func(context.Context, Batch) error { return nil })),
+		)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, CaptureNow(context.Background(), []ProfileType{executionTrace}, 20*time.Millisecond))
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		startTestProfiler(t, 10, WithProfileTypes(HeapProfile), WithPeriod(time.Hour))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := CaptureNow(ctx, []ProfileType{GoroutineProfile}, time.Second)
+		assert.Equal(t, context.Canceled, err)
+	})
+}
+
+func TestCapturePreemptsCPUProfile(t *testing.T) {
+	p, err := unstartedProfiler(WithProfileTypes(CPUProfile), WithPeriod(time.Hour), CPUDuration(time.Hour))
+	require.NoError(t, err)
+	started := make(chan struct{}, 2)
+	p.testHooks.startCPUProfile = func(w io.Writer) error {
+		started <- struct{}{}
+		return pprof.StartCPUProfile(w)
+	}
+	p.testHooks.stopCPUProfile = pprof.StopCPUProfile
+
+	periodic := make(chan error)
+	go func() {
+		_, err := p.runProfile(CPUProfile)
+		periodic <- err
+	}()
+	<-started
+
+	// the capture must stop the periodic CPU profile, which would otherwise
+	// last an hour.
+	capture := make(chan error)
+	go func() {
+		capture <- p.capture(context.Background(), "manual", []ProfileType{CPUProfile}, 10*time.Millisecond)
+	}()
+	select {
+	case err := <-periodic:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the periodic CPU profile wasn't preempted")
+	}
+	<-started
+	require.NoError(t, <-capture)
+}
+
+func TestCapturePreemptsCPUProfileDefaultTypes(t *testing.T) {
+	// with the default profile types, the periodic CPU profile would otherwise wait
+	// until the other profiles complete at the end of the period.
+	profiles := startTestProfiler(t, 10, WithPeriod(time.Hour), CPUDuration(time.Hour))
+	mu.Lock()
+	p := activeProfiler
+	mu.Unlock()
+	require.Eventually(t, func() bool { return len(p.cpuProfiler.sem) == 1 }, 5*time.Second, time.Millisecond,
+		"the periodic CPU profile didn't start")
+
+	done := make(chan error)
+	go func() {
+		done <- CaptureNow(context.Background(), []ProfileType{CPUProfile}, 10*time.Millisecond)
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the capture waited for the periodic profiles")
+	}
+	m := waitForTrigger(t, profiles, "manual")
+	assert.Equal(t, []string{"cpu.pprof"}, m.event.Attachments)
+}
@@ -0,0 +1,112 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+// pprofdiff compares two pprof profiles, or two directories of profiles, and
+// reports the functions whose cost regressed. See the compare package.
+//
+//	go run gopkg.in/DataDog/dd-trace-go.v1/profiler/cmd/pprofdiff [flags] base head
+//
+// It exits with status 1 if the head profiles regressed above the thresholds, and
+// with status 2 on errors, so that it can fail CI jobs.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/compare"
+)
+
+const (
+	exitRegressed = 1
+	exitError     = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("pprofdiff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: pprofdiff [flags] base head")
+		fmt.Fprintln(stderr, "\nCompares the head profile(s) to the base profile(s), which are pprof files or directories of them.")
+		fmt.Fprintln(stderr, "Exits with status 1 on regressions above the thresholds, and 2 on errors.")
+		fs.PrintDefaults()
+	}
+	var (
+		sampleType     = fs.String("sample_type", "", "sample type to compare, e.g. cpu or alloc_space (default: the default sample type of the profiles)")
+		cumulative     = fs.Bool("cum", false, "compare the cumulative values of the functions instead of their flat values")
+		top            = fs.Int("top", 10, "number of functions to report per profile")
+		threshold      = fs.Float64("threshold", compare.DefaultThreshold, "minimum increase of a function, as a fraction of the base total, to fail")
+		totalThreshold = fs.Float64("total_threshold", compare.DefaultThreshold, "minimum increase of the total, as a fraction of the base total, to fail")
+	)
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitError
+	}
+	reports, err := compare.CompareFiles(fs.Arg(0), fs.Arg(1), *sampleType, compare.Options{
+		Cumulative:     *cumulative,
+		Threshold:      threshold,
+		TotalThreshold: totalThreshold,
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "pprofdiff: %v\n", err)
+		return exitError
+	}
+	status := 0
+	for _, r := range reports {
+		writeReport(stdout, r, *top)
+		if r.Regressed() {
+			status = exitRegressed
+		}
+	}
+	return status
+}
+
+// writeReport writes the top functions of r by delta, marking the regressions.
+func writeReport(w io.Writer, r compare.NamedReport, top int) {
+	status := "ok"
+	if r.Regressed() {
+		status = "REGRESSED"
+	}
+	fmt.Fprintf(w, "%s: %s (%s) total %s -> %s (%s) %s\n", r.Name, r.SampleType, r.Unit,
+		formatValue(r.BaseTotal), formatValue(r.HeadTotal), formatChange(r.HeadTotal-r.BaseTotal, r.BaseTotal), status)
+	regressions := make(map[string]bool, len(r.Regressions))
+	for _, d := range r.Regressions {
+		regressions[d.Function] = true
+	}
+	for i, d := range r.Diffs {
+		if i == top || d.Delta() <= 0 {
+			break
+		}
+		mark := " "
+		if regressions[d.Function] {
+			mark = "!"
+		}
+		fmt.Fprintf(w, "%s %12s %12s %12s %8s  %s\n", mark, formatValue(d.Delta()), formatValue(d.Base), formatValue(d.Head),
+			formatChange(d.Delta(), r.BaseTotal), d.Function)
+	}
+	fmt.Fprintln(w)
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+// formatChange formats delta as a percentage of total.
+func formatChange(delta, total float64) string {
+	if total == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", 100*delta/total)
+}
@@ -0,0 +1,68 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/internal/pprofutils"
+)
+
+func writeProfile(t *testing.T, path, text string) {
+	t.Helper()
+	p, err := pprofutils.Text{}.Convert(strings.NewReader(text))
+	require.NoError(t, err)
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, p.Write(f))
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	base, head := filepath.Join(dir, "base.pprof"), filepath.Join(dir, "head.pprof")
+	writeProfile(t, base, "main;foo 50\nmain;bar 50")
+	writeProfile(t, head, "main;foo 80\nmain;bar 50")
+
+	t.Run("regressed", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, exitRegressed, run([]string{base, head}, &stdout, &stderr))
+		assert.Contains(t, stdout.String(), "REGRESSED")
+		assert.Regexp(t, `! +30 +50 +80 +\+30.0%  foo\n`, stdout.String())
+		assert.NotContains(t, stdout.String(), "bar")
+	})
+
+	t.Run("threshold", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, 0, run([]string{"-threshold=0.5", "-total_threshold=0.5", base, head}, &stdout, &stderr))
+		assert.Contains(t, stdout.String(), " ok\n")
+
+		// a zero threshold isn't replaced by the default one
+		stdout.Reset()
+		small := filepath.Join(dir, "small.pprof")
+		writeProfile(t, small, "main;foo 51\nmain;bar 50")
+		assert.Equal(t, exitRegressed, run([]string{"-threshold=0", "-total_threshold=0", base, small}, &stdout, &stderr))
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, exitError, run([]string{base}, &stdout, &stderr))
+		assert.Contains(t, stderr.String(), "usage: pprofdiff")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, exitError, run([]string{base, filepath.Join(dir, "missing.pprof")}, &stdout, &stderr))
+		assert.Contains(t, stderr.String(), "pprofdiff: ")
+	})
+}
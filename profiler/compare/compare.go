@@ -0,0 +1,267 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+// Package compare compares pprof profiles, such as the ones written by the
+// profiler with profiler.WithOutputDirectory or by benchmarks, and reports the
+// functions whose cost regressed. The pprofdiff command wraps it for use in CI:
+//
+//	go run gopkg.in/DataDog/dd-trace-go.v1/profiler/cmd/pprofdiff base.pprof head.pprof
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+// DefaultThreshold is the default threshold of the regressions, as a fraction of
+// the total of the base profile.
+const DefaultThreshold = 0.05
+
+// gaugeSampleTypes are the sample types measuring a state at the end of the
+// profile, rather than events during the profile, which can't be normalized by
+// the duration of the profiles.
+var gaugeSampleTypes = map[string]bool{
+	"inuse_space":   true,
+	"inuse_objects": true,
+	"goroutine":     true,
+	"goroutines":    true,
+}
+
+// Summary holds the values of a sample type of one or several profiles,
+// aggregated by function.
+type Summary struct {
+	// SampleType is the type of the values, e.g. "cpu" or "alloc_space".
+	SampleType string
+	// Unit is the unit of the values, e.g. "nanoseconds" or "bytes".
+	Unit string
+	// Duration is the total duration of the profiles, or zero if unknown.
+	Duration time.Duration
+	// Total is the sum of the values of all the samples.
+	Total float64
+	// Flat is the sum of the values of the samples by leaf function.
+	Flat map[string]float64
+	// Cum is the sum of the values of the samples by function anywhere in
+	// their stack.
+	Cum map[string]float64
+}
+
+// Summarize aggregates the values of the given sample type of p by function. If
+// sampleType is empty, the default sample type of p is used.
+//
+// Sample counts, e.g. the "samples" sample type of CPU profiles, are converted to
+// the unit of the sampling period of p, so that profiles collected with different
+// sampling rates can be compared.
+func Summarize(p *profile.Profile, sampleType string) (*Summary, error) {
+	idx, err := sampleIndex(p, sampleType)
+	if err != nil {
+		return nil, err
+	}
+	st := p.SampleType[idx]
+	s := &Summary{
+		SampleType: st.Type,
+		Unit:       st.Unit,
+		Duration:   time.Duration(p.DurationNanos),
+		Flat:       make(map[string]float64),
+		Cum:        make(map[string]float64),
+	}
+	scale := 1.0
+	if st.Unit == "count" && st.Type == "samples" && p.PeriodType != nil && p.Period > 0 {
+		scale = float64(p.Period)
+		s.Unit = p.PeriodType.Unit
+	}
+	seen := make(map[string]bool)
+	for _, sample := range p.Sample {
+		v := float64(sample.Value[idx]) * scale
+		if v == 0 {
+			continue
+		}
+		s.Total += v
+		for k := range seen {
+			delete(seen, k)
+		}
+		for i, loc := range sample.Location {
+			// the lines of a location are ordered from the innermost inlined
+			// function to the caller.
+			for j, line := range loc.Line {
+				if line.Function == nil {
+					continue
+				}
+				name := line.Function.Name
+				if i == 0 && j == 0 {
+					s.Flat[name] += v
+				}
+				if !seen[name] {
+					seen[name] = true
+					s.Cum[name] += v
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+// sampleIndex returns the index of the given sample type in p, or of its default
+// sample type if sampleType is empty.
+func sampleIndex(p *profile.Profile, sampleType string) (int, error) {
+	if len(p.SampleType) == 0 {
+		return 0, errors.New("profile has no sample types")
+	}
+	if sampleType == "" {
+		sampleType = p.DefaultSampleType
+	}
+	if sampleType == "" {
+		return len(p.SampleType) - 1, nil
+	}
+	for i, st := range p.SampleType {
+		if st.Type == sampleType {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("profile has no %q sample type", sampleType)
+}
+
+// Add adds the values of o to s, e.g. to compare the profiles of several runs of
+// a benchmark. As the values of gauge sample types, e.g. inuse_space, can't be
+// added up meaningfully, see Scale to average them instead.
+func (s *Summary) Add(o *Summary) error {
+	if s.SampleType != o.SampleType || s.Unit != o.Unit {
+		return fmt.Errorf("can't add %s/%s values to %s/%s values", o.SampleType, o.Unit, s.SampleType, s.Unit)
+	}
+	if s.Duration > 0 && o.Duration > 0 {
+		s.Duration += o.Duration
+	} else {
+		s.Duration = 0
+	}
+	s.Total += o.Total
+	for name, v := range o.Flat {
+		s.Flat[name] += v
+	}
+	for name, v := range o.Cum {
+		s.Cum[name] += v
+	}
+	return nil
+}
+
+// Scale multiplies the values of s by f, e.g. 1/n to average the values of n
+// profiles added together.
+func (s *Summary) Scale(f float64) {
+	s.Total *= f
+	for name := range s.Flat {
+		s.Flat[name] *= f
+	}
+	for name := range s.Cum {
+		s.Cum[name] *= f
+	}
+}
+
+// Options configures Compare.
+type Options struct {
+	// Cumulative compares the cumulative values of the functions, i.e.
+	// including their callees, instead of their flat values.
+	Cumulative bool
+	// Threshold is the minimum increase of the value of a function, as a
+	// fraction of the total of the base profile, for it to be reported as a
+	// regression. Defaults to DefaultThreshold if nil.
+	Threshold *float64
+	// TotalThreshold is the minimum increase of the total of the profiles, as a
+	// fraction of the total of the base profile, for it to be reported as a
+	// regression. Defaults to DefaultThreshold if nil.
+	TotalThreshold *float64
+}
+
+// threshold returns the value of t, or DefaultThreshold if t is nil.
+func threshold(t *float64) float64 {
+	if t == nil {
+		return DefaultThreshold
+	}
+	return *t
+}
+
+// FunctionDiff is the difference of the value of a function between two
+// profiles.
+type FunctionDiff struct {
+	Function string
+	Base     float64
+	Head     float64
+}
+
+// Delta returns the increase of the value of the function.
+func (d FunctionDiff) Delta() float64 {
+	return d.Head - d.Base
+}
+
+// Report is the result of the comparison of two profiles.
+type Report struct {
+	// SampleType is the type of the compared values.
+	SampleType string
+	// Unit is the unit of the compared values. It ends with "/s" if the values
+	// were normalized by the duration of the profiles.
+	Unit string
+	// BaseTotal and HeadTotal are the totals of the profiles.
+	BaseTotal, HeadTotal float64
+	// Diffs are the differences of all the functions of the profiles, by
+	// decreasing delta.
+	Diffs []FunctionDiff
+	// Regressions are the diffs whose delta is above the threshold.
+	Regressions []FunctionDiff
+	// TotalRegressed reports whether the increase of the total is above the
+	// total threshold.
+	TotalRegressed bool
+}
+
+// Regressed reports whether the head profile regressed compared to the base
+// profile, according to the thresholds of the comparison.
+func (r *Report) Regressed() bool {
+	return r.TotalRegressed || len(r.Regressions) > 0
+}
+
+// Compare compares the head profile to the base profile. If both profiles have a
+// duration, and their sample type counts events (e.g. CPU time or allocations)
+// rather than a state (e.g. the in-use heap), the values are normalized by their
+// duration.
+func Compare(base, head *Summary, opts Options) (*Report, error) {
+	if base.SampleType != head.SampleType || base.Unit != head.Unit {
+		return nil, fmt.Errorf("can't compare %s/%s values to %s/%s values", head.SampleType, head.Unit, base.SampleType, base.Unit)
+	}
+	funcThreshold, totalThreshold := threshold(opts.Threshold), threshold(opts.TotalThreshold)
+	baseScale, headScale := 1.0, 1.0
+	r := &Report{SampleType: base.SampleType, Unit: base.Unit}
+	if base.Duration > 0 && head.Duration > 0 && !gaugeSampleTypes[base.SampleType] {
+		baseScale, headScale = 1/base.Duration.Seconds(), 1/head.Duration.Seconds()
+		r.Unit += "/s"
+	}
+	r.BaseTotal, r.HeadTotal = base.Total*baseScale, head.Total*headScale
+
+	baseValues, headValues := base.Flat, head.Flat
+	if opts.Cumulative {
+		baseValues, headValues = base.Cum, head.Cum
+	}
+	for name, v := range baseValues {
+		r.Diffs = append(r.Diffs, FunctionDiff{Function: name, Base: v * baseScale, Head: headValues[name] * headScale})
+	}
+	for name, v := range headValues {
+		if _, ok := baseValues[name]; !ok {
+			r.Diffs = append(r.Diffs, FunctionDiff{Function: name, Head: v * headScale})
+		}
+	}
+	sort.Slice(r.Diffs, func(i, j int) bool {
+		if di, dj := r.Diffs[i].Delta(), r.Diffs[j].Delta(); di != dj {
+			return di > dj
+		}
+		return r.Diffs[i].Function < r.Diffs[j].Function
+	})
+	for _, d := range r.Diffs {
+		if d.Delta() <= funcThreshold*r.BaseTotal {
+			break
+		}
+		r.Regressions = append(r.Regressions, d)
+	}
+	r.TotalRegressed = r.HeadTotal-r.BaseTotal > totalThreshold*r.BaseTotal
+	return r, nil
+}
@@ -0,0 +1,236 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package compare
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/internal/pprofutils"
+)
+
+// parseText parses a profile in the folded text format of pprofutils.Text, e.g.
+// "main;foo 10", with the given duration.
+func parseText(t *testing.T, text string, duration time.Duration) *profile.Profile {
+	t.Helper()
+	p, err := pprofutils.Text{}.Convert(strings.NewReader(strings.TrimSpace(text)))
+	require.NoError(t, err)
+	p.DurationNanos = duration.Nanoseconds()
+	return p
+}
+
+func summarize(t *testing.T, text string, duration time.Duration) *Summary {
+	t.Helper()
+	s, err := Summarize(parseText(t, text, duration), "")
+	require.NoError(t, err)
+	return s
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestSummarize(t *testing.T) {
+	t.Run("flat-cum", func(t *testing.T) {
+		s := summarize(t, `
+main;foo;bar 10
+main;foo 5
+main;baz;foo 2
+`, time.Second)
+		assert.Equal(t, "samples", s.SampleType)
+		assert.Equal(t, 17.0, s.Total)
+		assert.Equal(t, map[string]float64{"bar": 10, "foo": 7}, s.Flat)
+		assert.Equal(t, map[string]float64{"main": 17, "foo": 17, "bar": 10, "baz": 2}, s.Cum)
+	})
+
+	t.Run("recursion", func(t *testing.T) {
+		s := summarize(t, "main;foo;foo;foo 3", time.Second)
+		assert.Equal(t, map[string]float64{"main": 3, "foo": 3}, s.Cum)
+	})
+
+	t.Run("sample-rate", func(t *testing.T) {
+		p := parseText(t, "main;foo 10", time.Second)
+		p.PeriodType = &profile.ValueType{Type: "cpu", Unit: "nanoseconds"}
+		p.Period = 10 * time.Millisecond.Nanoseconds()
+		s, err := Summarize(p, "")
+		require.NoError(t, err)
+		assert.Equal(t, "nanoseconds", s.Unit)
+		assert.Equal(t, float64(100*time.Millisecond), s.Total)
+	})
+
+	t.Run("sample-type", func(t *testing.T) {
+		p := parseText(t, `
+samples/count cpu/nanoseconds
+main;foo 1 10
+`, time.Second)
+		s, err := Summarize(p, "")
+		require.NoError(t, err)
+		assert.Equal(t, "cpu", s.SampleType)
+		assert.Equal(t, 10.0, s.Total)
+
+		s, err = Summarize(p, "samples")
+		require.NoError(t, err)
+		assert.Equal(t, 1.0, s.Total)
+
+		_, err = Summarize(p, "alloc_space")
+		assert.ErrorContains(t, err, `no "alloc_space" sample type`)
+	})
+}
+
+func TestCompare(t *testing.T) {
+	t.Run("regressions", func(t *testing.T) {
+		base := summarize(t, `
+main;foo 50
+main;bar 48
+main;baz 2
+`, time.Second)
+		head := summarize(t, `
+main;foo 60
+main;bar 46
+main;qux 4
+`, time.Second)
+		r, err := Compare(base, head, Options{})
+		require.NoError(t, err)
+		assert.Equal(t, 100.0, r.BaseTotal)
+		assert.Equal(t, 110.0, r.HeadTotal)
+		assert.Equal(t, []FunctionDiff{
+			{Function: "foo", Base: 50, Head: 60},
+			{Function: "qux", Head: 4},
+			{Function: "bar", Base: 48, Head: 46},
+			{Function: "baz", Base: 2},
+		}, r.Diffs)
+		assert.Equal(t, []FunctionDiff{{Function: "foo", Base: 50, Head: 60}}, r.Regressions)
+		assert.True(t, r.TotalRegressed)
+		assert.True(t, r.Regressed())
+	})
+
+	t.Run("thresholds", func(t *testing.T) {
+		base := summarize(t, "main;foo 100", time.Second)
+		head := summarize(t, "main;foo 110", time.Second)
+		r, err := Compare(base, head, Options{Threshold: ptr(0.2), TotalThreshold: ptr(0.2)})
+		require.NoError(t, err)
+		assert.Empty(t, r.Regressions)
+		assert.False(t, r.Regressed())
+
+		// any increase is a regression with zero thresholds.
+		head = summarize(t, "main;foo 101", time.Second)
+		r, err = Compare(base, head, Options{Threshold: ptr(0.0), TotalThreshold: ptr(0.0)})
+		require.NoError(t, err)
+		assert.Equal(t, []FunctionDiff{{Function: "foo", Base: 100, Head: 101}}, r.Regressions)
+		assert.True(t, r.TotalRegressed)
+	})
+
+	t.Run("cumulative", func(t *testing.T) {
+		base := summarize(t, "main;foo;bar 10\nmain;foo;baz 10", time.Second)
+		head := summarize(t, "main;foo;bar 11\nmain;foo;baz 11", time.Second)
+		r, err := Compare(base, head, Options{Cumulative: true, TotalThreshold: ptr(1.0)})
+		require.NoError(t, err)
+		assert.Equal(t, []FunctionDiff{
+			{Function: "foo", Base: 20, Head: 22},
+			{Function: "main", Base: 20, Head: 22},
+		}, r.Regressions)
+	})
+
+	t.Run("duration", func(t *testing.T) {
+		base := summarize(t, "main;foo 100", time.Second)
+		head := summarize(t, "main;foo 300", 2*time.Second)
+		r, err := Compare(base, head, Options{})
+		require.NoError(t, err)
+		assert.Equal(t, "count/s", r.Unit)
+		assert.Equal(t, []FunctionDiff{{Function: "foo", Base: 100, Head: 150}}, r.Regressions)
+	})
+
+	t.Run("gauge", func(t *testing.T) {
+		base := summarize(t, "inuse_space/bytes\nmain;foo 100", time.Second)
+		head := summarize(t, "inuse_space/bytes\nmain;foo 100", 2*time.Second)
+		r, err := Compare(base, head, Options{})
+		require.NoError(t, err)
+		assert.Equal(t, "bytes", r.Unit)
+		assert.False(t, r.Regressed())
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		base := summarize(t, "cpu/nanoseconds\nmain;foo 100", time.Second)
+		head := summarize(t, "alloc_space/bytes\nmain;foo 100", time.Second)
+		_, err := Compare(base, head, Options{})
+		assert.Error(t, err)
+	})
+}
+
+func TestCompareFiles(t *testing.T) {
+	write := func(t *testing.T, path, text string) {
+		t.Helper()
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		f, err := os.Create(path)
+		require.NoError(t, err)
+		defer f.Close()
+		require.NoError(t, parseText(t, text, time.Second).Write(f))
+	}
+
+	t.Run("files", func(t *testing.T) {
+		dir := t.TempDir()
+		write(t, filepath.Join(dir, "base.pprof"), "main;foo 10")
+		write(t, filepath.Join(dir, "head.pprof"), "main;foo 20")
+		reports, err := CompareFiles(filepath.Join(dir, "base.pprof"), filepath.Join(dir, "head.pprof"), "", Options{})
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		assert.Equal(t, "head.pprof", reports[0].Name)
+		assert.True(t, reports[0].Regressed())
+	})
+
+	t.Run("directories", func(t *testing.T) {
+		base, head := t.TempDir(), t.TempDir()
+		// the folders written by profiler.WithOutputDirectory
+		write(t, filepath.Join(base, "20240101T000000.000Z-0", "cpu.pprof"), "main;foo 10")
+		write(t, filepath.Join(base, "20240101T000100.000Z-1", "cpu.pprof"), "main;foo 10")
+		write(t, filepath.Join(base, "20240101T000000.000Z-0", "delta-heap.pprof"), "alloc_space/bytes\nmain;bar 10")
+		write(t, filepath.Join(base, "20240101T000000.000Z-0", "goroutines.pprof"), "goroutine/count\nmain;baz 10")
+		write(t, filepath.Join(head, "cpu.pprof"), "main;foo 10")
+		write(t, filepath.Join(head, "delta-heap.pprof"), "alloc_space/bytes\nmain;bar 20")
+		require.NoError(t, os.WriteFile(filepath.Join(head, "metrics.json"), []byte("[]"), 0644))
+
+		reports, err := CompareFiles(base, head, "", Options{})
+		require.NoError(t, err)
+		require.Len(t, reports, 2)
+		assert.Equal(t, "cpu.pprof", reports[0].Name)
+		assert.False(t, reports[0].Regressed())
+		assert.Equal(t, 10.0, reports[0].BaseTotal)
+		assert.Equal(t, "delta-heap.pprof", reports[1].Name)
+		assert.True(t, reports[1].Regressed())
+
+		reports, err = CompareFiles(base, head, "alloc_space", Options{})
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		assert.Equal(t, "delta-heap.pprof", reports[0].Name)
+	})
+
+	t.Run("gauges", func(t *testing.T) {
+		base, head := t.TempDir(), t.TempDir()
+		write(t, filepath.Join(base, "20240101T000000.000Z-0", "goroutines.pprof"), "goroutine/count\nmain;baz 10")
+		write(t, filepath.Join(base, "20240101T000100.000Z-1", "goroutines.pprof"), "goroutine/count\nmain;baz 30")
+		write(t, filepath.Join(head, "20240101T000000.000Z-0", "goroutines.pprof"), "goroutine/count\nmain;baz 20")
+
+		// the goroutine counts are averaged, not added up.
+		reports, err := CompareFiles(base, head, "", Options{})
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		assert.Equal(t, 20.0, reports[0].BaseTotal)
+		assert.Equal(t, 20.0, reports[0].HeadTotal)
+		assert.False(t, reports[0].Regressed())
+	})
+
+	t.Run("mixed", func(t *testing.T) {
+		dir := t.TempDir()
+		write(t, filepath.Join(dir, "cpu.pprof"), "main;foo 10")
+		_, err := CompareFiles(dir, filepath.Join(dir, "cpu.pprof"), "", Options{})
+		assert.ErrorContains(t, err, "both must be profiles or directories")
+	})
+}
@@ -0,0 +1,156 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package compare
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/pprof/profile"
+)
+
+// NamedReport is the report of the comparison of the profiles with a given file
+// name.
+type NamedReport struct {
+	Name string
+	*Report
+}
+
+// Load summarizes the given sample type of the pprof profile at path, which may be
+// gzipped. See Summarize.
+func Load(path string, sampleType string) (*Summary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := profile.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	s, err := Summarize(p, sampleType)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+// CompareFiles compares the given sample type of the profiles at the base and head
+// paths, which are either both profiles or both directories.
+//
+// The profiles of directories are found recursively, by their ".pprof" or
+// ".pb.gz" extension, and compared by file name, e.g. "cpu.pprof" to "cpu.pprof".
+// The profiles with the same file name, e.g. the ones of the folders written by
+// profiler.WithOutputDirectory, are added together, or averaged for the sample
+// types measuring a state rather than events, e.g. inuse_space or goroutine. The
+// profiles without the given sample type are skipped. The reports are sorted by
+// name.
+func CompareFiles(base, head string, sampleType string, opts Options) ([]NamedReport, error) {
+	baseInfo, err := os.Stat(base)
+	if err != nil {
+		return nil, err
+	}
+	headInfo, err := os.Stat(head)
+	if err != nil {
+		return nil, err
+	}
+	if baseInfo.IsDir() != headInfo.IsDir() {
+		return nil, fmt.Errorf("can't compare %s to %s: both must be profiles or directories", head, base)
+	}
+	if !baseInfo.IsDir() {
+		b, err := Load(base, sampleType)
+		if err != nil {
+			return nil, err
+		}
+		h, err := Load(head, sampleType)
+		if err != nil {
+			return nil, err
+		}
+		r, err := Compare(b, h, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []NamedReport{{Name: filepath.Base(head), Report: r}}, nil
+	}
+
+	baseSummaries, err := loadDir(base, sampleType)
+	if err != nil {
+		return nil, err
+	}
+	headSummaries, err := loadDir(head, sampleType)
+	if err != nil {
+		return nil, err
+	}
+	var reports []NamedReport
+	for name, b := range baseSummaries {
+		h, ok := headSummaries[name]
+		if !ok {
+			continue
+		}
+		r, err := Compare(b, h, opts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		reports = append(reports, NamedReport{Name: name, Report: r})
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("no profiles to compare in both %s and %s", base, head)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+	return reports, nil
+}
+
+// loadDir summarizes the profiles of dir by file name, see CompareFiles.
+func loadDir(dir string, sampleType string) (map[string]*Summary, error) {
+	summaries := make(map[string]*Summary)
+	counts := make(map[string]int) // number of profiles by file name
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() || !(strings.HasSuffix(name, ".pprof") || strings.HasSuffix(name, ".pb.gz")) {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		p, err := profile.Parse(f)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if sampleType != "" {
+			if _, err := sampleIndex(p, sampleType); err != nil {
+				return nil
+			}
+		}
+		s, err := Summarize(p, sampleType)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		counts[name]++
+		if prev, ok := summaries[name]; ok {
+			if err := prev.Add(s); err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			return nil
+		}
+		summaries[name] = s
+		return nil
+	})
+	for name, s := range summaries {
+		if n := counts[name]; n > 1 && gaugeSampleTypes[s.SampleType] {
+			s.Scale(1 / float64(n))
+		}
+	}
+	return summaries, err
+}
@@ -0,0 +1,16 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+//go:build !unix
+
+package profiler
+
+import "time"
+
+// processCPUTime returns the CPU time used by the process so far. It's not
+// available on this platform.
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
@@ -0,0 +1,213 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	runtimemetrics "runtime/metrics"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
+
+	pprofile "github.com/google/pprof/profile"
+)
+
+// allocFuncPrefix is the prefix of the function of the runtime allocating memory.
+// The CPU samples with this function in their stack are allocation samples.
+const allocFuncPrefix = "runtime.mallocgc"
+
+// allocCounters holds the runtime metrics counting the allocations.
+var allocCounters = []string{"/gc/heap/allocs:bytes", "/gc/heap/allocs:objects"}
+
+// readAllocs returns the number of bytes and objects allocated on the heap since
+// the program started.
+func readAllocs() (bytes, objects uint64) {
+	samples := make([]runtimemetrics.Sample, len(allocCounters))
+	for i, name := range allocCounters {
+		samples[i].Name = name
+	}
+	runtimemetrics.Read(samples)
+	if samples[0].Value.Kind() != runtimemetrics.KindUint64 || samples[1].Value.Kind() != runtimemetrics.KindUint64 {
+		return 0, 0
+	}
+	return samples[0].Value.Uint64(), samples[1].Value.Uint64()
+}
+
+// allocWindow counts the allocations made on the heap during a time window, such as
+// the duration of a CPU profile.
+type allocWindow struct {
+	start          time.Time
+	bytes, objects uint64
+	duration       time.Duration // zero until the window ends
+}
+
+// startAllocWindow starts counting the allocations.
+func startAllocWindow() allocWindow {
+	w := allocWindow{start: now()}
+	w.bytes, w.objects = readAllocs()
+	return w
+}
+
+// end returns the allocations made since the window started.
+func (w allocWindow) end() allocWindow {
+	b, o := readAllocs()
+	return allocWindow{
+		start:    w.start,
+		bytes:    b - w.bytes,
+		objects:  o - w.objects,
+		duration: now().Sub(w.start),
+	}
+}
+
+// attributeAllocations adds the endpoint allocation profile to the completed
+// profiles of a profiling cycle, whose CPU profile ran for duration, during which
+// allocBytes and allocObjects were allocated, see allocWindow. Each CPU sample spent
+// allocating memory gets the share of these allocations matching its share of the CPU
+// time spent allocating memory, keeping its stack and labels, including the trace
+// endpoint. The allocation rates of the endpoints over duration are added to the
+// metrics profile.
+//
+// This is a heuristic: the heap profile samples the allocations but doesn't record
+// the labels of the goroutines, so the CPU profile is the only source of the
+// endpoints. As the CPU time spent allocating isn't proportional to the allocated
+// memory, the values are estimates, and named as such. Measuring the allocations over
+// the window of the CPU profile, rather than the whole period, keeps them consistent
+// with the CPU samples when the CPU profile is shorter than the period, see
+// CPUDuration, or stopped early by a capture.
+func attributeAllocations(completed []*profile, allocBytes, allocObjects uint64, duration time.Duration) ([]*profile, error) {
+	var cpu, met *profile
+	for _, prof := range completed {
+		switch prof.pt {
+		case CPUProfile:
+			cpu = prof
+		case MetricsProfile:
+			met = prof
+		}
+	}
+	if cpu == nil {
+		return completed, errors.New("the endpoint allocation profile requires the CPU profile")
+	}
+	prof, err := pprofile.ParseData(cpu.data)
+	if err != nil {
+		return completed, fmt.Errorf("parsing CPU profile: %v", err)
+	}
+	allocs, endpoints := allocationProfile(prof, allocBytes, allocObjects)
+	var buf bytes.Buffer
+	if err := allocs.Write(&buf); err != nil {
+		return completed, err
+	}
+	completed = append(completed, &profile{
+		name: EndpointAllocationProfile.Filename(),
+		pt:   EndpointAllocationProfile,
+		data: buf.Bytes(),
+	})
+	if met != nil && duration >= time.Second {
+		data, err := appendEndpointAllocMetrics(met.data, endpoints, duration)
+		if err != nil {
+			return completed, err
+		}
+		met.data = data
+	}
+	return completed, nil
+}
+
+// endpointAllocs holds the allocations attributed to an endpoint.
+type endpointAllocs struct {
+	bytes, objects float64
+}
+
+// allocationProfile turns the CPU profile prof into an allocation profile, see
+// attributeAllocations, and returns the allocations attributed to each endpoint.
+func allocationProfile(prof *pprofile.Profile, allocBytes, allocObjects uint64) (*pprofile.Profile, map[string]*endpointAllocs) {
+	var (
+		samples []*pprofile.Sample
+		cpu     []int64
+		total   float64
+	)
+	valueIndex := len(prof.SampleType) - 1 // the CPU time is the last value
+	for _, s := range prof.Sample {
+		if !isAllocSample(s) {
+			continue
+		}
+		samples = append(samples, s)
+		cpu = append(cpu, s.Value[valueIndex])
+		total += float64(s.Value[valueIndex])
+	}
+	endpoints := make(map[string]*endpointAllocs)
+	if total == 0 {
+		samples = nil
+	}
+	for i, s := range samples {
+		share := float64(cpu[i]) / total
+		b, o := share*float64(allocBytes), share*float64(allocObjects)
+		s.Value = []int64{int64(math.Round(o)), int64(math.Round(b))}
+		if e := s.Label[traceprof.TraceEndpoint]; len(e) > 0 {
+			a, ok := endpoints[e[0]]
+			if !ok {
+				a = &endpointAllocs{}
+				endpoints[e[0]] = a
+			}
+			a.bytes += b
+			a.objects += o
+		}
+	}
+	prof.Sample = samples
+	prof.SampleType = []*pprofile.ValueType{
+		{Type: "alloc_objects_estimate", Unit: "count"},
+		{Type: "alloc_space_estimate", Unit: "bytes"},
+	}
+	prof.DefaultSampleType = "alloc_space_estimate"
+	prof.PeriodType = &pprofile.ValueType{Type: "space", Unit: "bytes"}
+	prof.Period = 0
+	return prof.Compact(), endpoints
+}
+
+// isAllocSample reports whether the CPU sample s was spent allocating memory.
+func isAllocSample(s *pprofile.Sample) bool {
+	for _, loc := range s.Location {
+		for _, line := range loc.Line {
+			if line.Function != nil && strings.HasPrefix(line.Function.Name, allocFuncPrefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// appendEndpointAllocMetrics appends the estimated allocation rates of the
+// endpoints over duration to the metrics profile data, e.g.
+// `["go_alloc_bytes_per_sec_estimate{endpoint=\"GET /users\"}", 1024]`.
+func appendEndpointAllocMetrics(data []byte, endpoints map[string]*endpointAllocs, duration time.Duration) ([]byte, error) {
+	var points []json.RawMessage
+	if err := json.Unmarshal(data, &points); err != nil {
+		return data, fmt.Errorf("decoding metrics profile: %v", err)
+	}
+	names := make([]string, 0, len(endpoints))
+	for e := range endpoints {
+		names = append(names, e)
+	}
+	sort.Strings(names)
+	seconds := duration.Seconds()
+	for _, e := range names {
+		for _, pt := range []point{
+			{metric: fmt.Sprintf("go_alloc_bytes_per_sec_estimate{endpoint=%q}", e), value: endpoints[e].bytes / seconds},
+			{metric: fmt.Sprintf("go_allocs_per_sec_estimate{endpoint=%q}", e), value: endpoints[e].objects / seconds},
+		} {
+			b, err := pt.MarshalJSON()
+			if err != nil {
+				return data, err
+			}
+			points = append(points, b)
+		}
+	}
+	return json.Marshal(points)
+}
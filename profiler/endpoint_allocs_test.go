@@ -0,0 +1,157 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cpuSample is a sample of the CPU profiles returned by testCPUProfile.
+type cpuSample struct {
+	cpu      int64
+	endpoint string
+	stack    []string // from the leaf to the root
+}
+
+// testCPUProfile returns a CPU profile with the given samples.
+func testCPUProfile(t *testing.T, samples ...cpuSample) []byte {
+	prof := &pprofile.Profile{
+		SampleType: []*pprofile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		PeriodType: &pprofile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:     10000000,
+	}
+	functions := make(map[string]*pprofile.Function)
+	for _, s := range samples {
+		sample := &pprofile.Sample{Value: []int64{1, s.cpu}}
+		if s.endpoint != "" {
+			sample.Label = map[string][]string{traceprof.TraceEndpoint: {s.endpoint}}
+		}
+		for _, name := range s.stack {
+			fn, ok := functions[name]
+			if !ok {
+				fn = &pprofile.Function{ID: uint64(len(functions) + 1), Name: name}
+				functions[name] = fn
+				prof.Function = append(prof.Function, fn)
+			}
+			loc := &pprofile.Location{ID: uint64(len(prof.Location) + 1), Line: []pprofile.Line{{Function: fn}}}
+			prof.Location = append(prof.Location, loc)
+			sample.Location = append(sample.Location, loc)
+		}
+		prof.Sample = append(prof.Sample, sample)
+	}
+	var buf bytes.Buffer
+	require.NoError(t, prof.Write(&buf))
+	return buf.Bytes()
+}
+
+func TestAttributeAllocations(t *testing.T) {
+	cpu := testCPUProfile(t,
+		cpuSample{30, "GET /users", []string{"runtime.mallocgc", "main.users"}},
+		cpuSample{10, "GET /orders", []string{"runtime.mallocgcSmallNoscan", "runtime.mallocgc", "main.orders"}},
+		cpuSample{10, "", []string{"runtime.mallocgc", "main.background"}},
+		cpuSample{50, "GET /users", []string{"main.compute", "main.users"}},
+	)
+	completed := []*profile{
+		{name: "cpu.pprof", pt: CPUProfile, data: cpu},
+		{name: "metrics.json", pt: MetricsProfile, data: []byte(`[["go_num_goroutine",10]]`)},
+	}
+	completed, err := attributeAllocations(completed, 5000, 50, 10*time.Second)
+	require.NoError(t, err)
+	require.Len(t, completed, 3)
+
+	allocs := completed[2]
+	assert.Equal(t, "endpoint-alloc.pprof", allocs.name)
+	prof, err := pprofile.ParseData(allocs.data)
+	require.NoError(t, err)
+	assert.Equal(t, "alloc_space_estimate", prof.DefaultSampleType)
+	got := map[string][]int64{}
+	for _, s := range prof.Sample {
+		got[s.Location[len(s.Location)-1].Line[0].Function.Name] = s.Value
+	}
+	assert.Equal(t, map[string][]int64{
+		"main.users":      {30, 3000},
+		"main.orders":     {10, 1000},
+		"main.background": {10, 1000},
+	}, got)
+
+	var metrics [][]any
+	require.NoError(t, json.Unmarshal(completed[1].data, &metrics))
+	assert.Equal(t, [][]any{
+		{"go_num_goroutine", 10.0},
+		{`go_alloc_bytes_per_sec_estimate{endpoint="GET /orders"}`, 100.0},
+		{`go_allocs_per_sec_estimate{endpoint="GET /orders"}`, 1.0},
+		{`go_alloc_bytes_per_sec_estimate{endpoint="GET /users"}`, 300.0},
+		{`go_allocs_per_sec_estimate{endpoint="GET /users"}`, 3.0},
+	}, metrics)
+
+	_, err = attributeAllocations(completed[1:2], 5000, 50, 10*time.Second)
+	assert.Error(t, err)
+}
+
+// allocSink makes the allocations of the tests escape to the heap.
+var allocSink []byte
+
+func TestEndpointAllocationProfile(t *testing.T) {
+	_, err := unstartedProfiler(WithProfileTypes(EndpointAllocationProfile))
+	assert.Error(t, err)
+
+	tracer.Start()
+	defer tracer.Stop()
+
+	profiles := startTestProfiler(t, 1,
+		WithProfileTypes(CPUProfile, EndpointAllocationProfile),
+		WithPeriod(500*time.Millisecond),
+	)
+	var m profileMeta
+	for m.attachments == nil {
+		select {
+		case m = <-profiles:
+		default:
+			span := tracer.StartSpan("http.request", tracer.ResourceName("/foo/bar"))
+			for i := 0; i < 1000; i++ {
+				allocSink = make([]byte, 1024)
+			}
+			span.Finish()
+		}
+	}
+
+	prof, err := pprofile.ParseData(m.attachments["endpoint-alloc.pprof"])
+	require.NoError(t, err)
+	var endpoint int64
+	for _, s := range prof.Sample {
+		if len(s.Label[traceprof.TraceEndpoint]) > 0 {
+			assert.Equal(t, []string{"/foo/bar"}, s.Label[traceprof.TraceEndpoint])
+			endpoint += s.Value[1]
+		}
+	}
+	assert.Greater(t, endpoint, int64(0))
+}
+
+func TestAllocWindow(t *testing.T) {
+	w := startAllocWindow()
+	for i := 0; i < 1000; i++ {
+		allocSink = make([]byte, 1024)
+	}
+	time.Sleep(time.Millisecond)
+	w = w.end()
+	// the runtime metrics lag behind the allocations cached by each P.
+	assert.Greater(t, w.bytes, uint64(500*1024))
+	assert.Greater(t, w.objects, uint64(500))
+	assert.GreaterOrEqual(t, w.duration, time.Millisecond)
+}
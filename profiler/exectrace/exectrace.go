@@ -0,0 +1,283 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+// Package exectrace computes the latency breakdown of the spans recorded in the
+// execution traces collected by the profiler. It's used with
+// profiler.WithExecutionTraceAnalyzer:
+//
+//	profiler.Start(
+//		profiler.WithExecutionTraceAnalyzer(exectrace.Analyzer{}),
+//	)
+//
+// The official execution trace parser lives in golang.org/x/exp, which is prone
+// to breaking changes as the trace format changes with new Go releases. So this
+// package lives in a separate module in order to freely upgrade
+// golang.org/x/exp/trace without affecting the users of the profiler.
+package exectrace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	exptrace "golang.org/x/exp/trace"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler"
+)
+
+const (
+	// spanIDCategory is the category of the execution trace logs written by
+	// the tracer at the start of each span, holding the span ID as a
+	// little-endian uint64.
+	spanIDCategory = "datadog.uint64_span_id"
+
+	// gcAssistRange is the name of the ranges of time spent by goroutines
+	// assisting the garbage collector.
+	gcAssistRange = "GC mark assist"
+)
+
+// blockedReasons are the reasons for which goroutines wait on channels, select
+// statements and the primitives of the sync package.
+var blockedReasons = map[string]bool{
+	"chan send":         true,
+	"chan receive":      true,
+	"select":            true,
+	"sync":              true,
+	"sync.(*Cond).Wait": true,
+}
+
+// Analyzer is a profiler.ExecutionTraceAnalyzer.
+//
+// The time of a span is measured on the goroutine which started it, from its
+// start until the end of its execution trace task, or the end of the trace.
+type Analyzer struct{}
+
+var _ profiler.ExecutionTraceAnalyzer = Analyzer{}
+
+// AnalyzeExecutionTrace implements profiler.ExecutionTraceAnalyzer.
+func (Analyzer) AnalyzeExecutionTrace(trace []byte) ([]profiler.SpanLatency, error) {
+	r, err := exptrace.NewReader(bytes.NewReader(trace))
+	if err != nil {
+		return nil, err
+	}
+	b := newBreakdown()
+	var last exptrace.Time
+	for {
+		ev, err := r.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		last = ev.Time()
+		switch ev.Kind() {
+		case exptrace.EventStateTransition:
+			st := ev.StateTransition()
+			if st.Resource.Kind != exptrace.ResourceGoroutine {
+				continue
+			}
+			_, to := st.Goroutine()
+			b.setState(int64(st.Resource.Goroutine()), goState(to, st.Reason), last)
+		case exptrace.EventRangeBegin, exptrace.EventRangeActive, exptrace.EventRangeEnd:
+			rg := ev.Range()
+			if rg.Name != gcAssistRange || rg.Scope.Kind != exptrace.ResourceGoroutine {
+				continue
+			}
+			b.setAssist(int64(rg.Scope.Goroutine()), ev.Kind() != exptrace.EventRangeEnd, last)
+		case exptrace.EventTaskBegin:
+			task := ev.Task()
+			b.taskNames[uint64(task.ID)] = task.Type
+		case exptrace.EventTaskEnd:
+			b.endTask(uint64(ev.Task().ID), last)
+		case exptrace.EventLog:
+			l := ev.Log()
+			if l.Category != spanIDCategory || len(l.Message) != 8 {
+				continue
+			}
+			spanID := binary.LittleEndian.Uint64([]byte(l.Message))
+			b.startSpan(spanID, uint64(l.Task), int64(ev.Goroutine()), last)
+		}
+	}
+	return b.finish(last), nil
+}
+
+// state is the state of a goroutine, as far as the breakdown is concerned.
+type state int
+
+const (
+	stateNone state = iota
+	stateRunning
+	stateRunnable
+	stateSyscall
+	stateBlocked
+	stateGCAssistWait
+	stateWaiting
+)
+
+// goState returns the state of a goroutine transitioning to the given state for
+// the given reason.
+func goState(s exptrace.GoState, reason string) state {
+	switch s {
+	case exptrace.GoRunning:
+		return stateRunning
+	case exptrace.GoRunnable:
+		return stateRunnable
+	case exptrace.GoSyscall:
+		return stateSyscall
+	case exptrace.GoWaiting:
+		switch {
+		case blockedReasons[reason]:
+			return stateBlocked
+		case reason == "GC mark assist wait for work":
+			return stateGCAssistWait
+		default:
+			return stateWaiting
+		}
+	default:
+		return stateNone
+	}
+}
+
+// goroutine tracks the state of a goroutine since the last event changing it.
+type goroutine struct {
+	state  state
+	assist bool // whether the goroutine is in a GC assist
+	since  exptrace.Time
+	spans  []*span // the spans started on the goroutine which haven't ended
+}
+
+type span struct {
+	task    uint64
+	start   exptrace.Time
+	latency profiler.SpanLatency
+}
+
+// breakdown accumulates the time spent by the goroutines of the spans of a trace
+// in each state.
+type breakdown struct {
+	goroutines map[int64]*goroutine
+	taskNames  map[uint64]string
+	spans      []*span
+}
+
+func newBreakdown() *breakdown {
+	return &breakdown{
+		goroutines: make(map[int64]*goroutine),
+		taskNames:  make(map[uint64]string),
+	}
+}
+
+func (b *breakdown) goroutine(id int64, now exptrace.Time) *goroutine {
+	g, ok := b.goroutines[id]
+	if !ok {
+		g = &goroutine{since: now}
+		b.goroutines[id] = g
+	}
+	return g
+}
+
+// flush adds the time spent by g in its current state until now to its spans.
+func (g *goroutine) flush(now exptrace.Time) {
+	d := now.Sub(g.since)
+	g.since = now
+	if d <= 0 {
+		return
+	}
+	for _, s := range g.spans {
+		l := &s.latency
+		switch g.state {
+		case stateRunning:
+			if g.assist {
+				l.GCAssist += d
+			} else {
+				l.Running += d
+			}
+		case stateRunnable:
+			l.Runnable += d
+		case stateSyscall:
+			l.Syscall += d
+		case stateBlocked:
+			l.Blocked += d
+		case stateGCAssistWait:
+			l.GCAssist += d
+		case stateWaiting:
+			l.Waiting += d
+		}
+	}
+}
+
+func (b *breakdown) setState(id int64, s state, now exptrace.Time) {
+	g := b.goroutine(id, now)
+	g.flush(now)
+	g.state = s
+}
+
+func (b *breakdown) setAssist(id int64, assist bool, now exptrace.Time) {
+	g := b.goroutine(id, now)
+	g.flush(now)
+	g.assist = assist
+}
+
+// startSpan starts measuring the span with the given ID, started by the goroutine
+// id within the given task.
+func (b *breakdown) startSpan(spanID, task uint64, id int64, now exptrace.Time) {
+	g := b.goroutine(id, now)
+	g.flush(now)
+	if g.state == stateNone {
+		// the span is started by the goroutine, which must be running.
+		g.state = stateRunning
+	}
+	s := &span{
+		task:    task,
+		start:   now,
+		latency: profiler.SpanLatency{SpanID: spanID, Name: b.taskNames[task]},
+	}
+	g.spans = append(g.spans, s)
+	b.spans = append(b.spans, s)
+}
+
+// endTask ends the spans of the given task.
+func (b *breakdown) endTask(task uint64, now exptrace.Time) {
+	for _, g := range b.goroutines {
+		var ended bool
+		for _, s := range g.spans {
+			if s.task == task {
+				ended = true
+				break
+			}
+		}
+		if !ended {
+			continue
+		}
+		g.flush(now)
+		spans := g.spans[:0]
+		for _, s := range g.spans {
+			if s.task == task {
+				s.latency.Duration = now.Sub(s.start)
+				continue
+			}
+			spans = append(spans, s)
+		}
+		g.spans = spans
+	}
+}
+
+// finish ends the spans which haven't ended at the end of the trace, and returns
+// the breakdown of all the spans in the order they started.
+func (b *breakdown) finish(now exptrace.Time) []profiler.SpanLatency {
+	for _, g := range b.goroutines {
+		g.flush(now)
+		for _, s := range g.spans {
+			s.latency.Duration = now.Sub(s.start)
+		}
+		g.spans = nil
+	}
+	latencies := make([]profiler.SpanLatency, len(b.spans))
+	for i, s := range b.spans {
+		latencies[i] = s.latency
+	}
+	return latencies
+}
@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package exectrace
+
+import (
+	"bytes"
+	"context"
+	"runtime/trace"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	exptrace "golang.org/x/exp/trace"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler"
+)
+
+func TestBreakdown(t *testing.T) {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	at := func(n int) exptrace.Time { return exptrace.Time(ms(n)) }
+	b := newBreakdown()
+	b.taskNames[1] = "GET /users"
+	b.setState(1, stateRunning, at(0))
+	b.startSpan(42, 1, 1, at(1))
+	b.setState(1, stateRunnable, at(2))
+	b.setState(1, stateRunning, at(4))
+	b.setAssist(1, true, at(5))
+	b.setAssist(1, false, at(6))
+	b.setState(1, goState(exptrace.GoWaiting, "chan receive"), at(7))
+	b.setState(1, stateRunning, at(10))
+	b.setState(1, stateSyscall, at(11))
+	b.setState(1, stateRunning, at(14))
+	b.setState(1, goState(exptrace.GoWaiting, "sleep"), at(15))
+	b.setState(1, stateRunning, at(20))
+	// a span on another goroutine, which doesn't end before the end of the trace
+	b.startSpan(43, 2, 2, at(20))
+	b.endTask(1, at(21))
+	b.setState(1, stateBlocked, at(22))
+
+	got := b.finish(at(30))
+	assert.Equal(t, []profiler.SpanLatency{
+		{
+			SpanID:   42,
+			Name:     "GET /users",
+			Duration: ms(20),
+			Running:  ms(6),
+			Runnable: ms(2),
+			Syscall:  ms(3),
+			Blocked:  ms(3),
+			GCAssist: ms(1),
+			Waiting:  ms(5),
+		},
+		{
+			SpanID:   43,
+			Duration: ms(10),
+			Running:  ms(10),
+		},
+	}, got)
+}
+
+func TestAnalyzer(t *testing.T) {
+	tracer.Start(tracer.WithLogger(discardLogger{}))
+	defer tracer.Stop()
+
+	buf := new(bytes.Buffer)
+	if trace.Start(buf) != nil {
+		t.Skip("execution tracer already running")
+	}
+	defer trace.Stop() // okay to double-stop
+
+	var mu sync.Mutex
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "request", tracer.ResourceName("GET /users"))
+	mu.Lock()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		mu.Unlock()
+	}()
+	mu.Lock()
+	child, _ := tracer.StartSpanFromContext(ctx, "child")
+	time.Sleep(10 * time.Millisecond)
+	child.Finish()
+	span.Finish()
+	trace.Stop()
+
+	spans, err := Analyzer{}.AnalyzeExecutionTrace(buf.Bytes())
+	if err != nil && strings.Contains(err.Error(), "unsupported") {
+		t.Skipf("golang.org/x/exp/trace can't parse the execution traces of this Go version: %v", err)
+	}
+	require.NoError(t, err)
+	require.Len(t, spans, 2)
+
+	root, c := spans[0], spans[1]
+	assert.Equal(t, span.Context().SpanID(), root.SpanID)
+	assert.Equal(t, "GET /users", root.Name)
+	assert.GreaterOrEqual(t, root.Blocked, 10*time.Millisecond)
+	assert.GreaterOrEqual(t, root.Waiting, 10*time.Millisecond)
+	assert.Equal(t, child.Context().SpanID(), c.SpanID)
+	assert.GreaterOrEqual(t, c.Waiting, 10*time.Millisecond)
+	assert.Less(t, c.Blocked, 10*time.Millisecond)
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Log(msg string) {}
@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+
+	pprofile "github.com/google/pprof/profile"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/goroutineleak"
+)
+
+// maxLoggedLeaks is the maximum number of goroutine leaks logged per profiling
+// period.
+const maxLoggedLeaks = 5
+
+// collectGoroutineLeaks observes the goroutines at the end of the profiling period
+// with the leak detector of the profiler, and returns its findings as a profile.
+func collectGoroutineLeaks(p *profiler) ([]byte, error) {
+	p.interruptibleSleep(p.cfg.period)
+	// the goroutine dump stops the world, like the goroutine wait profile.
+	if n := runtime.NumGoroutine(); n > p.cfg.maxGoroutinesWait {
+		return nil, fmt.Errorf("skipping goroutine leak profile: %d goroutines exceeds DD_PROFILING_WAIT_PROFILE_MAX_GOROUTINES limit of %d", n, p.cfg.maxGoroutinesWait)
+	}
+	var text, pprof bytes.Buffer
+	if err := p.lookupProfile("goroutine", &text, 2); err != nil {
+		return nil, err
+	}
+	findings, err := p.leaks.Observe(&text)
+	if err != nil {
+		return nil, err
+	}
+	p.logGoroutineLeaks(findings)
+	if len(findings) > 0 {
+		p.cfg.statsd.Count("datadog.profiling.go.goroutine_leaks", int64(len(findings)), p.cfg.tags.Slice(), 1)
+	}
+	err = goroutineLeaksToPprof(findings, &pprof)
+	return pprof.Bytes(), err
+}
+
+// logGoroutineLeaks logs the first findings of a leak detection whose stacks
+// weren't logged before.
+func (p *profiler) logGoroutineLeaks(findings []goroutineleak.Finding) {
+	var logged, skipped int
+	for _, f := range findings {
+		key := fmt.Sprint(f.Stack, f.CreatedBy)
+		if p.loggedLeaks[key] {
+			continue
+		}
+		if logged == maxLoggedLeaks {
+			skipped++
+			continue
+		}
+		p.loggedLeaks[key] = true
+		logged++
+		log.Debug("profiler: possible goroutine leak: %s", f)
+	}
+	if skipped > 0 {
+		log.Debug("profiler: %d more possible goroutine leaks, see the %s profile", skipped, GoroutineLeakProfile)
+	}
+}
+
+// goroutineLeaksToPprof writes the given findings as a pprof profile, with a
+// sample for each finding holding its number of goroutines and longest wait, and
+// labeled with its kind and the state of its goroutines.
+func goroutineLeaksToPprof(findings []goroutineleak.Finding, w io.Writer) error {
+	p := &pprofile.Profile{
+		TimeNanos: now().UnixNano(),
+		SampleType: []*pprofile.ValueType{
+			{Type: "goroutines", Unit: "count"},
+			{Type: "waitduration", Unit: "nanoseconds"},
+		},
+	}
+	m := &pprofile.Mapping{ID: 1, HasFunctions: true}
+	p.Mapping = []*pprofile.Mapping{m}
+	functions := make(map[goroutineleak.Frame]*pprofile.Location)
+	location := func(f goroutineleak.Frame) *pprofile.Location {
+		if l, ok := functions[f]; ok {
+			return l
+		}
+		fn := &pprofile.Function{ID: uint64(len(p.Function) + 1), Name: f.Function, Filename: f.File}
+		p.Function = append(p.Function, fn)
+		l := &pprofile.Location{
+			ID:      uint64(len(p.Location) + 1),
+			Mapping: m,
+			Line:    []pprofile.Line{{Function: fn, Line: int64(f.Line)}},
+		}
+		p.Location = append(p.Location, l)
+		functions[f] = l
+		return l
+	}
+	for _, f := range findings {
+		sample := &pprofile.Sample{
+			Value: []int64{int64(f.Count), f.MaxWait.Nanoseconds()},
+			Label: map[string][]string{
+				"leak":  {f.Kind.String()},
+				"state": {f.State},
+			},
+		}
+		for _, fr := range f.Stack {
+			sample.Location = append(sample.Location, location(fr))
+		}
+		// like the goroutine wait profile, the creation site is part of the
+		// stack.
+		if f.CreatedBy != nil {
+			sample.Location = append(sample.Location, location(*f.CreatedBy))
+		}
+		p.Sample = append(p.Sample, sample)
+	}
+	if err := p.CheckValid(); err != nil {
+		return fmt.Errorf("goroutineLeaksToPprof: %s", err)
+	}
+	return p.Write(w)
+}
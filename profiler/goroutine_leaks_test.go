@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+func TestGoroutineLeakProfile(t *testing.T) {
+	const sample = `
+goroutine 1 [running]:
+main.main()
+	/example/main.go:152 +0x3d2
+
+goroutine 3 [chan receive, 12 minutes]:
+main.worker(0xc000010000)
+	/example/worker.go:20 +0x2e
+created by main.start in goroutine 1
+	/example/main.go:15 +0x3f
+
+goroutine 4 [chan receive, 15 minutes]:
+main.worker(0xc000010000)
+	/example/worker.go:20 +0x2e
+created by main.start in goroutine 1
+	/example/main.go:15 +0x3f
+
+goroutine 5 [IO wait, 20 minutes]:
+internal/poll.runtime_pollWait(0x7f0c, 0x72)
+	/usr/local/go/src/runtime/netpoll.go:343 +0x85
+net/http.(*persistConn).Read(0xc000184000, {0xc0001c6000, 0x1000, 0x1000})
+	/usr/local/go/src/net/http/transport.go:1954 +0x4a
+created by net/http.(*Transport).dialConn in goroutine 1
+	/usr/local/go/src/net/http/transport.go:1776 +0x16f1
+
+goroutine 6 [select, 20 minutes]:
+gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer.(*concentrator).runIngester(0xc0000b6000)
+	/go/pkg/mod/gopkg.in/!data!dog/dd-trace-go.v1/ddtrace/tracer/stats.go:124 +0x8e
+created by gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer.(*concentrator).Start in goroutine 1
+	/go/pkg/mod/gopkg.in/!data!dog/dd-trace-go.v1/ddtrace/tracer/stats.go:110 +0x1a5
+
+goroutine 7 [chan receive, 30 minutes]:
+main.handler({0x7f0c, 0xc000184000}, 0xc000186000)
+	/example/handler.go:30 +0x2e
+gopkg.in/DataDog/dd-trace-go.v1/contrib/net/http.(*ServeMux).ServeHTTP(0xc000010010, {0x7f0c, 0xc000184000}, 0xc000186000)
+	/go/pkg/mod/gopkg.in/!data!dog/dd-trace-go.v1/contrib/net/http/http.go:65 +0x1c5
+net/http.(*conn).serve(0xc000188000, {0x7f0d, 0xc00018a000})
+	/usr/local/go/src/net/http/server.go:2039 +0x8a6
+created by net/http.(*Server).Serve in goroutine 1
+	/usr/local/go/src/net/http/server.go:3285 +0x4b4
+`
+	t.Setenv("DD_PROFILING_GOROUTINE_LEAK_MIN_WAIT", "10m")
+	rl := new(log.RecordLogger)
+	defer log.UseLogger(rl)()
+	log.SetLevel(log.LevelDebug)
+	defer log.SetLevel(log.LevelWarn)
+
+	p, err := unstartedProfiler(WithPeriod(time.Millisecond), WithProfileTypes(GoroutineLeakProfile))
+	require.NoError(t, err)
+	p.testHooks.lookupProfile = func(name string, w io.Writer, debug int) error {
+		assert.Equal(t, "goroutine", name)
+		assert.Equal(t, 2, debug)
+		_, err := w.Write([]byte(sample))
+		return err
+	}
+	profs, err := p.runProfile(GoroutineLeakProfile)
+	require.NoError(t, err)
+	require.Equal(t, "goroutineleaks.pprof", profs[0].name)
+	// the leaks are profiled at every period, but logged once
+	_, err = p.runProfile(GoroutineLeakProfile)
+	require.NoError(t, err)
+
+	pp, err := pprofile.Parse(bytes.NewReader(profs[0].data))
+	require.NoError(t, err)
+	// the goroutines of the tracer are ignored, but not the ones of the
+	// application going through an integration.
+	require.Len(t, pp.Sample, 2)
+	sort.Slice(pp.Sample, func(i, j int) bool { return pp.Sample[i].Value[0] > pp.Sample[j].Value[0] })
+	assert.Equal(t, "main.handler", pp.Sample[1].Location[0].Line[0].Function.Name)
+	s := pp.Sample[0]
+	assert.Equal(t, []int64{2, (15 * time.Minute).Nanoseconds()}, s.Value)
+	assert.Equal(t, []string{"long_wait"}, s.Label["leak"])
+	assert.Equal(t, []string{"chan receive"}, s.Label["state"])
+	var functions []string
+	for _, loc := range s.Location {
+		functions = append(functions, loc.Line[0].Function.Name)
+	}
+	assert.Equal(t, []string{"main.worker", "main.start"}, functions)
+
+	log.Flush()
+	var logged int
+	for _, m := range rl.Logs() {
+		if strings.Contains(m, "DEBUG: profiler: possible goroutine leak: 2 goroutines waiting for 15m0s or more") {
+			logged++
+		}
+	}
+	assert.Equal(t, 1, logged, "the leak must be logged once: %v", rl.Logs())
+}
+
+func TestGoroutineLeakProfileNoLeak(t *testing.T) {
+	p, err := unstartedProfiler(WithPeriod(time.Millisecond), WithProfileTypes(GoroutineLeakProfile))
+	require.NoError(t, err)
+	profs, err := p.runProfile(GoroutineLeakProfile)
+	require.NoError(t, err)
+	pp, err := pprofile.Parse(bytes.NewReader(profs[0].data))
+	require.NoError(t, err)
+	assert.Empty(t, pp.Sample)
+}
@@ -0,0 +1,437 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+// Package goroutineleak detects goroutine leaks from goroutine dumps.
+//
+// A Detector observes dumps periodically, e.g. at every profiling period when the
+// profiler.GoroutineLeakProfile is enabled, and flags the stacks whose number of
+// goroutines keeps growing, or whose goroutines have been waiting for a long time.
+//
+// In tests, VerifyNone and VerifyTestMain check that no goroutine outlives the
+// test, in the style of go.uber.org/goleak:
+//
+//	func TestFoo(t *testing.T) {
+//		defer goroutineleak.VerifyNone(t)
+//		...
+//	}
+package goroutineleak
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/gostackparse"
+)
+
+const (
+	// DefaultMinWait is the default minimum duration for which goroutines must
+	// be waiting to be flagged.
+	DefaultMinWait = 10 * time.Minute
+
+	// DefaultGrowthPeriods is the default number of consecutive observations
+	// over which the number of goroutines of a stack must grow to be flagged.
+	DefaultGrowthPeriods = 5
+
+	// defaultMaxRetry is the default duration for which VerifyNone waits for
+	// the goroutines to exit.
+	defaultMaxRetry = time.Second
+)
+
+type config struct {
+	minWait         time.Duration
+	growthPeriods   int
+	maxRetry        time.Duration
+	ignoreTop       map[string]bool
+	ignorePrefix    []string
+	ignoreTopPrefix []string
+	ignoreCreatedBy []string
+	ignoreStates    map[string]bool
+	ignoreIDs       map[int]bool
+}
+
+func defaultConfig() *config {
+	return &config{
+		minWait:       DefaultMinWait,
+		growthPeriods: DefaultGrowthPeriods,
+		maxRetry:      defaultMaxRetry,
+		ignoreTop:     make(map[string]bool),
+		ignoreStates:  make(map[string]bool),
+		ignoreIDs:     make(map[int]bool),
+	}
+}
+
+// An Option configures a Detector, or the verification of VerifyNone, Find and
+// VerifyTestMain.
+type Option func(*config)
+
+// WithMinWait sets the minimum duration for which goroutines must be waiting to be
+// flagged by a Detector. The runtime only reports wait durations of a minute or
+// more. It defaults to DefaultMinWait.
+func WithMinWait(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.minWait = d
+	}
+}
+
+// WithGrowthPeriods sets the number of consecutive observations over which the
+// number of goroutines of a stack must grow for the stack to be flagged by a
+// Detector. It defaults to DefaultGrowthPeriods.
+func WithGrowthPeriods(n int) Option {
+	return func(cfg *config) {
+		cfg.growthPeriods = n
+	}
+}
+
+// WithMaxRetry sets the duration for which VerifyNone, Find and VerifyTestMain
+// wait for the goroutines to exit before reporting them. It defaults to a second.
+func WithMaxRetry(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.maxRetry = d
+	}
+}
+
+// IgnoreTopFunction ignores the goroutines whose innermost function is fn, e.g.
+// "net/http.(*persistConn).readLoop".
+func IgnoreTopFunction(fn string) Option {
+	return func(cfg *config) {
+		cfg.ignoreTop[fn] = true
+	}
+}
+
+// IgnoreTopFunctionPrefix ignores the goroutines whose innermost function starts
+// with prefix, e.g. "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer." for the
+// goroutines waiting in the tracer.
+func IgnoreTopFunctionPrefix(prefix string) Option {
+	return func(cfg *config) {
+		cfg.ignoreTopPrefix = append(cfg.ignoreTopPrefix, prefix)
+	}
+}
+
+// IgnoreCreatedByPrefix ignores the goroutines created by a function starting with
+// prefix, e.g. "gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer." for the goroutines
+// started by the tracer.
+func IgnoreCreatedByPrefix(prefix string) Option {
+	return func(cfg *config) {
+		cfg.ignoreCreatedBy = append(cfg.ignoreCreatedBy, prefix)
+	}
+}
+
+// IgnoreFunctionPrefix ignores the goroutines with a function starting with
+// prefix anywhere in their stack or creation site. As it also matches the callers
+// of the goroutines, such as middlewares, prefer IgnoreTopFunctionPrefix and
+// IgnoreCreatedByPrefix to ignore the goroutines of a library.
+func IgnoreFunctionPrefix(prefix string) Option {
+	return func(cfg *config) {
+		cfg.ignorePrefix = append(cfg.ignorePrefix, prefix)
+	}
+}
+
+// IgnoreState ignores the goroutines in the given state, e.g. "IO wait" for the
+// goroutines waiting on idle network connections.
+func IgnoreState(state string) Option {
+	return func(cfg *config) {
+		cfg.ignoreStates[state] = true
+	}
+}
+
+// IgnoreCurrent ignores the goroutines which are running when the option is
+// created, e.g. the ones started by TestMain before the tests.
+func IgnoreCurrent() Option {
+	goroutines, err := dump()
+	return func(cfg *config) {
+		if err != nil {
+			return
+		}
+		for _, g := range goroutines {
+			cfg.ignoreIDs[g.ID] = true
+		}
+	}
+}
+
+// Kind is the kind of a Finding.
+type Kind int
+
+const (
+	// Growth flags a stack whose number of goroutines grew at every
+	// observation of the last growth periods.
+	Growth Kind = iota
+	// LongWait flags a stack whose goroutines have been waiting for longer
+	// than the minimum wait.
+	LongWait
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Growth:
+		return "growth"
+	case LongWait:
+		return "long_wait"
+	default:
+		return "unknown"
+	}
+}
+
+// Frame is a call frame of the stack of a Finding.
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+func (f Frame) String() string {
+	return fmt.Sprintf("%s (%s:%d)", f.Function, f.File, f.Line)
+}
+
+// Finding is a possible goroutine leak.
+type Finding struct {
+	Kind Kind
+	// State is the state of the goroutines, e.g. "chan receive".
+	State string
+	// Stack is the stack of the goroutines, from the innermost frame.
+	Stack []Frame
+	// CreatedBy is the frame which created the goroutines, if any.
+	CreatedBy *Frame
+	// Count is the number of goroutines with the stack.
+	Count int
+	// Counts is the number of goroutines with the stack at the last
+	// observations, for Growth findings.
+	Counts []int
+	// MaxWait is the longest wait of the goroutines with the stack.
+	MaxWait time.Duration
+}
+
+func (f Finding) String() string {
+	var b strings.Builder
+	switch f.Kind {
+	case Growth:
+		fmt.Fprintf(&b, "%d goroutines (grew %s)", f.Count, joinInts(f.Counts, " -> "))
+	default:
+		fmt.Fprintf(&b, "%d goroutines waiting for %s or more", f.Count, f.MaxWait)
+	}
+	fmt.Fprintf(&b, " in state %q", f.State)
+	if len(f.Stack) > 0 {
+		fmt.Fprintf(&b, " at %s", f.Stack[0])
+	}
+	if f.CreatedBy != nil {
+		fmt.Fprintf(&b, ", created by %s", f.CreatedBy)
+	}
+	return b.String()
+}
+
+func joinInts(ints []int, sep string) string {
+	s := make([]string, len(ints))
+	for i, n := range ints {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, sep)
+}
+
+// stack groups the goroutines of an observation with the same stack.
+type stack struct {
+	key       string
+	state     string // state of the first goroutine with the stack
+	frames    []Frame
+	createdBy *Frame
+	count     int
+	waiting   int           // number of goroutines waiting for longer than the minimum wait
+	maxWait   time.Duration // longest wait of the goroutines waiting for longer than the minimum wait
+}
+
+// A Detector detects goroutine leaks across observations of the goroutines of a
+// process. It's not safe for concurrent use.
+type Detector struct {
+	cfg *config
+	// counts holds the number of goroutines of each stack at the last
+	// observations, up to growthPeriods+1 of them.
+	counts map[string][]int
+}
+
+// NewDetector returns a Detector configured with the given options.
+func NewDetector(opts ...Option) *Detector {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return &Detector{cfg: cfg, counts: make(map[string][]int)}
+}
+
+// Check observes the current goroutines of the process. See Observe.
+func (d *Detector) Check() ([]Finding, error) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 2); err != nil {
+		return nil, err
+	}
+	return d.Observe(&buf)
+}
+
+// Observe observes the goroutines of the given goroutine dump, in the format of
+// the goroutine profile with debug=2 or of runtime.Stack, and returns the possible
+// leaks. The findings are sorted by decreasing number of goroutines.
+func (d *Detector) Observe(r io.Reader) ([]Finding, error) {
+	goroutines, errs := gostackparse.Parse(r)
+	if len(goroutines) == 0 && len(errs) > 0 {
+		return nil, errs[0]
+	}
+	stacks := groupStacks(goroutines, d.cfg)
+
+	var findings []Finding
+	seen := make(map[string]bool, len(stacks))
+	for _, s := range stacks {
+		seen[s.key] = true
+		counts := d.observe(s.key, s.count)
+		if d.cfg.growthPeriods > 0 && growing(counts) && len(counts) == d.cfg.growthPeriods+1 {
+			findings = append(findings, s.finding(Growth, s.count, append([]int(nil), counts...)))
+		}
+		if s.waiting > 0 {
+			findings = append(findings, s.finding(LongWait, s.waiting, nil))
+		}
+	}
+	for key := range d.counts {
+		if !seen[key] {
+			d.observe(key, 0)
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Count > findings[j].Count })
+	return findings, nil
+}
+
+// observe records the number of goroutines of the stack with the given key, and
+// returns the counts of its last observations.
+func (d *Detector) observe(key string, n int) []int {
+	counts := append(d.counts[key], n)
+	if max := d.cfg.growthPeriods + 1; len(counts) > max {
+		counts = counts[len(counts)-max:]
+	}
+	for _, c := range counts {
+		if c > 0 {
+			d.counts[key] = counts
+			return counts
+		}
+	}
+	// the stack has no goroutine anymore
+	delete(d.counts, key)
+	return nil
+}
+
+// growing reports whether counts is strictly increasing.
+func growing(counts []int) bool {
+	for i := 1; i < len(counts); i++ {
+		if counts[i] <= counts[i-1] {
+			return false
+		}
+	}
+	return len(counts) > 1
+}
+
+func (s *stack) finding(k Kind, count int, counts []int) Finding {
+	f := Finding{
+		Kind:      k,
+		State:     s.state,
+		Stack:     s.frames,
+		CreatedBy: s.createdBy,
+		Count:     count,
+		Counts:    counts,
+	}
+	if k == LongWait {
+		f.MaxWait = s.maxWait
+	}
+	return f
+}
+
+// groupStacks groups the goroutines which aren't ignored by stack, in the order in
+// which they first appear.
+func groupStacks(goroutines []*gostackparse.Goroutine, cfg *config) []*stack {
+	var (
+		stacks []*stack
+		byKey  = make(map[string]*stack)
+	)
+	for _, g := range goroutines {
+		if ignored(g, cfg) {
+			continue
+		}
+		key := stackKey(g)
+		s, ok := byKey[key]
+		if !ok {
+			s = &stack{key: key, state: g.State}
+			for _, f := range g.Stack {
+				s.frames = append(s.frames, Frame{Function: f.Func, File: f.File, Line: f.Line})
+			}
+			if g.CreatedBy != nil {
+				s.createdBy = &Frame{Function: g.CreatedBy.Func, File: g.CreatedBy.File, Line: g.CreatedBy.Line}
+			}
+			byKey[key] = s
+			stacks = append(stacks, s)
+		}
+		s.count++
+		if cfg.minWait > 0 && g.Wait >= cfg.minWait {
+			s.waiting++
+			if g.Wait > s.maxWait {
+				s.maxWait = g.Wait
+			}
+		}
+	}
+	return stacks
+}
+
+// stackKey identifies the stack and the creation site of g.
+func stackKey(g *gostackparse.Goroutine) string {
+	var b strings.Builder
+	for _, f := range g.Stack {
+		fmt.Fprintf(&b, "%s %s:%d\n", f.Func, f.File, f.Line)
+	}
+	if g.CreatedBy != nil {
+		fmt.Fprintf(&b, "created by %s %s:%d", g.CreatedBy.Func, g.CreatedBy.File, g.CreatedBy.Line)
+	}
+	return b.String()
+}
+
+func ignored(g *gostackparse.Goroutine, cfg *config) bool {
+	if cfg.ignoreIDs[g.ID] || cfg.ignoreStates[g.State] {
+		return true
+	}
+	if len(g.Stack) > 0 && cfg.ignoreTop[g.Stack[0].Func] {
+		return true
+	}
+	for _, prefix := range cfg.ignoreTopPrefix {
+		if len(g.Stack) > 0 && strings.HasPrefix(g.Stack[0].Func, prefix) {
+			return true
+		}
+	}
+	for _, prefix := range cfg.ignoreCreatedBy {
+		if g.CreatedBy != nil && strings.HasPrefix(g.CreatedBy.Func, prefix) {
+			return true
+		}
+	}
+	for _, prefix := range cfg.ignorePrefix {
+		if g.CreatedBy != nil && strings.HasPrefix(g.CreatedBy.Func, prefix) {
+			return true
+		}
+		for _, f := range g.Stack {
+			if strings.HasPrefix(f.Func, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dump returns the current goroutines of the process.
+func dump() ([]*gostackparse.Goroutine, error) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 2); err != nil {
+		return nil, err
+	}
+	goroutines, errs := gostackparse.Parse(&buf)
+	if len(goroutines) == 0 && len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return goroutines, nil
+}
@@ -0,0 +1,246 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package goroutineleak
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// goroutineDump returns a goroutine dump with n goroutines blocked in worker, and
+// waiting for the given number of minutes.
+func goroutineDump(n, waitMinutes int) string {
+	var b strings.Builder
+	b.WriteString(`goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x1d
+`)
+	for i := 0; i < n; i++ {
+		state := "chan receive"
+		if waitMinutes > 0 {
+			state = fmt.Sprintf("chan receive, %d minutes", waitMinutes)
+		}
+		fmt.Fprintf(&b, `
+goroutine %d [%s]:
+main.worker(0xc000010000)
+	/app/worker.go:20 +0x2e
+created by main.start in goroutine 1
+	/app/main.go:15 +0x3f
+`, 100+i, state)
+	}
+	return b.String()
+}
+
+func TestDetectorGrowth(t *testing.T) {
+	d := NewDetector(WithGrowthPeriods(3))
+	for _, n := range []int{1, 2, 3} {
+		findings, err := d.Observe(strings.NewReader(goroutineDump(n, 0)))
+		require.NoError(t, err)
+		assert.Empty(t, findings)
+	}
+	findings, err := d.Observe(strings.NewReader(goroutineDump(4, 0)))
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	f := findings[0]
+	assert.Equal(t, Growth, f.Kind)
+	assert.Equal(t, 4, f.Count)
+	assert.Equal(t, []int{1, 2, 3, 4}, f.Counts)
+	assert.Equal(t, "chan receive", f.State)
+	assert.Equal(t, []Frame{{Function: "main.worker", File: "/app/worker.go", Line: 20}}, f.Stack)
+	assert.Equal(t, &Frame{Function: "main.start", File: "/app/main.go", Line: 15}, f.CreatedBy)
+	assert.Equal(t, `4 goroutines (grew 1 -> 2 -> 3 -> 4) in state "chan receive" at main.worker (/app/worker.go:20), created by main.start (/app/main.go:15)`, f.String())
+
+	// the growth must be monotonic
+	findings, err = d.Observe(strings.NewReader(goroutineDump(4, 0)))
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestDetectorGrowthReset(t *testing.T) {
+	d := NewDetector(WithGrowthPeriods(2))
+	for _, n := range []int{1, 2, 0, 3} {
+		findings, err := d.Observe(strings.NewReader(goroutineDump(n, 0)))
+		require.NoError(t, err)
+		assert.Empty(t, findings)
+	}
+	assert.Len(t, d.counts, 2)
+	_, err := d.Observe(strings.NewReader(goroutineDump(0, 0)))
+	require.NoError(t, err)
+	_, err = d.Observe(strings.NewReader(goroutineDump(0, 0)))
+	require.NoError(t, err)
+	_, err = d.Observe(strings.NewReader(goroutineDump(0, 0)))
+	require.NoError(t, err)
+	assert.Len(t, d.counts, 1, "only main.main should still be tracked")
+}
+
+func TestDetectorLongWait(t *testing.T) {
+	d := NewDetector(WithMinWait(5 * time.Minute))
+	findings, err := d.Observe(strings.NewReader(goroutineDump(2, 3)))
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+
+	findings, err = d.Observe(strings.NewReader(goroutineDump(2, 7)))
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	assert.Equal(t, LongWait, findings[0].Kind)
+	assert.Equal(t, 2, findings[0].Count)
+	assert.Equal(t, 7*time.Minute, findings[0].MaxWait)
+	assert.Contains(t, findings[0].String(), "2 goroutines waiting for 7m0s or more")
+}
+
+func TestDetectorIgnoreTopFunction(t *testing.T) {
+	d := NewDetector(WithMinWait(time.Minute), IgnoreTopFunction("main.worker"))
+	findings, err := d.Observe(strings.NewReader(goroutineDump(2, 7)))
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestDetectorIgnoreFunctionPrefix(t *testing.T) {
+	for _, prefix := range []string{"main.work", "main.sta"} {
+		d := NewDetector(WithMinWait(time.Minute), IgnoreFunctionPrefix("other."), IgnoreFunctionPrefix(prefix))
+		findings, err := d.Observe(strings.NewReader(goroutineDump(2, 7)))
+		require.NoError(t, err)
+		assert.Empty(t, findings, prefix)
+	}
+}
+
+func TestDetectorIgnoreTopFunctionPrefix(t *testing.T) {
+	d := NewDetector(WithMinWait(time.Minute), IgnoreTopFunctionPrefix("main.sta"))
+	findings, err := d.Observe(strings.NewReader(goroutineDump(2, 7)))
+	require.NoError(t, err)
+	assert.Len(t, findings, 1, "the creation site isn't the top function")
+
+	d = NewDetector(WithMinWait(time.Minute), IgnoreTopFunctionPrefix("main.work"))
+	findings, err = d.Observe(strings.NewReader(goroutineDump(2, 7)))
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestDetectorIgnoreCreatedByPrefix(t *testing.T) {
+	d := NewDetector(WithMinWait(time.Minute), IgnoreCreatedByPrefix("main.work"))
+	findings, err := d.Observe(strings.NewReader(goroutineDump(2, 7)))
+	require.NoError(t, err)
+	assert.Len(t, findings, 1, "the top function isn't the creation site")
+
+	d = NewDetector(WithMinWait(time.Minute), IgnoreCreatedByPrefix("main.sta"))
+	findings, err = d.Observe(strings.NewReader(goroutineDump(2, 7)))
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestDetectorIgnoreState(t *testing.T) {
+	d := NewDetector(WithMinWait(time.Minute), IgnoreState("IO wait"))
+	dump := strings.ReplaceAll(goroutineDump(2, 7), "chan receive", "IO wait")
+	findings, err := d.Observe(strings.NewReader(dump))
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+
+	findings, err = d.Observe(strings.NewReader(goroutineDump(2, 7)))
+	require.NoError(t, err)
+	assert.Len(t, findings, 1)
+}
+
+func TestDetectorCheck(t *testing.T) {
+	d := NewDetector(WithGrowthPeriods(1))
+	stop := make(chan struct{})
+	defer close(stop)
+	startLeakyWorkers(t, stop, 1)
+	_, err := d.Check()
+	require.NoError(t, err)
+	startLeakyWorkers(t, stop, 2)
+	findings, err := d.Check()
+	require.NoError(t, err)
+	require.NotEmpty(t, findings)
+	assert.Equal(t, Growth, findings[0].Kind)
+	assert.Equal(t, []int{1, 3}, findings[0].Counts)
+	assert.Equal(t, "gopkg.in/DataDog/dd-trace-go.v1/profiler/goroutineleak.leakyWorker", findings[0].Stack[0].Function)
+}
+
+func leakyWorker(stop chan struct{}) {
+	<-stop
+}
+
+// startLeakyWorkers starts n goroutines blocked until stop is closed, and waits
+// until they're blocked.
+func startLeakyWorkers(t *testing.T, stop chan struct{}, n int) {
+	t.Helper()
+	want := blockedLeakyWorkers(t) + n
+	for i := 0; i < n; i++ {
+		go leakyWorker(stop)
+	}
+	require.Eventually(t, func() bool { return blockedLeakyWorkers(t) == want }, 5*time.Second, time.Millisecond)
+}
+
+func blockedLeakyWorkers(t *testing.T) int {
+	goroutines, err := dump()
+	require.NoError(t, err)
+	var n int
+	for _, g := range goroutines {
+		if g.State == "chan receive" && len(g.Stack) > 0 && strings.HasSuffix(g.Stack[0].Func, ".leakyWorker") {
+			n++
+		}
+	}
+	return n
+}
+
+type fakeT struct {
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Error(args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprint(args...))
+}
+
+func TestVerifyNone(t *testing.T) {
+	t.Run("none", func(t *testing.T) {
+		VerifyNone(t)
+	})
+
+	t.Run("exited", func(t *testing.T) {
+		done := make(chan struct{})
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			close(done)
+		}()
+		VerifyNone(t)
+	})
+
+	t.Run("leak", func(t *testing.T) {
+		stop := make(chan struct{})
+		defer close(stop)
+		startLeakyWorkers(t, stop, 1)
+
+		ft := &fakeT{}
+		VerifyNone(ft, WithMaxRetry(10*time.Millisecond))
+		require.Len(t, ft.errors, 1)
+		assert.Contains(t, ft.errors[0], "found 1 leaked goroutine(s)")
+		assert.Contains(t, ft.errors[0], "goroutineleak.leakyWorker")
+	})
+
+	t.Run("ignore", func(t *testing.T) {
+		stop := make(chan struct{})
+		defer close(stop)
+		startLeakyWorkers(t, stop, 1)
+
+		assert.NoError(t, Find(WithMaxRetry(0), IgnoreTopFunction("gopkg.in/DataDog/dd-trace-go.v1/profiler/goroutineleak.leakyWorker")))
+	})
+
+	t.Run("ignore-current", func(t *testing.T) {
+		stop := make(chan struct{})
+		defer close(stop)
+		startLeakyWorkers(t, stop, 1)
+		require.Error(t, Find(WithMaxRetry(0)))
+
+		assert.NoError(t, Find(WithMaxRetry(0), IgnoreCurrent()))
+	})
+}
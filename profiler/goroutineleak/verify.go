@@ -0,0 +1,159 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package goroutineleak
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/gostackparse"
+)
+
+// standardFunctions are the functions of the goroutines started by the runtime
+// and the testing package, which are never reported as leaks.
+var standardFunctions = map[string]bool{
+	"runtime.main":              true,
+	"testing.RunTests":          true,
+	"testing.(*T).Run":          true,
+	"testing.(*T).Parallel":     true,
+	"testing.runFuzzTests":      true,
+	"testing.runFuzzing":        true,
+	"os/signal.signal_recv":     true,
+	"os/signal.loop":            true,
+	"runtime.ensureSigM":        true,
+	"runtime/trace.Start.func1": true,
+}
+
+// TestingT is the subset of testing.TB used by VerifyNone.
+type TestingT interface {
+	Helper()
+	Error(args ...interface{})
+}
+
+// TestingM is the subset of testing.M used by VerifyTestMain.
+type TestingM interface {
+	Run() int
+}
+
+// Find returns an error listing the goroutines which are running besides the
+// current one and the ones of the runtime and the testing package, after waiting
+// for them to exit for up to a second (see WithMaxRetry). Unlike a Detector, it
+// doesn't take the growth or the wait of the goroutines into account: any other
+// goroutine is a leak.
+func Find(opts ...Option) error {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	current := currentID()
+	var (
+		leaks    []*gostackparse.Goroutine
+		deadline = time.Now().Add(cfg.maxRetry)
+		delay    = time.Microsecond
+	)
+	for {
+		goroutines, err := dump()
+		if err != nil {
+			return err
+		}
+		leaks = leaks[:0]
+		for _, g := range goroutines {
+			if g.ID != current && !ignored(g, cfg) && !standard(g) {
+				leaks = append(leaks, g)
+			}
+		}
+		if len(leaks) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(delay)
+		if delay < 100*time.Millisecond {
+			delay *= 2
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "found %d leaked goroutine(s):", len(leaks))
+	for _, s := range groupStacks(leaks, &config{}) {
+		fmt.Fprintf(&b, "\n\n%s", s)
+	}
+	return errors.New(b.String())
+}
+
+// VerifyNone reports an error to t if goroutines are leaked, see Find. It's meant
+// to be deferred at the start of tests:
+//
+//	defer goroutineleak.VerifyNone(t)
+//
+// Tests running in parallel can't be verified, as their goroutines would be
+// reported as leaks.
+func VerifyNone(t TestingT, opts ...Option) {
+	t.Helper()
+	if err := Find(opts...); err != nil {
+		t.Error(err)
+	}
+}
+
+// VerifyTestMain runs the tests of m, and makes them fail if goroutines are
+// leaked after they all passed, see Find. It exits the process with the status of
+// the tests:
+//
+//	func TestMain(m *testing.M) {
+//		goroutineleak.VerifyTestMain(m)
+//	}
+func VerifyTestMain(m TestingM, opts ...Option) {
+	code := m.Run()
+	if code == 0 {
+		if err := Find(opts...); err != nil {
+			fmt.Fprintf(os.Stderr, "goroutineleak: %v\n", err)
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// String formats s like a goroutine dump.
+func (s *stack) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d goroutine(s) [%s]:", s.count, s.state)
+	for _, f := range s.frames {
+		fmt.Fprintf(&b, "\n%s\n\t%s:%d", f.Function, f.File, f.Line)
+	}
+	if s.createdBy != nil {
+		fmt.Fprintf(&b, "\ncreated by %s\n\t%s:%d", s.createdBy.Function, s.createdBy.File, s.createdBy.Line)
+	}
+	return b.String()
+}
+
+// standard reports whether g is a goroutine of the runtime or the testing package.
+func standard(g *gostackparse.Goroutine) bool {
+	for _, f := range g.Stack {
+		if standardFunctions[f.Func] {
+			return true
+		}
+	}
+	return false
+}
+
+// currentID returns the ID of the current goroutine.
+func currentID() int {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	// the stack starts with "goroutine 42 [running]:"
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.Atoi(string(b))
+	return id
+}
@@ -26,6 +26,7 @@ import (
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/osinfo"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/version"
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/goroutineleak"
 	"gopkg.in/DataDog/dd-trace-go.v1/profiler/internal/immutable"
 
 	"github.com/DataDog/datadog-go/v5/statsd"
@@ -88,29 +89,38 @@ type config struct {
 	agentless bool
 	// targetURL is the upload destination URL. It will be set by the profiler on start to either apiURL or agentURL
 	// based on the other options.
-	targetURL            string
-	apiURL               string // apiURL is the Datadog intake API URL
-	agentURL             string // agentURL is the Datadog agent profiling URL
-	service, env         string
-	version              string
-	hostname             string
-	statsd               StatsdClient
-	httpClient           *http.Client
-	tags                 immutable.StringSlice
-	customProfilerLabels []string
-	types                map[ProfileType]struct{}
-	period               time.Duration
-	cpuDuration          time.Duration
-	cpuProfileRate       int
-	uploadTimeout        time.Duration
-	maxGoroutinesWait    int
-	mutexFraction        int
-	blockRate            int
-	outputDir            string
-	deltaProfiles        bool
-	logStartup           bool
-	traceConfig          executionTraceConfig
-	endpointCountEnabled bool
+	targetURL              string
+	apiURL                 string // apiURL is the Datadog intake API URL
+	agentURL               string // agentURL is the Datadog agent profiling URL
+	service, env           string
+	version                string
+	hostname               string
+	statsd                 StatsdClient
+	httpClient             *http.Client
+	tags                   immutable.StringSlice
+	customProfilerLabels   []string
+	types                  map[ProfileType]struct{}
+	period                 time.Duration
+	cpuDuration            time.Duration
+	cpuProfileRate         int
+	uploadTimeout          time.Duration
+	maxGoroutinesWait      int
+	goroutineLeakMinWait   time.Duration
+	mutexFraction          int
+	blockRate              int
+	adaptiveSamplingBudget float64
+	outputDir              string
+	outputRetention        OutputRetention
+	uploadEnabled          bool
+	uploader               Uploader
+	traceAnalyzer          ExecutionTraceAnalyzer
+	deltaProfiles          bool
+	logStartup             bool
+	traceConfig            executionTraceConfig
+	endpointCountEnabled   bool
+	triggers               []Trigger
+	triggerDuration        time.Duration
+	triggerCooldown        time.Duration
 }
 
 // logStartup records the configuration to the configured logger in JSON format
@@ -119,6 +129,10 @@ func logStartup(c *config) {
 	for t := range c.types {
 		enabledProfiles = append(enabledProfiles, t.String())
 	}
+	var triggers []string
+	for _, t := range c.triggers {
+		triggers = append(triggers, t.reason)
+	}
 	info := map[string]any{
 		"date":                       time.Now().Format(time.RFC3339),
 		"os_name":                    osinfo.OSName(),
@@ -139,13 +153,20 @@ func logStartup(c *config) {
 		"cpu_profile_rate":           c.cpuProfileRate,
 		"block_profile_rate":         c.blockRate,
 		"mutex_profile_fraction":     c.mutexFraction,
+		"adaptive_sampling_budget":   c.adaptiveSamplingBudget,
 		"max_goroutines_wait":        c.maxGoroutinesWait,
+		"goroutine_leak_min_wait":    c.goroutineLeakMinWait.String(),
 		"upload_timeout":             c.uploadTimeout.String(),
 		"execution_trace_enabled":    c.traceConfig.Enabled,
 		"execution_trace_period":     c.traceConfig.Period.String(),
 		"execution_trace_size_limit": c.traceConfig.Limit,
 		"endpoint_count_enabled":     c.endpointCountEnabled,
 		"custom_profiler_label_keys": c.customProfilerLabels,
+		"triggers":                   triggers,
+		"upload_enabled":             c.uploadEnabled,
+		"custom_uploader":            c.uploader != nil,
+		"execution_trace_analyzer":   c.traceAnalyzer != nil,
+		"output_dir":                 c.outputDir,
 	}
 	b, err := json.Marshal(info)
 	if err != nil {
@@ -183,19 +204,24 @@ func (c *config) addProfileType(t ProfileType) {
 
 func defaultConfig() (*config, error) {
 	c := config{
-		apiURL:               defaultAPIURL,
-		service:              filepath.Base(os.Args[0]),
-		statsd:               &statsd.NoOpClient{},
-		httpClient:           defaultClient,
-		period:               DefaultPeriod,
-		cpuDuration:          DefaultDuration,
-		blockRate:            DefaultBlockRate,
-		mutexFraction:        DefaultMutexFraction,
-		uploadTimeout:        DefaultUploadTimeout,
-		maxGoroutinesWait:    1000, // arbitrary value, should limit STW to ~30ms
-		deltaProfiles:        internal.BoolEnv("DD_PROFILING_DELTA", true),
-		logStartup:           internal.BoolEnv("DD_TRACE_STARTUP_LOGS", true),
-		endpointCountEnabled: internal.BoolEnv(traceprof.EndpointCountEnvVar, false),
+		apiURL:                 defaultAPIURL,
+		service:                filepath.Base(os.Args[0]),
+		statsd:                 &statsd.NoOpClient{},
+		httpClient:             defaultClient,
+		period:                 DefaultPeriod,
+		cpuDuration:            DefaultDuration,
+		blockRate:              DefaultBlockRate,
+		mutexFraction:          DefaultMutexFraction,
+		uploadTimeout:          DefaultUploadTimeout,
+		maxGoroutinesWait:      1000, // arbitrary value, should limit STW to ~30ms
+		adaptiveSamplingBudget: internal.FloatEnv("DD_PROFILING_ADAPTIVE_SAMPLING_BUDGET", 0),
+		goroutineLeakMinWait:   internal.DurationEnv("DD_PROFILING_GOROUTINE_LEAK_MIN_WAIT", goroutineleak.DefaultMinWait),
+		deltaProfiles:          internal.BoolEnv("DD_PROFILING_DELTA", true),
+		logStartup:             internal.BoolEnv("DD_TRACE_STARTUP_LOGS", true),
+		uploadEnabled:          internal.BoolEnv("DD_PROFILING_UPLOAD_ENABLED", true),
+		endpointCountEnabled:   internal.BoolEnv(traceprof.EndpointCountEnvVar, false),
+		triggerDuration:        DefaultTriggerDuration,
+		triggerCooldown:        DefaultTriggerCooldown,
 	}
 	c.tags = c.tags.Append(fmt.Sprintf("process_id:%d", os.Getpid()))
 	for _, t := range defaultProfileTypes {
@@ -262,9 +288,11 @@ func defaultConfig() (*config, error) {
 	if v := os.Getenv("DD_PROFILING_URL"); v != "" {
 		WithURL(v)(&c)
 	}
-	// not for public use
 	if v := os.Getenv("DD_PROFILING_OUTPUT_DIR"); v != "" {
-		withOutputDir(v)(&c)
+		WithOutputDirectory(v, OutputRetention{
+			MaxAge:   internal.DurationEnv("DD_PROFILING_OUTPUT_DIR_MAX_AGE", DefaultOutputRetention.MaxAge),
+			MaxBytes: int64(internal.IntEnv("DD_PROFILING_OUTPUT_DIR_MAX_BYTES", int(DefaultOutputRetention.MaxBytes))),
+		})(&c)
 	}
 	if v := os.Getenv("DD_PROFILING_WAIT_PROFILE_MAX_GOROUTINES"); v != "" {
 		n, err := strconv.Atoi(v)
@@ -361,8 +389,9 @@ func CPUProfileRate(hz int) Option {
 // MutexProfileFraction turns on mutex profiles with rate indicating the fraction
 // of mutex contention events reported in the mutex profile.
 // On average, 1/rate events are reported.
-// Setting an aggressive rate can hurt performance.
-// For more information on this value, check runtime.SetMutexProfileFraction.
+// Setting an aggressive rate can hurt performance, see WithAdaptiveSampling to
+// bound it. For more information on this value, check
+// runtime.SetMutexProfileFraction.
 func MutexProfileFraction(rate int) Option {
 	return func(cfg *config) {
 		cfg.addProfileType(MutexProfile)
@@ -373,7 +402,8 @@ func MutexProfileFraction(rate int) Option {
 // BlockProfileRate turns on block profiles with the given rate. We do not
 // recommend enabling this profile type, see DefaultBlockRate for more
 // information. The rate is given in nanoseconds and a block event with a given
-// duration has a min(duration/rate, 1) chance of getting sampled.
+// duration has a min(duration/rate, 1) chance of getting sampled. See
+// WithAdaptiveSampling to bound its overhead.
 func BlockProfileRate(rate int) Option {
 	return func(cfg *config) {
 		cfg.addProfileType(BlockProfile)
@@ -485,15 +515,6 @@ func WithUDS(socketPath string) Option {
 	}
 }
 
-// withOutputDir writes a copy of all uploaded profiles to the given
-// directory. This is intended for local development or debugging uploading
-// issues. The directory will keep growing, no cleanup is performed.
-func withOutputDir(dir string) Option {
-	return func(cfg *config) {
-		cfg.outputDir = dir
-	}
-}
-
 // WithLogStartup toggles logging the configuration of the profiler to standard
 // error when profiling is started. The configuration is logged in a JSON
 // format. This option is enabled by default.
@@ -569,6 +590,9 @@ func (e *executionTraceConfig) Refresh() {
 // after the first 10 will be ignored (but labels with ignored keys will still
 // be available in the raw profile data).
 //
+// The keys of the labels applied by the tracer from span tags, see
+// tracer.WithProfilerSpanLabels, are added after the ones given here.
+//
 // [profiler label]: https://rakyll.org/profiler-labels/
 func WithCustomProfilerLabelKeys(keys ...string) Option {
 	return func(cfg *config) {
@@ -0,0 +1,183 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package profiler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+)
+
+// outputDirTimeFormat is the format of the timestamp starting the name of the
+// folders of the output directory, in basic ISO 8601 format in UTC.
+const outputDirTimeFormat = "20060102T150405.000Z"
+
+// DefaultOutputRetention is the retention of the output directory set with the
+// DD_PROFILING_OUTPUT_DIR env variable, which can be overridden with the
+// DD_PROFILING_OUTPUT_DIR_MAX_AGE and DD_PROFILING_OUTPUT_DIR_MAX_BYTES env
+// variables.
+var DefaultOutputRetention = OutputRetention{
+	MaxAge:   24 * time.Hour,
+	MaxBytes: 1 << 30,
+}
+
+// OutputRetention limits the profiles kept in an output directory. See
+// WithOutputDirectory.
+type OutputRetention struct {
+	// MaxAge is the age above which profiles are removed. Zero means no limit.
+	MaxAge time.Duration
+	// MaxBytes is the total size above which the oldest profiles are removed.
+	// The latest profiles are always kept. Zero means no limit.
+	MaxBytes int64
+}
+
+// WithOutputDirectory writes all the profiles to the given directory, pruned
+// according to retention. Each batch of profiles is written to its own folder,
+// named after the end of its profiling period and its sequence number (e.g.
+// "20240102T150405.000Z-42"), along with the metadata otherwise sent with the
+// upload in an event.json file. The profiles are still uploaded, unless
+// WithUpload(false) is used.
+//
+// This option can also be enabled with the DD_PROFILING_OUTPUT_DIR env
+// variable, in which case DefaultOutputRetention is used.
+func WithOutputDirectory(dir string, retention OutputRetention) Option {
+	return func(cfg *config) {
+		cfg.outputDir = dir
+		cfg.outputRetention = retention
+	}
+}
+
+// WithUpload toggles uploading profiles to Datadog. Disabling it is only useful
+// with WithOutputDirectory, to keep the profiles locally in environments which
+// can't upload them. This option is enabled by default, and can also be set with
+// the DD_PROFILING_UPLOAD_ENABLED env variable. It doesn't apply to the Uploader
+// set with WithUploader, which is always used.
+func WithUpload(enabled bool) Option {
+	return func(cfg *config) {
+		cfg.uploadEnabled = enabled
+	}
+}
+
+// outputDir writes the given batch of profiles to a new folder of the output
+// directory, and prunes the older ones.
+func (p *profiler) outputDir(bat batch) error {
+	if p.cfg.outputDir == "" {
+		return nil
+	}
+	return writeOutputDir(p.cfg.outputDir, p.cfg.outputRetention, newBatch(bat, p.batchTags(bat)))
+}
+
+// writeOutputDir writes the given batch of profiles to a new folder of dir, and
+// prunes the older ones according to retention.
+func writeOutputDir(dir string, retention OutputRetention, b Batch) error {
+	name := fmt.Sprintf("%s-%d", b.End.UTC().Format(outputDirTimeFormat), b.Seq)
+	dirPath := filepath.Join(dir, name)
+	// 0755 is what mkdir does, should be reasonable for the use cases here.
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		return err
+	}
+
+	for _, prof := range b.Profiles {
+		filePath := filepath.Join(dirPath, prof.Name)
+		// 0644 is what touch does, should be reasonable for the use cases here.
+		if err := os.WriteFile(filePath, prof.Data, 0644); err != nil {
+			return err
+		}
+	}
+	event, err := json.Marshal(newUploadEvent(b))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dirPath, "event.json"), event, 0644); err != nil {
+		return err
+	}
+	return pruneOutputDir(dir, retention, now())
+}
+
+// outputFolder is a folder of the output directory.
+type outputFolder struct {
+	name string
+	end  time.Time
+	size int64
+}
+
+// pruneOutputDir removes the folders of the output directory dir which are older
+// than the max age of retention, then the oldest ones until their total size is
+// below its max size. The latest folder is always kept. Only the folders written
+// by the profiler are considered, other files are left untouched.
+func pruneOutputDir(dir string, retention OutputRetention, now time.Time) error {
+	if retention.MaxAge <= 0 && retention.MaxBytes <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var (
+		folders []outputFolder
+		total   int64
+	)
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		ts, _, ok := strings.Cut(e.Name(), "-")
+		if !ok {
+			continue
+		}
+		end, err := time.Parse(outputDirTimeFormat, ts)
+		if err != nil {
+			continue
+		}
+		size, err := dirSize(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		folders = append(folders, outputFolder{name: e.Name(), end: end, size: size})
+		total += size
+	}
+	sort.Slice(folders, func(i, j int) bool { return folders[i].end.Before(folders[j].end) })
+	for i, f := range folders {
+		if i == len(folders)-1 {
+			break
+		}
+		expired := retention.MaxAge > 0 && now.Sub(f.end) > retention.MaxAge
+		tooBig := retention.MaxBytes > 0 && total > retention.MaxBytes
+		if !expired && !tooBig {
+			break
+		}
+		if err := os.RemoveAll(filepath.Join(dir, f.name)); err != nil {
+			return err
+		}
+		log.Debug("profiler: removed %s from the output directory", f.name)
+		total -= f.size
+	}
+	return nil
+}
+
+// dirSize returns the total size of the files of the given directory.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
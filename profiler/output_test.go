@@ -0,0 +1,132 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package profiler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithOutputDirectory(t *testing.T) {
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("DD_PROFILING_OUTPUT_DIR", "/tmp/profiles")
+		t.Setenv("DD_PROFILING_OUTPUT_DIR_MAX_AGE", "1h")
+		p, err := unstartedProfiler()
+		require.NoError(t, err)
+		assert.Equal(t, "/tmp/profiles", p.cfg.outputDir)
+		assert.Equal(t, OutputRetention{MaxAge: time.Hour, MaxBytes: DefaultOutputRetention.MaxBytes}, p.cfg.outputRetention)
+	})
+
+	t.Run("event", func(t *testing.T) {
+		dir := t.TempDir()
+		p, err := unstartedProfiler(
+			WithOutputDirectory(dir, OutputRetention{}),
+			WithService("my-service"),
+		)
+		require.NoError(t, err)
+		bat := batch{
+			seq:      3,
+			end:      time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+			profiles: []*profile{{name: "cpu.pprof", data: []byte("cpu")}},
+		}
+		require.NoError(t, p.outputDir(bat))
+
+		data, err := os.ReadFile(filepath.Join(dir, "20240102T150405.000Z-3", "event.json"))
+		require.NoError(t, err)
+		var event uploadEvent
+		require.NoError(t, json.Unmarshal(data, &event))
+		assert.Equal(t, []string{"cpu.pprof"}, event.Attachments)
+		assert.Contains(t, event.Tags, "service:my-service")
+		assert.Contains(t, event.Tags, "profile_seq:3")
+	})
+
+	t.Run("no-upload", func(t *testing.T) {
+		_, err := unstartedProfiler(WithUpload(false))
+		assert.Error(t, err)
+
+		dir := t.TempDir()
+		p, err := unstartedProfiler(
+			WithOutputDirectory(dir, OutputRetention{}),
+			WithUpload(false),
+			WithPeriod(10*time.Millisecond),
+			WithProfileTypes(HeapProfile),
+		)
+		require.NoError(t, err)
+		p.uploadFunc = func(batch) error {
+			t.Error("unexpected upload")
+			return nil
+		}
+		p.run()
+		defer p.stop()
+		require.Eventually(t, func() bool {
+			files, _ := filepath.Glob(filepath.Join(dir, "*", "*heap.pprof"))
+			return len(files) > 0
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestPruneOutputDir(t *testing.T) {
+	start := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	folder := func(seq int) string {
+		return fmt.Sprintf("%s-%d", start.Add(time.Duration(seq)*time.Minute).Format(outputDirTimeFormat), seq)
+	}
+	setup := func(t *testing.T) string {
+		dir := t.TempDir()
+		for seq := 0; seq < 5; seq++ {
+			require.NoError(t, os.Mkdir(filepath.Join(dir, folder(seq)), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, folder(seq), "cpu.pprof"), make([]byte, 100), 0644))
+		}
+		// files not written by the profiler are left untouched
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "other"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), make([]byte, 1000), 0644))
+		return dir
+	}
+	assertKept := func(t *testing.T, dir string, seqs ...int) {
+		want := []string{"notes.txt", "other"}
+		for _, seq := range seqs {
+			want = append(want, folder(seq))
+		}
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Name())
+		}
+		assert.ElementsMatch(t, want, got)
+	}
+	now := start.Add(4 * time.Minute)
+
+	t.Run("none", func(t *testing.T) {
+		dir := setup(t)
+		require.NoError(t, pruneOutputDir(dir, OutputRetention{}, now))
+		assertKept(t, dir, 0, 1, 2, 3, 4)
+	})
+
+	t.Run("age", func(t *testing.T) {
+		dir := setup(t)
+		require.NoError(t, pruneOutputDir(dir, OutputRetention{MaxAge: 150 * time.Second}, now))
+		assertKept(t, dir, 2, 3, 4)
+	})
+
+	t.Run("size", func(t *testing.T) {
+		dir := setup(t)
+		require.NoError(t, pruneOutputDir(dir, OutputRetention{MaxBytes: 250}, now))
+		assertKept(t, dir, 3, 4)
+	})
+
+	t.Run("latest", func(t *testing.T) {
+		dir := setup(t)
+		require.NoError(t, pruneOutputDir(dir, OutputRetention{MaxAge: time.Second, MaxBytes: 1}, now.Add(time.Hour)))
+		assertKept(t, dir, 4)
+	})
+}
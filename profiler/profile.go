@@ -50,11 +50,36 @@ const (
 	expGoroutineWaitProfile
 	// MetricsProfile reports top-line metrics associated with user-specified profiles
 	MetricsProfile
+	// EndpointAllocationProfile reports an estimate of the memory allocated by
+	// each trace endpoint, see tracer.WithProfilerEndpoints. The allocations made
+	// while the CPU profile runs are attributed to its samples spent allocating
+	// memory, which carry the labels of the spans, so it requires CPUProfile. Its sample types
+	// are alloc_objects_estimate and alloc_space_estimate, to tell them apart from
+	// the sampled allocations of the heap profile. The estimated allocation rates
+	// of the endpoints are also added to the MetricsProfile, if enabled. This
+	// profile is not enabled by default.
+	EndpointAllocationProfile
+	// GoroutineLeakProfile reports possible goroutine leaks: the stacks whose
+	// number of goroutines grew at every profiling period for the last
+	// goroutineleak.DefaultGrowthPeriods periods, and the ones whose goroutines
+	// have been waiting for longer than DD_PROFILING_GOROUTINE_LEAK_MIN_WAIT
+	// (goroutineleak.DefaultMinWait by default). The goroutines of dd-trace-go
+	// and the ones in the "IO wait" state are ignored. The stacks of the leaks
+	// are also logged once, at the debug level.
+	// Like the goroutine wait profile, it's skipped when there are more than
+	// DD_PROFILING_WAIT_PROFILE_MAX_GOROUTINES goroutines.
+	// This profile is not enabled by default.
+	GoroutineLeakProfile
 
 	// executionTrace is the runtime/trace execution tracer.
 	// This is private, as this trace requires special explicit configuration and
 	// shouldn't just be added to WithProfileTypes
 	executionTrace
+
+	// spanLatencyProfile is the latency breakdown of the spans of an execution
+	// trace, see WithExecutionTraceAnalyzer. It's derived from the execution
+	// trace, so it can't be collected on its own.
+	spanLatencyProfile
 )
 
 // profileType holds the implementation details of a ProfileType.
@@ -92,6 +117,10 @@ var profileTypes = map[ProfileType]profileType{
 			// period so that we're sure to capture the CPU usage of
 			// this library, which mostly happens at the end
 			p.interruptibleSleep(p.cfg.period - p.cfg.cpuDuration)
+			if !p.cpuProfiler.acquire(p.exit) {
+				return nil, nil
+			}
+			defer p.cpuProfiler.release()
 			if p.cfg.cpuProfileRate != 0 {
 				// The profile has to be set each time before
 				// profiling is started. Otherwise,
@@ -103,13 +132,35 @@ var profileTypes = map[ProfileType]profileType{
 			if err := p.startCPUProfile(&buf); err != nil {
 				return nil, err
 			}
-			p.interruptibleSleep(p.cfg.cpuDuration)
+			// The endpoint allocation profile spreads the allocations
+			// made while the CPU profile runs, see attributeAllocations.
+			_, countAllocs := p.cfg.types[EndpointAllocationProfile]
+			var allocs allocWindow
+			if countAllocs {
+				allocs = startAllocWindow()
+			}
+			// Captures stop the CPU profile early, see CaptureNow.
+			select {
+			case <-p.exit:
+			case <-time.After(p.cfg.cpuDuration):
+			case <-p.cpuProfiler.preempt:
+				// The capture can't wait for the other profile types,
+				// which may only complete at the end of the period.
+				p.stopCPUProfile()
+				if countAllocs {
+					p.cpuAllocs = allocs.end()
+				}
+				return buf.Bytes(), nil
+			}
 
 			// We want the CPU profiler to finish last so that it can
 			// properly record all of our profile processing work for
 			// the other profile types
 			p.pendingProfiles.Wait()
 			p.stopCPUProfile()
+			if countAllocs {
+				p.cpuAllocs = allocs.end()
+			}
 			return buf.Bytes(), nil
 		},
 	},
@@ -182,10 +233,28 @@ var profileTypes = map[ProfileType]profileType{
 			return buf.Bytes(), err
 		},
 	},
+	EndpointAllocationProfile: {
+		Name:     "endpoint-alloc",
+		Filename: "endpoint-alloc.pprof",
+		// The profile is derived from the CPU profile at the end of the profiling
+		// cycle, see attributeAllocations.
+		Collect: func(_ *profiler) ([]byte, error) {
+			return nil, errors.New("the endpoint allocation profile can't be collected on its own")
+		},
+	},
+	GoroutineLeakProfile: {
+		Name:     "goroutineleak",
+		Filename: "goroutineleaks.pprof",
+		Collect:  collectGoroutineLeaks,
+	},
 	executionTrace: {
 		Name:     "execution-trace",
 		Filename: "go.trace",
 		Collect: func(p *profiler) ([]byte, error) {
+			if !p.executionTracer.acquire(p.exit) {
+				return nil, nil
+			}
+			defer p.executionTracer.release()
 			p.lastTrace = time.Now()
 			buf := new(bytes.Buffer)
 			lt := newLimitedTraceCollector(buf, int64(p.cfg.traceConfig.Limit))
@@ -197,6 +266,7 @@ var profileTypes = map[ProfileType]profileType{
 			case <-p.exit: // Profiling was stopped
 			case <-time.After(p.cfg.period): // The profiling cycle has ended
 			case <-lt.done: // The trace size limit was exceeded
+			case <-p.executionTracer.preempt: // A capture needs the execution tracer
 			}
 			trace.Stop()
 			return buf.Bytes(), nil
@@ -339,6 +409,25 @@ func (b *batch) addProfile(p *profile) {
 	b.profiles = append(b.profiles, p)
 }
 
+// without returns a copy of b without the profiles with the given names.
+func (b batch) without(names []string) batch {
+	if len(names) == 0 {
+		return b
+	}
+	skip := make(map[string]bool, len(names))
+	for _, name := range names {
+		skip[name] = true
+	}
+	profiles := make([]*profile, 0, len(b.profiles))
+	for _, p := range b.profiles {
+		if !skip[p.name] {
+			profiles = append(profiles, p)
+		}
+	}
+	b.profiles = profiles
+	return b
+}
+
 func (p *profiler) runProfile(pt ProfileType) ([]*profile, error) {
 	start := now()
 	t := pt.lookup()
@@ -11,16 +11,17 @@ import (
 	"io"
 	"math/rand"
 	"os"
-	"path/filepath"
 	"runtime"
 	"runtime/pprof"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gopkg.in/DataDog/dd-trace-go.v1/internal"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
 	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler/goroutineleak"
 	"gopkg.in/DataDog/dd-trace-go.v1/profiler/internal/immutable"
 )
 
@@ -31,6 +32,14 @@ const outChannelSize = 5
 // be used as custom attributes in the profiler UI
 const customProfileLabelLimit = 10
 
+// internalFuncPrefixes are the prefixes of the functions of the tracer and the
+// profiler, whose goroutines aren't reported by the GoroutineLeakProfile.
+var internalFuncPrefixes = []string{
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer.",
+	"gopkg.in/DataDog/dd-trace-go.v1/profiler.",
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/",
+}
+
 var (
 	mu             sync.Mutex
 	activeProfiler *profiler
@@ -75,21 +84,36 @@ func Stop() {
 // profiler collects and sends preset profiles to the Datadog API at a given frequency
 // using a given configuration.
 type profiler struct {
-	cfg             *config           // profile configuration
-	out             chan batch        // upload queue
-	uploadFunc      func(batch) error // defaults to (*profiler).upload; replaced in tests
-	exit            chan struct{}     // exit signals the profiler to stop; it is closed after stopping
-	stopOnce        sync.Once         // stopOnce ensures the profiler is stopped exactly once.
-	wg              sync.WaitGroup    // wg waits for all goroutines to exit when stopping.
-	met             *metrics          // metric collector state
+	cfg             *config                 // profile configuration
+	out             chan batch              // upload queue
+	uploadFunc      func(batch) error       // defaults to (*profiler).upload; replaced in tests
+	exit            chan struct{}           // exit signals the profiler to stop; it is closed after stopping
+	stopOnce        sync.Once               // stopOnce ensures the profiler is stopped exactly once.
+	wg              sync.WaitGroup          // wg waits for all goroutines to exit when stopping.
+	met             *metrics                // metric collector state
+	leaks           *goroutineleak.Detector // goroutine leak detector state, if GoroutineLeakProfile is enabled
+	loggedLeaks     map[string]bool         // stacks of the goroutine leaks logged already
+	sampler         *adaptiveSampler        // adaptive sampling state of the mutex and block profiles, if enabled
 	deltas          map[ProfileType]*fastDeltaProfiler
-	seq             uint64         // seq is the value of the profile_seq tag
+	seq             uint64         // seq is the value of the profile_seq tag; accessed atomically
 	pendingProfiles sync.WaitGroup // signal that profile collection is done, for stopping CPU profiling
 
+	outMu     sync.Mutex // guards outClosed, so that captures don't enqueue batches once out is closed
+	outClosed bool       // whether out is closed
+
+	cpuProfiler     *runtimeProfiler // serializes the CPU profiles of the periodic profiles and captures
+	executionTracer *runtimeProfiler // serializes the execution traces of the periodic profiles and captures
+	capturing       int32            // 1 while a capture is in progress; accessed atomically
+	traceLimit      int64            // execution trace size limit as of the last cfg.traceConfig refresh; accessed atomically
+
 	testHooks testHooks
 
 	// lastTrace is the last time an execution trace was collected
 	lastTrace time.Time
+
+	// cpuAllocs holds the allocations made during the last periodic CPU profile,
+	// if EndpointAllocationProfile is enabled, see attributeAllocations.
+	cpuAllocs allocWindow
 }
 
 // testHooks are functions that are replaced during testing which would normally
@@ -166,6 +190,9 @@ func newProfiler(opts ...Option) (*profiler, error) {
 		}
 		cfg.targetURL = cfg.agentURL
 	}
+	if !cfg.uploadEnabled && cfg.outputDir == "" && cfg.uploader == nil {
+		return nil, errors.New("profiler.WithUpload(false) requires an output directory or an uploader. Use profiler.WithOutputDirectory, the DD_PROFILING_OUTPUT_DIR env variable or profiler.WithUploader to set one")
+	}
 	if cfg.hostname == "" {
 		hostname, err := os.Hostname()
 		if err != nil {
@@ -192,6 +219,14 @@ func newProfiler(opts ...Option) (*profiler, error) {
 			return nil, fmt.Errorf("unknown profile type: %d", pt)
 		}
 	}
+	if _, ok := cfg.types[EndpointAllocationProfile]; ok {
+		if _, ok := cfg.types[CPUProfile]; !ok {
+			return nil, errors.New("the endpoint allocation profile requires the CPU profile")
+		}
+	}
+	if cfg.adaptiveSamplingBudget < 0 {
+		return nil, fmt.Errorf("invalid adaptive sampling budget, must be >= 0: %v", cfg.adaptiveSamplingBudget)
+	}
 	if cfg.cpuDuration > cfg.period {
 		cfg.cpuDuration = cfg.period
 	}
@@ -225,12 +260,39 @@ func newProfiler(opts ...Option) (*profiler, error) {
 		exit:   make(chan struct{}),
 		met:    newMetrics(),
 		deltas: make(map[ProfileType]*fastDeltaProfiler),
+
+		cpuProfiler:     newRuntimeProfiler(),
+		executionTracer: newRuntimeProfiler(),
+		traceLimit:      int64(cfg.traceConfig.Limit),
 	}
 	for pt := range cfg.types {
 		if d := profileTypes[pt].DeltaValues; len(d) > 0 {
 			p.deltas[pt] = newFastDeltaProfiler(d...)
 		}
 	}
+	if _, ok := cfg.types[GoroutineLeakProfile]; ok {
+		leakOpts := []goroutineleak.Option{
+			goroutineleak.WithMinWait(cfg.goroutineLeakMinWait),
+			// the goroutines waiting on idle connections are long-lived by
+			// design.
+			goroutineleak.IgnoreState("IO wait"),
+		}
+		for _, prefix := range internalFuncPrefixes {
+			// so are the goroutines of the tracer and the profiler, but not
+			// the ones merely going through the integrations.
+			leakOpts = append(leakOpts,
+				goroutineleak.IgnoreCreatedByPrefix(prefix),
+				goroutineleak.IgnoreTopFunctionPrefix(prefix),
+			)
+		}
+		p.leaks = goroutineleak.NewDetector(leakOpts...)
+		p.loggedLeaks = make(map[string]bool)
+	}
+	_, mutex := cfg.types[MutexProfile]
+	_, block := cfg.types[BlockProfile]
+	if cfg.adaptiveSamplingBudget > 0 && (mutex || block) {
+		p.sampler = newAdaptiveSampler(cfg)
+	}
 	p.uploadFunc = p.upload
 	return &p, nil
 }
@@ -261,12 +323,26 @@ func (p *profiler) run() {
 		defer p.wg.Done()
 		p.send()
 	}()
+	if len(p.cfg.triggers) > 0 {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			tick := time.NewTicker(triggerInterval)
+			defer tick.Stop()
+			p.runTriggers(tick.C)
+		}()
+	}
 }
 
 // collect runs the profile types found in the configuration whenever the ticker receives
 // an item.
 func (p *profiler) collect(ticker <-chan time.Time) {
-	defer close(p.out)
+	defer func() {
+		p.outMu.Lock()
+		defer p.outMu.Unlock()
+		p.outClosed = true
+		close(p.out)
+	}()
 	var (
 		// mu guards completed
 		mu        sync.Mutex
@@ -287,7 +363,7 @@ func (p *profiler) collect(ticker <-chan time.Time) {
 
 	for {
 		bat := batch{
-			seq:   p.seq,
+			seq:   atomic.AddUint64(&p.seq, 1) - 1,
 			host:  p.cfg.hostname,
 			start: now(),
 			extraTags: []string{
@@ -298,9 +374,11 @@ func (p *profiler) collect(ticker <-chan time.Time) {
 				fmt.Sprintf("_dd.profiler.go_execution_trace_enabled:%v", p.cfg.traceConfig.Enabled),
 				pgoTag(),
 			},
-			customAttributes: p.cfg.customProfilerLabels,
+			customAttributes: p.customAttributes(),
+		}
+		if p.sampler != nil {
+			bat.extraTags = append(bat.extraTags, p.sampler.tags(p.cfg.types)...)
 		}
-		p.seq++
 
 		completed = completed[:0]
 		// We need to increment pendingProfiles for every non-CPU
@@ -311,9 +389,21 @@ func (p *profiler) collect(ticker <-chan time.Time) {
 		// profiling starts)
 
 		profileTypes := p.enabledProfileTypes()
+		// The endpoint allocation profile is derived from the CPU profile once
+		// the other profiles are collected, see attributeAllocations.
+		var attributeAllocs bool
+		for i, t := range profileTypes {
+			if t == EndpointAllocationProfile {
+				profileTypes = append(profileTypes[:i], profileTypes[i+1:]...)
+				attributeAllocs = true
+				break
+			}
+		}
 
 		// Decide whether we should record an execution trace
 		p.cfg.traceConfig.Refresh()
+		// The captures read the limit concurrently with the refreshes.
+		atomic.StoreInt64(&p.traceLimit, int64(p.cfg.traceConfig.Limit))
 		// Randomly record a trace with probability (profile period) / (trace period).
 		// Note that if the trace period is equal to or less than the profile period,
 		// we will always record a trace
@@ -353,6 +443,29 @@ func (p *profiler) collect(ticker <-chan time.Time) {
 			}(t)
 		}
 		wg.Wait()
+		if attributeAllocs {
+			// the CPU profile counted the allocations, see allocWindow.
+			allocs := p.cpuAllocs
+			p.cpuAllocs = allocWindow{}
+			var err error
+			completed, err = attributeAllocations(completed, allocs.bytes, allocs.objects, allocs.duration)
+			if err != nil {
+				log.Error("Error getting %s profile: %v; skipping.", EndpointAllocationProfile, err)
+				tags := append(p.cfg.tags.Slice(), EndpointAllocationProfile.Tag())
+				p.cfg.statsd.Count("datadog.profiling.go.collect_error", 1, tags, 1)
+			}
+		}
+		if p.sampler != nil {
+			p.sampler.adjust(completed, now().Sub(bat.start))
+		}
+		if p.cfg.traceAnalyzer != nil {
+			var err error
+			completed, err = analyzeExecutionTrace(p.cfg.traceAnalyzer, completed)
+			if err != nil {
+				log.Error("Error analyzing execution trace: %v; skipping.", err)
+				p.cfg.statsd.Count("datadog.profiling.go.collect_error", 1, append(p.cfg.tags.Slice(), executionTrace.Tag()), 1)
+			}
+		}
 		for _, prof := range completed {
 			if prof.pt == executionTrace {
 				// If the profile batch includes a runtime execution trace, add a tag so
@@ -406,6 +519,8 @@ func (p *profiler) enabledProfileTypes() []ProfileType {
 		GoroutineProfile,
 		expGoroutineWaitProfile,
 		MetricsProfile,
+		EndpointAllocationProfile,
+		GoroutineLeakProfile,
 		executionTrace,
 	}
 	enabled := []ProfileType{}
@@ -417,6 +532,31 @@ func (p *profiler) enabledProfileTypes() []ProfileType {
 	return enabled
 }
 
+// customAttributes returns the pprof label keys which should be available as
+// attributes in the UI: the ones set with WithCustomProfilerLabelKeys, followed by
+// the ones applied by the tracer from span tags, up to customProfileLabelLimit.
+func (p *profiler) customAttributes() []string {
+	spanKeys := traceprof.SpanLabelKeys()
+	if len(spanKeys) == 0 {
+		return p.cfg.customProfilerLabels
+	}
+	keys := append([]string(nil), p.cfg.customProfilerLabels...)
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		seen[k] = true
+	}
+	for _, k := range spanKeys {
+		if len(keys) >= customProfileLabelLimit {
+			break
+		}
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
 // enqueueUpload pushes a batch of profiles onto the queue to be uploaded. If there is no room, it will
 // evict the oldest profile to make some. Typically a batch would be one of each enabled profile.
 func (p *profiler) enqueueUpload(bat batch) {
@@ -449,6 +589,9 @@ func (p *profiler) send() {
 			if err := p.outputDir(bat); err != nil {
 				log.Error("Failed to output profile to dir: %v", err)
 			}
+			if !p.cfg.uploadEnabled && p.cfg.uploader == nil {
+				continue
+			}
 			if err := p.uploadFunc(bat); err != nil {
 				log.Error("Failed to upload profile: %v", err)
 			}
@@ -456,28 +599,6 @@ func (p *profiler) send() {
 	}
 }
 
-func (p *profiler) outputDir(bat batch) error {
-	if p.cfg.outputDir == "" {
-		return nil
-	}
-	// Basic ISO 8601 Format in UTC as the name for the directories.
-	dir := bat.end.UTC().Format("20060102T150405Z")
-	dirPath := filepath.Join(p.cfg.outputDir, dir)
-	// 0755 is what mkdir does, should be reasonable for the use cases here.
-	if err := os.MkdirAll(dirPath, 0755); err != nil {
-		return err
-	}
-
-	for _, prof := range bat.profiles {
-		filePath := filepath.Join(dirPath, prof.name)
-		// 0644 is what touch does, should be reasonable for the use cases here.
-		if err := os.WriteFile(filePath, prof.data, 0644); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 // interruptibleSleep sleeps for the given duration or until interrupted by the
 // p.exit channel being closed.
 func (p *profiler) interruptibleSleep(d time.Duration) {
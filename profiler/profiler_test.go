@@ -396,6 +396,23 @@ func TestAllUploaded(t *testing.T) {
 	validateProfile(<-profiles, 1)
 }
 
+func TestCustomAttributesSpanLabels(t *testing.T) {
+	traceprof.SetSpanLabelKeys([]string{"tenant", "user", "tier"})
+	defer traceprof.SetSpanLabelKeys(nil)
+
+	p, err := unstartedProfiler(WithCustomProfilerLabelKeys("user"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user", "tenant", "tier"}, p.customAttributes())
+
+	var keys []string
+	for i := 0; i < customProfileLabelLimit; i++ {
+		keys = append(keys, fmt.Sprintf("key%d", i))
+	}
+	p, err = unstartedProfiler(WithCustomProfilerLabelKeys(keys...))
+	require.NoError(t, err)
+	assert.Equal(t, keys, p.customAttributes())
+}
+
 func TestCorrectTags(t *testing.T) {
 	profiles := startTestProfiler(t, 1,
 		WithProfileTypes(HeapProfile),
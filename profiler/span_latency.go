@@ -0,0 +1,89 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// spanLatencyFilename is the name of the companion profile of the execution
+// traces holding the latency breakdown of their spans.
+const spanLatencyFilename = "span-latency.json"
+
+// SpanLatency is the breakdown of the latency of a span into the states of the
+// goroutine running it, as recorded by an execution trace. The states are
+// measured from the start of the span until its end, or the end of the trace.
+type SpanLatency struct {
+	// SpanID is the ID of the span.
+	SpanID uint64 `json:"span_id"`
+	// Name is the name of the execution trace task of the span, i.e. its
+	// resource, or its type if the resource could contain PII.
+	Name string `json:"name"`
+	// Duration is the duration of the span within the trace.
+	Duration time.Duration `json:"duration_ns"`
+	// Running is the time spent running, excluding GC assists.
+	Running time.Duration `json:"running_ns"`
+	// Runnable is the time spent waiting for the scheduler to run the goroutine.
+	Runnable time.Duration `json:"runnable_ns"`
+	// Syscall is the time spent blocked in system calls.
+	Syscall time.Duration `json:"syscall_ns"`
+	// Blocked is the time spent blocked on channels, select statements and the
+	// primitives of the sync package.
+	Blocked time.Duration `json:"blocked_ns"`
+	// GCAssist is the time spent assisting the garbage collector.
+	GCAssist time.Duration `json:"gc_assist_ns"`
+	// Waiting is the time spent waiting for other reasons, such as network
+	// I/O or sleeping.
+	Waiting time.Duration `json:"waiting_ns"`
+}
+
+// An ExecutionTraceAnalyzer computes the latency breakdown of the spans recorded
+// in an execution trace. See WithExecutionTraceAnalyzer.
+type ExecutionTraceAnalyzer interface {
+	// AnalyzeExecutionTrace returns the latency breakdown of the spans of the
+	// given execution trace, as written by runtime/trace.
+	AnalyzeExecutionTrace(trace []byte) ([]SpanLatency, error)
+}
+
+// WithExecutionTraceAnalyzer post-processes each execution trace collected by the
+// profiler with the given analyzer, and uploads the latency breakdown of its spans
+// as a companion profile named "span-latency.json". This helps understanding why
+// a request was slow while the CPU usage was low, e.g. because of scheduling
+// latency or lock contention.
+//
+// Parsing execution traces requires golang.org/x/exp/trace, which breaks along
+// with the trace format of new Go releases, so the analyzer isn't built into the
+// profiler. The profiler/exectrace module provides one.
+func WithExecutionTraceAnalyzer(a ExecutionTraceAnalyzer) Option {
+	return func(cfg *config) {
+		cfg.traceAnalyzer = a
+	}
+}
+
+// analyzeExecutionTrace adds the latency breakdown of the spans of the execution
+// trace of the completed profiles, if any, to them.
+func analyzeExecutionTrace(a ExecutionTraceAnalyzer, completed []*profile) ([]*profile, error) {
+	for _, prof := range completed {
+		if prof.pt != executionTrace || len(prof.data) == 0 {
+			continue
+		}
+		spans, err := a.AnalyzeExecutionTrace(prof.data)
+		if err != nil {
+			return completed, err
+		}
+		if spans == nil {
+			spans = []SpanLatency{}
+		}
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(spans); err != nil {
+			return completed, err
+		}
+		return append(completed, &profile{name: spanLatencyFilename, pt: spanLatencyProfile, data: buf.Bytes()}), nil
+	}
+	return completed, nil
+}
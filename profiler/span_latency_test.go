@@ -0,0 +1,71 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package profiler
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTraceAnalyzer struct {
+	spans []SpanLatency
+	err   error
+}
+
+func (a fakeTraceAnalyzer) AnalyzeExecutionTrace(trace []byte) ([]SpanLatency, error) {
+	if len(trace) == 0 {
+		return nil, errors.New("empty trace")
+	}
+	return a.spans, a.err
+}
+
+func TestExecutionTraceAnalyzer(t *testing.T) {
+	t.Setenv("DD_PROFILING_EXECUTION_TRACE_ENABLED", "true")
+	t.Setenv("DD_PROFILING_EXECUTION_TRACE_PERIOD", "10ms")
+
+	t.Run("spans", func(t *testing.T) {
+		spans := []SpanLatency{{
+			SpanID:   42,
+			Name:     "GET /users",
+			Duration: 10 * time.Millisecond,
+			Running:  2 * time.Millisecond,
+			Runnable: time.Millisecond,
+			Blocked:  7 * time.Millisecond,
+		}}
+		profile := <-startTestProfiler(t, 1,
+			WithProfileTypes(),
+			WithPeriod(10*time.Millisecond),
+			WithExecutionTraceAnalyzer(fakeTraceAnalyzer{spans: spans}),
+		)
+		require.Contains(t, profile.event.Attachments, "go.trace")
+		require.Contains(t, profile.event.Attachments, spanLatencyFilename)
+		var got []SpanLatency
+		require.NoError(t, json.Unmarshal(profile.attachments[spanLatencyFilename], &got))
+		assert.Equal(t, spans, got)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		profile := <-startTestProfiler(t, 1,
+			WithProfileTypes(),
+			WithPeriod(10*time.Millisecond),
+			WithExecutionTraceAnalyzer(fakeTraceAnalyzer{err: errors.New("unsupported trace")}),
+		)
+		assert.Contains(t, profile.event.Attachments, "go.trace")
+		assert.NotContains(t, profile.event.Attachments, spanLatencyFilename)
+	})
+}
+
+func TestAnalyzeExecutionTraceWithoutTrace(t *testing.T) {
+	completed := []*profile{{name: "cpu.pprof", pt: CPUProfile, data: []byte("cpu")}}
+	got, err := analyzeExecutionTrace(fakeTraceAnalyzer{}, completed)
+	require.NoError(t, err)
+	assert.Equal(t, completed, got)
+}
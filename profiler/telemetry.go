@@ -41,6 +41,7 @@ func startTelemetry(c *config) {
 			{Name: "cpu_profile_rate", Value: c.cpuProfileRate},
 			{Name: "block_profile_rate", Value: c.blockRate},
 			{Name: "mutex_profile_fraction", Value: c.mutexFraction},
+			{Name: "adaptive_sampling_budget", Value: c.adaptiveSamplingBudget},
 			{Name: "max_goroutines_wait", Value: c.maxGoroutinesWait},
 			{Name: "cpu_profile_enabled", Value: profileEnabled(CPUProfile)},
 			{Name: "heap_profile_enabled", Value: profileEnabled(HeapProfile)},
@@ -48,12 +49,19 @@ func startTelemetry(c *config) {
 			{Name: "mutex_profile_enabled", Value: profileEnabled(MutexProfile)},
 			{Name: "goroutine_profile_enabled", Value: profileEnabled(GoroutineProfile)},
 			{Name: "goroutine_wait_profile_enabled", Value: profileEnabled(expGoroutineWaitProfile)},
+			{Name: "endpoint_allocation_profile_enabled", Value: profileEnabled(EndpointAllocationProfile)},
+			{Name: "goroutine_leak_profile_enabled", Value: profileEnabled(GoroutineLeakProfile)},
+			{Name: "goroutine_leak_min_wait", Value: c.goroutineLeakMinWait.String()},
 			{Name: "upload_timeout", Value: c.uploadTimeout.String()},
 			{Name: "execution_trace_enabled", Value: c.traceConfig.Enabled},
 			{Name: "execution_trace_period", Value: c.traceConfig.Period.String()},
 			{Name: "execution_trace_size_limit", Value: c.traceConfig.Limit},
+			{Name: "execution_trace_analyzer", Value: c.traceAnalyzer != nil},
 			{Name: "endpoint_count_enabled", Value: c.endpointCountEnabled},
 			{Name: "num_custom_profiler_label_keys", Value: len(c.customProfilerLabels)},
+			{Name: "upload_enabled", Value: c.uploadEnabled},
+			{Name: "custom_uploader", Value: c.uploader != nil},
+			{Name: "output_dir_enabled", Value: c.outputDir != ""},
 		},
 	)
 }
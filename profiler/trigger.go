@@ -0,0 +1,221 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package profiler
+
+import (
+	"context"
+	"fmt"
+	runtimemetrics "runtime/metrics"
+	"sort"
+	"time"
+
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/log"
+	"gopkg.in/DataDog/dd-trace-go.v1/internal/traceprof"
+)
+
+const (
+	// DefaultTriggerDuration specifies the default length of the captures started
+	// by triggers.
+	DefaultTriggerDuration = 10 * time.Second
+
+	// DefaultTriggerCooldown specifies the default minimum time between two
+	// captures started by triggers, which bounds their overhead.
+	DefaultTriggerCooldown = 5 * time.Minute
+)
+
+const (
+	// triggerInterval is the interval at which the triggers are checked.
+	triggerInterval = time.Second
+
+	// latencyWindow is the duration over which the p99 latency of the endpoints
+	// watched by latency triggers is computed.
+	latencyWindow = 10 * time.Second
+
+	// minLatencySamples is the minimum number of requests over latencyWindow for
+	// the p99 latency of an endpoint to be considered.
+	minLatencySamples = 10
+)
+
+// A Trigger is a condition which, when met, makes the profiler immediately
+// capture a high-resolution CPU profile, an execution trace and a goroutine
+// dump, uploaded as a separate profile tagged with the reason of the trigger
+// (e.g. "profile_trigger:cpu"). Triggers are set with WithTriggers.
+type Trigger struct {
+	// reason identifies the trigger in the tags of the captured profiles.
+	reason string
+	// endpoint is the endpoint watched by latency triggers.
+	endpoint string
+	// newCheck returns the function checking the condition of the trigger. It
+	// reports whether the condition is met, along with a description of it.
+	newCheck func() triggerCheck
+}
+
+// triggerCheck checks the condition of a trigger at the given tick. It reports
+// whether the condition is met, along with a description of it.
+type triggerCheck func(tick triggerTick) (string, bool)
+
+// triggerTick holds the state of the process shared by the checks of a tick.
+type triggerTick struct {
+	now       time.Time
+	latencies map[string][]time.Duration // durations of the watched endpoints since the previous tick
+}
+
+// CPUTrigger returns a Trigger which fires when the CPU usage of the process is
+// above percent, where 100 means one full CPU core. It's only available on unix
+// systems.
+func CPUTrigger(percent float64) Trigger {
+	return Trigger{
+		reason: "cpu",
+		newCheck: func() triggerCheck {
+			last, ok := processCPUTime()
+			if !ok {
+				log.Warn("profiler: the CPU usage of the process is not available on this platform; the CPU trigger is disabled.")
+				return func(triggerTick) (string, bool) { return "", false }
+			}
+			lastCheck := time.Now()
+			return func(tick triggerTick) (string, bool) {
+				elapsed := tick.now.Sub(lastCheck)
+				if elapsed <= 0 {
+					return "", false
+				}
+				cpu, _ := processCPUTime()
+				usage := 100 * float64(cpu-last) / float64(elapsed)
+				last, lastCheck = cpu, tick.now
+				return fmt.Sprintf("CPU usage %.0f%% above %.0f%%", usage, percent), usage > percent
+			}
+		},
+	}
+}
+
+// HeapGrowthTrigger returns a Trigger which fires when the live heap has grown by
+// more than factor since the profiler started, or since the trigger last fired.
+// For instance, a factor of 1.5 fires when the live heap grew by 50%.
+func HeapGrowthTrigger(factor float64) Trigger {
+	return Trigger{
+		reason:   "heap_growth",
+		newCheck: func() triggerCheck { return growthCheck("/gc/heap/live:bytes", "live heap", factor) },
+	}
+}
+
+// GoroutineSpikeTrigger returns a Trigger which fires when the number of goroutines
+// has grown by more than factor since the profiler started, or since the trigger
+// last fired. For instance, a factor of 2 fires when the number of goroutines doubled.
+func GoroutineSpikeTrigger(factor float64) Trigger {
+	return Trigger{
+		reason:   "goroutine_spike",
+		newCheck: func() triggerCheck { return growthCheck("/sched/goroutines:goroutines", "goroutines", factor) },
+	}
+}
+
+// growthCheck returns a check which is met when the runtime metric name grew by
+// more than factor compared to its value at the start, or when the check was last
+// met.
+func growthCheck(name, desc string, factor float64) triggerCheck {
+	sample := []runtimemetrics.Sample{{Name: name}}
+	read := func() uint64 {
+		runtimemetrics.Read(sample)
+		if sample[0].Value.Kind() != runtimemetrics.KindUint64 {
+			return 0
+		}
+		return sample[0].Value.Uint64()
+	}
+	baseline := read()
+	return func(triggerTick) (string, bool) {
+		v := read()
+		if baseline == 0 || float64(v) <= factor*float64(baseline) {
+			if baseline == 0 {
+				baseline = v
+			}
+			return "", false
+		}
+		msg := fmt.Sprintf("%s grew from %d to %d", desc, baseline, v)
+		baseline = v
+		return msg, true
+	}
+}
+
+// LatencyTrigger returns a Trigger which fires when the p99 latency of the given
+// traced endpoint, i.e. the resource name of its root spans, is above p99. The
+// latency is computed over windows of 10 seconds, and requires the tracer to be
+// started with profiler endpoints enabled, which is the default.
+func LatencyTrigger(endpoint string, p99 time.Duration) Trigger {
+	return Trigger{
+		reason:   "latency",
+		endpoint: endpoint,
+		newCheck: func() triggerCheck {
+			var (
+				durations   []time.Duration
+				windowStart = time.Now()
+			)
+			return func(tick triggerTick) (string, bool) {
+				durations = append(durations, tick.latencies[endpoint]...)
+				if tick.now.Sub(windowStart) < latencyWindow {
+					return "", false
+				}
+				d := durations
+				durations, windowStart = nil, tick.now
+				if len(d) < minLatencySamples {
+					return "", false
+				}
+				sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+				got := d[(len(d)*99-1)/100]
+				return fmt.Sprintf("p99 latency of %s %s above %s", endpoint, got, p99), got > p99
+			}
+		},
+	}
+}
+
+// WithTriggers sets triggers which make the profiler immediately capture profiles
+// when their condition is met, in addition to the periodic profiles. The captures
+// last DefaultTriggerDuration, and are at least DefaultTriggerCooldown apart. See
+// CaptureNow.
+func WithTriggers(triggers ...Trigger) Option {
+	return func(cfg *config) {
+		cfg.triggers = append(cfg.triggers, triggers...)
+	}
+}
+
+// runTriggers checks the triggers of the configuration at every tick, and starts a
+// capture when one of them fires, until the profiler is stopped.
+func (p *profiler) runTriggers(ticker <-chan time.Time) {
+	var endpoints []string
+	checks := make([]triggerCheck, len(p.cfg.triggers))
+	for i, t := range p.cfg.triggers {
+		checks[i] = t.newCheck()
+		if t.endpoint != "" {
+			endpoints = append(endpoints, t.endpoint)
+		}
+	}
+	el := traceprof.GlobalEndpointLatencies()
+	if len(endpoints) > 0 {
+		el.Watch(endpoints...)
+		defer el.Watch()
+	}
+	var lastCapture time.Time
+	for {
+		select {
+		case <-p.exit:
+			return
+		case now := <-ticker:
+			tick := triggerTick{now: now}
+			if len(endpoints) > 0 {
+				tick.latencies = el.GetAndReset()
+			}
+			for i, check := range checks {
+				desc, ok := check(tick)
+				if !ok || now.Sub(lastCapture) < p.cfg.triggerCooldown {
+					continue
+				}
+				reason := p.cfg.triggers[i].reason
+				log.Info("profiler: %s trigger fired (%s), capturing profiles for %s", reason, desc, p.cfg.triggerDuration)
+				p.cfg.statsd.Count("datadog.profiling.go.trigger", 1, append(p.cfg.tags.Slice(), "trigger:"+reason), 1)
+				logCaptureError(reason, p.capture(context.Background(), reason, nil, p.cfg.triggerDuration))
+				lastCapture = time.Now()
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package profiler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoroutineSpikeTrigger(t *testing.T) {
+	check := GoroutineSpikeTrigger(2).newCheck()
+	_, ok := check(triggerTick{now: time.Now()})
+	assert.False(t, ok)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	for i := 0; i < 1000; i++ {
+		go func() { <-stop }()
+	}
+	desc, ok := check(triggerTick{now: time.Now()})
+	assert.True(t, ok)
+	assert.Contains(t, desc, "goroutines grew from")
+
+	// the baseline is reset when the trigger fires
+	_, ok = check(triggerTick{now: time.Now()})
+	assert.False(t, ok)
+}
+
+func TestLatencyTrigger(t *testing.T) {
+	check := LatencyTrigger("/foo", 100*time.Millisecond).newCheck()
+	start := time.Now()
+	tick := func(after time.Duration, d time.Duration, n int) (string, bool) {
+		durations := make([]time.Duration, n)
+		for i := range durations {
+			durations[i] = d
+		}
+		return check(triggerTick{
+			now:       start.Add(after),
+			latencies: map[string][]time.Duration{"/foo": durations, "/bar": {time.Hour}},
+		})
+	}
+
+	// the p99 latency is only checked at the end of the window
+	_, ok := tick(time.Second, time.Second, 100)
+	assert.False(t, ok)
+	desc, ok := tick(latencyWindow+time.Second, time.Millisecond, 1)
+	assert.True(t, ok)
+	assert.Equal(t, "p99 latency of /foo 1s above 100ms", desc)
+
+	// the latencies are reset at the end of the window
+	_, ok = tick(2*latencyWindow+2*time.Second, time.Millisecond, 100)
+	assert.False(t, ok)
+
+	// too few samples
+	_, ok = tick(3*latencyWindow+3*time.Second, time.Second, minLatencySamples-1)
+	assert.False(t, ok)
+}
+
+func TestRunTriggers(t *testing.T) {
+	trigger := Trigger{
+		reason: "test",
+		newCheck: func() triggerCheck {
+			return func(triggerTick) (string, bool) { return "always", true }
+		},
+	}
+	p, err := unstartedProfiler(
+		WithProfileTypes(HeapProfile),
+		WithTriggers(trigger),
+	)
+	require.NoError(t, err)
+	p.cfg.triggerDuration = time.Millisecond
+	p.cfg.triggerCooldown = time.Hour
+
+	ticker := make(chan time.Time)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.runTriggers(ticker)
+	}()
+	// captures are synchronous, so the second tick is received after the
+	// first capture, and is within its cooldown.
+	ticker <- time.Now()
+	ticker <- time.Now()
+	close(p.exit)
+	<-done
+
+	require.Len(t, p.out, 1)
+	bat := <-p.out
+	assert.Contains(t, bat.extraTags, "profile_trigger:test")
+}
@@ -32,6 +32,7 @@ var errOldAgent = errors.New("Datadog Agent is not accepting profiles. Agent-bas
 func (p *profiler) upload(bat batch) error {
 	statsd := p.cfg.statsd
 	var err error
+	remaining := bat
 	for i := 0; i < maxRetries; i++ {
 		select {
 		case <-p.exit:
@@ -39,8 +40,15 @@ func (p *profiler) upload(bat batch) error {
 		default:
 		}
 
-		err = p.doRequest(bat)
-		if rerr, ok := err.(*retriableError); ok {
+		if p.cfg.uploader != nil {
+			err = p.doUpload(remaining)
+		} else {
+			err = p.doRequest(remaining)
+		}
+		var rerr *retriableError
+		if errors.As(err, &rerr) {
+			// only the profiles which weren't uploaded yet are retried.
+			remaining = remaining.without(rerr.done)
 			statsd.Count("datadog.profiling.go.upload_retry", 1, nil, 1)
 			wait := time.Duration(rand.Int63n(p.cfg.period.Nanoseconds())) * time.Nanosecond
 			log.Error("Uploading profile failed: %v. Trying again in %s...", rerr, wait)
@@ -63,14 +71,32 @@ func (p *profiler) upload(bat batch) error {
 }
 
 // retriableError is an error returned by the server which may be retried at a later time.
-type retriableError struct{ err error }
+type retriableError struct {
+	err  error
+	done []string // names of the profiles of the batch which don't need to be retried
+}
 
 // Error implements error.
 func (e retriableError) Error() string { return e.err.Error() }
 
-// doRequest makes an HTTP POST request to the Datadog Profiling API with the
-// given profile.
-func (p *profiler) doRequest(bat batch) error {
+// uploadContext returns the context of an upload, which is done after the upload
+// timeout, or when the profiler is stopped.
+func (p *profiler) uploadContext() (context.Context, context.CancelFunc) {
+	// uploadTimeout is guaranteed to be >= 0, see newProfiler.
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.uploadTimeout)
+	go func() {
+		select {
+		case <-p.exit:
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+	return ctx, cancel
+}
+
+// batchTags returns the tags of the given batch of profiles, except the ones
+// added by encode.
+func (p *profiler) batchTags(bat batch) []string {
 	tags := append(p.cfg.tags.Slice(),
 		fmt.Sprintf("service:%s", p.cfg.service),
 		// The profile_seq tag can be used to identify the first profile
@@ -85,21 +111,18 @@ func (p *profiler) doRequest(bat batch) error {
 	if p.cfg.env != "" {
 		tags = append(tags, fmt.Sprintf("env:%s", p.cfg.env))
 	}
-	contentType, body, err := encode(bat, tags)
+	return tags
+}
+
+// doRequest makes an HTTP POST request to the Datadog Profiling API with the
+// given profile.
+func (p *profiler) doRequest(bat batch) error {
+	contentType, body, err := encode(bat, p.batchTags(bat))
 	if err != nil {
 		return err
 	}
-	funcExit := make(chan struct{})
-	defer close(funcExit)
-	// uploadTimeout is guaranteed to be >= 0, see newProfiler.
-	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.uploadTimeout)
-	go func() {
-		select {
-		case <-p.exit:
-		case <-funcExit:
-		}
-		cancel()
-	}()
+	ctx, cancel := p.uploadContext()
+	defer cancel()
 	req, err := http.NewRequestWithContext(ctx, "POST", p.cfg.targetURL, body)
 	if err != nil {
 		return err
@@ -117,12 +140,12 @@ func (p *profiler) doRequest(bat batch) error {
 
 	resp, err := p.cfg.httpClient.Do(req)
 	if err != nil {
-		return &retriableError{err}
+		return &retriableError{err: err}
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode/100 == 5 {
 		// 5xx can be retried
-		return &retriableError{errors.New(resp.Status)}
+		return &retriableError{err: errors.New(resp.Status)}
 	}
 	if resp.StatusCode == 404 && p.cfg.targetURL == p.cfg.agentURL {
 		// 404 from the agent means we have an old agent version without profiling endpoint
@@ -146,34 +169,37 @@ type uploadEvent struct {
 	CustomAttributes []string          `json:"custom_attributes,omitempty"`
 }
 
+// newUploadEvent returns the event describing the given batch of profiles.
+func newUploadEvent(b Batch) *uploadEvent {
+	event := &uploadEvent{
+		Version:          "4",
+		Family:           "go",
+		Start:            b.Start.Format(time.RFC3339Nano),
+		End:              b.End.Format(time.RFC3339Nano),
+		Tags:             strings.Join(b.Tags, ","),
+		EndpointCounts:   b.EndpointCounts,
+		CustomAttributes: b.CustomAttributes,
+	}
+	for _, p := range b.Profiles {
+		event.Attachments = append(event.Attachments, p.Name)
+	}
+	return event
+}
+
 // encode encodes the profile as a multipart mime request.
 func encode(bat batch, tags []string) (contentType string, body io.Reader, err error) {
 	var buf bytes.Buffer
 
 	mw := multipart.NewWriter(&buf)
 
-	if bat.host != "" {
-		tags = append(tags, fmt.Sprintf("host:%s", bat.host))
-	}
-	tags = append(tags, "runtime:go")
-
-	event := &uploadEvent{
-		Version:          "4",
-		Family:           "go",
-		Start:            bat.start.Format(time.RFC3339Nano),
-		End:              bat.end.Format(time.RFC3339Nano),
-		Tags:             strings.Join(tags, ","),
-		EndpointCounts:   bat.endpointCounts,
-		CustomAttributes: bat.customAttributes,
-	}
-
-	for _, p := range bat.profiles {
-		event.Attachments = append(event.Attachments, p.name)
-		f, err := mw.CreateFormFile(p.name, p.name)
+	b := newBatch(bat, tags)
+	event := newUploadEvent(b)
+	for _, p := range b.Profiles {
+		f, err := mw.CreateFormFile(p.Name, p.Name)
 		if err != nil {
 			return "", nil, err
 		}
-		if _, err := f.Write(p.data); err != nil {
+		if _, err := f.Write(p.Data); err != nil {
 			return "", nil, err
 		}
 	}
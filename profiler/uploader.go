@@ -0,0 +1,224 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package profiler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An Uploader sends the profiles collected by the profiler to a destination
+// other than Datadog. See WithUploader.
+type Uploader interface {
+	// Upload sends the given batch of profiles. The context is done after the
+	// upload timeout, or when the profiler is stopped. Errors wrapped with
+	// RetriableError are retried.
+	Upload(ctx context.Context, b Batch) error
+}
+
+// A Batch is a set of profiles collected over the same profiling period.
+type Batch struct {
+	// Seq is the sequence number of the batch, starting at 0 when the profiler
+	// starts.
+	Seq uint64
+	// Start and End delimit the profiling period.
+	Start, End time.Time
+	// Tags are the tags of the profiles, e.g. "service:my-service".
+	Tags []string
+	// Profiles are the files of the batch, such as "cpu.pprof" or "metrics.json".
+	Profiles []Profile
+	// EndpointCounts is the number of requests to each endpoint during the
+	// profiling period, if endpoint counting is enabled.
+	EndpointCounts map[string]uint64
+	// CustomAttributes are the pprof label keys set with WithCustomProfilerLabelKeys.
+	CustomAttributes []string
+}
+
+// A Profile is a file of a Batch.
+type Profile struct {
+	// Name is the file name of the profile, e.g. "cpu.pprof". Delta profiles are
+	// prefixed with "delta-".
+	Name string
+	// Type is the type of the profile.
+	Type ProfileType
+	// Data is the content of the file.
+	Data []byte
+}
+
+// newBatch returns the Batch of the given batch of profiles, with the given tags
+// completed with the ones describing the host and the runtime.
+func newBatch(bat batch, tags []string) Batch {
+	if bat.host != "" {
+		tags = append(tags, fmt.Sprintf("host:%s", bat.host))
+	}
+	tags = append(tags, "runtime:go")
+	b := Batch{
+		Seq:              bat.seq,
+		Start:            bat.start,
+		End:              bat.end,
+		Tags:             tags,
+		EndpointCounts:   bat.endpointCounts,
+		CustomAttributes: bat.customAttributes,
+	}
+	for _, p := range bat.profiles {
+		b.Profiles = append(b.Profiles, Profile{Name: p.name, Type: p.pt, Data: p.data})
+	}
+	return b
+}
+
+// RetriableError wraps err returned by an Uploader to signal that the upload may
+// succeed if tried again later, e.g. because of a network error.
+func RetriableError(err error) error {
+	return &retriableError{err: err}
+}
+
+// WithUploader sends the profiles with the given Uploader instead of uploading
+// them to the Datadog agent or intake. The uploads are retried and timed out as
+// configured for Datadog, see WithUploadTimeout. See HTTPUploader and
+// DirectoryUploader for the built-in uploaders.
+func WithUploader(u Uploader) Option {
+	return func(cfg *config) {
+		cfg.uploader = u
+	}
+}
+
+// doUpload sends the given batch of profiles with the configured Uploader.
+func (p *profiler) doUpload(bat batch) error {
+	ctx, cancel := p.uploadContext()
+	defer cancel()
+	return p.cfg.uploader.Upload(ctx, newBatch(bat, p.batchTags(bat)))
+}
+
+// HTTPUploader returns an Uploader sending the pprof profiles to a pprof-compatible
+// collector, such as the ingest API of Pyroscope (e.g.
+// "http://localhost:4040/ingest"). Each profile is sent in the body of its own
+// POST request, described by the following query parameters:
+//
+//   - name: the service, followed by the env, version and host tags in braces,
+//     e.g. "my-service{env=prod,host=my-host,version=1.0}".
+//   - from and until: the profiling period, in seconds since the Unix epoch.
+//   - format: "pprof".
+//
+// The other files, such as the metrics profile and the execution traces, are not
+// sent. If client is nil, the default client of the profiler is used.
+func HTTPUploader(endpoint string, client *http.Client) Uploader {
+	if client == nil {
+		client = defaultClient
+	}
+	return &httpUploader{endpoint: endpoint, client: client}
+}
+
+type httpUploader struct {
+	endpoint string
+	client   *http.Client
+}
+
+// Upload implements Uploader. All the profiles are sent, even if some of them fail.
+func (u *httpUploader) Upload(ctx context.Context, b Batch) error {
+	query := url.Values{
+		"name":   {httpUploaderName(b.Tags)},
+		"from":   {strconv.FormatInt(b.Start.Unix(), 10)},
+		"until":  {strconv.FormatInt(b.End.Unix(), 10)},
+		"format": {"pprof"},
+	}
+	var (
+		errs  []error
+		retry bool
+		done  []string // the profiles which aren't retried
+	)
+	for _, p := range b.Profiles {
+		if !strings.HasSuffix(p.Name, ".pprof") {
+			continue
+		}
+		err := u.send(ctx, query, p)
+		var rerr *retriableError
+		if errors.As(err, &rerr) {
+			retry = true
+		} else {
+			done = append(done, p.Name)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if retry {
+		// only the profiles which failed with a retriable error are retried.
+		return &retriableError{err: errors.Join(errs...), done: done}
+	}
+	return errors.Join(errs...)
+}
+
+// send sends the given profile to the collector.
+func (u *httpUploader) send(ctx context.Context, query url.Values, p Profile) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", u.endpoint+"?"+query.Encode(), bytes.NewReader(p.Data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return RetriableError(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 == 5 {
+		// 5xx can be retried
+		return RetriableError(fmt.Errorf("%s: %s", p.Name, resp.Status))
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", p.Name, resp.Status)
+	}
+	return nil
+}
+
+// httpUploaderName returns the name of the profiles sent by httpUploader, made of
+// the service and the env, version and host tags.
+func httpUploaderName(tags []string) string {
+	var (
+		service = "unnamed-go-service"
+		labels  []string
+	)
+	for _, tag := range tags {
+		k, v, _ := strings.Cut(tag, ":")
+		switch k {
+		case "service":
+			service = v
+		case "env", "version", "host":
+			labels = append(labels, k+"="+v)
+		}
+	}
+	if len(labels) == 0 {
+		return service
+	}
+	sort.Strings(labels)
+	return service + "{" + strings.Join(labels, ",") + "}"
+}
+
+// DirectoryUploader returns an Uploader writing the profiles to the given
+// directory, pruned according to retention, in the same layout as
+// WithOutputDirectory.
+func DirectoryUploader(dir string, retention OutputRetention) Uploader {
+	return &directoryUploader{dir: dir, retention: retention}
+}
+
+type directoryUploader struct {
+	dir       string
+	retention OutputRetention
+}
+
+// Upload implements Uploader.
+func (u *directoryUploader) Upload(_ context.Context, b Batch) error {
+	return writeOutputDir(u.dir, u.retention, b)
+}
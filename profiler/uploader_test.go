@@ -0,0 +1,201 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2024 Datadog, Inc.
+
+package profiler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// uploaderFunc is an Uploader calling itself.
+type uploaderFunc func(ctx context.Context, b Batch) error
+
+func (f uploaderFunc) Upload(ctx context.Context, b Batch) error { return f(ctx, b) }
+
+func TestWithUploader(t *testing.T) {
+	t.Run("batch", func(t *testing.T) {
+		var batches []Batch
+		p, err := newProfiler(
+			WithUploader(uploaderFunc(func(_ context.Context, b Batch) error {
+				batches = append(batches, b)
+				return nil
+			})),
+			WithService("my-service"),
+			WithPeriod(10*time.Millisecond),
+			WithProfileTypes(HeapProfile),
+		)
+		require.NoError(t, err)
+		require.NoError(t, p.upload(testBatch))
+
+		require.Len(t, batches, 1)
+		b := batches[0]
+		assert.Equal(t, testBatch.seq, b.Seq)
+		assert.Equal(t, testBatch.end, b.End)
+		assert.Contains(t, b.Tags, "service:my-service")
+		assert.Contains(t, b.Tags, "profile_seq:23")
+		assert.Contains(t, b.Tags, "host:my-host")
+		assert.Contains(t, b.Tags, "runtime:go")
+		require.Len(t, b.Profiles, 2)
+		assert.Equal(t, Profile{Name: "cpu.pprof", Data: []byte("my-cpu-profile")}, b.Profiles[0])
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		var calls int
+		p, err := newProfiler(
+			WithUploader(uploaderFunc(func(context.Context, Batch) error {
+				calls++
+				return RetriableError(errors.New("unavailable"))
+			})),
+			WithPeriod(10*time.Millisecond),
+		)
+		require.NoError(t, err)
+		err = p.upload(testBatch)
+		assert.ErrorContains(t, err, "unavailable")
+		assert.Equal(t, maxRetries, calls)
+	})
+
+	t.Run("retry-failed", func(t *testing.T) {
+		var (
+			bodies []string
+			fail   = true
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			if string(body) == "my-cpu-profile" && fail {
+				fail = false
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer server.Close()
+		p, err := newProfiler(
+			WithUploader(HTTPUploader(server.URL, nil)),
+			WithPeriod(10*time.Millisecond),
+		)
+		require.NoError(t, err)
+		require.NoError(t, p.upload(testBatch))
+		// the heap profile, which succeeded, isn't sent again.
+		assert.Equal(t, []string{"my-cpu-profile", "my-heap-profile", "my-cpu-profile"}, bodies)
+	})
+
+	t.Run("upload-disabled", func(t *testing.T) {
+		batches := make(chan Batch, 1)
+		p, err := newProfiler(
+			WithUpload(false),
+			WithUploader(uploaderFunc(func(_ context.Context, b Batch) error {
+				select {
+				case batches <- b:
+				default:
+				}
+				return nil
+			})),
+			WithPeriod(10*time.Millisecond),
+			WithProfileTypes(HeapProfile),
+		)
+		require.NoError(t, err, "an uploader doesn't require an output directory")
+		p.run()
+		defer p.stop()
+		select {
+		case <-batches:
+		case <-time.After(10 * time.Second):
+			t.Fatal("the uploader wasn't used")
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var calls int
+		p, err := newProfiler(
+			WithUploader(uploaderFunc(func(context.Context, Batch) error {
+				calls++
+				return errors.New("denied")
+			})),
+			WithPeriod(10*time.Millisecond),
+		)
+		require.NoError(t, err)
+		assert.ErrorContains(t, p.upload(testBatch), "denied")
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		p, err := newProfiler(
+			WithUploader(uploaderFunc(func(ctx context.Context, _ Batch) error {
+				<-ctx.Done()
+				return ctx.Err()
+			})),
+			WithUploadTimeout(10*time.Millisecond),
+		)
+		require.NoError(t, err)
+		assert.ErrorIs(t, p.upload(testBatch), context.DeadlineExceeded)
+	})
+}
+
+func TestHTTPUploader(t *testing.T) {
+	type request struct {
+		query string
+		body  string
+	}
+	var (
+		requests []request
+		status   = http.StatusOK
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, request{query: r.URL.RawQuery, body: string(body)})
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	u := HTTPUploader(server.URL+"/ingest", nil)
+	b := Batch{
+		Start: time.Unix(100, 0),
+		End:   time.Unix(160, 0),
+		Tags:  []string{"service:my-service", "env:prod", "profile_seq:3", "host:my-host"},
+		Profiles: []Profile{
+			{Name: "cpu.pprof", Data: []byte("cpu")},
+			{Name: "metrics.json", Data: []byte("{}")},
+			{Name: "delta-heap.pprof", Data: []byte("heap")},
+		},
+	}
+	require.NoError(t, u.Upload(context.Background(), b))
+	query := "format=pprof&from=100&name=my-service%7Benv%3Dprod%2Chost%3Dmy-host%7D&until=160"
+	assert.Equal(t, []request{{query, "cpu"}, {query, "heap"}}, requests)
+
+	status = http.StatusServiceUnavailable
+	var rerr *retriableError
+	assert.ErrorAs(t, u.Upload(context.Background(), b), &rerr)
+	assert.Empty(t, rerr.done)
+
+	status = http.StatusBadRequest
+	err := u.Upload(context.Background(), b)
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &rerr))
+}
+
+func TestDirectoryUploader(t *testing.T) {
+	dir := t.TempDir()
+	u := DirectoryUploader(dir, OutputRetention{})
+	b := Batch{
+		Seq:      1,
+		End:      time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+		Profiles: []Profile{{Name: "cpu.pprof", Data: []byte("cpu")}},
+	}
+	require.NoError(t, u.Upload(context.Background(), b))
+
+	data, err := os.ReadFile(filepath.Join(dir, "20240102T150405.000Z-1", "cpu.pprof"))
+	require.NoError(t, err)
+	assert.Equal(t, "cpu", string(data))
+	assert.FileExists(t, filepath.Join(dir, "20240102T150405.000Z-1", "event.json"))
+}